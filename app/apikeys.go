@@ -0,0 +1,145 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// ApiKeys creates an api key on POST, and lists a user's api keys on GET.
+// Api keys are managed with an access token, an api key can't be used to create or revoke other keys
+func (wb *app) ApiKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		wb.createApiKey(w, r)
+	case http.MethodGet:
+		wb.listApiKeys(w, r)
+	default:
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+	}
+}
+
+// createApiKey creates a long lived api key for scripts and bots
+// @Summary     Create an api key
+// @Description Create a named api key that can be used in place of an access token, the key is only returned in this response.
+// @Description The key can be granted a narrower scope than the access token used to create it
+// @Tags        apikeys
+// @Accept      json
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Param       ApiKey body     req.CreateApiKeyRequest true "Name of the api key"
+// @Success     201    {object} resp.CreateApiKeyResponse
+// @Failure     400    {object} resp.StatusBadRequest          "resp.ErrParseApiKey, resp.ErrApiKeyNameIsMissing, resp.ErrApiKeyNameIsTooLong, resp.ErrInvalidScope"
+// @Failure     401    {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403    {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     405    {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500    {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotStoreApiKey"
+// @Router      /apikeys [post]
+func (wb *app) createApiKey(w http.ResponseWriter, r *http.Request) {
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	var reqBody req.CreateApiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		wb.errorResponse(resp.ErrParseApiKey, w)
+		return
+	}
+
+	scope, err := util.NarrowScope(principal.Scope, reqBody.Scope)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp, err := wb.apiKeys.CreateApiKey(principal.UserId, reqBody.Name, scope)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// listApiKeys lists the api keys of a user
+// @Summary     List api keys
+// @Description List every api key of the user, along with the last time each key was used
+// @Tags        apikeys
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Success     200 {object} resp.ApiKeysResponse
+// @Failure     401 {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403 {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     405 {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500 {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotListApiKeys"
+// @Router      /apikeys [get]
+func (wb *app) listApiKeys(w http.ResponseWriter, r *http.Request) {
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	keys, err := wb.apiKeys.ListApiKeys(principal.UserId)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp := &resp.ApiKeysResponse{
+		ApiKeys: keys,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// RevokeApiKey revokes one of a user's api keys, scripts using it will no longer be authorized
+// @Summary     Revoke an api key
+// @Description Revoke one of the user's api keys, requests using the key are rejected right away
+// @Tags        apikeys
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Param       id  path     string true "Id of the api key to revoke"
+// @Success     200 {object} resp.RevokeApiKeyResponse
+// @Failure     400 {object} resp.StatusBadRequest          "resp.ErrNoApiKeyId"
+// @Failure     401 {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403 {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     404 {object} resp.StatusNotFound            "resp.ErrUnknownApiKey"
+// @Failure     405 {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500 {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotRevokeApiKey"
+// @Router      /apikeys/{id} [delete]
+func (wb *app) RevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	keyId := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:] // grab the id from the end of the path
+	if keyId == "" {
+		wb.errorResponse(resp.ErrNoApiKeyId, w)
+		return
+	}
+
+	if err = wb.apiKeys.RevokeApiKey(principal.UserId, keyId); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp := &resp.RevokeApiKeyResponse{
+		Message: "api key has been revoked",
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -3,42 +3,94 @@ package app
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/internal/service/apikey"
 	"github.com/bchadwic/wordbubble/internal/service/auth"
+	"github.com/bchadwic/wordbubble/internal/service/lockout"
+	"github.com/bchadwic/wordbubble/internal/service/magiclink"
+	"github.com/bchadwic/wordbubble/internal/service/mfa"
+	"github.com/bchadwic/wordbubble/internal/service/oauth"
+	"github.com/bchadwic/wordbubble/internal/service/oidc"
+	"github.com/bchadwic/wordbubble/internal/service/passkey"
+	"github.com/bchadwic/wordbubble/internal/service/role"
 	"github.com/bchadwic/wordbubble/internal/service/user"
 	"github.com/bchadwic/wordbubble/internal/service/wb"
+	"github.com/bchadwic/wordbubble/model"
 	"github.com/bchadwic/wordbubble/model/resp"
 	"github.com/bchadwic/wordbubble/util"
 )
 
 type app struct {
 	auth        auth.AuthService
+	apiKeys     apikey.ApiKeyService
+	lockout     lockout.LockoutService
+	loginLinks  magiclink.MagicLinkService
+	mfa         mfa.MfaService
+	oauth       oauth.OAuthService
+	oidc        oidc.OidcService
+	passkeys    passkey.PasskeyService
+	roles       role.RoleService
 	users       user.UserService
 	wordbubbles wb.WordbubbleService
 	log         util.Logger
 	timer       util.Timer
+	keys        util.KeyProvider
+	// requireVerifiedEmail stops users from pushing until they verify their email
+	requireVerifiedEmail bool
+	// accessTokenLifetime is how long the access tokens issued last, for clients that are told
+	accessTokenLifetime time.Duration
+	// trustedProxies are the proxies client addresses are read from X-Forwarded-For for
+	trustedProxies util.TrustedProxies
 }
 
-func NewApp(cfg cfg.Config, authService auth.AuthService, userService user.UserService, wbService wb.WordbubbleService) *app {
+func NewApp(cfg cfg.Config, authService auth.AuthService, apiKeyService apikey.ApiKeyService, lockoutService lockout.LockoutService, magicLinkService magiclink.MagicLinkService, mfaService mfa.MfaService, oauthService oauth.OAuthService, oidcService oidc.OidcService, passkeyService passkey.PasskeyService, roleService role.RoleService, userService user.UserService, wbService wb.WordbubbleService) *app {
 	return &app{
 		auth:        authService,
+		apiKeys:     apiKeyService,
+		lockout:     lockoutService,
+		loginLinks:  magicLinkService,
+		mfa:         mfaService,
+		oauth:       oauthService,
+		oidc:        oidcService,
+		passkeys:    passkeyService,
+		roles:       roleService,
 		users:       userService,
 		wordbubbles: wbService,
 		log:         cfg.NewLogger("app"),
 		timer:       cfg.Timer(),
+		keys:        cfg.Keys(),
+
+		requireVerifiedEmail: cfg.RequireVerifiedEmail(),
+		accessTokenLifetime:  cfg.TokenLifetimes().AccessToken,
+		trustedProxies:       cfg.TrustedProxies(),
+	}
+}
+
+// checkVerified makes sure a user has verified their email, when the config requires it
+// error could be (400) resp.ErrUnknownUser, (403) resp.ErrEmailNotVerified, (500) resp.ErrSQLMappingError or nil.
+func (wb *app) checkVerified(userId int64) error {
+	if !wb.requireVerifiedEmail {
+		return nil
+	}
+	user, err := wb.users.RetrieveUserById(userId)
+	if err != nil {
+		return err
 	}
+	if !user.Verified {
+		return resp.ErrEmailNotVerified
+	}
+	return nil
 }
 
-// TODO make this entire file better
-func (wb *app) BackgroundCleaner(authCleaner auth.AuthCleaner) {
-	const refreshTokenTimeLimit = 60
-	return // fixme
-	// go func() {
-	// 	for range wb.timer.Tick(auth.RefreshTokenCleanerRate) {
-	// 		_ = authCleaner.CleanupExpiredRefreshTokens(wb.timer.Now().Unix() - refreshTokenTimeLimit)
-	// 	}
-	// }()
+// deviceFromRequest returns the metadata of the client that sent the request, the address is
+// only taken from X-Forwarded-For when the request came from a trusted proxy
+func (wb *app) deviceFromRequest(r *http.Request) *model.Device {
+	return &model.Device{
+		UserAgent: r.UserAgent(),
+		IPAddress: wb.trustedProxies.ClientIp(r.RemoteAddr, r.Header.Get("X-Forwarded-For")),
+	}
 }
 
 func (wb *app) errorResponse(err error, w http.ResponseWriter) {
@@ -55,6 +107,14 @@ func (wb *app) errorResponse(err error, w http.ResponseWriter) {
 		wb.log.Warn("%d - %s", t.Code, t.Error())
 		w.WriteHeader(t.Code)
 		json.NewEncoder(w).Encode(t)
+	case *resp.StatusForbidden:
+		wb.log.Warn("%d - %s", t.Code, t.Error())
+		w.WriteHeader(t.Code)
+		json.NewEncoder(w).Encode(t)
+	case *resp.StatusNotFound:
+		wb.log.Warn("%d - %s", t.Code, t.Error())
+		w.WriteHeader(t.Code)
+		json.NewEncoder(w).Encode(t)
 	case *resp.StatusMethodNotAllowed:
 		wb.log.Warn("%d - %s", t.Code, t.Error())
 		w.WriteHeader(t.Code)
@@ -63,6 +123,14 @@ func (wb *app) errorResponse(err error, w http.ResponseWriter) {
 		wb.log.Warn("%d - %s", t.Code, t.Error())
 		w.WriteHeader(t.Code)
 		json.NewEncoder(w).Encode(t)
+	case *resp.StatusLocked:
+		wb.log.Warn("%d - %s", t.Code, t.Error())
+		w.WriteHeader(t.Code)
+		json.NewEncoder(w).Encode(t)
+	case *resp.StatusTooManyRequests:
+		wb.log.Warn("%d - %s", t.Code, t.Error())
+		w.WriteHeader(t.Code)
+		json.NewEncoder(w).Encode(t)
 	case *resp.StatusInternalServerError:
 		wb.log.Warn("%d - %s", t.Code, t.Error())
 		w.WriteHeader(t.Code)
@@ -2,6 +2,7 @@ package app
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
 	"strings"
 	"testing"
@@ -23,10 +24,69 @@ func (tcase *TestCase) HttpRequestTest(t *testing.T) {
 	tcase.testApp.wordbubbles = tcase.wordbubbleService
 	tcase.testApp.users = tcase.userService
 	tcase.testApp.auth = tcase.authService
-	w := &TestWriter{}
+	tcase.testApp.apiKeys = tcase.apiKeyService
+	tcase.testApp.lockout = tcase.lockoutService
+	tcase.testApp.loginLinks = tcase.magicLinkService
+	tcase.testApp.mfa = tcase.mfaService
+	tcase.testApp.oauth = tcase.oauthService
+	tcase.testApp.oidc = tcase.oidcService
+	tcase.testApp.passkeys = tcase.passkeyService
+	if tcase.roleService != nil {
+		tcase.testApp.roles = tcase.roleService
+	}
+	if tcase.keys != nil {
+		tcase.testApp.keys = tcase.keys
+	}
+	w := &TestWriter{header: http.Header{}}
 	tcase.operation(w, req)
 	assert.Equal(t, tcase.respBody, w.respBody)
 	assert.Equal(t, tcase.respStatusCode, w.statusCode)
+	if tcase.respCookies != nil {
+		names := []string{}
+		for _, cookie := range (&http.Response{Header: w.header}).Cookies() {
+			names = append(names, cookie.Name)
+		}
+		assert.Equal(t, tcase.respCookies, names)
+	}
+}
+
+func Test_DeviceFromRequest(t *testing.T) {
+	tests := map[string]struct {
+		remoteAddr     string
+		header         http.Header
+		expectedDevice *model.Device
+	}{
+		"direct connection": {
+			remoteAddr:     "203.0.113.7:52814",
+			header:         http.Header{"User-Agent": []string{"curl/7.85.0"}},
+			expectedDevice: &model.Device{UserAgent: "curl/7.85.0", IPAddress: "203.0.113.7"},
+		},
+		"behind a proxy": {
+			remoteAddr:     "10.0.0.2:52814",
+			header:         http.Header{"User-Agent": []string{"curl/7.85.0"}, "X-Forwarded-For": []string{"203.0.113.7, 10.0.0.1"}},
+			expectedDevice: &model.Device{UserAgent: "curl/7.85.0", IPAddress: "203.0.113.7"},
+		},
+		"forwarded header not sent by a proxy": {
+			remoteAddr:     "203.0.113.7:52814",
+			header:         http.Header{"User-Agent": []string{"curl/7.85.0"}, "X-Forwarded-For": []string{"198.51.100.1"}},
+			expectedDevice: &model.Device{UserAgent: "curl/7.85.0", IPAddress: "203.0.113.7"},
+		},
+		"no metadata": {
+			expectedDevice: &model.Device{},
+		},
+	}
+	proxies, _ := util.ParseTrustedProxies("10.0.0.0/8")
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			wb := NewTestApp()
+			wb.trustedProxies = proxies
+			r := &http.Request{RemoteAddr: tcase.remoteAddr, Header: tcase.header}
+			if r.Header == nil {
+				r.Header = http.Header{}
+			}
+			assert.Equal(t, tcase.expectedDevice, wb.deviceFromRequest(r))
+		})
+	}
 }
 
 func structToJson(s any) string {
@@ -37,9 +97,33 @@ func structToJson(s any) string {
 	return string(b) + "\n"
 }
 
+// scopedToken returns an access token for user 2 that never expires, granted only the scope passed
+func scopedToken(scope string) string {
+	return util.GenerateSignedToken(util.TestKeyProvider(), util.TokenTypeAccess, 0, math.MaxInt64, 2, scope, "")
+}
+
+// roleToken returns an access token for user 2 that never expires, granted the scope passed and issued with the role passed
+func roleToken(scope, role string) string {
+	return util.GenerateSignedToken(util.TestKeyProvider(), util.TokenTypeAccess, 0, math.MaxInt64, 2, scope, role)
+}
+
+// scopedRefreshToken returns a refresh token for user 2 that never expires, granted only the scope passed
+func scopedRefreshToken(scope string) string {
+	return util.GenerateSignedToken(util.TestKeyProvider(), util.TokenTypeRefresh, 0, math.MaxInt64, 2, scope, "")
+}
+
+// clientRefreshToken returns a refresh token for user 2 that never expires, granted the scope passed and issued to the oauth client passed
+func clientRefreshToken(clientId, scope string) string {
+	return util.GenerateSignedClientToken(util.TestKeyProvider(), util.TokenTypeRefresh, 0, math.MaxInt64, 2, scope, "", clientId)
+}
+
 func NewTestApp() *app {
 	return &app{
-		log: util.TestLogger(),
+		log:   util.TestLogger(),
+		keys:  util.TestKeyProvider(),
+		roles: &TestRoleService{RoleRole: util.RoleUser},
+
+		accessTokenLifetime: util.DefaultTokenLifetimes().AccessToken,
 	}
 }
 
@@ -54,10 +138,21 @@ type TestCase struct {
 
 	respBody       string
 	respStatusCode int
+	// respCookies are the names of the cookies set by the response, in order, checked when not nil
+	respCookies []string
 
 	userService       *TestUserService
 	wordbubbleService *TestWordbubbleService
 	authService       *TestAuthService
+	apiKeyService     *TestApiKeyService
+	lockoutService    *TestLockoutService
+	magicLinkService  *TestMagicLinkService
+	mfaService        *TestMfaService
+	oauthService      *TestOAuthService
+	oidcService       *TestOidcService
+	passkeyService    *TestPasskeyService
+	roleService       *TestRoleService // when nil, every user is a user
+	keys              util.KeyProvider
 }
 
 type TestWriter struct {
@@ -81,18 +176,40 @@ func (tr *TestWriter) WriteHeader(statusCode int) {
 }
 
 type TestAuthService struct {
-	TokenIsNearEOL             bool
-	GenerateAccessTokenString  string
-	GenerateRefreshTokenString string
-	GenerateRefreshTokenError  error
-	ValidateRefreshTokenError  error
+	TokenIsNearEOL                   bool
+	GenerateAccessTokenString        string
+	GenerateRefreshTokenString       string
+	GenerateRefreshTokenError        error
+	GenerateClientRefreshTokenString string
+	GenerateClientRefreshTokenError  error
+	ValidateRefreshTokenError        error
+	RotateRefreshTokenString         string
+	RotateRefreshTokenError          error
+	RevokeRefreshTokenError          error
+	RevokeAllRefreshTokensError      error
+	ValidateAccessTokenError         error
+	RevokeAccessTokenError           error
+	ListSessionsSessions             []resp.Session
+	ListSessionsError                error
+	RevokeSessionError               error
 }
 
-func (tas *TestAuthService) GenerateAccessToken(userId int64) string {
+func (tas *TestAuthService) GenerateAccessToken(userId int64, scope, role string) string {
 	return tas.GenerateAccessTokenString
 }
 
-func (tas *TestAuthService) GenerateRefreshToken(userId int64) (string, error) {
+func (tas *TestAuthService) ValidateAccessToken(tokenStr string) (*model.TokenClaims, error) {
+	if tas.ValidateAccessTokenError != nil {
+		return nil, tas.ValidateAccessTokenError
+	}
+	return util.ParseWithClaims(util.TestKeyProvider(), util.TokenTypeAccess, tokenStr)
+}
+
+func (tas *TestAuthService) RevokeAccessToken(principal *model.Principal) error {
+	return tas.RevokeAccessTokenError
+}
+
+func (tas *TestAuthService) GenerateRefreshToken(userId int64, scope string, rememberMe bool, device *model.Device) (string, error) {
 	return tas.GenerateRefreshTokenString, tas.GenerateRefreshTokenError
 }
 
@@ -101,18 +218,273 @@ func (tas *TestAuthService) ValidateRefreshToken(token *auth.RefreshToken) error
 	return tas.ValidateRefreshTokenError
 }
 
+func (tas *TestAuthService) GenerateClientRefreshToken(userId int64, clientId, scope string, device *model.Device) (string, error) {
+	return tas.GenerateClientRefreshTokenString, tas.GenerateClientRefreshTokenError
+}
+
+func (tas *TestAuthService) RotateRefreshToken(token *auth.RefreshToken, device *model.Device) (string, error) {
+	return tas.RotateRefreshTokenString, tas.RotateRefreshTokenError
+}
+
+func (tas *TestAuthService) RevokeRefreshToken(token *auth.RefreshToken) error {
+	return tas.RevokeRefreshTokenError
+}
+
+func (tas *TestAuthService) RevokeAllRefreshTokens(userId int64) error {
+	return tas.RevokeAllRefreshTokensError
+}
+
+func (tas *TestAuthService) ListSessions(userId int64) ([]resp.Session, error) {
+	return tas.ListSessionsSessions, tas.ListSessionsError
+}
+
+func (tas *TestAuthService) RevokeSession(userId int64, sessionId string) error {
+	return tas.RevokeSessionError
+}
+
+type TestApiKeyService struct {
+	CreateApiKeyResponse *resp.CreateApiKeyResponse
+	CreateApiKeyError    error
+	ValidateApiKeyUserId int64
+	ValidateApiKeyScope  string
+	ValidateApiKeyError  error
+	ListApiKeysKeys      []resp.ApiKey
+	ListApiKeysError     error
+	RevokeApiKeyError    error
+}
+
+func (taks *TestApiKeyService) CreateApiKey(userId int64, name, scope string) (*resp.CreateApiKeyResponse, error) {
+	return taks.CreateApiKeyResponse, taks.CreateApiKeyError
+}
+
+func (taks *TestApiKeyService) ValidateApiKey(key string) (int64, string, error) {
+	return taks.ValidateApiKeyUserId, taks.ValidateApiKeyScope, taks.ValidateApiKeyError
+}
+
+func (taks *TestApiKeyService) ListApiKeys(userId int64) ([]resp.ApiKey, error) {
+	return taks.ListApiKeysKeys, taks.ListApiKeysError
+}
+
+func (taks *TestApiKeyService) RevokeApiKey(userId int64, keyId string) error {
+	return taks.RevokeApiKeyError
+}
+
+type TestOAuthService struct {
+	RegisterClientResponse          *resp.RegisterClientResponse
+	RegisterClientError             error
+	ValidateAuthorizeRequestConsent *resp.ConsentResponse
+	ValidateAuthorizeRequestError   error
+	CreateAuthorizationCodeString   string
+	CreateAuthorizationCodeError    error
+	ExchangeAuthorizationCodeUserId int64
+	ExchangeAuthorizationCodeScope  string
+	ExchangeAuthorizationCodeError  error
+}
+
+func (tos *TestOAuthService) RegisterClient(userId int64, name, redirectUri string) (*resp.RegisterClientResponse, error) {
+	return tos.RegisterClientResponse, tos.RegisterClientError
+}
+
+func (tos *TestOAuthService) ValidateAuthorizeRequest(request *req.AuthorizeRequest) (*resp.ConsentResponse, error) {
+	return tos.ValidateAuthorizeRequestConsent, tos.ValidateAuthorizeRequestError
+}
+
+func (tos *TestOAuthService) CreateAuthorizationCode(userId int64, clientId, redirectUri, scope, codeChallenge string) (string, error) {
+	return tos.CreateAuthorizationCodeString, tos.CreateAuthorizationCodeError
+}
+
+func (tos *TestOAuthService) ExchangeAuthorizationCode(clientId, code, redirectUri, codeVerifier string) (int64, string, error) {
+	return tos.ExchangeAuthorizationCodeUserId, tos.ExchangeAuthorizationCodeScope, tos.ExchangeAuthorizationCodeError
+}
+
+type TestOidcService struct {
+	AuthorizationUrlUrl   string
+	AuthorizationUrlError error
+	CompleteLoginLogin    *model.FederatedLogin
+	CompleteLoginError    error
+	LinkedUserUserId      int64
+	LinkedUserError       error
+	LinkIdentityError     error
+	LinkedUserId          int64 // the user an identity was linked to
+}
+
+func (tos *TestOidcService) AuthorizationUrl(linkUserId int64, scope string, rememberMe bool) (string, error) {
+	return tos.AuthorizationUrlUrl, tos.AuthorizationUrlError
+}
+
+func (tos *TestOidcService) CompleteLogin(code, state string) (*model.FederatedLogin, error) {
+	return tos.CompleteLoginLogin, tos.CompleteLoginError
+}
+
+func (tos *TestOidcService) LinkedUser(identity *model.FederatedIdentity) (int64, error) {
+	return tos.LinkedUserUserId, tos.LinkedUserError
+}
+
+func (tos *TestOidcService) LinkIdentity(userId int64, identity *model.FederatedIdentity) error {
+	tos.LinkedUserId = userId
+	return tos.LinkIdentityError
+}
+
+type TestPasskeyService struct {
+	BeginRegistrationOptions  *resp.PasskeyCreationOptions
+	BeginRegistrationError    error
+	FinishRegistrationPasskey *resp.Passkey
+	FinishRegistrationError   error
+	BeginLoginOptions         *resp.PasskeyRequestOptions
+	BeginLoginError           error
+	FinishLoginLogin          *model.PasskeyLogin
+	FinishLoginError          error
+	ListPasskeysPasskeys      []resp.Passkey
+	ListPasskeysError         error
+	RevokePasskeyError        error
+}
+
+func (tps *TestPasskeyService) BeginRegistration(user *model.User) (*resp.PasskeyCreationOptions, error) {
+	return tps.BeginRegistrationOptions, tps.BeginRegistrationError
+}
+
+func (tps *TestPasskeyService) FinishRegistration(userId int64, request *req.PasskeyRegistrationRequest) (*resp.Passkey, error) {
+	return tps.FinishRegistrationPasskey, tps.FinishRegistrationError
+}
+
+func (tps *TestPasskeyService) BeginLogin(scope string, rememberMe bool) (*resp.PasskeyRequestOptions, error) {
+	return tps.BeginLoginOptions, tps.BeginLoginError
+}
+
+func (tps *TestPasskeyService) FinishLogin(credential *req.PasskeyCredential) (*model.PasskeyLogin, error) {
+	return tps.FinishLoginLogin, tps.FinishLoginError
+}
+
+func (tps *TestPasskeyService) ListPasskeys(userId int64) ([]resp.Passkey, error) {
+	return tps.ListPasskeysPasskeys, tps.ListPasskeysError
+}
+
+func (tps *TestPasskeyService) RevokePasskey(userId int64, passkeyId string) error {
+	return tps.RevokePasskeyError
+}
+
+type TestRoleService struct {
+	RoleRole            string
+	RoleError           error
+	AssignRoleError     error
+	BootstrapAdminError error
+}
+
+func (trs *TestRoleService) Role(userId int64) (string, error) {
+	return trs.RoleRole, trs.RoleError
+}
+
+func (trs *TestRoleService) AssignRole(assigner *model.Principal, userId int64, role string) error {
+	return trs.AssignRoleError
+}
+
+func (trs *TestRoleService) BootstrapAdmin(userId int64) error {
+	return trs.BootstrapAdminError
+}
+
+type TestLockoutService struct {
+	CheckLoginError     error
+	LoginFailedError    error
+	LoginSucceededError error
+}
+
+func (tls *TestLockoutService) CheckLogin(userId int64, login, ip string) error {
+	return tls.CheckLoginError
+}
+
+func (tls *TestLockoutService) LoginFailed(userId int64, login, ip string) error {
+	return tls.LoginFailedError
+}
+
+func (tls *TestLockoutService) LoginSucceeded(userId int64, ip string) error {
+	return tls.LoginSucceededError
+}
+
+type TestMagicLinkService struct {
+	SendLoginLinkError    error
+	RedeemLoginLinkUserId int64
+	RedeemLoginLinkScope  string
+	RedeemLoginLinkError  error
+}
+
+func (tmls *TestMagicLinkService) SendLoginLink(address string, userId int64, scope string, rememberMe bool) error {
+	return tmls.SendLoginLinkError
+}
+
+func (tmls *TestMagicLinkService) RedeemLoginLink(token string) (int64, string, bool, error) {
+	return tmls.RedeemLoginLinkUserId, tmls.RedeemLoginLinkScope, false, tmls.RedeemLoginLinkError
+}
+
+type TestMfaService struct {
+	EnrollTotpResponse      *resp.EnrollTotpResponse
+	EnrollTotpError         error
+	ConfirmTotpResponse     *resp.RecoveryCodesResponse
+	ConfirmTotpError        error
+	DisableTotpError        error
+	MfaEnabledBool          bool
+	MfaEnabledError         error
+	CreateChallengeResponse *resp.MfaChallengeResponse
+	CreateChallengeError    error
+	ChallengeUserUserId     int64
+	ChallengeUserError      error
+	VerifyChallengeUserId   int64
+	VerifyChallengeScope    string
+	VerifyChallengeError    error
+}
+
+func (tms *TestMfaService) EnrollTotp(userId int64, account string) (*resp.EnrollTotpResponse, error) {
+	return tms.EnrollTotpResponse, tms.EnrollTotpError
+}
+
+func (tms *TestMfaService) ConfirmTotp(userId int64, code string) (*resp.RecoveryCodesResponse, error) {
+	return tms.ConfirmTotpResponse, tms.ConfirmTotpError
+}
+
+func (tms *TestMfaService) DisableTotp(userId int64, code string) error {
+	return tms.DisableTotpError
+}
+
+func (tms *TestMfaService) MfaEnabled(userId int64) (bool, error) {
+	return tms.MfaEnabledBool, tms.MfaEnabledError
+}
+
+func (tms *TestMfaService) CreateChallenge(userId int64, scope string) (*resp.MfaChallengeResponse, error) {
+	return tms.CreateChallengeResponse, tms.CreateChallengeError
+}
+
+func (tms *TestMfaService) ChallengeUser(mfaToken string) (int64, error) {
+	return tms.ChallengeUserUserId, tms.ChallengeUserError
+}
+
+func (tms *TestMfaService) VerifyChallenge(mfaToken, code string) (int64, string, error) {
+	return tms.VerifyChallengeUserId, tms.VerifyChallengeScope, tms.VerifyChallengeError
+}
+
 type TestUserService struct {
 	AddUserError                     error
 	RetrieveUnauthenticatedUserUser  *model.User
 	RetrieveUnauthenticatedUserError error
 	RetrieveAuthenticatedUserUser    *model.User
 	RetrieveAuthenticatedUserError   error
+	RetrieveUserByIdUser             *model.User
+	RetrieveUserByIdError            error
+	ForgotPasswordError              error
+	ResetPasswordUserId              int64
+	ResetPasswordError               error
+	SendVerificationError            error
+	VerifyEmailError                 error
+	AddFederatedUserUser             *model.User
+	AddFederatedUserError            error
 }
 
 func (tus *TestUserService) AddUser(user *model.User) error {
 	return tus.AddUserError
 }
 
+func (tus *TestUserService) AddFederatedUser(email, usernameHint string) (*model.User, error) {
+	return tus.AddFederatedUserUser, tus.AddFederatedUserError
+}
+
 func (tus *TestUserService) RetrieveUnauthenticatedUser(userStr string) (*model.User, error) {
 	return tus.RetrieveUnauthenticatedUserUser, tus.RetrieveUnauthenticatedUserError
 }
@@ -121,6 +493,26 @@ func (tus *TestUserService) RetrieveAuthenticatedUser(userStr, password string)
 	return tus.RetrieveAuthenticatedUserUser, tus.RetrieveAuthenticatedUserError
 }
 
+func (tus *TestUserService) RetrieveUserById(userId int64) (*model.User, error) {
+	return tus.RetrieveUserByIdUser, tus.RetrieveUserByIdError
+}
+
+func (tus *TestUserService) ForgotPassword(userStr string) error {
+	return tus.ForgotPasswordError
+}
+
+func (tus *TestUserService) ResetPassword(token, password string) (int64, error) {
+	return tus.ResetPasswordUserId, tus.ResetPasswordError
+}
+
+func (tus *TestUserService) SendVerification(userId int64) error {
+	return tus.SendVerificationError
+}
+
+func (tus *TestUserService) VerifyEmail(token string) error {
+	return tus.VerifyEmailError
+}
+
 type TestWordbubbleService struct {
 	AddNewWordbubbleError                              error
 	RemoveAndReturnLatestWordbubbleForUserIdWordbubble *resp.WordbubbleResponse
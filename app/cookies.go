@@ -0,0 +1,148 @@
+package app
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bchadwic/wordbubble/internal/service/auth"
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// Browser clients opt in to cookie sessions by sending the session mode header to /signup, /login or /login/mfa.
+// The tokens are then set as HttpOnly cookies that scripts can't read, instead of being returned in the body,
+// and every state changing request sent with them must double submit the csrf cookie in the csrf header
+const (
+	sessionModeHeader  = "X-Session-Mode"
+	sessionModeCookie  = "cookie"
+	accessTokenCookie  = "wb_access"
+	refreshTokenCookie = "wb_refresh"
+	csrfCookie         = "wb_csrf"
+	csrfHeader         = "X-CSRF-Token"
+	cookiePath         = "/v1"
+)
+
+// wantsCookies returns true if the client asked for its tokens to be set as cookies
+func wantsCookies(r *http.Request) bool {
+	return r.Header.Get(sessionModeHeader) == sessionModeCookie
+}
+
+// writeTokens responds with the access and refresh tokens, in the body, or as cookies when useCookies is true.
+// A new csrf token is set along with the cookies, which scripts can read so they can send it back in the csrf header
+func (wb *app) writeTokens(w http.ResponseWriter, statusCode int, useCookies bool, accessToken, refreshToken string) {
+	if !useCookies {
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(&resp.TokenResponse{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+		})
+		return
+	}
+
+	// the refresh and csrf cookies last as long as the refresh token, or the browser session when it can't be read
+	var expires time.Time
+	if token, err := auth.RefreshTokenFromTokenString(wb.keys, refreshToken); err == nil {
+		expires = time.Unix(token.ExpiresAt(), 0)
+	}
+	http.SetCookie(w, sessionCookie(accessTokenCookie, accessToken, time.Now().Add(wb.accessTokenLifetime), true))
+	http.SetCookie(w, sessionCookie(refreshTokenCookie, refreshToken, expires, true))
+	http.SetCookie(w, sessionCookie(csrfCookie, util.RandomString(32), expires, false))
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(&resp.CookieSessionResponse{
+		ExpiresIn: int64(wb.accessTokenLifetime.Seconds()),
+	})
+}
+
+// clearSessionCookies expires every session cookie, so the browser stops sending them
+func clearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{accessTokenCookie, refreshTokenCookie, csrfCookie} {
+		cookie := sessionCookie(name, "", time.Time{}, name != csrfCookie)
+		cookie.MaxAge = -1
+		http.SetCookie(w, cookie)
+	}
+}
+
+// sessionCookie creates a cookie that's only sent over https, and never with requests started by another site
+func sessionCookie(name, value string, expires time.Time, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     cookiePath,
+		Expires:  expires,
+		Secure:   true,
+		HttpOnly: httpOnly,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// refreshTokenFromRequest reads the refresh token request from the body, the token is taken from the refresh
+// token cookie when the body doesn't have one, which is only accepted along with the csrf token
+// *req.RefreshTokenRequest is the token and the scope requested, can be nil.
+// bool is true if the token was taken from the cookie.
+// error could be (400) resp.ErrParseRefreshToken, (403) resp.ErrInvalidCsrfToken or nil.
+func refreshTokenFromRequest(r *http.Request) (*req.RefreshTokenRequest, bool, error) {
+	var reqBody req.RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil && !errors.Is(err, io.EOF) {
+		return nil, false, resp.ErrParseRefreshToken
+	}
+	if reqBody.Token != "" {
+		return &reqBody, false, nil
+	}
+	cookie, err := r.Cookie(refreshTokenCookie)
+	if err != nil || cookie.Value == "" {
+		return nil, false, resp.ErrParseRefreshToken
+	}
+	if err := checkCsrf(r); err != nil {
+		return nil, false, err
+	}
+	reqBody.Token = cookie.Value
+	return &reqBody, true, nil
+}
+
+// checkCsrf makes sure the csrf header matches the csrf cookie. Another site can make the browser send cookies,
+// but it can't read them, so it can't send the matching header
+// error could be (403) resp.ErrInvalidCsrfToken or nil.
+func checkCsrf(r *http.Request) error {
+	cookie, err := r.Cookie(csrfCookie)
+	if err != nil || cookie.Value == "" {
+		return resp.ErrInvalidCsrfToken
+	}
+	if subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(r.Header.Get(csrfHeader))) != 1 {
+		return resp.ErrInvalidCsrfToken
+	}
+	return nil
+}
+
+// isSafeMethod returns true for methods that don't change state, so they don't need a csrf token
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// RequireCsrf wraps a handler that doesn't authenticate with cookies, but changes state, so requests sent
+// by a browser with a cookie session must still send the csrf token. Requests without session cookies are let through
+func (wb *app) RequireCsrf(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isSafeMethod(r.Method) && hasSessionCookie(r) {
+			if err := checkCsrf(r); err != nil {
+				wb.errorResponse(err, w)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// hasSessionCookie returns true if the request was sent with an access or refresh token cookie
+func hasSessionCookie(r *http.Request) bool {
+	for _, name := range []string{accessTokenCookie, refreshTokenCookie} {
+		if cookie, err := r.Cookie(name); err == nil && cookie.Value != "" {
+			return true
+		}
+	}
+	return false
+}
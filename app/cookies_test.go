@@ -0,0 +1,189 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// sessionCookies returns a cookie header for a browser session, the csrf cookie is left out when csrf is empty
+func sessionCookies(csrf string) string {
+	cookies := fmt.Sprintf("wb_access=%s; wb_refresh=%s", scopedToken(util.AllScopes()), scopedRefreshToken(util.AllScopes()))
+	if csrf != "" {
+		cookies += "; wb_csrf=" + csrf
+	}
+	return cookies
+}
+
+func Test_TokenWithCookies(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid, refresh token from the cookie": {
+			reqHeader:      http.Header{"Cookie": []string{sessionCookies("abc123")}, "X-Csrf-Token": []string{"abc123"}},
+			respBody:       fmt.Sprintln(`{"expires_in":30}`),
+			respStatusCode: http.StatusOK,
+			respCookies:    []string{"wb_access", "wb_refresh", "wb_csrf"},
+			reqMethod:      http.MethodPost,
+			authService: &TestAuthService{
+				GenerateAccessTokenString: "aaa.bbb.ccc",
+				RotateRefreshTokenString:  "ddd.eee.fff",
+			},
+		},
+		"valid, refresh token from the body set as cookies": {
+			reqBody:        fmt.Sprintf(`{"refresh_token":"%s"}`, scopedRefreshToken(util.AllScopes())),
+			reqHeader:      http.Header{"X-Session-Mode": []string{"cookie"}},
+			respBody:       fmt.Sprintln(`{"expires_in":30}`),
+			respStatusCode: http.StatusOK,
+			respCookies:    []string{"wb_access", "wb_refresh", "wb_csrf"},
+			reqMethod:      http.MethodPost,
+			authService: &TestAuthService{
+				GenerateAccessTokenString: "aaa.bbb.ccc",
+				RotateRefreshTokenString:  "ddd.eee.fff",
+			},
+		},
+		"valid, refresh token from the body takes precedence over the cookie": {
+			reqBody:        fmt.Sprintf(`{"refresh_token":"%s"}`, scopedRefreshToken(util.AllScopes())),
+			reqHeader:      http.Header{"Cookie": []string{sessionCookies("")}},
+			respBody:       fmt.Sprintln(`{"access_token":"aaa.bbb.ccc","refresh_token":"ddd.eee.fff"}`),
+			respStatusCode: http.StatusOK,
+			respCookies:    []string{},
+			reqMethod:      http.MethodPost,
+			authService: &TestAuthService{
+				GenerateAccessTokenString: "aaa.bbb.ccc",
+				RotateRefreshTokenString:  "ddd.eee.fff",
+			},
+		},
+		"invalid, no csrf header": {
+			reqHeader:      http.Header{"Cookie": []string{sessionCookies("abc123")}},
+			respBody:       structToJson(resp.ErrInvalidCsrfToken),
+			respStatusCode: resp.ErrInvalidCsrfToken.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, no csrf cookie": {
+			reqHeader:      http.Header{"Cookie": []string{sessionCookies("")}, "X-Csrf-Token": []string{"abc123"}},
+			respBody:       structToJson(resp.ErrInvalidCsrfToken),
+			respStatusCode: resp.ErrInvalidCsrfToken.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, csrf header doesn't match": {
+			reqHeader:      http.Header{"Cookie": []string{sessionCookies("abc123")}, "X-Csrf-Token": []string{"abc124"}},
+			respBody:       structToJson(resp.ErrInvalidCsrfToken),
+			respStatusCode: resp.ErrInvalidCsrfToken.Code,
+			reqMethod:      http.MethodPost,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.Token
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_LogoutWithCookies(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid, session cookies are cleared": {
+			reqHeader:      http.Header{"Cookie": []string{sessionCookies("abc123")}, "X-Csrf-Token": []string{"abc123"}},
+			respBody:       structToJson(&resp.LogoutResponse{Message: "you have been logged out"}),
+			respStatusCode: http.StatusOK,
+			respCookies:    []string{"wb_access", "wb_refresh", "wb_csrf"},
+			reqMethod:      http.MethodPost,
+			authService:    &TestAuthService{},
+		},
+		"invalid, no csrf header": {
+			reqHeader:      http.Header{"Cookie": []string{sessionCookies("abc123")}},
+			respBody:       structToJson(resp.ErrInvalidCsrfToken),
+			respStatusCode: resp.ErrInvalidCsrfToken.Code,
+			respCookies:    []string{},
+			reqMethod:      http.MethodPost,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.Logout
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_RequireTokenWithCookies(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid, safe method without csrf header": {
+			reqHeader:      http.Header{"Cookie": []string{sessionCookies("abc123")}},
+			respBody:       fmt.Sprintln(`{"UserId":2,"Scope":"account:manage wordbubble:push wordbubble:read","ApiKey":false,"HasId":true}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodGet,
+			authService:    &TestAuthService{},
+		},
+		"valid, state changing method with csrf header": {
+			reqHeader:      http.Header{"Cookie": []string{sessionCookies("abc123")}, "X-Csrf-Token": []string{"abc123"}},
+			respBody:       fmt.Sprintln(`{"UserId":2,"Scope":"account:manage wordbubble:push wordbubble:read","ApiKey":false,"HasId":true}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			authService:    &TestAuthService{},
+		},
+		"valid, authorization header doesn't need csrf header": {
+			reqHeader: http.Header{
+				"Authorization": []string{"Bearer " + scopedToken(util.ScopeAccountManage)},
+				"Cookie":        []string{sessionCookies("abc123")},
+			},
+			respBody:       fmt.Sprintln(`{"UserId":2,"Scope":"account:manage","ApiKey":false,"HasId":true}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			authService:    &TestAuthService{},
+		},
+		"invalid, state changing method without csrf header": {
+			reqHeader:      http.Header{"Cookie": []string{sessionCookies("abc123")}},
+			respBody:       structToJson(resp.ErrInvalidCsrfToken),
+			respStatusCode: resp.ErrInvalidCsrfToken.Code,
+			reqMethod:      http.MethodDelete,
+		},
+		"invalid, access token cookie is expired": {
+			reqHeader:      http.Header{"Cookie": []string{"wb_access=" + util.GenerateSignedToken(util.TestKeyProvider(), util.TokenTypeAccess, 0, 1, 2, util.AllScopes(), "")}},
+			respBody:       structToJson(resp.ErrTokenIsExpired),
+			respStatusCode: resp.ErrTokenIsExpired.Code,
+			reqMethod:      http.MethodGet,
+			authService:    &TestAuthService{},
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.RequireToken(util.ScopeAccountManage, principalHandler)
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_RequireCsrf(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid, no session cookies": {
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodDelete,
+		},
+		"valid, session cookies with csrf header": {
+			reqHeader:      http.Header{"Cookie": []string{sessionCookies("abc123")}, "X-Csrf-Token": []string{"abc123"}},
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodDelete,
+		},
+		"invalid, session cookies without csrf header": {
+			reqHeader:      http.Header{"Cookie": []string{sessionCookies("abc123")}},
+			respBody:       structToJson(resp.ErrInvalidCsrfToken),
+			respStatusCode: resp.ErrInvalidCsrfToken.Code,
+			reqMethod:      http.MethodDelete,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.RequireCsrf(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+)
+
+// JWKS returns the public keys that can be used to verify wordbubble tokens
+// @Summary     Public keys for api.wordbubble.io tokens
+// @Description JWKS returns the public keys of RS256 and EdDSA signing keys, so other services can validate tokens on their own. Served from /.well-known/jwks.json at the root of the host
+// @Tags        auth
+// @Produce     json
+// @Success     200 {object} resp.JWKSResponse
+// @Failure     405 {object} resp.StatusMethodNotAllowed "resp.ErrInvalidHttpMethod"
+// @Router      /.well-known/jwks.json [get]
+func (wb *app) JWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	jwks := &resp.JWKSResponse{
+		Keys: []resp.JWK{},
+	}
+	for _, key := range wb.keys.VerificationKeys() {
+		if jwk := key.PublicJWK(); jwk != nil {
+			jwks.Keys = append(jwks.Keys, *jwk)
+		}
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(jwks)
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+func Test_JWKS(t *testing.T) {
+	private := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	x := base64.RawURLEncoding.EncodeToString(private.Public().(ed25519.PublicKey))
+	tests := map[string]TestCase{
+		"valid, only public keys are returned": {
+			respBody:       fmt.Sprintf(`{"keys":[{"kty":"OKP","use":"sig","alg":"EdDSA","kid":"b","crv":"Ed25519","x":"%s"}]}`+"\n", x),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodGet,
+			keys: util.NewKeyProvider(util.NewTimer(), time.Hour, util.DefaultClaimsPolicy(),
+				&util.Key{Id: "b", Algorithm: util.EdDSA, Private: private},
+				&util.Key{Id: "a", Algorithm: util.HS256, Secret: []byte("secret"), RetiredAt: time.Now().Unix()},
+			),
+		},
+		"valid, no public keys": {
+			respBody:       fmt.Sprintln(`{"keys":[]}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodGet,
+		},
+		"invalid, POST http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodPost,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.JWKS
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
@@ -7,20 +7,29 @@ import (
 
 	"github.com/bchadwic/wordbubble/model/req"
 	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
 )
 
 // Login is used to get the access and refresh token for a user's credentials
 // @Summary     Login to api.wordbubble.io
-// @Description Login to api.wordbubble.io using the user credentials
+// @Description Login to api.wordbubble.io using the user credentials, the tokens are granted every scope unless a narrower scope is requested.
+// @Description When two-factor authentication is enabled, an mfa token is returned instead, which is exchanged along with a code at /login/mfa.
+// @Description Sending remember_me makes the session last longer before the user has to login again.
+// @Description Browsers can ask for the tokens to be set as cookies, every state changing request must then send the wb_csrf cookie back in the X-CSRF-Token header.
+// @Description Failed logins are counted per account and per client, after a few each following attempt has to wait longer, and an account is locked for a while after many
 // @Tags        auth
 // @Accept      json
 // @Produce     json
-// @Param       User body     req.LoginUserRequest           true "Credentials used to authenticate a user"
-// @Success     200  {object} resp.TokenResponse             "Valid access and refresh tokens for user"
-// @Failure     405  {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
-// @Failure     400  {object} resp.StatusBadRequest          "resp.ErrParseUser, resp.ErrNoPassword, resp.ErrNoUser, resp.ErrUnknownUser, resp.ErrCouldNotDetermineUserType"
-// @Failure     401  {object} resp.StatusUnauthorized        "resp.ErrInvalidCredentials"
-// @Failure     500  {object} resp.StatusInternalServerError "resp.ErrSQLMappingError, resp.ErrCouldNotStoreRefreshToken"
+// @Param       User           body     req.LoginUserRequest           true  "Credentials used to authenticate a user"
+// @Param       X-Session-Mode header   string                         false "Send cookie to receive the tokens as HttpOnly cookies, resp.CookieSessionResponse is returned instead"
+// @Success     200            {object} resp.TokenResponse             "Valid access and refresh tokens for user"
+// @Success     202            {object} resp.MfaChallengeResponse      "Mfa token when two-factor authentication is enabled"
+// @Failure     405            {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     400            {object} resp.StatusBadRequest          "resp.ErrParseUser, resp.ErrNoPassword, resp.ErrNoUser, resp.ErrUnknownUser, resp.ErrCouldNotDetermineUserType, resp.ErrInvalidScope"
+// @Failure     401            {object} resp.StatusUnauthorized        "resp.ErrInvalidCredentials"
+// @Failure     423            {object} resp.StatusLocked              "resp.ErrAccountLocked"
+// @Failure     429            {object} resp.StatusTooManyRequests     "resp.ErrTooManyLoginAttempts"
+// @Failure     500            {object} resp.StatusInternalServerError "resp.ErrSQLMappingError, resp.ErrCouldNotCheckLoginAttempts, resp.ErrCouldNotRecordLoginAttempt, resp.ErrCouldNotCheckMfa, resp.ErrCouldNotStoreMfaChallenge, resp.ErrCouldNotRetrieveRole, resp.ErrCouldNotStoreRefreshToken"
 // @Router      /login [post]
 func (wb *app) Login(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -34,24 +43,72 @@ func (wb *app) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	scope, err := util.ValidScope(user.Scope)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	// failures are counted per user, so the username and email can't each be guessed at
+	var userId int64
+	if found, err := wb.users.RetrieveUnauthenticatedUser(user.User); err == nil {
+		userId = found.Id
+	} else if err != resp.ErrUnknownUser {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	ip := wb.deviceFromRequest(r).IPAddress
+	if err = wb.lockout.CheckLogin(userId, user.User, ip); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
 	authenticatedUser, err := wb.users.RetrieveAuthenticatedUser(user.User, user.Password)
 	if err != nil {
+		if err == resp.ErrInvalidCredentials || err == resp.ErrUnknownUser {
+			if lockoutErr := wb.lockout.LoginFailed(userId, user.User, ip); lockoutErr != nil {
+				err = lockoutErr
+			}
+		}
+		wb.errorResponse(err, w)
+		return
+	}
+
+	mfaEnabled, err := wb.mfa.MfaEnabled(authenticatedUser.Id)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	if mfaEnabled { // no tokens are issued until a second factor is sent to /login/mfa, failures are only reset once it is
+		challenge, err := wb.mfa.CreateChallenge(authenticatedUser.Id, scope)
+		if err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(challenge)
+		return
+	}
+
+	if err = wb.lockout.LoginSucceeded(authenticatedUser.Id, ip); err != nil {
 		wb.errorResponse(err, w)
 		return
 	}
 
-	refreshToken, err := wb.auth.GenerateRefreshToken(authenticatedUser.Id)
+	role, err := wb.roles.Role(authenticatedUser.Id)
 	if err != nil {
 		wb.errorResponse(err, w)
 		return
 	}
 
-	resp := resp.TokenResponse{
-		RefreshToken: refreshToken,
-		AccessToken:  wb.auth.GenerateAccessToken(authenticatedUser.Id),
+	refreshToken, err := wb.auth.GenerateRefreshToken(authenticatedUser.Id, scope, user.RememberMe, wb.deviceFromRequest(r))
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(resp)
+
+	wb.writeTokens(w, http.StatusOK, wantsCookies(r), wb.auth.GenerateAccessToken(authenticatedUser.Id, scope, role), refreshToken)
 }
 
 func getLoginUserFromBody(body io.Reader) (*req.LoginUserRequest, error) {
@@ -16,22 +16,130 @@ func Test_Login(t *testing.T) {
 			respBody:       fmt.Sprintln(`{"access_token":"test.access.token","refresh_token":"test.refresh.token"}`),
 			respStatusCode: http.StatusOK,
 			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{},
 			userService: &TestUserService{
-				RetrieveAuthenticatedUserUser: &model.User{},
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+				RetrieveAuthenticatedUserUser:   &model.User{Id: 2},
 			},
+			mfaService: &TestMfaService{},
 			authService: &TestAuthService{
 				GenerateRefreshTokenString: "test.refresh.token",
 				GenerateAccessTokenString:  "test.access.token",
 			},
 		},
+		"invalid, role couldn't be retrieved": {
+			reqBody:        `{"user":"ben","password":"SomePassword123"}`,
+			respBody:       structToJson(resp.ErrCouldNotRetrieveRole),
+			respStatusCode: resp.ErrCouldNotRetrieveRole.Code,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+				RetrieveAuthenticatedUserUser:   &model.User{Id: 2},
+			},
+			mfaService: &TestMfaService{},
+			roleService: &TestRoleService{
+				RoleError: resp.ErrCouldNotRetrieveRole,
+			},
+		},
+		"valid, tokens set as cookies": {
+			reqBody:        `{"user":"ben","password":"SomePassword123"}`,
+			reqHeader:      http.Header{"X-Session-Mode": []string{"cookie"}},
+			respBody:       fmt.Sprintln(`{"expires_in":30}`),
+			respStatusCode: http.StatusOK,
+			respCookies:    []string{"wb_access", "wb_refresh", "wb_csrf"},
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+				RetrieveAuthenticatedUserUser:   &model.User{Id: 2},
+			},
+			mfaService: &TestMfaService{},
+			authService: &TestAuthService{
+				GenerateRefreshTokenString: "test.refresh.token",
+				GenerateAccessTokenString:  "test.access.token",
+			},
+		},
+		"valid, narrower scope": {
+			reqBody:        `{"user":"ben","password":"SomePassword123","scope":"wordbubble:push"}`,
+			respBody:       fmt.Sprintln(`{"access_token":"test.access.token","refresh_token":"test.refresh.token"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+				RetrieveAuthenticatedUserUser:   &model.User{Id: 2},
+			},
+			mfaService: &TestMfaService{},
+			authService: &TestAuthService{
+				GenerateRefreshTokenString: "test.refresh.token",
+				GenerateAccessTokenString:  "test.access.token",
+			},
+		},
+		"valid, mfa enabled": {
+			reqBody:        `{"user":"ben","password":"SomePassword123"}`,
+			respBody:       fmt.Sprintln(`{"mfa_token":"test.mfa.token","expires_in":300}`),
+			respStatusCode: http.StatusAccepted,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+				RetrieveAuthenticatedUserUser:   &model.User{Id: 2},
+			},
+			mfaService: &TestMfaService{
+				MfaEnabledBool: true,
+				CreateChallengeResponse: &resp.MfaChallengeResponse{
+					MfaToken:  "test.mfa.token",
+					ExpiresIn: 300,
+				},
+			},
+		},
+		"invalid, mfa service couldn't store a challenge": {
+			reqBody:        `{"user":"ben","password":"SomePassword123"}`,
+			respBody:       structToJson(resp.ErrCouldNotStoreMfaChallenge),
+			respStatusCode: resp.ErrCouldNotStoreMfaChallenge.Code,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+				RetrieveAuthenticatedUserUser:   &model.User{Id: 2},
+			},
+			mfaService: &TestMfaService{
+				MfaEnabledBool:       true,
+				CreateChallengeError: resp.ErrCouldNotStoreMfaChallenge,
+			},
+		},
+		"invalid, mfa service couldn't check mfa": {
+			reqBody:        `{"user":"ben","password":"SomePassword123"}`,
+			respBody:       structToJson(resp.ErrCouldNotCheckMfa),
+			respStatusCode: resp.ErrCouldNotCheckMfa.Code,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+				RetrieveAuthenticatedUserUser:   &model.User{Id: 2},
+			},
+			mfaService: &TestMfaService{
+				MfaEnabledError: resp.ErrCouldNotCheckMfa,
+			},
+		},
+		"invalid, unknown scope": {
+			reqBody:        `{"user":"ben","password":"SomePassword123","scope":"wordbubble:push admin"}`,
+			respBody:       structToJson(resp.ErrInvalidScope),
+			respStatusCode: resp.ErrInvalidScope.Code,
+			reqMethod:      http.MethodPost,
+		},
 		"invalid, auth service couldn't store a refresh token ": {
 			reqBody:        `{"user":"ben","password":"SomePassword123"}`,
 			respBody:       structToJson(resp.ErrCouldNotStoreRefreshToken),
 			respStatusCode: resp.ErrCouldNotStoreRefreshToken.Code,
 			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{},
 			userService: &TestUserService{
-				RetrieveAuthenticatedUserUser: &model.User{},
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+				RetrieveAuthenticatedUserUser:   &model.User{Id: 2},
 			},
+			mfaService: &TestMfaService{},
 			authService: &TestAuthService{
 				GenerateRefreshTokenError: resp.ErrCouldNotStoreRefreshToken,
 			},
@@ -41,8 +149,102 @@ func Test_Login(t *testing.T) {
 			respBody:       structToJson(resp.ErrCouldNotDetermineUserType),
 			respStatusCode: resp.ErrCouldNotDetermineUserType.Code,
 			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserError: resp.ErrCouldNotDetermineUserType,
+			},
+		},
+		"invalid, unknown user is counted": {
+			reqBody:        `{"user":"nobody","password":"SomePassword123"}`,
+			respBody:       structToJson(resp.ErrUnknownUser),
+			respStatusCode: resp.ErrUnknownUser.Code,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserError: resp.ErrUnknownUser,
+				RetrieveAuthenticatedUserError:   resp.ErrUnknownUser,
+			},
+		},
+		"invalid, wrong password is counted": {
+			reqBody:        `{"user":"ben","password":"WrongPassword123"}`,
+			respBody:       structToJson(resp.ErrInvalidCredentials),
+			respStatusCode: resp.ErrInvalidCredentials.Code,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+				RetrieveAuthenticatedUserError:  resp.ErrInvalidCredentials,
+			},
+		},
+		"invalid, wrong password couldn't be counted": {
+			reqBody:        `{"user":"ben","password":"WrongPassword123"}`,
+			respBody:       structToJson(resp.ErrCouldNotRecordLoginAttempt),
+			respStatusCode: resp.ErrCouldNotRecordLoginAttempt.Code,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{
+				LoginFailedError: resp.ErrCouldNotRecordLoginAttempt,
+			},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+				RetrieveAuthenticatedUserError:  resp.ErrInvalidCredentials,
+			},
+		},
+		"invalid, too many attempts": {
+			reqBody:        `{"user":"ben","password":"SomePassword123"}`,
+			respBody:       structToJson(resp.ErrTooManyLoginAttempts),
+			respStatusCode: resp.ErrTooManyLoginAttempts.Code,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{
+				CheckLoginError: resp.ErrTooManyLoginAttempts,
+			},
 			userService: &TestUserService{
-				RetrieveAuthenticatedUserError: resp.ErrCouldNotDetermineUserType,
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+			},
+		},
+		"invalid, account locked": {
+			reqBody:        `{"user":"ben","password":"SomePassword123"}`,
+			respBody:       structToJson(resp.ErrAccountLocked),
+			respStatusCode: resp.ErrAccountLocked.Code,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{
+				CheckLoginError: resp.ErrAccountLocked,
+			},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+			},
+		},
+		"invalid, lockout service couldn't reset attempts": {
+			reqBody:        `{"user":"ben","password":"SomePassword123"}`,
+			respBody:       structToJson(resp.ErrCouldNotRecordLoginAttempt),
+			respStatusCode: resp.ErrCouldNotRecordLoginAttempt.Code,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{
+				LoginSucceededError: resp.ErrCouldNotRecordLoginAttempt,
+			},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+				RetrieveAuthenticatedUserUser:   &model.User{Id: 2},
+			},
+			mfaService: &TestMfaService{},
+		},
+		"valid, mfa enabled doesn't reset attempts": {
+			reqBody:        `{"user":"ben","password":"SomePassword123"}`,
+			respBody:       fmt.Sprintln(`{"mfa_token":"test.mfa.token","expires_in":300}`),
+			respStatusCode: http.StatusAccepted,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{
+				LoginSucceededError: resp.ErrCouldNotRecordLoginAttempt,
+			},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+				RetrieveAuthenticatedUserUser:   &model.User{Id: 2},
+			},
+			mfaService: &TestMfaService{
+				MfaEnabledBool: true,
+				CreateChallengeResponse: &resp.MfaChallengeResponse{
+					MfaToken:  "test.mfa.token",
+					ExpiresIn: 300,
+				},
 			},
 		},
 		"invalid, missing password": {
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// LoginLink emails a single use login link to a user, so they can login without their password
+// @Summary     Request a login link
+// @Description Email a signed, single use link to /login/link/callback, which logs the user in with the scope requested here.
+// @Description The same response is returned whether or not a user has the email, and only a few links can be requested for an email at a time
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       Email body     req.LoginLinkRequest true "Email of the user, and the scope the tokens are granted"
+// @Success     202   {object} resp.LoginLinkResponse
+// @Failure     400   {object} resp.StatusBadRequest          "resp.ErrParseLoginLink, resp.ErrEmailIsNotValid, resp.ErrEmailIsTooLong, resp.ErrInvalidScope"
+// @Failure     405   {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     429   {object} resp.StatusTooManyRequests     "resp.ErrTooManyLoginLinks"
+// @Failure     500   {object} resp.StatusInternalServerError "resp.ErrSQLMappingError, resp.ErrCouldNotCheckLoginLink, resp.ErrCouldNotStoreLoginLink, resp.ErrCouldNotSendEmail"
+// @Router      /login/link [post]
+func (wb *app) LoginLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	var reqBody req.LoginLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		wb.errorResponse(resp.ErrParseLoginLink, w)
+		return
+	}
+	if err := util.ValidEmail(reqBody.Email); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	scope, err := util.ValidScope(reqBody.Scope)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	var userId int64 // left as zero for an unknown email, the request is counted but no link is sent
+	user, err := wb.users.RetrieveUnauthenticatedUser(reqBody.Email)
+	if err == nil {
+		userId = user.Id
+	} else if err != resp.ErrUnknownUser {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	if err = wb.loginLinks.SendLoginLink(reqBody.Email, userId, scope, reqBody.RememberMe); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp := &resp.LoginLinkResponse{
+		Message: "if an account uses this email, a login link has been sent",
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// LoginLinkCallback redeems a login link for the access and refresh token of the user it was sent to
+// @Summary     Login with a login link
+// @Description Redeem the token of a link emailed from /login/link, the link can only be used once and expires after a while.
+// @Description When two-factor authentication is enabled, an mfa token is returned instead, which is exchanged along with a code at /login/mfa.
+// @Description Browsers can ask for the tokens to be set as cookies, every state changing request must then send the wb_csrf cookie back in the X-CSRF-Token header
+// @Tags        auth
+// @Produce     json
+// @Param       token          query    string                         true  "Token of the login link"
+// @Param       X-Session-Mode header   string                         false "Send cookie to receive the tokens as HttpOnly cookies, resp.CookieSessionResponse is returned instead"
+// @Success     200            {object} resp.TokenResponse             "Valid access and refresh tokens for user"
+// @Success     202            {object} resp.MfaChallengeResponse      "Mfa token when two-factor authentication is enabled"
+// @Failure     400            {object} resp.StatusBadRequest          "resp.ErrNoLoginLinkToken"
+// @Failure     401            {object} resp.StatusUnauthorized        "resp.ErrInvalidLoginLink"
+// @Failure     405            {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500            {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckLoginLink, resp.ErrCouldNotCheckMfa, resp.ErrCouldNotStoreMfaChallenge, resp.ErrCouldNotRetrieveRole, resp.ErrCouldNotStoreRefreshToken"
+// @Router      /login/link/callback [get]
+func (wb *app) LoginLinkCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		wb.errorResponse(resp.ErrNoLoginLinkToken, w)
+		return
+	}
+
+	userId, scope, rememberMe, err := wb.loginLinks.RedeemLoginLink(token)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	mfaEnabled, err := wb.mfa.MfaEnabled(userId)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	if mfaEnabled { // a link only proves the email, the second factor is still sent to /login/mfa
+		challenge, err := wb.mfa.CreateChallenge(userId, scope)
+		if err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(challenge)
+		return
+	}
+
+	role, err := wb.roles.Role(userId)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	refreshToken, err := wb.auth.GenerateRefreshToken(userId, scope, rememberMe, wb.deviceFromRequest(r))
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	wb.writeTokens(w, http.StatusOK, wantsCookies(r), wb.auth.GenerateAccessToken(userId, scope, role), refreshToken)
+}
@@ -0,0 +1,202 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+)
+
+func Test_LoginLink(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid": {
+			reqBody:        `{"email":"ben@wordbubble.com","scope":"wordbubble:push"}`,
+			respBody:       fmt.Sprintln(`{"message":"if an account uses this email, a login link has been sent"}`),
+			respStatusCode: http.StatusAccepted,
+			reqMethod:      http.MethodPost,
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+			},
+			magicLinkService: &TestMagicLinkService{},
+		},
+		"valid, unknown email gets the same response": {
+			reqBody:        `{"email":"nobody@wordbubble.com"}`,
+			respBody:       fmt.Sprintln(`{"message":"if an account uses this email, a login link has been sent"}`),
+			respStatusCode: http.StatusAccepted,
+			reqMethod:      http.MethodPost,
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserError: resp.ErrUnknownUser,
+			},
+			magicLinkService: &TestMagicLinkService{},
+		},
+		"invalid, too many links": {
+			reqBody:        `{"email":"ben@wordbubble.com"}`,
+			respBody:       structToJson(resp.ErrTooManyLoginLinks),
+			respStatusCode: resp.ErrTooManyLoginLinks.Code,
+			reqMethod:      http.MethodPost,
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+			},
+			magicLinkService: &TestMagicLinkService{
+				SendLoginLinkError: resp.ErrTooManyLoginLinks,
+			},
+		},
+		"invalid, email could not be sent": {
+			reqBody:        `{"email":"ben@wordbubble.com"}`,
+			respBody:       structToJson(resp.ErrCouldNotSendEmail),
+			respStatusCode: resp.ErrCouldNotSendEmail.Code,
+			reqMethod:      http.MethodPost,
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+			},
+			magicLinkService: &TestMagicLinkService{
+				SendLoginLinkError: resp.ErrCouldNotSendEmail,
+			},
+		},
+		"invalid, user service couldn't find user": {
+			reqBody:        `{"email":"ben@wordbubble.com"}`,
+			respBody:       structToJson(resp.ErrSQLMappingError),
+			respStatusCode: resp.ErrSQLMappingError.Code,
+			reqMethod:      http.MethodPost,
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserError: resp.ErrSQLMappingError,
+			},
+		},
+		"invalid, unknown scope": {
+			reqBody:        `{"email":"ben@wordbubble.com","scope":"wordbubble:push admin"}`,
+			respBody:       structToJson(resp.ErrInvalidScope),
+			respStatusCode: resp.ErrInvalidScope.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, email": {
+			reqBody:        `{"email":"ben"}`,
+			respBody:       structToJson(resp.ErrEmailIsNotValid),
+			respStatusCode: resp.ErrEmailIsNotValid.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, bad body": {
+			reqBody:        `howdy!`,
+			respBody:       structToJson(resp.ErrParseLoginLink),
+			respStatusCode: resp.ErrParseLoginLink.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.LoginLink
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_LoginLinkCallback(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid": {
+			reqPath:        "/v1/login/link/callback?token=link.100060.signature",
+			respBody:       fmt.Sprintln(`{"access_token":"test.access.token","refresh_token":"test.refresh.token"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodGet,
+			magicLinkService: &TestMagicLinkService{
+				RedeemLoginLinkUserId: 2,
+				RedeemLoginLinkScope:  "wordbubble:push",
+			},
+			mfaService: &TestMfaService{},
+			authService: &TestAuthService{
+				GenerateRefreshTokenString: "test.refresh.token",
+				GenerateAccessTokenString:  "test.access.token",
+			},
+		},
+		"valid, tokens set as cookies": {
+			reqPath:        "/v1/login/link/callback?token=link.100060.signature",
+			reqHeader:      http.Header{"X-Session-Mode": []string{"cookie"}},
+			respBody:       fmt.Sprintln(`{"expires_in":30}`),
+			respStatusCode: http.StatusOK,
+			respCookies:    []string{"wb_access", "wb_refresh", "wb_csrf"},
+			reqMethod:      http.MethodGet,
+			magicLinkService: &TestMagicLinkService{
+				RedeemLoginLinkUserId: 2,
+			},
+			mfaService: &TestMfaService{},
+			authService: &TestAuthService{
+				GenerateRefreshTokenString: "test.refresh.token",
+				GenerateAccessTokenString:  "test.access.token",
+			},
+		},
+		"valid, mfa enabled": {
+			reqPath:        "/v1/login/link/callback?token=link.100060.signature",
+			respBody:       fmt.Sprintln(`{"mfa_token":"test.mfa.token","expires_in":300}`),
+			respStatusCode: http.StatusAccepted,
+			reqMethod:      http.MethodGet,
+			magicLinkService: &TestMagicLinkService{
+				RedeemLoginLinkUserId: 2,
+			},
+			mfaService: &TestMfaService{
+				MfaEnabledBool: true,
+				CreateChallengeResponse: &resp.MfaChallengeResponse{
+					MfaToken:  "test.mfa.token",
+					ExpiresIn: 300,
+				},
+			},
+		},
+		"invalid, mfa service couldn't check mfa": {
+			reqPath:        "/v1/login/link/callback?token=link.100060.signature",
+			respBody:       structToJson(resp.ErrCouldNotCheckMfa),
+			respStatusCode: resp.ErrCouldNotCheckMfa.Code,
+			reqMethod:      http.MethodGet,
+			magicLinkService: &TestMagicLinkService{
+				RedeemLoginLinkUserId: 2,
+			},
+			mfaService: &TestMfaService{
+				MfaEnabledError: resp.ErrCouldNotCheckMfa,
+			},
+		},
+		"invalid, auth service couldn't store a refresh token": {
+			reqPath:        "/v1/login/link/callback?token=link.100060.signature",
+			respBody:       structToJson(resp.ErrCouldNotStoreRefreshToken),
+			respStatusCode: resp.ErrCouldNotStoreRefreshToken.Code,
+			reqMethod:      http.MethodGet,
+			magicLinkService: &TestMagicLinkService{
+				RedeemLoginLinkUserId: 2,
+			},
+			mfaService: &TestMfaService{},
+			authService: &TestAuthService{
+				GenerateRefreshTokenError: resp.ErrCouldNotStoreRefreshToken,
+			},
+		},
+		"invalid, link": {
+			reqPath:        "/v1/login/link/callback?token=link.100060.signature",
+			respBody:       structToJson(resp.ErrInvalidLoginLink),
+			respStatusCode: resp.ErrInvalidLoginLink.Code,
+			reqMethod:      http.MethodGet,
+			magicLinkService: &TestMagicLinkService{
+				RedeemLoginLinkError: resp.ErrInvalidLoginLink,
+			},
+		},
+		"invalid, no token": {
+			reqPath:        "/v1/login/link/callback",
+			respBody:       structToJson(resp.ErrNoLoginLinkToken),
+			respStatusCode: resp.ErrNoLoginLinkToken.Code,
+			reqMethod:      http.MethodGet,
+		},
+		"invalid, POST http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodPost,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.LoginLinkCallback
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bchadwic/wordbubble/internal/service/auth"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// Logout revokes the refresh token passed, along with the tokens it was rotated from
+// @Summary     Logout of api.wordbubble.io
+// @Description Logout of api.wordbubble.io by revoking the refresh token used on this device.
+// @Description A browser session can send an empty body, the refresh token cookie is revoked instead and the session cookies are cleared,
+// @Description which needs the wb_csrf cookie sent back in the X-CSRF-Token header
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       Token body     req.RefreshTokenRequest false "Refresh token that will be revoked"
+// @Success     200   {object} resp.LogoutResponse
+// @Failure     400   {object} resp.StatusBadRequest          "resp.ErrParseRefreshToken"
+// @Failure     401   {object} resp.StatusUnauthorized        "resp.ErrTokenIsExpired, resp.ErrSessionIsExpired, resp.ErrCouldNotValidateRefreshToken, resp.ErrRefreshTokenReused"
+// @Failure     403   {object} resp.StatusForbidden           "resp.ErrInvalidCsrfToken"
+// @Failure     405   {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500   {object} resp.StatusInternalServerError "resp.ErrCouldNotRevokeRefreshToken"
+// @Router      /logout [post]
+func (wb *app) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	reqBody, fromCookie, err := refreshTokenFromRequest(r)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	token, err := auth.RefreshTokenFromTokenString(wb.keys, reqBody.Token)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	if err = wb.auth.RevokeRefreshToken(token); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	if fromCookie {
+		clearSessionCookies(w)
+	}
+
+	resp := &resp.LogoutResponse{
+		Message: "you have been logged out",
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// LogoutAll revokes every refresh token for the user of the access token, along with the access token itself
+// @Summary     Logout of api.wordbubble.io everywhere
+// @Description Logout of api.wordbubble.io on every device by revoking all of a user's refresh tokens and the access token used,
+// @Description the session cookies of a browser session are cleared
+// @Tags        auth
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Success     200 {object} resp.LogoutResponse
+// @Failure     401 {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403 {object} resp.StatusForbidden           "resp.ErrInsufficientScope, resp.ErrInvalidCsrfToken"
+// @Failure     405 {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500 {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotRevokeRefreshToken, resp.ErrCouldNotRevokeAccessToken"
+// @Router      /logout/all [post]
+func (wb *app) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	if err = wb.auth.RevokeAllRefreshTokens(principal.UserId); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	if err = wb.auth.RevokeAccessToken(principal); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	clearSessionCookies(w)
+
+	resp := &resp.LogoutResponse{
+		Message: "you have been logged out everywhere",
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,218 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// Totp enrolls a totp secret on POST, and disables two-factor authentication on DELETE.
+// Both are managed with an access token, an api key can't turn off a second factor
+func (wb *app) Totp(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		wb.enrollTotp(w, r)
+	case http.MethodDelete:
+		wb.disableTotp(w, r)
+	default:
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+	}
+}
+
+// enrollTotp creates a totp secret for the user to add to an authenticator app
+// @Summary     Enroll a totp secret
+// @Description Create a totp secret and its provisioning uri, which can be shown as a QR code.
+// @Description The secret isn't required at login until it's confirmed with a code at /mfa/totp/confirm
+// @Tags        mfa
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Success     201 {object} resp.EnrollTotpResponse
+// @Failure     400 {object} resp.StatusBadRequest          "resp.ErrUnknownUser"
+// @Failure     401 {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403 {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     405 {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     409 {object} resp.StatusConflict            "resp.ErrMfaAlreadyEnabled"
+// @Failure     500 {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrSQLMappingError, resp.ErrCouldNotStoreTotpSecret"
+// @Router      /mfa/totp [post]
+func (wb *app) enrollTotp(w http.ResponseWriter, r *http.Request) {
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	user, err := wb.users.RetrieveUserById(principal.UserId)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp, err := wb.mfa.EnrollTotp(principal.UserId, user.Username)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// disableTotp turns off two-factor authentication for the user
+// @Summary     Disable two-factor authentication
+// @Description Remove the user's totp secret and recovery codes, a current code or an unused recovery code is required
+// @Tags        mfa
+// @Accept      json
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Param       Code body     req.MfaCodeRequest true "Code from an authenticator app, or a recovery code"
+// @Success     200  {object} resp.DisableMfaResponse
+// @Failure     400  {object} resp.StatusBadRequest          "resp.ErrParseMfaCode, resp.ErrMfaNotEnrolled"
+// @Failure     401  {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked, resp.ErrInvalidMfaCode"
+// @Failure     403  {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     405  {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500  {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotCheckMfa, resp.ErrCouldNotDisableMfa"
+// @Router      /mfa/totp [delete]
+func (wb *app) disableTotp(w http.ResponseWriter, r *http.Request) {
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	var reqBody req.MfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		wb.errorResponse(resp.ErrParseMfaCode, w)
+		return
+	}
+
+	if err = wb.mfa.DisableTotp(principal.UserId, reqBody.Code); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp := &resp.DisableMfaResponse{
+		Message: "two-factor authentication has been disabled",
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ConfirmTotp confirms the user's totp secret, after which a code is required at login
+// @Summary     Confirm a totp secret
+// @Description Confirm the enrolled totp secret with a code from an authenticator app, which enables two-factor authentication.
+// @Description Recovery codes are only returned in this response, each one can be used once in place of a code
+// @Tags        mfa
+// @Accept      json
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Param       Code body     req.MfaCodeRequest true "Code from an authenticator app"
+// @Success     200  {object} resp.RecoveryCodesResponse
+// @Failure     400  {object} resp.StatusBadRequest          "resp.ErrParseMfaCode, resp.ErrMfaNotEnrolled"
+// @Failure     401  {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked, resp.ErrInvalidMfaCode"
+// @Failure     403  {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     405  {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     409  {object} resp.StatusConflict            "resp.ErrMfaAlreadyEnabled"
+// @Failure     500  {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotCheckMfa, resp.ErrCouldNotStoreRecoveryCodes"
+// @Router      /mfa/totp/confirm [post]
+func (wb *app) ConfirmTotp(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	var reqBody req.MfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		wb.errorResponse(resp.ErrParseMfaCode, w)
+		return
+	}
+
+	resp, err := wb.mfa.ConfirmTotp(principal.UserId, reqBody.Code)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// LoginMfa is used to finish logging in when two-factor authentication is enabled
+// @Summary     Finish logging in with a second factor
+// @Description Exchange the mfa token returned from /login, along with a code from an authenticator app or a recovery code,
+// @Description for the access and refresh tokens. An mfa token can only be used once, and is rejected after too many wrong codes.
+// @Description Wrong codes are counted along with failed logins, so the account is locked after too many
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       Code           body     req.LoginMfaRequest            true  "Mfa token from /login and a code"
+// @Param       X-Session-Mode header   string                         false "Send cookie to receive the tokens as HttpOnly cookies, resp.CookieSessionResponse is returned instead"
+// @Success     200            {object} resp.TokenResponse             "Valid access and refresh tokens for user"
+// @Failure     400            {object} resp.StatusBadRequest          "resp.ErrParseMfaToken, resp.ErrMfaNotEnrolled"
+// @Failure     401            {object} resp.StatusUnauthorized        "resp.ErrInvalidMfaToken, resp.ErrInvalidMfaCode"
+// @Failure     405            {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     423            {object} resp.StatusLocked              "resp.ErrAccountLocked"
+// @Failure     429            {object} resp.StatusTooManyRequests     "resp.ErrTooManyLoginAttempts"
+// @Failure     500            {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckMfa, resp.ErrCouldNotCheckLoginAttempts, resp.ErrCouldNotRecordLoginAttempt, resp.ErrCouldNotRetrieveRole, resp.ErrCouldNotStoreRefreshToken"
+// @Router      /login/mfa [post]
+func (wb *app) LoginMfa(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	var reqBody req.LoginMfaRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil || reqBody.MfaToken == "" {
+		wb.errorResponse(resp.ErrParseMfaToken, w)
+		return
+	}
+
+	userId, err := wb.mfa.ChallengeUser(reqBody.MfaToken)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	// wrong codes count towards the same lockout as wrong passwords, so new mfa tokens don't get more guesses
+	ip := wb.deviceFromRequest(r).IPAddress
+	if err = wb.lockout.CheckLogin(userId, "", ip); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	_, scope, err := wb.mfa.VerifyChallenge(reqBody.MfaToken, reqBody.Code)
+	if err != nil {
+		if err == resp.ErrInvalidMfaCode {
+			if lockoutErr := wb.lockout.LoginFailed(userId, "", ip); lockoutErr != nil {
+				err = lockoutErr
+			}
+		}
+		wb.errorResponse(err, w)
+		return
+	}
+
+	if err = wb.lockout.LoginSucceeded(userId, ip); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	role, err := wb.roles.Role(userId)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	refreshToken, err := wb.auth.GenerateRefreshToken(userId, scope, reqBody.RememberMe, wb.deviceFromRequest(r))
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	wb.writeTokens(w, http.StatusOK, wantsCookies(r), wb.auth.GenerateAccessToken(userId, scope, role), refreshToken)
+}
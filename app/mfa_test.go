@@ -0,0 +1,283 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+func Test_Totp(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid, enroll": {
+			respBody:       fmt.Sprintln(`{"secret":"GEZDGNBVGY3TQOJQ","provisioning_uri":"otpauth://totp/wordbubble:ben?secret=GEZDGNBVGY3TQOJQ"}`),
+			respStatusCode: http.StatusCreated,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			userService: &TestUserService{
+				RetrieveUserByIdUser: &model.User{Id: 2, Username: "ben"},
+			},
+			mfaService: &TestMfaService{
+				EnrollTotpResponse: &resp.EnrollTotpResponse{Secret: "GEZDGNBVGY3TQOJQ", ProvisioningUri: "otpauth://totp/wordbubble:ben?secret=GEZDGNBVGY3TQOJQ"},
+			},
+		},
+		"invalid, enroll when already enabled": {
+			respBody:       structToJson(resp.ErrMfaAlreadyEnabled),
+			respStatusCode: resp.ErrMfaAlreadyEnabled.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			userService: &TestUserService{
+				RetrieveUserByIdUser: &model.User{Id: 2, Username: "ben"},
+			},
+			mfaService: &TestMfaService{
+				EnrollTotpError: resp.ErrMfaAlreadyEnabled,
+			},
+		},
+		"invalid, enroll for a user that was removed": {
+			respBody:       structToJson(resp.ErrUnknownUser),
+			respStatusCode: resp.ErrUnknownUser.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			userService: &TestUserService{
+				RetrieveUserByIdError: resp.ErrUnknownUser,
+			},
+		},
+		"invalid, enroll without the account scope": {
+			respBody:       structToJson(resp.ErrInsufficientScope),
+			respStatusCode: resp.ErrInsufficientScope.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("wordbubble:push")}},
+			authService:    &TestAuthService{},
+		},
+		"invalid, enroll with an api key": {
+			respBody:       structToJson(resp.ErrUnauthorized),
+			respStatusCode: resp.ErrUnauthorized.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"ApiKey wb_2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"}},
+		},
+		"valid, disable": {
+			reqBody:        `{"code":"287082"}`,
+			respBody:       fmt.Sprintln(`{"message":"two-factor authentication has been disabled"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodDelete,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			mfaService:     &TestMfaService{},
+		},
+		"invalid, disable with a wrong code": {
+			reqBody:        `{"code":"000000"}`,
+			respBody:       structToJson(resp.ErrInvalidMfaCode),
+			respStatusCode: resp.ErrInvalidMfaCode.Code,
+			reqMethod:      http.MethodDelete,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			mfaService: &TestMfaService{
+				DisableTotpError: resp.ErrInvalidMfaCode,
+			},
+		},
+		"invalid, disable with no body": {
+			reqBody:        ``,
+			respBody:       structToJson(resp.ErrParseMfaCode),
+			respStatusCode: resp.ErrParseMfaCode.Code,
+			reqMethod:      http.MethodDelete,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken(util.AllScopes())}},
+			authService:    &TestAuthService{},
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.RequireToken(util.ScopeAccountManage, tcase.testApp.Totp)
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_ConfirmTotp(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid": {
+			reqBody:        `{"code":"287082"}`,
+			respBody:       fmt.Sprintln(`{"recovery_codes":["4f1a9-c2e07-8b3d5-17f6a","09e2c-d41b7-a6f30-5c8e1"]}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			mfaService: &TestMfaService{
+				ConfirmTotpResponse: &resp.RecoveryCodesResponse{RecoveryCodes: []string{"4f1a9-c2e07-8b3d5-17f6a", "09e2c-d41b7-a6f30-5c8e1"}},
+			},
+		},
+		"invalid, not enrolled": {
+			reqBody:        `{"code":"287082"}`,
+			respBody:       structToJson(resp.ErrMfaNotEnrolled),
+			respStatusCode: resp.ErrMfaNotEnrolled.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			mfaService: &TestMfaService{
+				ConfirmTotpError: resp.ErrMfaNotEnrolled,
+			},
+		},
+		"invalid, bad body": {
+			reqBody:        `howdy!`,
+			respBody:       structToJson(resp.ErrParseMfaCode),
+			respStatusCode: resp.ErrParseMfaCode.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+		},
+		"invalid, no authorization": {
+			reqBody:        `{"code":"287082"}`,
+			respBody:       structToJson(resp.ErrUnauthorized),
+			respStatusCode: resp.ErrUnauthorized.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{},
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken(util.AllScopes())}},
+			authService:    &TestAuthService{},
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.RequireToken(util.ScopeAccountManage, tcase.testApp.ConfirmTotp)
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_LoginMfa(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid": {
+			reqBody:        `{"mfa_token":"test.mfa.token","code":"287082"}`,
+			respBody:       fmt.Sprintln(`{"access_token":"test.access.token","refresh_token":"test.refresh.token"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{},
+			lockoutService: &TestLockoutService{},
+			mfaService: &TestMfaService{
+				ChallengeUserUserId:   2,
+				VerifyChallengeUserId: 2,
+				VerifyChallengeScope:  "wordbubble:push",
+			},
+			authService: &TestAuthService{
+				GenerateRefreshTokenString: "test.refresh.token",
+				GenerateAccessTokenString:  "test.access.token",
+			},
+		},
+		"invalid, wrong code": {
+			reqBody:        `{"mfa_token":"test.mfa.token","code":"000000"}`,
+			respBody:       structToJson(resp.ErrInvalidMfaCode),
+			respStatusCode: resp.ErrInvalidMfaCode.Code,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{},
+			mfaService: &TestMfaService{
+				ChallengeUserUserId:  2,
+				VerifyChallengeError: resp.ErrInvalidMfaCode,
+			},
+		},
+		"invalid, wrong code couldn't be counted": {
+			reqBody:        `{"mfa_token":"test.mfa.token","code":"000000"}`,
+			respBody:       structToJson(resp.ErrCouldNotRecordLoginAttempt),
+			respStatusCode: resp.ErrCouldNotRecordLoginAttempt.Code,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{
+				LoginFailedError: resp.ErrCouldNotRecordLoginAttempt,
+			},
+			mfaService: &TestMfaService{
+				ChallengeUserUserId:  2,
+				VerifyChallengeError: resp.ErrInvalidMfaCode,
+			},
+		},
+		"invalid, account locked": {
+			reqBody:        `{"mfa_token":"test.mfa.token","code":"287082"}`,
+			respBody:       structToJson(resp.ErrAccountLocked),
+			respStatusCode: resp.ErrAccountLocked.Code,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{
+				CheckLoginError: resp.ErrAccountLocked,
+			},
+			mfaService: &TestMfaService{
+				ChallengeUserUserId:   2,
+				VerifyChallengeUserId: 2,
+			},
+		},
+		"invalid, expired mfa token": {
+			reqBody:        `{"mfa_token":"test.mfa.token","code":"287082"}`,
+			respBody:       structToJson(resp.ErrInvalidMfaToken),
+			respStatusCode: resp.ErrInvalidMfaToken.Code,
+			reqMethod:      http.MethodPost,
+			mfaService: &TestMfaService{
+				ChallengeUserError: resp.ErrInvalidMfaToken,
+			},
+		},
+		"invalid, lockout service couldn't reset attempts": {
+			reqBody:        `{"mfa_token":"test.mfa.token","code":"287082"}`,
+			respBody:       structToJson(resp.ErrCouldNotRecordLoginAttempt),
+			respStatusCode: resp.ErrCouldNotRecordLoginAttempt.Code,
+			reqMethod:      http.MethodPost,
+			lockoutService: &TestLockoutService{
+				LoginSucceededError: resp.ErrCouldNotRecordLoginAttempt,
+			},
+			mfaService: &TestMfaService{
+				ChallengeUserUserId:   2,
+				VerifyChallengeUserId: 2,
+			},
+		},
+		"invalid, auth service couldn't store a refresh token": {
+			reqBody:        `{"mfa_token":"test.mfa.token","code":"287082"}`,
+			respBody:       structToJson(resp.ErrCouldNotStoreRefreshToken),
+			respStatusCode: resp.ErrCouldNotStoreRefreshToken.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{},
+			lockoutService: &TestLockoutService{},
+			mfaService: &TestMfaService{
+				ChallengeUserUserId:   2,
+				VerifyChallengeUserId: 2,
+			},
+			authService: &TestAuthService{
+				GenerateRefreshTokenError: resp.ErrCouldNotStoreRefreshToken,
+			},
+		},
+		"invalid, missing mfa token": {
+			reqBody:        `{"code":"287082"}`,
+			respBody:       structToJson(resp.ErrParseMfaToken),
+			respStatusCode: resp.ErrParseMfaToken.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, bad body": {
+			reqBody:        `howdy!`,
+			respBody:       structToJson(resp.ErrParseMfaToken),
+			respStatusCode: resp.ErrParseMfaToken.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.LoginMfa
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
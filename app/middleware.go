@@ -0,0 +1,109 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// authorization schemes, compared case insensitively
+const (
+	schemeBearer = "bearer"
+	schemeApiKey = "apikey"
+)
+
+// RequireToken wraps a handler so it's only called for requests with a valid access token granted the scope required.
+// Api keys aren't accepted, so endpoints that manage an account can only be used after logging in.
+// The handler reads who sent the request with util.PrincipalFromContext
+func (wb *app) RequireToken(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return wb.requirePrincipal(scope, false, next)
+}
+
+// RequireTokenOrApiKey wraps a handler so it's only called for requests with either a valid access token
+// or a personal api key, which must have been granted the scope required.
+// The handler reads who sent the request with util.PrincipalFromContext
+func (wb *app) RequireTokenOrApiKey(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return wb.requirePrincipal(scope, true, next)
+}
+
+// RequirePermission wraps a handler so it's only called for requests with a valid access token granted the scope required,
+// issued to a user whose role has the permission required. Api keys aren't accepted, they're never issued with a role
+func (wb *app) RequirePermission(scope, permission string, next http.HandlerFunc) http.HandlerFunc {
+	return wb.requirePrincipal(scope, false, func(w http.ResponseWriter, r *http.Request) {
+		principal, err := util.PrincipalFromContext(r.Context())
+		if err == nil {
+			err = util.Authorize(principal, permission)
+		}
+		if err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (wb *app) requirePrincipal(scope string, allowApiKey bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := wb.authenticate(r, scope, allowApiKey)
+		if err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+		next(w, r.WithContext(util.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// authenticate finds who sent a request from the authorization header, which must have been granted the scope required.
+// Without the header, the access token cookie of a browser session is used, which needs the csrf token to change state
+// *model.Principal is who sent the request, can be nil.
+// error could be (401) resp.ErrUnauthorized, (401) resp.ErrMalformedToken, (401) resp.ErrInvalidTokenSignature,
+// (401) resp.ErrTokenIsExpired, (401) resp.ErrTokenIsNotYetValid, (401) resp.ErrInvalidTokenType, (401) resp.ErrInvalidTokenClaims,
+// (401) resp.ErrTokenIsRevoked, (401) resp.ErrInvalidApiKey, (403) resp.ErrInsufficientScope, (403) resp.ErrInvalidCsrfToken,
+// (500) resp.ErrCouldNotCheckDenylist or nil.
+func (wb *app) authenticate(r *http.Request, scope string, allowApiKey bool) (*model.Principal, error) {
+	authorization := r.Header.Get("authorization")
+	if cookie, err := r.Cookie(accessTokenCookie); authorization == "" && err == nil && cookie.Value != "" {
+		if !isSafeMethod(r.Method) {
+			if err := checkCsrf(r); err != nil {
+				return nil, err
+			}
+		}
+		authorization = schemeBearer + " " + cookie.Value
+	}
+
+	var principal *model.Principal
+	switch scheme, credentials := splitAuthorization(authorization); {
+	case credentials == "":
+		return nil, resp.ErrUnauthorized
+	case scheme == schemeBearer:
+		claims, err := wb.auth.ValidateAccessToken(credentials)
+		if err != nil {
+			return nil, err
+		}
+		principal = &model.Principal{UserId: claims.UserId, Scope: claims.Scope, TokenId: claims.Id, ExpiresAt: claims.ExpiresAt, Role: claims.Role}
+	case scheme == schemeApiKey && allowApiKey:
+		userId, granted, err := wb.apiKeys.ValidateApiKey(credentials)
+		if err != nil {
+			return nil, err
+		}
+		principal = &model.Principal{UserId: userId, Scope: granted, ApiKey: true}
+	default:
+		return nil, resp.ErrUnauthorized
+	}
+	if !util.HasScope(principal.Scope, scope) {
+		return nil, resp.ErrInsufficientScope
+	}
+	return principal, nil
+}
+
+// splitAuthorization splits an authorization header into its lowercased scheme and its credentials
+func splitAuthorization(authorization string) (string, string) {
+	scheme, credentials, found := strings.Cut(strings.TrimSpace(authorization), " ")
+	if !found {
+		return "", ""
+	}
+	return strings.ToLower(scheme), strings.TrimSpace(credentials)
+}
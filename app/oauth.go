@@ -0,0 +1,277 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/bchadwic/wordbubble/internal/service/auth"
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// grant types supported by /oauth/token
+const (
+	grantTypeAuthorizationCode = "authorization_code"
+	grantTypeRefreshToken      = "refresh_token"
+)
+
+// RegisterClient registers a third party app that users can authorize without sharing their password
+// @Summary     Register an oauth client
+// @Description Register a third party app, users are sent to /oauth/authorize with the client id returned.
+// @Description Clients are public, so every authorization must use PKCE
+// @Tags        oauth
+// @Accept      json
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Param       Client body     req.RegisterClientRequest true "Name and redirect uri of the app"
+// @Success     201    {object} resp.RegisterClientResponse
+// @Failure     400    {object} resp.StatusBadRequest          "resp.ErrParseClient, resp.ErrClientNameIsMissing, resp.ErrClientNameIsTooLong, resp.ErrInvalidRedirectUri"
+// @Failure     401    {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403    {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     405    {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500    {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotStoreClient"
+// @Router      /oauth/clients [post]
+func (wb *app) RegisterClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	var reqBody req.RegisterClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		wb.errorResponse(resp.ErrParseClient, w)
+		return
+	}
+
+	resp, err := wb.oauth.RegisterClient(principal.UserId, reqBody.Name, reqBody.RedirectUri)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Authorize shows what a third party app is asking for on GET, and records whether the user approved it on POST
+func (wb *app) Authorize(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		wb.consent(w, r)
+	case http.MethodPost:
+		wb.RequireToken(util.ScopeAccountManage, wb.approve)(w, r)
+	default:
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+	}
+}
+
+// consent validates the request a third party app sent the user with
+// @Summary     Review an oauth authorization
+// @Description Validate the request a third party app sent the user with, returning what should be shown to the user before they approve it
+// @Tags        oauth
+// @Produce     json
+// @Param       response_type         query    string true  "Must be code"
+// @Param       client_id             query    string true  "Id of the oauth client"
+// @Param       redirect_uri          query    string true  "Must match the redirect uri the client registered"
+// @Param       scope                 query    string false "Scope requested, wordbubble:push and wordbubble:read when empty"
+// @Param       state                 query    string false "Returned to the app untouched"
+// @Param       code_challenge        query    string true  "S256 PKCE code challenge"
+// @Param       code_challenge_method query    string true  "Must be S256"
+// @Success     200                   {object} resp.ConsentResponse
+// @Failure     400                   {object} resp.StatusBadRequest       "resp.ErrUnsupportedResponseType, resp.ErrUnknownClient, resp.ErrRedirectUriMismatch, resp.ErrInvalidCodeChallenge, resp.ErrInvalidScope"
+// @Failure     405                   {object} resp.StatusMethodNotAllowed "resp.ErrInvalidHttpMethod"
+// @Router      /oauth/authorize [get]
+func (wb *app) consent(w http.ResponseWriter, r *http.Request) {
+	resp, err := wb.oauth.ValidateAuthorizeRequest(authorizeRequestFromQuery(r))
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// approve records whether the user approved a third party app, creating an authorization code when they did
+// @Summary     Approve an oauth authorization
+// @Description Approve or deny a third party app's access to the user's account, the user is then sent to the redirect uri returned.
+// @Description The app is granted no more than the scope of the access token used to approve it
+// @Tags        oauth
+// @Accept      json
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Param       response_type         query    string             true  "Must be code"
+// @Param       client_id             query    string             true  "Id of the oauth client"
+// @Param       redirect_uri          query    string             true  "Must match the redirect uri the client registered"
+// @Param       scope                 query    string             false "Scope requested, wordbubble:push and wordbubble:read when empty"
+// @Param       state                 query    string             false "Returned to the app untouched"
+// @Param       code_challenge        query    string             true  "S256 PKCE code challenge"
+// @Param       code_challenge_method query    string             true  "Must be S256"
+// @Param       Consent               body     req.ConsentRequest true  "Whether the user approved the app"
+// @Success     200                   {object} resp.AuthorizeResponse
+// @Failure     400                   {object} resp.StatusBadRequest          "resp.ErrUnsupportedResponseType, resp.ErrUnknownClient, resp.ErrRedirectUriMismatch, resp.ErrInvalidCodeChallenge, resp.ErrInvalidScope, resp.ErrParseConsent"
+// @Failure     401                   {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403                   {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     405                   {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500                   {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotStoreAuthorizationCode"
+// @Router      /oauth/authorize [post]
+func (wb *app) approve(w http.ResponseWriter, r *http.Request) {
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	request := authorizeRequestFromQuery(r)
+	consent, err := wb.oauth.ValidateAuthorizeRequest(request)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	var reqBody req.ConsentRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		wb.errorResponse(resp.ErrParseConsent, w)
+		return
+	}
+
+	params := url.Values{}
+	if request.State != "" {
+		params.Set("state", request.State)
+	}
+	if !reqBody.Approve {
+		params.Set("error", "access_denied")
+	} else {
+		scope, err := util.NarrowScope(principal.Scope, consent.Scope)
+		if err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+		code, err := wb.oauth.CreateAuthorizationCode(principal.UserId, consent.ClientId, consent.RedirectUri, scope, request.CodeChallenge)
+		if err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+		params.Set("code", code)
+	}
+
+	resp := &resp.AuthorizeResponse{
+		RedirectUri: redirectWith(consent.RedirectUri, params),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// OAuthToken issues tokens to a third party app, either by redeeming an authorization code or by rotating a refresh token
+// @Summary     Token to api.wordbubble.io for third party apps
+// @Description Exchange an authorization code and its PKCE code verifier for tokens, or rotate a refresh token, as defined by RFC 6749.
+// @Description The tokens work on every endpoint that accepts an access token, within the scope the user approved.
+// @Description A refresh token can only be rotated by the client it was issued to
+// @Tags        oauth
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       grant_type    formData string true  "authorization_code or refresh_token"
+// @Param       client_id     formData string true  "Id of the oauth client"
+// @Param       code          formData string false "Authorization code, required for authorization_code"
+// @Param       redirect_uri  formData string false "Redirect uri the code was sent to, required for authorization_code"
+// @Param       code_verifier formData string false "PKCE code verifier, required for authorization_code"
+// @Param       refresh_token formData string false "Refresh token, required for refresh_token"
+// @Param       scope         formData string false "Narrows the scope of the access token, only for refresh_token"
+// @Success     200           {object} resp.OAuthTokenResponse
+// @Failure     400           {object} resp.StatusBadRequest          "resp.ErrUnsupportedGrantType, resp.ErrInvalidAuthorizationCode, resp.ErrInvalidCodeVerifier, resp.ErrInvalidScope"
+// @Failure     401           {object} resp.StatusUnauthorized        "resp.ErrInvalidClient, resp.ErrTokenIsExpired, resp.ErrSessionIsExpired, resp.ErrCouldNotValidateRefreshToken, resp.ErrRefreshTokenReused"
+// @Failure     405           {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500           {object} resp.StatusInternalServerError "resp.ErrCouldNotUseAuthorizationCode, resp.ErrCouldNotStoreRefreshToken, resp.ErrCouldNotRevokeRefreshToken"
+// @Router      /oauth/token [post]
+func (wb *app) OAuthToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	var userId int64
+	var scope, refreshToken string
+	var err error
+	switch r.PostFormValue("grant_type") {
+	case grantTypeAuthorizationCode:
+		userId, scope, err = wb.oauth.ExchangeAuthorizationCode(
+			r.PostFormValue("client_id"), r.PostFormValue("code"), r.PostFormValue("redirect_uri"), r.PostFormValue("code_verifier"),
+		)
+		if err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+		if refreshToken, err = wb.auth.GenerateClientRefreshToken(userId, r.PostFormValue("client_id"), scope, wb.deviceFromRequest(r)); err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+	case grantTypeRefreshToken:
+		token, err := auth.RefreshTokenFromTokenString(wb.keys, r.PostFormValue("refresh_token"))
+		if err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+		// a refresh token is bound to the client it was issued to, the user's own sessions are never refreshed here
+		if clientId := r.PostFormValue("client_id"); clientId == "" || clientId != token.ClientId() {
+			wb.errorResponse(resp.ErrInvalidClient, w)
+			return
+		}
+		if scope, err = util.NarrowScope(token.Scope(), r.PostFormValue("scope")); err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+		if refreshToken, err = wb.auth.RotateRefreshToken(token, wb.deviceFromRequest(r)); err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+		userId = token.UserId()
+	default:
+		wb.errorResponse(resp.ErrUnsupportedGrantType, w)
+		return
+	}
+
+	resp := &resp.OAuthTokenResponse{
+		AccessToken:  wb.auth.GenerateAccessToken(userId, scope, util.RoleUser), // clients act for a user, never with the user's role
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(wb.accessTokenLifetime.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        scope,
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// authorizeRequestFromQuery reads the request a third party app sent a user to /oauth/authorize with
+func authorizeRequestFromQuery(r *http.Request) *req.AuthorizeRequest {
+	query := r.URL.Query()
+	return &req.AuthorizeRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientId:            query.Get("client_id"),
+		RedirectUri:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+}
+
+// redirectWith adds the params to a redirect uri, keeping any query the client registered it with
+func redirectWith(redirectUri string, params url.Values) string {
+	u, err := url.Parse(redirectUri)
+	if err != nil { // redirect uris are validated when a client is registered
+		return redirectUri
+	}
+	query := u.Query()
+	for key := range params {
+		query.Set(key, params.Get(key))
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,317 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAuthorizePath = "/v1/oauth/authorize?response_type=code&client_id=desktop&redirect_uri=https%3A%2F%2Fapp.example.com%2Fcallback&state=af0ifjsldkj&code_challenge=E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM&code_challenge_method=S256"
+
+var testConsent = &resp.ConsentResponse{
+	ClientId:    "desktop",
+	ClientName:  "wordbubble for desktop",
+	RedirectUri: "https://app.example.com/callback",
+	Scope:       "wordbubble:push wordbubble:read",
+}
+
+func Test_RegisterClient(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid": {
+			reqBody:        `{"name":"wordbubble for desktop","redirect_uri":"https://app.example.com/callback"}`,
+			respBody:       fmt.Sprintln(`{"client_id":"desktop","name":"wordbubble for desktop","redirect_uri":"https://app.example.com/callback","created_at":100}`),
+			respStatusCode: http.StatusCreated,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("account:manage")}},
+			authService:    &TestAuthService{},
+			oauthService: &TestOAuthService{
+				RegisterClientResponse: &resp.RegisterClientResponse{ClientId: "desktop", Name: "wordbubble for desktop", RedirectUri: "https://app.example.com/callback", CreatedAt: 100},
+			},
+		},
+		"invalid, redirect uri": {
+			reqBody:        `{"name":"wordbubble for desktop","redirect_uri":"http://app.example.com/callback"}`,
+			respBody:       structToJson(resp.ErrInvalidRedirectUri),
+			respStatusCode: resp.ErrInvalidRedirectUri.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("account:manage")}},
+			authService:    &TestAuthService{},
+			oauthService: &TestOAuthService{
+				RegisterClientError: resp.ErrInvalidRedirectUri,
+			},
+		},
+		"invalid, no body": {
+			reqBody:        ``,
+			respBody:       structToJson(resp.ErrParseClient),
+			respStatusCode: resp.ErrParseClient.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("account:manage")}},
+			authService:    &TestAuthService{},
+		},
+		"invalid, token doesn't have the account scope": {
+			reqBody:        `{"name":"wordbubble for desktop","redirect_uri":"https://app.example.com/callback"}`,
+			respBody:       structToJson(resp.ErrInsufficientScope),
+			respStatusCode: resp.ErrInsufficientScope.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("wordbubble:push")}},
+			authService:    &TestAuthService{},
+		},
+		"invalid, no token": {
+			respBody:       structToJson(resp.ErrUnauthorized),
+			respStatusCode: resp.ErrUnauthorized.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken(util.AllScopes())}},
+			authService:    &TestAuthService{},
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.RequireToken(util.ScopeAccountManage, tcase.testApp.RegisterClient)
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_Authorize(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid, consent": {
+			reqPath:        testAuthorizePath,
+			respBody:       structToJson(testConsent),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodGet,
+			oauthService: &TestOAuthService{
+				ValidateAuthorizeRequestConsent: testConsent,
+			},
+		},
+		"invalid, consent for an unknown client": {
+			reqPath:        testAuthorizePath,
+			respBody:       structToJson(resp.ErrUnknownClient),
+			respStatusCode: resp.ErrUnknownClient.Code,
+			reqMethod:      http.MethodGet,
+			oauthService: &TestOAuthService{
+				ValidateAuthorizeRequestError: resp.ErrUnknownClient,
+			},
+		},
+		"valid, approved": {
+			reqPath:        testAuthorizePath,
+			reqBody:        `{"approve":true}`,
+			respBody:       structToJson(&resp.AuthorizeResponse{RedirectUri: "https://app.example.com/callback?code=9a0364b9&state=af0ifjsldkj"}),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			oauthService: &TestOAuthService{
+				ValidateAuthorizeRequestConsent: testConsent,
+				CreateAuthorizationCodeString:   "9a0364b9",
+			},
+		},
+		"valid, denied": {
+			reqPath:        testAuthorizePath,
+			reqBody:        `{"approve":false}`,
+			respBody:       structToJson(&resp.AuthorizeResponse{RedirectUri: "https://app.example.com/callback?error=access_denied&state=af0ifjsldkj"}),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			oauthService: &TestOAuthService{
+				ValidateAuthorizeRequestConsent: testConsent,
+			},
+		},
+		"invalid, approved with a token that wasn't granted the scope requested": {
+			reqPath:        testAuthorizePath,
+			reqBody:        `{"approve":true}`,
+			respBody:       structToJson(resp.ErrInvalidScope),
+			respStatusCode: resp.ErrInvalidScope.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("account:manage wordbubble:push")}},
+			authService:    &TestAuthService{},
+			oauthService: &TestOAuthService{
+				ValidateAuthorizeRequestConsent: testConsent,
+			},
+		},
+		"invalid, approved with a token that doesn't have the account scope": {
+			reqPath:        testAuthorizePath,
+			reqBody:        `{"approve":true}`,
+			respBody:       structToJson(resp.ErrInsufficientScope),
+			respStatusCode: resp.ErrInsufficientScope.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("wordbubble:push wordbubble:read")}},
+			authService:    &TestAuthService{},
+		},
+		"invalid, approved without a token": {
+			reqPath:        testAuthorizePath,
+			reqBody:        `{"approve":true}`,
+			respBody:       structToJson(resp.ErrUnauthorized),
+			respStatusCode: resp.ErrUnauthorized.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, approved with no body": {
+			reqPath:        testAuthorizePath,
+			respBody:       structToJson(resp.ErrParseConsent),
+			respStatusCode: resp.ErrParseConsent.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			oauthService: &TestOAuthService{
+				ValidateAuthorizeRequestConsent: testConsent,
+			},
+		},
+		"invalid, database couldn't store the code": {
+			reqPath:        testAuthorizePath,
+			reqBody:        `{"approve":true}`,
+			respBody:       structToJson(resp.ErrCouldNotStoreAuthorizationCode),
+			respStatusCode: resp.ErrCouldNotStoreAuthorizationCode.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			oauthService: &TestOAuthService{
+				ValidateAuthorizeRequestConsent: testConsent,
+				CreateAuthorizationCodeError:    resp.ErrCouldNotStoreAuthorizationCode,
+			},
+		},
+		"invalid, DELETE http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodDelete,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.Authorize
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_OAuthToken(t *testing.T) {
+	form := http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}}
+	tests := map[string]TestCase{
+		"valid, authorization code": {
+			reqBody:        "grant_type=authorization_code&client_id=desktop&code=9a0364b9&redirect_uri=https%3A%2F%2Fapp.example.com%2Fcallback&code_verifier=dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk",
+			respBody:       fmt.Sprintln(`{"access_token":"aaa.bbb.ccc","token_type":"Bearer","expires_in":30,"refresh_token":"ddd.eee.fff","scope":"wordbubble:push"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			reqHeader:      form,
+			authService: &TestAuthService{
+				GenerateAccessTokenString:        "aaa.bbb.ccc",
+				GenerateClientRefreshTokenString: "ddd.eee.fff",
+			},
+			oauthService: &TestOAuthService{
+				ExchangeAuthorizationCodeUserId: 2,
+				ExchangeAuthorizationCodeScope:  "wordbubble:push",
+			},
+		},
+		"invalid, code verifier doesn't match": {
+			reqBody:        "grant_type=authorization_code&client_id=desktop&code=9a0364b9&redirect_uri=https%3A%2F%2Fapp.example.com%2Fcallback&code_verifier=wrong",
+			respBody:       structToJson(resp.ErrInvalidCodeVerifier),
+			respStatusCode: resp.ErrInvalidCodeVerifier.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      form,
+			oauthService: &TestOAuthService{
+				ExchangeAuthorizationCodeError: resp.ErrInvalidCodeVerifier,
+			},
+		},
+		"invalid, database couldn't store the refresh token": {
+			reqBody:        "grant_type=authorization_code&client_id=desktop&code=9a0364b9&redirect_uri=https%3A%2F%2Fapp.example.com%2Fcallback&code_verifier=dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk",
+			respBody:       structToJson(resp.ErrCouldNotStoreRefreshToken),
+			respStatusCode: resp.ErrCouldNotStoreRefreshToken.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      form,
+			authService: &TestAuthService{
+				GenerateClientRefreshTokenError: resp.ErrCouldNotStoreRefreshToken,
+			},
+			oauthService: &TestOAuthService{
+				ExchangeAuthorizationCodeUserId: 2,
+				ExchangeAuthorizationCodeScope:  "wordbubble:push",
+			},
+		},
+		"valid, refresh token": {
+			reqBody:        "grant_type=refresh_token&client_id=desktop&refresh_token=" + clientRefreshToken("desktop", "wordbubble:push wordbubble:read") + "&scope=wordbubble:read",
+			respBody:       fmt.Sprintln(`{"access_token":"aaa.bbb.ccc","token_type":"Bearer","expires_in":30,"refresh_token":"ddd.eee.fff","scope":"wordbubble:read"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			reqHeader:      form,
+			authService: &TestAuthService{
+				GenerateAccessTokenString: "aaa.bbb.ccc",
+				RotateRefreshTokenString:  "ddd.eee.fff",
+			},
+		},
+		"invalid, refresh token from a login": {
+			reqBody:        "grant_type=refresh_token&client_id=desktop&refresh_token=" + scopedRefreshToken("wordbubble:push"),
+			respBody:       structToJson(resp.ErrInvalidClient),
+			respStatusCode: resp.ErrInvalidClient.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      form,
+			authService:    &TestAuthService{},
+		},
+		"invalid, refresh token issued to another client": {
+			reqBody:        "grant_type=refresh_token&client_id=desktop&refresh_token=" + clientRefreshToken("mobile", "wordbubble:push"),
+			respBody:       structToJson(resp.ErrInvalidClient),
+			respStatusCode: resp.ErrInvalidClient.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      form,
+			authService:    &TestAuthService{},
+		},
+		"invalid, no client id": {
+			reqBody:        "grant_type=refresh_token&refresh_token=" + clientRefreshToken("desktop", "wordbubble:push"),
+			respBody:       structToJson(resp.ErrInvalidClient),
+			respStatusCode: resp.ErrInvalidClient.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      form,
+			authService:    &TestAuthService{},
+		},
+		"invalid, refresh token with a scope it wasn't granted": {
+			reqBody:        "grant_type=refresh_token&client_id=desktop&refresh_token=" + clientRefreshToken("desktop", "wordbubble:push") + "&scope=account:manage",
+			respBody:       structToJson(resp.ErrInvalidScope),
+			respStatusCode: resp.ErrInvalidScope.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      form,
+			authService:    &TestAuthService{},
+		},
+		"invalid, refresh token was already used": {
+			reqBody:        "grant_type=refresh_token&client_id=desktop&refresh_token=" + clientRefreshToken("desktop", "wordbubble:push"),
+			respBody:       structToJson(resp.ErrRefreshTokenReused),
+			respStatusCode: resp.ErrRefreshTokenReused.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      form,
+			authService: &TestAuthService{
+				RotateRefreshTokenError: resp.ErrRefreshTokenReused,
+			},
+		},
+		"invalid, grant type": {
+			reqBody:        "grant_type=password&username=ben&password=SomePassword_123",
+			respBody:       structToJson(resp.ErrUnsupportedGrantType),
+			respStatusCode: resp.ErrUnsupportedGrantType.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      form,
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.OAuthToken
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_RedirectWith(t *testing.T) {
+	params := map[string][]string{"code": {"9a0364b9"}}
+	assert.Equal(t, "https://app.example.com/callback?code=9a0364b9", redirectWith("https://app.example.com/callback", params))
+	assert.Equal(t, "https://app.example.com/callback?code=9a0364b9&from=wordbubble", redirectWith("https://app.example.com/callback?from=wordbubble", params))
+}
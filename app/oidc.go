@@ -0,0 +1,213 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// OidcLogin starts a login with the OpenID Connect identity provider
+// @Summary     Login with an identity provider
+// @Description Start a login with the identity provider, the user is sent to the url returned and comes back to /login/oidc/callback.
+// @Description The request body is optional, every scope is granted when it's not sent
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       Login body     req.OidcLoginRequest false "Scope the tokens are granted"
+// @Success     200   {object} resp.OidcAuthorizationResponse
+// @Failure     400   {object} resp.StatusBadRequest          "resp.ErrParseOidcLogin, resp.ErrInvalidScope"
+// @Failure     404   {object} resp.StatusNotFound            "resp.ErrOidcNotConfigured"
+// @Failure     405   {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500   {object} resp.StatusInternalServerError "resp.ErrCouldNotReachOidcProvider, resp.ErrCouldNotStoreOidcState"
+// @Router      /login/oidc [post]
+func (wb *app) OidcLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	var reqBody req.OidcLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil && err != io.EOF {
+		wb.errorResponse(resp.ErrParseOidcLogin, w)
+		return
+	}
+
+	scope, err := util.ValidScope(reqBody.Scope)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	authorizationUrl, err := wb.oidc.AuthorizationUrl(0, scope, reqBody.RememberMe)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&resp.OidcAuthorizationResponse{AuthorizationUrl: authorizationUrl})
+}
+
+// LinkOidcIdentity starts linking an identity at the OpenID Connect identity provider to the user
+// @Summary     Link an identity
+// @Description Start linking the user's identity at the identity provider, the user is sent to the url returned and comes back to /login/oidc/callback.
+// @Description Once linked, the user can login with the identity provider, even when it doesn't share a verified email
+// @Tags        auth
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Success     200 {object} resp.OidcAuthorizationResponse
+// @Failure     401 {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403 {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     404 {object} resp.StatusNotFound            "resp.ErrOidcNotConfigured"
+// @Failure     405 {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500 {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotReachOidcProvider, resp.ErrCouldNotStoreOidcState"
+// @Router      /oidc/link [post]
+func (wb *app) LinkOidcIdentity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	authorizationUrl, err := wb.oidc.AuthorizationUrl(principal.UserId, "", false)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&resp.OidcAuthorizationResponse{AuthorizationUrl: authorizationUrl})
+}
+
+// OidcCallback completes a login or a link with the OpenID Connect identity provider
+// @Summary     Complete a login with an identity provider
+// @Description The identity provider sends the user back here from /login/oidc or /oidc/link.
+// @Description A login returns the access and refresh token of the user the identity is linked to, an identity that isn't linked yet is linked to the user with its verified email when the user verified it too,
+// @Description and a user is created when no user has the email. When two-factor authentication is enabled, an mfa token is returned instead, which is exchanged along with a code at /login/mfa.
+// @Description Browsers can ask for the tokens to be set as cookies, every state changing request must then send the wb_csrf cookie back in the X-CSRF-Token header
+// @Tags        auth
+// @Produce     json
+// @Param       code           query    string                         false "Authorization code from the identity provider"
+// @Param       state          query    string                         false "State the login was started with"
+// @Param       error          query    string                         false "Error from the identity provider, when the user didn't login"
+// @Param       X-Session-Mode header   string                         false "Send cookie to receive the tokens as HttpOnly cookies, resp.CookieSessionResponse is returned instead"
+// @Success     200            {object} resp.TokenResponse             "Valid access and refresh tokens for user, or resp.IdentityLinkedResponse when an identity was linked"
+// @Success     202            {object} resp.MfaChallengeResponse      "Mfa token when two-factor authentication is enabled"
+// @Failure     400            {object} resp.StatusBadRequest          "resp.ErrNoOidcCode, resp.ErrUserWithEmailAlreadyExists"
+// @Failure     401            {object} resp.StatusUnauthorized        "resp.ErrOidcLoginDenied, resp.ErrInvalidOidcState, resp.ErrOidcCodeRejected, resp.ErrInvalidIdToken"
+// @Failure     403            {object} resp.StatusForbidden           "resp.ErrOidcEmailNotVerified, resp.ErrOidcUserNotVerified"
+// @Failure     404            {object} resp.StatusNotFound            "resp.ErrOidcNotConfigured"
+// @Failure     405            {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     409            {object} resp.StatusConflict            "resp.ErrIdentityAlreadyLinked"
+// @Failure     500            {object} resp.StatusInternalServerError "resp.ErrCouldNotUseOidcState, resp.ErrCouldNotReachOidcProvider, resp.ErrCouldNotCheckIdentity, resp.ErrCouldNotLinkIdentity, resp.ErrSQLMappingError, resp.ErrCouldNotDetermineUserExistence, resp.ErrCouldNotChooseUsername, resp.ErrCouldNotAddUser, resp.ErrCouldNotCheckMfa, resp.ErrCouldNotRetrieveRole, resp.ErrCouldNotStoreRefreshToken"
+// @Router      /login/oidc/callback [get]
+func (wb *app) OidcCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		wb.errorResponse(resp.ErrOidcLoginDenied, w)
+		return
+	}
+	code, state := query.Get("code"), query.Get("state")
+	if code == "" || state == "" {
+		wb.errorResponse(resp.ErrNoOidcCode, w)
+		return
+	}
+
+	login, err := wb.oidc.CompleteLogin(code, state)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	if login.LinkUserId != 0 { // the login was started by a user linking their identity, no tokens are issued
+		if err = wb.oidc.LinkIdentity(login.LinkUserId, &login.Identity); err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&resp.IdentityLinkedResponse{
+			Message: "identity has been linked, it can now be used to login",
+		})
+		return
+	}
+
+	userId, err := wb.federatedUser(&login.Identity)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	mfaEnabled, err := wb.mfa.MfaEnabled(userId)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	if mfaEnabled { // the identity provider is only the first factor
+		challenge, err := wb.mfa.CreateChallenge(userId, login.Scope)
+		if err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(challenge)
+		return
+	}
+
+	role, err := wb.roles.Role(userId)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	refreshToken, err := wb.auth.GenerateRefreshToken(userId, login.Scope, login.RememberMe, wb.deviceFromRequest(r))
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	wb.writeTokens(w, http.StatusOK, wantsCookies(r), wb.auth.GenerateAccessToken(userId, login.Scope, role), refreshToken)
+}
+
+// federatedUser finds the user an identity logs in as. An identity that isn't linked yet is linked to the user with its email,
+// which is only trusted when the identity provider and the user both verified it, and a user is created when no user has the email.
+// int64 is the user id, or zero.
+// error could be (403) resp.ErrOidcEmailNotVerified, (403) resp.ErrOidcUserNotVerified, (409) resp.ErrIdentityAlreadyLinked, (500) resp.ErrCouldNotCheckIdentity,
+// (500) resp.ErrCouldNotLinkIdentity, (500) resp.ErrSQLMappingError, or any error from adding a user or nil.
+func (wb *app) federatedUser(identity *model.FederatedIdentity) (int64, error) {
+	userId, err := wb.oidc.LinkedUser(identity)
+	if err != nil || userId != 0 {
+		return userId, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return 0, resp.ErrOidcEmailNotVerified
+	}
+	user, err := wb.users.RetrieveUnauthenticatedUser(identity.Email)
+	switch {
+	case err == resp.ErrUnknownUser:
+		user, err = wb.users.AddFederatedUser(identity.Email, identity.Username)
+	case err == nil && !user.Verified: // anyone can sign up with an email they don't own, only its owner can verify it
+		err = resp.ErrOidcUserNotVerified
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err = wb.oidc.LinkIdentity(user.Id, identity); err != nil {
+		return 0, err
+	}
+	return user.Id, nil
+}
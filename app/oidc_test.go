@@ -0,0 +1,364 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+const testAuthorizationUrl = "https://accounts.example.com/authorize?state=9c1185a5c5e9fc54"
+
+func Test_OidcLogin(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid": {
+			reqBody:        `{"scope":"wordbubble:push","remember_me":true}`,
+			respBody:       structToJson(&resp.OidcAuthorizationResponse{AuthorizationUrl: testAuthorizationUrl}),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			oidcService: &TestOidcService{
+				AuthorizationUrlUrl: testAuthorizationUrl,
+			},
+		},
+		"valid, no body": {
+			respBody:       structToJson(&resp.OidcAuthorizationResponse{AuthorizationUrl: testAuthorizationUrl}),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			oidcService: &TestOidcService{
+				AuthorizationUrlUrl: testAuthorizationUrl,
+			},
+		},
+		"invalid, not configured": {
+			respBody:       structToJson(resp.ErrOidcNotConfigured),
+			respStatusCode: resp.ErrOidcNotConfigured.Code,
+			reqMethod:      http.MethodPost,
+			oidcService: &TestOidcService{
+				AuthorizationUrlError: resp.ErrOidcNotConfigured,
+			},
+		},
+		"invalid, provider couldn't be reached": {
+			respBody:       structToJson(resp.ErrCouldNotReachOidcProvider),
+			respStatusCode: resp.ErrCouldNotReachOidcProvider.Code,
+			reqMethod:      http.MethodPost,
+			oidcService: &TestOidcService{
+				AuthorizationUrlError: resp.ErrCouldNotReachOidcProvider,
+			},
+		},
+		"invalid, unknown scope": {
+			reqBody:        `{"scope":"wordbubble:push admin"}`,
+			respBody:       structToJson(resp.ErrInvalidScope),
+			respStatusCode: resp.ErrInvalidScope.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, bad body": {
+			reqBody:        `howdy!`,
+			respBody:       structToJson(resp.ErrParseOidcLogin),
+			respStatusCode: resp.ErrParseOidcLogin.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.OidcLogin
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_LinkOidcIdentity(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid": {
+			respBody:       structToJson(&resp.OidcAuthorizationResponse{AuthorizationUrl: testAuthorizationUrl}),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			oidcService: &TestOidcService{
+				AuthorizationUrlUrl: testAuthorizationUrl,
+			},
+		},
+		"invalid, not configured": {
+			respBody:       structToJson(resp.ErrOidcNotConfigured),
+			respStatusCode: resp.ErrOidcNotConfigured.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			oidcService: &TestOidcService{
+				AuthorizationUrlError: resp.ErrOidcNotConfigured,
+			},
+		},
+		"invalid, without the account scope": {
+			respBody:       structToJson(resp.ErrInsufficientScope),
+			respStatusCode: resp.ErrInsufficientScope.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("wordbubble:push")}},
+			authService:    &TestAuthService{},
+		},
+		"invalid, no token": {
+			respBody:       structToJson(resp.ErrUnauthorized),
+			respStatusCode: resp.ErrUnauthorized.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{},
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken(util.AllScopes())}},
+			authService:    &TestAuthService{},
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.RequireToken(util.ScopeAccountManage, tcase.testApp.LinkOidcIdentity)
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_OidcCallback(t *testing.T) {
+	login := func(linkUserId int64, emailVerified bool) *model.FederatedLogin {
+		return &model.FederatedLogin{
+			Identity: model.FederatedIdentity{
+				Issuer:        "https://accounts.example.com",
+				Subject:       "248289761001",
+				Email:         "ben@wordbubble.com",
+				EmailVerified: emailVerified,
+				Username:      "ben",
+			},
+			LinkUserId: linkUserId,
+			Scope:      "wordbubble:push",
+		}
+	}
+	tests := map[string]TestCase{
+		"valid, identity is linked": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			respBody:       fmt.Sprintln(`{"access_token":"test.access.token","refresh_token":"test.refresh.token"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginLogin: login(0, false),
+				LinkedUserUserId:   2,
+			},
+			mfaService: &TestMfaService{},
+			authService: &TestAuthService{
+				GenerateRefreshTokenString: "test.refresh.token",
+				GenerateAccessTokenString:  "test.access.token",
+			},
+		},
+		"valid, identity is linked to the user with its verified email": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			respBody:       fmt.Sprintln(`{"access_token":"test.access.token","refresh_token":"test.refresh.token"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginLogin: login(0, true),
+			},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2, Verified: true},
+			},
+			mfaService: &TestMfaService{},
+			authService: &TestAuthService{
+				GenerateRefreshTokenString: "test.refresh.token",
+				GenerateAccessTokenString:  "test.access.token",
+			},
+		},
+		"valid, user is created for an unknown email": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			reqHeader:      http.Header{"X-Session-Mode": []string{"cookie"}},
+			respBody:       fmt.Sprintln(`{"expires_in":30}`),
+			respStatusCode: http.StatusOK,
+			respCookies:    []string{"wb_access", "wb_refresh", "wb_csrf"},
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginLogin: login(0, true),
+			},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserError: resp.ErrUnknownUser,
+				AddFederatedUserUser:             &model.User{Id: 2, Username: "ben"},
+			},
+			mfaService: &TestMfaService{},
+			authService: &TestAuthService{
+				GenerateRefreshTokenString: "test.refresh.token",
+				GenerateAccessTokenString:  "test.access.token",
+			},
+		},
+		"valid, identity is linked to the user that started the login": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			respBody:       fmt.Sprintln(`{"message":"identity has been linked, it can now be used to login"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginLogin: login(2, false),
+			},
+		},
+		"valid, mfa enabled": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			respBody:       fmt.Sprintln(`{"mfa_token":"test.mfa.token","expires_in":300}`),
+			respStatusCode: http.StatusAccepted,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginLogin: login(0, false),
+				LinkedUserUserId:   2,
+			},
+			mfaService: &TestMfaService{
+				MfaEnabledBool: true,
+				CreateChallengeResponse: &resp.MfaChallengeResponse{
+					MfaToken:  "test.mfa.token",
+					ExpiresIn: 300,
+				},
+			},
+		},
+		"invalid, identity is linked to another user": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			respBody:       structToJson(resp.ErrIdentityAlreadyLinked),
+			respStatusCode: resp.ErrIdentityAlreadyLinked.Code,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginLogin: login(2, false),
+				LinkIdentityError:  resp.ErrIdentityAlreadyLinked,
+			},
+		},
+		"invalid, email isn't verified": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			respBody:       structToJson(resp.ErrOidcEmailNotVerified),
+			respStatusCode: resp.ErrOidcEmailNotVerified.Code,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginLogin: login(0, false),
+			},
+		},
+		"invalid, user with the email isn't verified": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			respBody:       structToJson(resp.ErrOidcUserNotVerified),
+			respStatusCode: resp.ErrOidcUserNotVerified.Code,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginLogin: login(0, true),
+			},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2},
+			},
+		},
+		"invalid, linked identities couldn't be checked": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			respBody:       structToJson(resp.ErrCouldNotCheckIdentity),
+			respStatusCode: resp.ErrCouldNotCheckIdentity.Code,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginLogin: login(0, true),
+				LinkedUserError:    resp.ErrCouldNotCheckIdentity,
+			},
+		},
+		"invalid, user service couldn't find user": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			respBody:       structToJson(resp.ErrSQLMappingError),
+			respStatusCode: resp.ErrSQLMappingError.Code,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginLogin: login(0, true),
+			},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserError: resp.ErrSQLMappingError,
+			},
+		},
+		"invalid, user service couldn't choose a username": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			respBody:       structToJson(resp.ErrCouldNotChooseUsername),
+			respStatusCode: resp.ErrCouldNotChooseUsername.Code,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginLogin: login(0, true),
+			},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserError: resp.ErrUnknownUser,
+				AddFederatedUserError:            resp.ErrCouldNotChooseUsername,
+			},
+		},
+		"invalid, identity couldn't be linked": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			respBody:       structToJson(resp.ErrCouldNotLinkIdentity),
+			respStatusCode: resp.ErrCouldNotLinkIdentity.Code,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginLogin: login(0, true),
+				LinkIdentityError:  resp.ErrCouldNotLinkIdentity,
+			},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2, Verified: true},
+			},
+		},
+		"invalid, auth service couldn't store a refresh token": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			respBody:       structToJson(resp.ErrCouldNotStoreRefreshToken),
+			respStatusCode: resp.ErrCouldNotStoreRefreshToken.Code,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginLogin: login(0, false),
+				LinkedUserUserId:   2,
+			},
+			mfaService: &TestMfaService{},
+			authService: &TestAuthService{
+				GenerateRefreshTokenError: resp.ErrCouldNotStoreRefreshToken,
+			},
+		},
+		"invalid, id token": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=state",
+			respBody:       structToJson(resp.ErrInvalidIdToken),
+			respStatusCode: resp.ErrInvalidIdToken.Code,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginError: resp.ErrInvalidIdToken,
+			},
+		},
+		"invalid, state": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code&state=forged",
+			respBody:       structToJson(resp.ErrInvalidOidcState),
+			respStatusCode: resp.ErrInvalidOidcState.Code,
+			reqMethod:      http.MethodGet,
+			oidcService: &TestOidcService{
+				CompleteLoginError: resp.ErrInvalidOidcState,
+			},
+		},
+		"invalid, user denied the login": {
+			reqPath:        "/v1/login/oidc/callback?error=access_denied&state=state",
+			respBody:       structToJson(resp.ErrOidcLoginDenied),
+			respStatusCode: resp.ErrOidcLoginDenied.Code,
+			reqMethod:      http.MethodGet,
+		},
+		"invalid, no code": {
+			reqPath:        "/v1/login/oidc/callback?state=state",
+			respBody:       structToJson(resp.ErrNoOidcCode),
+			respStatusCode: resp.ErrNoOidcCode.Code,
+			reqMethod:      http.MethodGet,
+		},
+		"invalid, no state": {
+			reqPath:        "/v1/login/oidc/callback?code=idp-code",
+			respBody:       structToJson(resp.ErrNoOidcCode),
+			respStatusCode: resp.ErrNoOidcCode.Code,
+			reqMethod:      http.MethodGet,
+		},
+		"invalid, POST http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodPost,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.OidcCallback
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
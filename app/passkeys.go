@@ -0,0 +1,293 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// BeginPasskeyRegistration starts registering a passkey for the user
+// @Summary     Start registering a passkey
+// @Description Start registering a passkey, the options returned are passed to navigator.credentials.create() in the browser,
+// @Description and the credential it creates is sent to /passkeys along with a name. The options expire after five minutes
+// @Tags        passkeys
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Success     200 {object} resp.PasskeyCreationOptions
+// @Failure     400 {object} resp.StatusBadRequest          "resp.ErrUnknownUser"
+// @Failure     401 {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403 {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     404 {object} resp.StatusNotFound            "resp.ErrPasskeysNotConfigured"
+// @Failure     405 {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500 {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrSQLMappingError, resp.ErrCouldNotListPasskeys, resp.ErrCouldNotStorePasskeyChallenge"
+// @Router      /passkeys/options [post]
+func (wb *app) BeginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	user, err := wb.users.RetrieveUserById(principal.UserId)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	options, err := wb.passkeys.BeginRegistration(user)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(options)
+}
+
+// Passkeys registers a passkey on POST, and lists a user's passkeys on GET
+func (wb *app) Passkeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		wb.registerPasskey(w, r)
+	case http.MethodGet:
+		wb.listPasskeys(w, r)
+	default:
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+	}
+}
+
+// registerPasskey finishes registering a passkey for the user
+// @Summary     Register a passkey
+// @Description Register the passkey created with the options from /passkeys/options, the binary fields of the credential are base64url encoded.
+// @Description Once registered, the passkey can be used to login at /login/passkey
+// @Tags        passkeys
+// @Accept      json
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Param       Passkey body     req.PasskeyRegistrationRequest true "Name of the passkey and the credential created by the authenticator"
+// @Success     201     {object} resp.Passkey
+// @Failure     400     {object} resp.StatusBadRequest          "resp.ErrParsePasskey, resp.ErrPasskeyNameIsMissing, resp.ErrPasskeyNameIsTooLong, resp.ErrInvalidPasskeyRegistration, resp.ErrUnsupportedPasskeyAlgorithm"
+// @Failure     401     {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked, resp.ErrInvalidPasskeyChallenge"
+// @Failure     403     {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     404     {object} resp.StatusNotFound            "resp.ErrPasskeysNotConfigured"
+// @Failure     405     {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     409     {object} resp.StatusConflict            "resp.ErrPasskeyAlreadyRegistered"
+// @Failure     500     {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotUsePasskeyChallenge, resp.ErrCouldNotCheckPasskey, resp.ErrCouldNotStorePasskey"
+// @Router      /passkeys [post]
+func (wb *app) registerPasskey(w http.ResponseWriter, r *http.Request) {
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	var reqBody req.PasskeyRegistrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		wb.errorResponse(resp.ErrParsePasskey, w)
+		return
+	}
+
+	passkey, err := wb.passkeys.FinishRegistration(principal.UserId, &reqBody)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(passkey)
+}
+
+// listPasskeys lists the passkeys of a user
+// @Summary     List passkeys
+// @Description List every passkey of the user, along with the last time each passkey was used to login
+// @Tags        passkeys
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Success     200 {object} resp.PasskeysResponse
+// @Failure     401 {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403 {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     405 {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500 {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotListPasskeys"
+// @Router      /passkeys [get]
+func (wb *app) listPasskeys(w http.ResponseWriter, r *http.Request) {
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	passkeys, err := wb.passkeys.ListPasskeys(principal.UserId)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp := &resp.PasskeysResponse{
+		Passkeys: passkeys,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// RevokePasskey revokes one of a user's passkeys, it can no longer be used to login
+// @Summary     Revoke a passkey
+// @Description Revoke one of the user's passkeys, the passkey stays on the authenticator but logins with it are rejected
+// @Tags        passkeys
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Param       id  path     string true "Id of the passkey to revoke"
+// @Success     200 {object} resp.RevokePasskeyResponse
+// @Failure     400 {object} resp.StatusBadRequest          "resp.ErrNoPasskeyId"
+// @Failure     401 {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403 {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     404 {object} resp.StatusNotFound            "resp.ErrUnknownPasskey"
+// @Failure     405 {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500 {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotRevokePasskey"
+// @Router      /passkeys/{id} [delete]
+func (wb *app) RevokePasskey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	passkeyId := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:] // grab the id from the end of the path
+	if passkeyId == "" {
+		wb.errorResponse(resp.ErrNoPasskeyId, w)
+		return
+	}
+
+	if err = wb.passkeys.RevokePasskey(principal.UserId, passkeyId); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp := &resp.RevokePasskeyResponse{
+		Message: "passkey has been revoked",
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// BeginPasskeyLogin starts a login with a passkey
+// @Summary     Start a login with a passkey
+// @Description Start a login with a passkey, the options returned are passed to navigator.credentials.get() in the browser,
+// @Description and the credential it returns is sent to /login/passkey. The request body is optional, every scope is granted when it's not sent
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       Login body     req.BeginPasskeyLoginRequest false "Scope the tokens are granted"
+// @Success     200   {object} resp.PasskeyRequestOptions
+// @Failure     400   {object} resp.StatusBadRequest          "resp.ErrParsePasskey, resp.ErrInvalidScope"
+// @Failure     404   {object} resp.StatusNotFound            "resp.ErrPasskeysNotConfigured"
+// @Failure     405   {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500   {object} resp.StatusInternalServerError "resp.ErrCouldNotStorePasskeyChallenge"
+// @Router      /login/passkey/options [post]
+func (wb *app) BeginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	var reqBody req.BeginPasskeyLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil && err != io.EOF {
+		wb.errorResponse(resp.ErrParsePasskey, w)
+		return
+	}
+
+	scope, err := util.ValidScope(reqBody.Scope)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	options, err := wb.passkeys.BeginLogin(scope, reqBody.RememberMe)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(options)
+}
+
+// FinishPasskeyLogin completes a login with a passkey
+// @Summary     Login with a passkey
+// @Description Login with the passkey the browser returned for the options from /login/passkey/options, the binary fields of the credential are base64url encoded.
+// @Description When two-factor authentication is enabled and the authenticator didn't verify the user, an mfa token is returned instead, which is exchanged along with a code at /login/mfa.
+// @Description Browsers can ask for the tokens to be set as cookies, every state changing request must then send the wb_csrf cookie back in the X-CSRF-Token header
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       Credential     body     req.PasskeyCredential          true  "Credential returned by the authenticator"
+// @Param       X-Session-Mode header   string                         false "Send cookie to receive the tokens as HttpOnly cookies, resp.CookieSessionResponse is returned instead"
+// @Success     200            {object} resp.TokenResponse             "Valid access and refresh tokens for user"
+// @Success     202            {object} resp.MfaChallengeResponse      "Mfa token when two-factor authentication is enabled"
+// @Failure     400            {object} resp.StatusBadRequest          "resp.ErrParsePasskey"
+// @Failure     401            {object} resp.StatusUnauthorized        "resp.ErrInvalidPasskey, resp.ErrInvalidPasskeyChallenge"
+// @Failure     404            {object} resp.StatusNotFound            "resp.ErrPasskeysNotConfigured"
+// @Failure     405            {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500            {object} resp.StatusInternalServerError "resp.ErrCouldNotUsePasskeyChallenge, resp.ErrCouldNotCheckPasskey, resp.ErrCouldNotStorePasskey, resp.ErrCouldNotCheckMfa, resp.ErrCouldNotStoreMfaChallenge, resp.ErrCouldNotRetrieveRole, resp.ErrCouldNotStoreRefreshToken"
+// @Router      /login/passkey [post]
+func (wb *app) FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	var credential req.PasskeyCredential
+	if err := json.NewDecoder(r.Body).Decode(&credential); err != nil {
+		wb.errorResponse(resp.ErrParsePasskey, w)
+		return
+	}
+
+	login, err := wb.passkeys.FinishLogin(&credential)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	if !login.UserVerified { // a passkey the user didn't unlock is only the first factor
+		mfaEnabled, err := wb.mfa.MfaEnabled(login.UserId)
+		if err != nil {
+			wb.errorResponse(err, w)
+			return
+		}
+		if mfaEnabled {
+			challenge, err := wb.mfa.CreateChallenge(login.UserId, login.Scope)
+			if err != nil {
+				wb.errorResponse(err, w)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(challenge)
+			return
+		}
+	}
+
+	role, err := wb.roles.Role(login.UserId)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	refreshToken, err := wb.auth.GenerateRefreshToken(login.UserId, login.Scope, login.RememberMe, wb.deviceFromRequest(r))
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	wb.writeTokens(w, http.StatusOK, wantsCookies(r), wb.auth.GenerateAccessToken(login.UserId, login.Scope, role), refreshToken)
+}
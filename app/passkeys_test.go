@@ -0,0 +1,413 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+func Test_BeginPasskeyRegistration(t *testing.T) {
+	options := &resp.PasskeyCreationOptions{
+		Challenge: "8zJnPRRKxl1yHs0mUVnmIB9xf2bbCNhtB7xdi_9oQHY",
+		Rp:        resp.PasskeyRelyingParty{Id: "wordbubble.com", Name: "wordbubble"},
+		User:      resp.PasskeyUser{Id: "AAAAAAAAAAI", Name: "ben", DisplayName: "ben"},
+	}
+	tests := map[string]TestCase{
+		"valid": {
+			respBody:       structToJson(options),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			userService: &TestUserService{
+				RetrieveUserByIdUser: &model.User{Id: 2, Username: "ben"},
+			},
+			passkeyService: &TestPasskeyService{
+				BeginRegistrationOptions: options,
+			},
+		},
+		"invalid, not configured": {
+			respBody:       structToJson(resp.ErrPasskeysNotConfigured),
+			respStatusCode: resp.ErrPasskeysNotConfigured.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			userService: &TestUserService{
+				RetrieveUserByIdUser: &model.User{Id: 2, Username: "ben"},
+			},
+			passkeyService: &TestPasskeyService{
+				BeginRegistrationError: resp.ErrPasskeysNotConfigured,
+			},
+		},
+		"invalid, unknown user": {
+			respBody:       structToJson(resp.ErrUnknownUser),
+			respStatusCode: resp.ErrUnknownUser.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			userService: &TestUserService{
+				RetrieveUserByIdError: resp.ErrUnknownUser,
+			},
+		},
+		"invalid, without the account scope": {
+			respBody:       structToJson(resp.ErrInsufficientScope),
+			respStatusCode: resp.ErrInsufficientScope.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("wordbubble:push")}},
+			authService:    &TestAuthService{},
+		},
+		"invalid, no token": {
+			respBody:       structToJson(resp.ErrUnauthorized),
+			respStatusCode: resp.ErrUnauthorized.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.RequireToken(util.ScopeAccountManage, tcase.testApp.BeginPasskeyRegistration)
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_Passkeys(t *testing.T) {
+	authenticator := util.NewTestAuthenticator(util.TestRelyingParty())
+	credential := authenticator.Register("8zJnPRRKxl1yHs0mUVnmIB9xf2bbCNhtB7xdi_9oQHY", "AAAAAAAAAAI")
+	registration := fmt.Sprintf(`{"name":"laptop","credential":%s}`, structToJson(credential))
+	tests := map[string]TestCase{
+		"valid, register": {
+			reqBody:        registration,
+			respBody:       fmt.Sprintf("{\"id\":\"%s\",\"name\":\"laptop\",\"created_at\":100,\"last_used_at\":0}\n", authenticator.Id()),
+			respStatusCode: http.StatusCreated,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			passkeyService: &TestPasskeyService{
+				FinishRegistrationPasskey: &resp.Passkey{Id: authenticator.Id(), Name: "laptop", CreatedAt: 100},
+			},
+		},
+		"invalid, register a passkey that's already registered": {
+			reqBody:        registration,
+			respBody:       structToJson(resp.ErrPasskeyAlreadyRegistered),
+			respStatusCode: resp.ErrPasskeyAlreadyRegistered.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			passkeyService: &TestPasskeyService{
+				FinishRegistrationError: resp.ErrPasskeyAlreadyRegistered,
+			},
+		},
+		"invalid, register with an expired challenge": {
+			reqBody:        registration,
+			respBody:       structToJson(resp.ErrInvalidPasskeyChallenge),
+			respStatusCode: resp.ErrInvalidPasskeyChallenge.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			passkeyService: &TestPasskeyService{
+				FinishRegistrationError: resp.ErrInvalidPasskeyChallenge,
+			},
+		},
+		"invalid, register with no body": {
+			reqBody:        ``,
+			respBody:       structToJson(resp.ErrParsePasskey),
+			respStatusCode: resp.ErrParsePasskey.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+		},
+		"valid, list": {
+			respBody:       fmt.Sprintln(`{"passkeys":[{"id":"lmFkBXY8sKwUtYFVrtlTJA","name":"laptop","created_at":100,"last_used_at":200}]}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodGet,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			passkeyService: &TestPasskeyService{
+				ListPasskeysPasskeys: []resp.Passkey{{Id: "lmFkBXY8sKwUtYFVrtlTJA", Name: "laptop", CreatedAt: 100, LastUsedAt: 200}},
+			},
+		},
+		"valid, list with no passkeys": {
+			respBody:       fmt.Sprintln(`{"passkeys":[]}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodGet,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			passkeyService: &TestPasskeyService{
+				ListPasskeysPasskeys: []resp.Passkey{},
+			},
+		},
+		"invalid, list fails": {
+			respBody:       structToJson(resp.ErrCouldNotListPasskeys),
+			respStatusCode: resp.ErrCouldNotListPasskeys.Code,
+			reqMethod:      http.MethodGet,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			passkeyService: &TestPasskeyService{
+				ListPasskeysError: resp.ErrCouldNotListPasskeys,
+			},
+		},
+		"invalid, no token": {
+			respBody:       structToJson(resp.ErrUnauthorized),
+			respStatusCode: resp.ErrUnauthorized.Code,
+			reqMethod:      http.MethodGet,
+		},
+		"invalid, PUT http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodPut,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.RequireToken(util.ScopeAccountManage, tcase.testApp.Passkeys)
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_RevokePasskey(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid": {
+			reqPath:        "/v1/passkeys/lmFkBXY8sKwUtYFVrtlTJA",
+			respBody:       fmt.Sprintln(`{"message":"passkey has been revoked"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodDelete,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			passkeyService: &TestPasskeyService{},
+		},
+		"invalid, unknown passkey": {
+			reqPath:        "/v1/passkeys/lmFkBXY8sKwUtYFVrtlTJA",
+			respBody:       structToJson(resp.ErrUnknownPasskey),
+			respStatusCode: resp.ErrUnknownPasskey.Code,
+			reqMethod:      http.MethodDelete,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			passkeyService: &TestPasskeyService{
+				RevokePasskeyError: resp.ErrUnknownPasskey,
+			},
+		},
+		"invalid, no passkey id": {
+			reqPath:        "/v1/passkeys/",
+			respBody:       structToJson(resp.ErrNoPasskeyId),
+			respStatusCode: resp.ErrNoPasskeyId.Code,
+			reqMethod:      http.MethodDelete,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+		},
+		"invalid, no token": {
+			reqPath:        "/v1/passkeys/lmFkBXY8sKwUtYFVrtlTJA",
+			respBody:       structToJson(resp.ErrUnauthorized),
+			respStatusCode: resp.ErrUnauthorized.Code,
+			reqMethod:      http.MethodDelete,
+		},
+		"invalid, GET http method": {
+			reqPath:        "/v1/passkeys/lmFkBXY8sKwUtYFVrtlTJA",
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.RequireToken(util.ScopeAccountManage, tcase.testApp.RevokePasskey)
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_BeginPasskeyLogin(t *testing.T) {
+	options := &resp.PasskeyRequestOptions{
+		Challenge:        "8zJnPRRKxl1yHs0mUVnmIB9xf2bbCNhtB7xdi_9oQHY",
+		Timeout:          300000,
+		RpId:             "wordbubble.com",
+		AllowCredentials: []resp.PasskeyCredentialDescriptor{},
+		UserVerification: "preferred",
+	}
+	tests := map[string]TestCase{
+		"valid": {
+			reqBody:        `{"scope":"wordbubble:push","remember_me":true}`,
+			respBody:       structToJson(options),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			passkeyService: &TestPasskeyService{
+				BeginLoginOptions: options,
+			},
+		},
+		"valid, no body": {
+			respBody:       structToJson(options),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			passkeyService: &TestPasskeyService{
+				BeginLoginOptions: options,
+			},
+		},
+		"invalid, not configured": {
+			respBody:       structToJson(resp.ErrPasskeysNotConfigured),
+			respStatusCode: resp.ErrPasskeysNotConfigured.Code,
+			reqMethod:      http.MethodPost,
+			passkeyService: &TestPasskeyService{
+				BeginLoginError: resp.ErrPasskeysNotConfigured,
+			},
+		},
+		"invalid, unknown scope": {
+			reqBody:        `{"scope":"wordbubble:push admin"}`,
+			respBody:       structToJson(resp.ErrInvalidScope),
+			respStatusCode: resp.ErrInvalidScope.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, bad body": {
+			reqBody:        `howdy!`,
+			respBody:       structToJson(resp.ErrParsePasskey),
+			respStatusCode: resp.ErrParsePasskey.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.BeginPasskeyLogin
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_FinishPasskeyLogin(t *testing.T) {
+	authenticator := util.NewTestAuthenticator(util.TestRelyingParty())
+	assertion := structToJson(authenticator.Login("8zJnPRRKxl1yHs0mUVnmIB9xf2bbCNhtB7xdi_9oQHY"))
+	login := func(userVerified bool) *model.PasskeyLogin {
+		return &model.PasskeyLogin{UserId: 2, Scope: "wordbubble:push", UserVerified: userVerified}
+	}
+	tests := map[string]TestCase{
+		"valid": {
+			reqBody:        assertion,
+			respBody:       fmt.Sprintln(`{"access_token":"test.access.token","refresh_token":"test.refresh.token"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			passkeyService: &TestPasskeyService{
+				FinishLoginLogin: login(false),
+			},
+			mfaService: &TestMfaService{},
+			authService: &TestAuthService{
+				GenerateRefreshTokenString: "test.refresh.token",
+				GenerateAccessTokenString:  "test.access.token",
+			},
+		},
+		"valid, cookies": {
+			reqBody:        assertion,
+			reqHeader:      http.Header{"X-Session-Mode": []string{"cookie"}},
+			respBody:       fmt.Sprintln(`{"expires_in":30}`),
+			respStatusCode: http.StatusOK,
+			respCookies:    []string{"wb_access", "wb_refresh", "wb_csrf"},
+			reqMethod:      http.MethodPost,
+			passkeyService: &TestPasskeyService{
+				FinishLoginLogin: login(false),
+			},
+			mfaService: &TestMfaService{},
+			authService: &TestAuthService{
+				GenerateRefreshTokenString: "test.refresh.token",
+				GenerateAccessTokenString:  "test.access.token",
+			},
+		},
+		"valid, mfa enabled and the user was verified": {
+			reqBody:        assertion,
+			respBody:       fmt.Sprintln(`{"access_token":"test.access.token","refresh_token":"test.refresh.token"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			passkeyService: &TestPasskeyService{
+				FinishLoginLogin: login(true),
+			},
+			mfaService: &TestMfaService{
+				MfaEnabledBool: true,
+			},
+			authService: &TestAuthService{
+				GenerateRefreshTokenString: "test.refresh.token",
+				GenerateAccessTokenString:  "test.access.token",
+			},
+		},
+		"valid, mfa enabled and the user wasn't verified": {
+			reqBody:        assertion,
+			respBody:       fmt.Sprintln(`{"mfa_token":"test.mfa.token","expires_in":300}`),
+			respStatusCode: http.StatusAccepted,
+			reqMethod:      http.MethodPost,
+			passkeyService: &TestPasskeyService{
+				FinishLoginLogin: login(false),
+			},
+			mfaService: &TestMfaService{
+				MfaEnabledBool:          true,
+				CreateChallengeResponse: &resp.MfaChallengeResponse{MfaToken: "test.mfa.token", ExpiresIn: 300},
+			},
+		},
+		"invalid, passkey couldn't be verified": {
+			reqBody:        assertion,
+			respBody:       structToJson(resp.ErrInvalidPasskey),
+			respStatusCode: resp.ErrInvalidPasskey.Code,
+			reqMethod:      http.MethodPost,
+			passkeyService: &TestPasskeyService{
+				FinishLoginError: resp.ErrInvalidPasskey,
+			},
+		},
+		"invalid, challenge was already used": {
+			reqBody:        assertion,
+			respBody:       structToJson(resp.ErrInvalidPasskeyChallenge),
+			respStatusCode: resp.ErrInvalidPasskeyChallenge.Code,
+			reqMethod:      http.MethodPost,
+			passkeyService: &TestPasskeyService{
+				FinishLoginError: resp.ErrInvalidPasskeyChallenge,
+			},
+		},
+		"invalid, refresh token couldn't be stored": {
+			reqBody:        assertion,
+			respBody:       structToJson(resp.ErrCouldNotStoreRefreshToken),
+			respStatusCode: resp.ErrCouldNotStoreRefreshToken.Code,
+			reqMethod:      http.MethodPost,
+			passkeyService: &TestPasskeyService{
+				FinishLoginLogin: login(true),
+			},
+			authService: &TestAuthService{
+				GenerateRefreshTokenError: resp.ErrCouldNotStoreRefreshToken,
+			},
+		},
+		"invalid, bad body": {
+			reqBody:        `howdy!`,
+			respBody:       structToJson(resp.ErrParsePasskey),
+			respStatusCode: resp.ErrParsePasskey.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.FinishPasskeyLogin
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
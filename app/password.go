@@ -0,0 +1,94 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+)
+
+// ForgotPassword emails a password reset token to a user
+// @Summary     Request a password reset
+// @Description Email a single use password reset token to the user, which can be sent to /password/reset.
+// @Description The same response is returned whether or not the user exists
+// @Tags        password
+// @Accept      json
+// @Produce     json
+// @Param       User body     req.ForgotPasswordRequest true "Username or email of the user"
+// @Success     202  {object} resp.ForgotPasswordResponse
+// @Failure     400  {object} resp.StatusBadRequest          "resp.ErrParseUser, resp.ErrNoUser, resp.ErrCouldNotDetermineUserType"
+// @Failure     405  {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500  {object} resp.StatusInternalServerError "resp.ErrSQLMappingError, resp.ErrCouldNotStorePasswordReset, resp.ErrCouldNotSendEmail"
+// @Router      /password/forgot [post]
+func (wb *app) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	var reqBody req.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		wb.errorResponse(resp.ErrParseUser, w)
+		return
+	}
+	if reqBody.User == "" {
+		wb.errorResponse(resp.ErrNoUser, w)
+		return
+	}
+
+	if err := wb.users.ForgotPassword(reqBody.User); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp := &resp.ForgotPasswordResponse{
+		Message: "if the user exists, a password reset email has been sent",
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ResetPassword replaces a user's password using a reset token, and logs the user out everywhere
+// @Summary     Reset a password
+// @Description Choose a new password using the token emailed from /password/forgot, the token can only be used once.
+// @Description Every refresh token of the user is revoked, so each device has to login again
+// @Tags        password
+// @Accept      json
+// @Produce     json
+// @Param       Reset body     req.ResetPasswordRequest true "Reset token and the new password"
+// @Success     200   {object} resp.ResetPasswordResponse
+// @Failure     400   {object} resp.StatusBadRequest          "resp.ErrParsePasswordReset, resp.ErrInvalidResetToken, InvalidPassword, resp.ErrPasswordIsCommon, resp.ErrPasswordIsTooGuessable"
+// @Failure     405   {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500   {object} resp.StatusInternalServerError "resp.ErrCouldNotBeHashPassword, resp.ErrCouldNotResetPassword, resp.ErrCouldNotRevokeRefreshToken"
+// @Router      /password/reset [post]
+func (wb *app) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	var reqBody req.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil || reqBody.Token == "" {
+		wb.errorResponse(resp.ErrParsePasswordReset, w)
+		return
+	}
+
+	userId, err := wb.users.ResetPassword(reqBody.Token, reqBody.Password)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	// whoever knew the old password may still hold a session
+	if err = wb.auth.RevokeAllRefreshTokens(userId); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp := &resp.ResetPasswordResponse{
+		Message: "your password has been reset, please login again",
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+func Test_ForgotPassword(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid": {
+			reqBody:        `{"user":"ben"}`,
+			respBody:       fmt.Sprintln(`{"message":"if the user exists, a password reset email has been sent"}`),
+			respStatusCode: http.StatusAccepted,
+			reqMethod:      http.MethodPost,
+			userService:    &TestUserService{},
+		},
+		"invalid, email could not be sent": {
+			reqBody:        `{"user":"ben"}`,
+			respBody:       structToJson(resp.ErrCouldNotSendEmail),
+			respStatusCode: resp.ErrCouldNotSendEmail.Code,
+			reqMethod:      http.MethodPost,
+			userService: &TestUserService{
+				ForgotPasswordError: resp.ErrCouldNotSendEmail,
+			},
+		},
+		"invalid, missing user": {
+			reqBody:        `{}`,
+			respBody:       structToJson(resp.ErrNoUser),
+			respStatusCode: resp.ErrNoUser.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, bad body": {
+			reqBody:        `howdy!`,
+			respBody:       structToJson(resp.ErrParseUser),
+			respStatusCode: resp.ErrParseUser.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.ForgotPassword
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_ResetPassword(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid": {
+			reqBody:        `{"token":"9c1185a5c5e9fc54612808977ee8f548b2258d31","password":"NewPassword123"}`,
+			respBody:       fmt.Sprintln(`{"message":"your password has been reset, please login again"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			userService: &TestUserService{
+				ResetPasswordUserId: 2,
+			},
+			authService: &TestAuthService{},
+		},
+		"invalid, password": {
+			reqBody:        `{"token":"9c1185a5c5e9fc54612808977ee8f548b2258d31","password":"password"}`,
+			respBody:       structToJson(util.ValidPassword("password")),
+			respStatusCode: http.StatusBadRequest,
+			reqMethod:      http.MethodPost,
+			userService: &TestUserService{
+				ResetPasswordError: util.ValidPassword("password"),
+			},
+		},
+		"invalid, used token": {
+			reqBody:        `{"token":"9c1185a5c5e9fc54612808977ee8f548b2258d31","password":"NewPassword123"}`,
+			respBody:       structToJson(resp.ErrInvalidResetToken),
+			respStatusCode: resp.ErrInvalidResetToken.Code,
+			reqMethod:      http.MethodPost,
+			userService: &TestUserService{
+				ResetPasswordError: resp.ErrInvalidResetToken,
+			},
+		},
+		"invalid, auth service couldn't revoke refresh tokens": {
+			reqBody:        `{"token":"9c1185a5c5e9fc54612808977ee8f548b2258d31","password":"NewPassword123"}`,
+			respBody:       structToJson(resp.ErrCouldNotRevokeRefreshToken),
+			respStatusCode: resp.ErrCouldNotRevokeRefreshToken.Code,
+			reqMethod:      http.MethodPost,
+			userService: &TestUserService{
+				ResetPasswordUserId: 2,
+			},
+			authService: &TestAuthService{
+				RevokeAllRefreshTokensError: resp.ErrCouldNotRevokeRefreshToken,
+			},
+		},
+		"invalid, missing token": {
+			reqBody:        `{"password":"NewPassword123"}`,
+			respBody:       structToJson(resp.ErrParsePasswordReset),
+			respStatusCode: resp.ErrParsePasswordReset.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, bad body": {
+			reqBody:        `howdy!`,
+			respBody:       structToJson(resp.ErrParsePasswordReset),
+			respStatusCode: resp.ErrParsePasswordReset.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.ResetPassword
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
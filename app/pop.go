@@ -11,7 +11,8 @@ import (
 
 // Pop removes and returns a wordbubble for a user
 // @Summary     Pop a wordbubble
-// @Description Pop removes and returns a wordbubble for a user
+// @Description Pop removes and returns a wordbubble for a user.
+// @Description Browsers with a cookie session must send the wb_csrf cookie back in the X-CSRF-Token header
 // @Tags        wordbubble
 // @Accept      json
 // @Produce     json
@@ -21,6 +22,7 @@ import (
 // @Failure     405                 {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
 // @Failure     400                 {object} resp.StatusBadRequest          "resp.ErrParseUser, resp.ErrNoUser, resp.ErrUnknownUser, resp.ErrCouldNotDetermineUserType"
 // @Failure     401                 {object} resp.StatusUnauthorized        "resp.ErrInvalidCredentials"
+// @Failure     403                 {object} resp.StatusForbidden           "resp.ErrInvalidCsrfToken"
 // @Failure     500                 {object} resp.StatusInternalServerError "resp.ErrSQLMappingError, resp.ErrCouldNotStoreRefreshToken"
 // @Router      /pop [delete]
 func (wb *app) Pop(w http.ResponseWriter, r *http.Request) {
@@ -65,7 +65,7 @@ func Test_Pop(t *testing.T) {
 	for tname, tcase := range tests {
 		t.Run(tname, func(t *testing.T) {
 			tcase.testApp = NewTestApp()
-			tcase.operation = tcase.testApp.Pop
+			tcase.operation = tcase.testApp.RequireCsrf(tcase.testApp.Pop)
 			tcase.HttpRequestTest(t)
 		})
 	}
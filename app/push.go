@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
-	"strings"
 
 	"github.com/bchadwic/wordbubble/model/req"
 	"github.com/bchadwic/wordbubble/model/resp"
@@ -13,18 +12,20 @@ import (
 
 // Push queues a wordbubble for a user
 // @Summary     Push a wordbubble
-// @Description Push adds a new wordbubble to a user's queue
+// @Description Push adds a new wordbubble to a user's queue, the user's email may need to be verified first
 // @Tags        wordbubble
 // @Accept      json
 // @Produce     json
 // @Security    ApiKeyAuth
+// @Security    PersonalApiKey
 // @Param       Wordbubble body     req.WordbubbleRequest true "Wordbubble containing the text to be stored"
 // @Success     201        {object} resp.PushResponse
 // @Failure     405        {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
 // @Failure     400        {object} resp.StatusBadRequest          "resp.ErrParseWordbubble, InvalidWordbubble"
 // @Failure     409        {object} resp.StatusConflict            "resp.ErrMaxAmountOfWordbubblesReached"
-// @Failure     401        {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired"
-// @Failure     500        {object} resp.StatusInternalServerError "resp.UnknownError"
+// @Failure     401        {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked, resp.ErrInvalidApiKey"
+// @Failure     403        {object} resp.StatusForbidden           "resp.ErrInsufficientScope, resp.ErrEmailNotVerified"
+// @Failure     500        {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrSQLMappingError, resp.UnknownError"
 // @Router      /push [post]
 func (wb *app) Push(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -32,15 +33,13 @@ func (wb *app) Push(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	splitToken := strings.Split(r.Header.Get("authorization"), "Bearer ")
-	if len(splitToken) < 2 {
-		wb.errorResponse(resp.ErrUnauthorized, w)
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
 		return
 	}
 
-	tokenStr := splitToken[1] // grab the token from the Bearer string
-	userId, err := util.GetUserIdFromTokenString(tokenStr)
-	if err != nil {
+	if err = wb.checkVerified(principal.UserId); err != nil {
 		wb.errorResponse(err, w)
 		return
 	}
@@ -51,7 +50,7 @@ func (wb *app) Push(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = wb.wordbubbles.AddNewWordbubble(userId, wordbubble)
+	err = wb.wordbubbles.AddNewWordbubble(principal.UserId, wordbubble)
 	if err != nil {
 		wb.errorResponse(err, w)
 		return
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// AssignRole gives a user a role, only admins can assign roles
+// @Summary     Assign a role
+// @Description Make a user a user, moderator or admin. The user gets the role when their access token is next refreshed.
+// @Description An admin can't change their own role, so there's always an admin left to assign roles
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Param       Role body     req.AssignRoleRequest true "Username or email of the user, and the role they're assigned"
+// @Success     200  {object} resp.RoleResponse
+// @Failure     400  {object} resp.StatusBadRequest          "resp.ErrParseRole, resp.ErrInvalidRole, resp.ErrUnknownUser, resp.ErrCouldNotDetermineUserType"
+// @Failure     401  {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403  {object} resp.StatusForbidden           "resp.ErrInsufficientScope, resp.ErrInsufficientRole, resp.ErrCannotChangeOwnRole"
+// @Failure     405  {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500  {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrSQLMappingError, resp.ErrCouldNotAssignRole"
+// @Router      /admin/roles [post]
+func (wb *app) AssignRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	var reqBody req.AssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil || strings.TrimSpace(reqBody.User) == "" {
+		wb.errorResponse(resp.ErrParseRole, w)
+		return
+	}
+	if err := util.ValidRole(reqBody.Role); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	user, err := wb.users.RetrieveUnauthenticatedUser(strings.TrimSpace(reqBody.User))
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	if err = wb.roles.AssignRole(principal, user.Id, reqBody.Role); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&resp.RoleResponse{
+		Username: user.Username,
+		Role:     reqBody.Role,
+	})
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+func Test_AssignRole(t *testing.T) {
+	admin := http.Header{"Authorization": []string{"Bearer " + roleToken(util.AllScopes(), util.RoleAdmin)}}
+	tests := map[string]TestCase{
+		"valid": {
+			reqBody:        `{"user":"ben","role":"moderator"}`,
+			respBody:       fmt.Sprintln(`{"username":"ben","role":"moderator"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodPost,
+			reqHeader:      admin,
+			authService:    &TestAuthService{},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 5, Username: "ben"},
+			},
+			roleService: &TestRoleService{},
+		},
+		"invalid, admin changes their own role": {
+			reqBody:        `{"user":"admin@wordbubble.com","role":"user"}`,
+			respBody:       structToJson(resp.ErrCannotChangeOwnRole),
+			respStatusCode: resp.ErrCannotChangeOwnRole.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      admin,
+			authService:    &TestAuthService{},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 2, Username: "admin"},
+			},
+			roleService: &TestRoleService{
+				AssignRoleError: resp.ErrCannotChangeOwnRole,
+			},
+		},
+		"invalid, role couldn't be assigned": {
+			reqBody:        `{"user":"ben","role":"moderator"}`,
+			respBody:       structToJson(resp.ErrCouldNotAssignRole),
+			respStatusCode: resp.ErrCouldNotAssignRole.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      admin,
+			authService:    &TestAuthService{},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserUser: &model.User{Id: 5, Username: "ben"},
+			},
+			roleService: &TestRoleService{
+				AssignRoleError: resp.ErrCouldNotAssignRole,
+			},
+		},
+		"invalid, unknown user": {
+			reqBody:        `{"user":"nobody","role":"moderator"}`,
+			respBody:       structToJson(resp.ErrUnknownUser),
+			respStatusCode: resp.ErrUnknownUser.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      admin,
+			authService:    &TestAuthService{},
+			userService: &TestUserService{
+				RetrieveUnauthenticatedUserError: resp.ErrUnknownUser,
+			},
+		},
+		"invalid, unknown role": {
+			reqBody:        `{"user":"ben","role":"superuser"}`,
+			respBody:       structToJson(resp.ErrInvalidRole),
+			respStatusCode: resp.ErrInvalidRole.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      admin,
+			authService:    &TestAuthService{},
+		},
+		"invalid, no user": {
+			reqBody:        `{"role":"moderator"}`,
+			respBody:       structToJson(resp.ErrParseRole),
+			respStatusCode: resp.ErrParseRole.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      admin,
+			authService:    &TestAuthService{},
+		},
+		"invalid, bad body": {
+			reqBody:        `howdy!`,
+			respBody:       structToJson(resp.ErrParseRole),
+			respStatusCode: resp.ErrParseRole.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      admin,
+			authService:    &TestAuthService{},
+		},
+		"invalid, moderator": {
+			reqBody:        `{"user":"ben","role":"moderator"}`,
+			respBody:       structToJson(resp.ErrInsufficientRole),
+			respStatusCode: resp.ErrInsufficientRole.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + roleToken(util.AllScopes(), util.RoleModerator)}},
+			authService:    &TestAuthService{},
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+			reqHeader:      admin,
+			authService:    &TestAuthService{},
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.RequirePermission(util.ScopeAccountManage, util.PermissionManageRoles, tcase.testApp.AssignRole)
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// Sessions lists the active sessions of a user, one for each device the user is logged in on
+// @Summary     List sessions
+// @Description List every active session of the user, along with the device each session was last used on
+// @Tags        auth
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Success     200 {object} resp.SessionsResponse
+// @Failure     401 {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403 {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     405 {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500 {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotListSessions"
+// @Router      /sessions [get]
+func (wb *app) Sessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	sessions, err := wb.auth.ListSessions(principal.UserId)
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp := &resp.SessionsResponse{
+		Sessions: sessions,
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// RevokeSession logs a user out of a single device by revoking the session's refresh tokens
+// @Summary     Revoke a session
+// @Description Revoke one of the user's sessions, the device using it will have to login again once its access token expires
+// @Tags        auth
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Param       id  path     string true "Id of the session to revoke"
+// @Success     200 {object} resp.RevokeSessionResponse
+// @Failure     400 {object} resp.StatusBadRequest          "resp.ErrNoSessionId"
+// @Failure     401 {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403 {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     404 {object} resp.StatusNotFound            "resp.ErrUnknownSession"
+// @Failure     405 {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500 {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotRevokeRefreshToken"
+// @Router      /sessions/{id} [delete]
+func (wb *app) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	sessionId := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:] // grab the id from the end of the path
+	if sessionId == "" {
+		wb.errorResponse(resp.ErrNoSessionId, w)
+		return
+	}
+
+	if err = wb.auth.RevokeSession(principal.UserId, sessionId); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp := &resp.RevokeSessionResponse{
+		Message: "session has been revoked",
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
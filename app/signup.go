@@ -12,15 +12,16 @@ import (
 
 // Signup is used to signup a new user
 // @Summary     Signup to api.wordbubble.io
-// @Description Signup to api.wordbubble.io using a unique email and username
+// @Description Signup to api.wordbubble.io using a unique email and username, a verification token is emailed to the user
 // @Tags        auth
 // @Accept      json
 // @Produce     json
-// @Param       User body     req.SignupUserRequest true "User information required to signup"
-// @Success     200  {object} resp.TokenResponse
-// @Failure     400  {object} resp.StatusBadRequest          "resp.ErrParseUser, resp.ErrEmailIsNotValid, resp.ErrEmailIsTooLong, resp.ErrUsernameIsTooLong, resp.ErrUsernameIsNotLongEnough, resp.ErrUsernameInvalidChars, resp.ErrUserWithUsernameAlreadyExists, resp.ErrUserWithEmailAlreadyExists, resp.ErrCouldNotDetermineUserExistence, InvalidPassword"
-// @Failure     405  {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
-// @Failure     500  {object} resp.StatusInternalServerError "resp.ErrCouldNotBeHashPassword, resp.ErrCouldNotAddUser, resp.ErrCouldNotStoreRefreshToken"
+// @Param       User           body     req.SignupUserRequest true  "User information required to signup"
+// @Param       X-Session-Mode header   string                false "Send cookie to receive the tokens as HttpOnly cookies, resp.CookieSessionResponse is returned instead"
+// @Success     200            {object} resp.TokenResponse
+// @Failure     400            {object} resp.StatusBadRequest          "resp.ErrParseUser, resp.ErrEmailIsNotValid, resp.ErrEmailIsTooLong, resp.ErrUsernameIsTooLong, resp.ErrUsernameIsNotLongEnough, resp.ErrUsernameInvalidChars, resp.ErrUserWithUsernameAlreadyExists, resp.ErrUserWithEmailAlreadyExists, resp.ErrCouldNotDetermineUserExistence, InvalidPassword, resp.ErrPasswordIsCommon, resp.ErrPasswordIsTooGuessable"
+// @Failure     405            {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500            {object} resp.StatusInternalServerError "resp.ErrCouldNotBeHashPassword, resp.ErrCouldNotAddUser, resp.ErrCouldNotStoreRefreshToken"
 // @Router      /signup [post]
 func (wb *app) Signup(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -49,16 +50,17 @@ func (wb *app) Signup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	refreshToken, err := wb.auth.GenerateRefreshToken(user.Id)
+	// the user can ask for another email from /verify/resend, so signing up doesn't fail without one
+	if err := wb.users.SendVerification(user.Id); err != nil {
+		wb.log.Warn("could not send verification for user: %d, error: %s", user.Id, err)
+	}
+
+	scope := util.AllScopes()
+	refreshToken, err := wb.auth.GenerateRefreshToken(user.Id, scope, false, wb.deviceFromRequest(r))
 	if err != nil {
 		wb.errorResponse(err, w)
 		return
 	}
 
-	resp := &resp.TokenResponse{
-		RefreshToken: refreshToken,
-		AccessToken:  wb.auth.GenerateAccessToken(user.Id),
-	}
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(resp)
+	wb.writeTokens(w, http.StatusCreated, wantsCookies(r), wb.auth.GenerateAccessToken(user.Id, scope, util.RoleUser), refreshToken)
 }
@@ -11,7 +11,7 @@ import (
 func Test_ValidSignup(t *testing.T) {
 	tests := map[string]TestCase{
 		"valid": {
-			reqBody:        `{"email":"benchadwick87@gmail.com","username":"user1","password":"Password123!"}`,
+			reqBody:        `{"email":"benchadwick87@gmail.com","username":"user1","password":"BlueKettle_Jumps7"}`,
 			respBody:       fmt.Sprintln(`{"access_token":"some.access.token","refresh_token":"some.refresh.token"}`),
 			respStatusCode: http.StatusCreated,
 			reqMethod:      http.MethodPost,
@@ -21,8 +21,21 @@ func Test_ValidSignup(t *testing.T) {
 				GenerateAccessTokenString:  "some.access.token",
 			},
 		},
+		"valid, verification email could not be sent": {
+			reqBody:        `{"email":"benchadwick87@gmail.com","username":"user1","password":"BlueKettle_Jumps7"}`,
+			respBody:       fmt.Sprintln(`{"access_token":"some.access.token","refresh_token":"some.refresh.token"}`),
+			respStatusCode: http.StatusCreated,
+			reqMethod:      http.MethodPost,
+			userService: &TestUserService{
+				SendVerificationError: resp.ErrCouldNotSendEmail,
+			},
+			authService: &TestAuthService{
+				GenerateRefreshTokenString: "some.refresh.token",
+				GenerateAccessTokenString:  "some.access.token",
+			},
+		},
 		"invalid, error generating the refresh token": {
-			reqBody:        `{"email":"benchadwick87@gmail.com","username":"user1","password":"Password123!"}`,
+			reqBody:        `{"email":"benchadwick87@gmail.com","username":"user1","password":"BlueKettle_Jumps7"}`,
 			respBody:       structToJson(resp.ErrCouldNotStoreRefreshToken),
 			respStatusCode: resp.ErrCouldNotStoreRefreshToken.Code,
 			reqMethod:      http.MethodPost,
@@ -32,7 +45,7 @@ func Test_ValidSignup(t *testing.T) {
 			},
 		},
 		"invalid, error adding user": {
-			reqBody:        `{"email":"benchadwick87@gmail.com","username":"user1","password":"Password123!"}`,
+			reqBody:        `{"email":"benchadwick87@gmail.com","username":"user1","password":"BlueKettle_Jumps7"}`,
 			respBody:       structToJson(resp.ErrCouldNotAddUser),
 			respStatusCode: resp.ErrCouldNotAddUser.Code,
 			reqMethod:      http.MethodPost,
@@ -42,18 +55,30 @@ func Test_ValidSignup(t *testing.T) {
 		},
 		"invalid, no password": {
 			reqBody:        `{"email":"benchadwick87@gmail.com","username":"user1"}`,
-			respBody:       structToJson(resp.BadRequest(`password must contain at least 6 characters, one uppercase character, one lowercase character, and one number`)),
+			respBody:       structToJson(resp.BadRequest(`password must contain at least 8 characters, one uppercase character, one lowercase character, and one number`)),
 			respStatusCode: http.StatusBadRequest,
 			reqMethod:      http.MethodPost,
 		},
+		"invalid, common password": {
+			reqBody:        `{"email":"benchadwick87@gmail.com","username":"user1","password":"Password123!"}`,
+			respBody:       structToJson(resp.ErrPasswordIsCommon),
+			respStatusCode: resp.ErrPasswordIsCommon.Code,
+			reqMethod:      http.MethodPost,
+		},
+		"invalid, password too easy to guess": {
+			reqBody:        `{"email":"benchadwick87@gmail.com","username":"user1","password":"qwertyuiop1A"}`,
+			respBody:       structToJson(resp.ErrPasswordIsTooGuessable),
+			respStatusCode: resp.ErrPasswordIsTooGuessable.Code,
+			reqMethod:      http.MethodPost,
+		},
 		"invalid, no username": {
-			reqBody:        `{"email":"benchadwick87@gmail.com","password":"Password123!"}`,
+			reqBody:        `{"email":"benchadwick87@gmail.com","password":"BlueKettle_Jumps7"}`,
 			respBody:       structToJson(resp.ErrUsernameIsMissing),
 			respStatusCode: resp.ErrUsernameIsMissing.Code,
 			reqMethod:      http.MethodPost,
 		},
 		"invalid, no email": {
-			reqBody:        `{"username":"ben","password":"Password123!"}`,
+			reqBody:        `{"username":"ben","password":"BlueKettle_Jumps7"}`,
 			respBody:       structToJson(resp.ErrEmailIsNotValid),
 			respStatusCode: resp.ErrEmailIsNotValid.Code,
 			reqMethod:      http.MethodPost,
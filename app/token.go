@@ -1,26 +1,30 @@
 package app
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/bchadwic/wordbubble/internal/service/auth"
-	"github.com/bchadwic/wordbubble/model/req"
 	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
 )
 
-// Token is used to retrieve a new access token from a refresh token
+// Token is used to retrieve a new access token and a new refresh token from a refresh token
 // @Summary     Token to api.wordbubble.io
-// @Description Token to api.wordbubble.io for authorized use
+// @Description Token to api.wordbubble.io for authorized use, the refresh token passed is revoked and replaced.
+// @Description The access token can be granted a narrower scope than the refresh token. Refresh tokens issued to oauth clients are refreshed at /oauth/token instead.
+// @Description The replacement never outlives the max session lifetime counted from login, the user must login again after it.
+// @Description A browser session can send an empty body, the refresh token cookie is used instead and the replacements are set as cookies,
+// @Description which needs the wb_csrf cookie sent back in the X-CSRF-Token header
 // @Tags        auth
 // @Accept      json
 // @Produce     json
-// @Param       Token body     req.RefreshTokenRequest true "Valid refresh token to gain a new access token"
+// @Param       Token body     req.RefreshTokenRequest false "Valid refresh token to gain a new access token"
 // @Success     200   {object} resp.TokenResponse
-// @Failure     400   {object} resp.StatusBadRequest          "resp.ErrParseRefreshToken"
-// @Failure     401   {object} resp.StatusUnauthorized        "resp.ErrTokenIsExpired, resp.ErrCouldNotValidateRefreshToken"
+// @Failure     400   {object} resp.StatusBadRequest          "resp.ErrParseRefreshToken, resp.ErrClientRefreshToken, resp.ErrInvalidScope"
+// @Failure     401   {object} resp.StatusUnauthorized        "resp.ErrTokenIsExpired, resp.ErrSessionIsExpired, resp.ErrCouldNotValidateRefreshToken, resp.ErrRefreshTokenReused"
+// @Failure     403   {object} resp.StatusForbidden           "resp.ErrInvalidCsrfToken"
 // @Failure     405   {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
-// @Failure     500   {object} resp.StatusInternalServerError "resp.ErrCouldNotStoreRefreshToken"
+// @Failure     500   {object} resp.StatusInternalServerError "resp.ErrCouldNotRetrieveRole, resp.ErrCouldNotStoreRefreshToken, resp.ErrCouldNotRevokeRefreshToken"
 // @Router      /token [post]
 func (wb *app) Token(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -28,31 +32,38 @@ func (wb *app) Token(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var reqBody req.RefreshTokenRequest
-	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
-		wb.errorResponse(resp.ErrParseRefreshToken, w)
+	reqBody, fromCookie, err := refreshTokenFromRequest(r)
+	if err != nil {
+		wb.errorResponse(err, w)
 		return
 	}
 
-	token, err := auth.RefreshTokenFromTokenString(reqBody.Token)
+	token, err := auth.RefreshTokenFromTokenString(wb.keys, reqBody.Token)
 	if err != nil {
 		wb.errorResponse(err, w)
 		return
 	}
-	if err = wb.auth.ValidateRefreshToken(token); err != nil {
+	if token.ClientId() != "" { // clients act for a user, they never get the user's role
+		wb.errorResponse(resp.ErrClientRefreshToken, w)
+		return
+	}
+	scope, err := util.NarrowScope(token.Scope(), reqBody.Scope)
+	if err != nil {
 		wb.errorResponse(err, w)
 		return
 	}
-
-	var latestRefreshToken string
-	if token.IsNearEndOfLife() {
-		latestRefreshToken, _ = wb.auth.GenerateRefreshToken(token.UserId())
+	// the role is looked up again, so a role that's assigned or taken away applies from the next refresh
+	role, err := wb.roles.Role(token.UserId())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
 	}
-
-	resp := &resp.TokenResponse{
-		RefreshToken: latestRefreshToken,
-		AccessToken:  wb.auth.GenerateAccessToken(token.UserId()),
+	latestRefreshToken, err := wb.auth.RotateRefreshToken(token, wb.deviceFromRequest(r))
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
 	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(resp)
+
+	// a browser session keeps its tokens in cookies, the rotated tokens replace them
+	wb.writeTokens(w, http.StatusOK, fromCookie || wantsCookies(r), wb.auth.GenerateAccessToken(token.UserId(), scope, role), latestRefreshToken)
 }
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// Verify marks a user's email as verified using the token emailed to them
+// @Summary     Verify an email
+// @Description Verify the email of a user with the token sent at signup or from /verify/resend, the token can only be used once
+// @Tags        verify
+// @Produce     json
+// @Param       token query    string true "Verification token from the email"
+// @Success     200   {object} resp.VerifyEmailResponse
+// @Failure     400   {object} resp.StatusBadRequest          "resp.ErrNoVerificationToken, resp.ErrInvalidVerificationToken"
+// @Failure     405   {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     500   {object} resp.StatusInternalServerError "resp.ErrCouldNotVerifyEmail"
+// @Router      /verify [get]
+func (wb *app) Verify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		wb.errorResponse(resp.ErrNoVerificationToken, w)
+		return
+	}
+
+	if err := wb.users.VerifyEmail(token); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp := &resp.VerifyEmailResponse{
+		Message: "your email has been verified",
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ResendVerification emails another verification token to the user
+// @Summary     Resend a verification email
+// @Description Email another verification token to the user, tokens sent before stay valid until they expire
+// @Tags        verify
+// @Produce     json
+// @Security    ApiKeyAuth
+// @Success     202 {object} resp.VerificationSentResponse
+// @Failure     400 {object} resp.StatusBadRequest          "resp.ErrUnknownUser"
+// @Failure     401 {object} resp.StatusUnauthorized        "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked"
+// @Failure     403 {object} resp.StatusForbidden           "resp.ErrInsufficientScope"
+// @Failure     405 {object} resp.StatusMethodNotAllowed    "resp.ErrInvalidHttpMethod"
+// @Failure     409 {object} resp.StatusConflict            "resp.ErrEmailAlreadyVerified"
+// @Failure     500 {object} resp.StatusInternalServerError "resp.ErrCouldNotCheckDenylist, resp.ErrSQLMappingError, resp.ErrCouldNotStoreVerification, resp.ErrCouldNotSendEmail"
+// @Router      /verify/resend [post]
+func (wb *app) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wb.errorResponse(resp.ErrInvalidHttpMethod, w)
+		return
+	}
+
+	principal, err := util.PrincipalFromContext(r.Context())
+	if err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	if err = wb.users.SendVerification(principal.UserId); err != nil {
+		wb.errorResponse(err, w)
+		return
+	}
+
+	resp := &resp.VerificationSentResponse{
+		Message: "a verification email has been sent",
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+func Test_Verify(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid": {
+			reqPath:        "/v1/verify?token=9c1185a5c5e9fc54612808977ee8f548b2258d31",
+			respBody:       fmt.Sprintln(`{"message":"your email has been verified"}`),
+			respStatusCode: http.StatusOK,
+			reqMethod:      http.MethodGet,
+			userService:    &TestUserService{},
+		},
+		"invalid, used token": {
+			reqPath:        "/v1/verify?token=9c1185a5c5e9fc54612808977ee8f548b2258d31",
+			respBody:       structToJson(resp.ErrInvalidVerificationToken),
+			respStatusCode: resp.ErrInvalidVerificationToken.Code,
+			reqMethod:      http.MethodGet,
+			userService: &TestUserService{
+				VerifyEmailError: resp.ErrInvalidVerificationToken,
+			},
+		},
+		"invalid, missing token": {
+			reqPath:        "/v1/verify",
+			respBody:       structToJson(resp.ErrNoVerificationToken),
+			respStatusCode: resp.ErrNoVerificationToken.Code,
+			reqMethod:      http.MethodGet,
+		},
+		"invalid, POST http method": {
+			reqPath:        "/v1/verify",
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodPost,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.Verify
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
+
+func Test_ResendVerification(t *testing.T) {
+	tests := map[string]TestCase{
+		"valid": {
+			respBody:       fmt.Sprintln(`{"message":"a verification email has been sent"}`),
+			respStatusCode: http.StatusAccepted,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			userService:    &TestUserService{},
+		},
+		"invalid, already verified": {
+			respBody:       structToJson(resp.ErrEmailAlreadyVerified),
+			respStatusCode: resp.ErrEmailAlreadyVerified.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("")}},
+			authService:    &TestAuthService{},
+			userService: &TestUserService{
+				SendVerificationError: resp.ErrEmailAlreadyVerified,
+			},
+		},
+		"invalid, without the account scope": {
+			respBody:       structToJson(resp.ErrInsufficientScope),
+			respStatusCode: resp.ErrInsufficientScope.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken("wordbubble:push")}},
+			authService:    &TestAuthService{},
+		},
+		"invalid, no authorization": {
+			respBody:       structToJson(resp.ErrUnauthorized),
+			respStatusCode: resp.ErrUnauthorized.Code,
+			reqMethod:      http.MethodPost,
+			reqHeader:      http.Header{},
+		},
+		"invalid, GET http method": {
+			respBody:       structToJson(resp.ErrInvalidHttpMethod),
+			respStatusCode: resp.ErrInvalidHttpMethod.Code,
+			reqMethod:      http.MethodGet,
+			reqHeader:      http.Header{"Authorization": []string{"Bearer " + scopedToken(util.AllScopes())}},
+			authService:    &TestAuthService{},
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.testApp = NewTestApp()
+			tcase.operation = tcase.testApp.RequireToken(util.ScopeAccountManage, tcase.testApp.ResendVerification)
+			tcase.HttpRequestTest(t)
+		})
+	}
+}
@@ -24,9 +24,40 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/login": {
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "JWKS returns the public keys of RS256 and EdDSA signing keys, so other services can validate tokens on their own. Served from /.well-known/jwks.json at the root of the host",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Public keys for api.wordbubble.io tokens",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.JWKSResponse"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/roles": {
             "post": {
-                "description": "Login to api.wordbubble.io using the user credentials",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Make a user a user, moderator or admin. The user gets the role when their access token is next refreshed.\nAn admin can't change their own role, so there's always an admin left to assign roles",
                 "consumes": [
                     "application/json"
                 ],
@@ -34,39 +65,45 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "Login to api.wordbubble.io",
+                "summary": "Assign a role",
                 "parameters": [
                     {
-                        "description": "Credentials used to authenticate a user",
-                        "name": "User",
+                        "description": "Username or email of the user, and the role they're assigned",
+                        "name": "Role",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/req.LoginUserRequest"
+                            "$ref": "#/definitions/req.AssignRoleRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Valid access and refresh tokens for user",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/resp.TokenResponse"
+                            "$ref": "#/definitions/resp.RoleResponse"
                         }
                     },
                     "400": {
-                        "description": "resp.ErrParseUser, resp.ErrNoPassword, resp.ErrNoUser, resp.ErrUnknownUser, resp.ErrCouldNotDetermineUserType",
+                        "description": "resp.ErrParseRole, resp.ErrInvalidRole, resp.ErrUnknownUser, resp.ErrCouldNotDetermineUserType",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusBadRequest"
                         }
                     },
                     "401": {
-                        "description": "resp.ErrInvalidCredentials",
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusUnauthorized"
                         }
                     },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope, resp.ErrInsufficientRole, resp.ErrCannotChangeOwnRole",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
                     "405": {
                         "description": "resp.ErrInvalidHttpMethod",
                         "schema": {
@@ -74,7 +111,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "resp.ErrSQLMappingError, resp.ErrCouldNotStoreRefreshToken",
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrSQLMappingError, resp.ErrCouldNotAssignRole",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusInternalServerError"
                         }
@@ -82,9 +119,61 @@ const docTemplate = `{
                 }
             }
         },
-        "/pop": {
-            "delete": {
-                "description": "Pop removes and returns a wordbubble for a user",
+        "/apikeys": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "List every api key of the user, along with the last time each key was used",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "apikeys"
+                ],
+                "summary": "List api keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ApiKeysResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotListApiKeys",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a named api key that can be used in place of an access token, the key is only returned in this response.\nThe key can be granted a narrower scope than the access token used to create it",
                 "consumes": [
                     "application/json"
                 ],
@@ -92,45 +181,45 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "wordbubble"
+                    "apikeys"
                 ],
-                "summary": "Pop a wordbubble",
+                "summary": "Create an api key",
                 "parameters": [
                     {
-                        "description": "Username or email that the wordbubble will come from",
-                        "name": "UnauthenticatedUser",
+                        "description": "Name of the api key",
+                        "name": "ApiKey",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/req.PopUserRequest"
+                            "$ref": "#/definitions/req.CreateApiKeyRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Latest Wordbubble for user passed",
-                        "schema": {
-                            "$ref": "#/definitions/resp.WordbubbleResponse"
-                        }
-                    },
                     "201": {
-                        "description": "resp.ErrNoWordbubble",
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/resp.StatusNoContent"
+                            "$ref": "#/definitions/resp.CreateApiKeyResponse"
                         }
                     },
                     "400": {
-                        "description": "resp.ErrParseUser, resp.ErrNoUser, resp.ErrUnknownUser, resp.ErrCouldNotDetermineUserType",
+                        "description": "resp.ErrParseApiKey, resp.ErrApiKeyNameIsMissing, resp.ErrApiKeyNameIsTooLong, resp.ErrInvalidScope",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusBadRequest"
                         }
                     },
                     "401": {
-                        "description": "resp.ErrInvalidCredentials",
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusUnauthorized"
                         }
                     },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
                     "405": {
                         "description": "resp.ErrInvalidHttpMethod",
                         "schema": {
@@ -138,7 +227,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "resp.ErrSQLMappingError, resp.ErrCouldNotStoreRefreshToken",
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotStoreApiKey",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusInternalServerError"
                         }
@@ -146,14 +235,79 @@ const docTemplate = `{
                 }
             }
         },
-        "/push": {
-            "post": {
+        "/apikeys/{id}": {
+            "delete": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Push adds a new wordbubble to a user's queue",
+                "description": "Revoke one of the user's api keys, requests using the key are rejected right away",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "apikeys"
+                ],
+                "summary": "Revoke an api key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Id of the api key to revoke",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.RevokeApiKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrNoApiKeyId",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "404": {
+                        "description": "resp.ErrUnknownApiKey",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusNotFound"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotRevokeApiKey",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/login": {
+            "post": {
+                "description": "Login to api.wordbubble.io using the user credentials, the tokens are granted every scope unless a narrower scope is requested.\nWhen two-factor authentication is enabled, an mfa token is returned instead, which is exchanged along with a code at /login/mfa.\nSending remember_me makes the session last longer before the user has to login again.\nBrowsers can ask for the tokens to be set as cookies, every state changing request must then send the wb_csrf cookie back in the X-CSRF-Token header.\nFailed logins are counted per account and per client, after a few each following attempt has to wait longer, and an account is locked for a while after many",
                 "consumes": [
                     "application/json"
                 ],
@@ -161,35 +315,47 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "wordbubble"
+                    "auth"
                 ],
-                "summary": "Push a wordbubble",
+                "summary": "Login to api.wordbubble.io",
                 "parameters": [
                     {
-                        "description": "Wordbubble containing the text to be stored",
-                        "name": "Wordbubble",
+                        "description": "Credentials used to authenticate a user",
+                        "name": "User",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/req.WordbubbleRequest"
+                            "$ref": "#/definitions/req.LoginUserRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Send cookie to receive the tokens as HttpOnly cookies, resp.CookieSessionResponse is returned instead",
+                        "name": "X-Session-Mode",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "Valid access and refresh tokens for user",
                         "schema": {
-                            "$ref": "#/definitions/resp.PushResponse"
+                            "$ref": "#/definitions/resp.TokenResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Mfa token when two-factor authentication is enabled",
+                        "schema": {
+                            "$ref": "#/definitions/resp.MfaChallengeResponse"
                         }
                     },
                     "400": {
-                        "description": "resp.ErrParseWordbubble, InvalidWordbubble",
+                        "description": "resp.ErrParseUser, resp.ErrNoPassword, resp.ErrNoUser, resp.ErrUnknownUser, resp.ErrCouldNotDetermineUserType, resp.ErrInvalidScope",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusBadRequest"
                         }
                     },
                     "401": {
-                        "description": "resp.ErrUnauthorized, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired",
+                        "description": "resp.ErrInvalidCredentials",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusUnauthorized"
                         }
@@ -200,14 +366,20 @@ const docTemplate = `{
                             "$ref": "#/definitions/resp.StatusMethodNotAllowed"
                         }
                     },
-                    "409": {
-                        "description": "resp.ErrMaxAmountOfWordbubblesReached",
+                    "423": {
+                        "description": "resp.ErrAccountLocked",
                         "schema": {
-                            "$ref": "#/definitions/resp.StatusConflict"
+                            "$ref": "#/definitions/resp.StatusLocked"
+                        }
+                    },
+                    "429": {
+                        "description": "resp.ErrTooManyLoginAttempts",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusTooManyRequests"
                         }
                     },
                     "500": {
-                        "description": "resp.UnknownError",
+                        "description": "resp.ErrSQLMappingError, resp.ErrCouldNotCheckLoginAttempts, resp.ErrCouldNotRecordLoginAttempt, resp.ErrCouldNotCheckMfa, resp.ErrCouldNotStoreMfaChallenge, resp.ErrCouldNotRetrieveRole, resp.ErrCouldNotStoreRefreshToken",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusInternalServerError"
                         }
@@ -215,9 +387,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/signup": {
+        "/login/link": {
             "post": {
-                "description": "Signup to api.wordbubble.io using a unique email and username",
+                "description": "Email a signed, single use link to /login/link/callback, which logs the user in with the scope requested here.\nThe same response is returned whether or not a user has the email, and only a few links can be requested for an email at a time",
                 "consumes": [
                     "application/json"
                 ],
@@ -227,31 +399,102 @@ const docTemplate = `{
                 "tags": [
                     "auth"
                 ],
-                "summary": "Signup to api.wordbubble.io",
+                "summary": "Request a login link",
                 "parameters": [
                     {
-                        "description": "User information required to signup",
-                        "name": "User",
+                        "description": "Email of the user, and the scope the tokens are granted",
+                        "name": "Email",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/req.SignupUserRequest"
+                            "$ref": "#/definitions/req.LoginLinkRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/resp.LoginLinkResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParseLoginLink, resp.ErrEmailIsNotValid, resp.ErrEmailIsTooLong, resp.ErrInvalidScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "429": {
+                        "description": "resp.ErrTooManyLoginLinks",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusTooManyRequests"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrSQLMappingError, resp.ErrCouldNotCheckLoginLink, resp.ErrCouldNotStoreLoginLink, resp.ErrCouldNotSendEmail",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
                         }
                     }
+                }
+            }
+        },
+        "/login/link/callback": {
+            "get": {
+                "description": "Redeem the token of a link emailed from /login/link, the link can only be used once and expires after a while.\nWhen two-factor authentication is enabled, an mfa token is returned instead, which is exchanged along with a code at /login/mfa.\nBrowsers can ask for the tokens to be set as cookies, every state changing request must then send the wb_csrf cookie back in the X-CSRF-Token header",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Login with a login link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token of the login link",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Send cookie to receive the tokens as HttpOnly cookies, resp.CookieSessionResponse is returned instead",
+                        "name": "X-Session-Mode",
+                        "in": "header"
+                    }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Valid access and refresh tokens for user",
                         "schema": {
                             "$ref": "#/definitions/resp.TokenResponse"
                         }
                     },
+                    "202": {
+                        "description": "Mfa token when two-factor authentication is enabled",
+                        "schema": {
+                            "$ref": "#/definitions/resp.MfaChallengeResponse"
+                        }
+                    },
                     "400": {
-                        "description": "resp.ErrParseUser, resp.ErrEmailIsNotValid, resp.ErrEmailIsTooLong, resp.ErrUsernameIsTooLong, resp.ErrUsernameIsNotLongEnough, resp.ErrUsernameInvalidChars, resp.ErrUserWithUsernameAlreadyExists, resp.ErrUserWithEmailAlreadyExists, resp.ErrCouldNotDetermineUserExistence, InvalidPassword",
+                        "description": "resp.ErrNoLoginLinkToken",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusBadRequest"
                         }
                     },
+                    "401": {
+                        "description": "resp.ErrInvalidLoginLink",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
                     "405": {
                         "description": "resp.ErrInvalidHttpMethod",
                         "schema": {
@@ -259,7 +502,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "resp.ErrCouldNotBeHashPassword, resp.ErrCouldNotAddUser, resp.ErrCouldNotStoreRefreshToken",
+                        "description": "resp.ErrCouldNotCheckLoginLink, resp.ErrCouldNotCheckMfa, resp.ErrCouldNotStoreMfaChallenge, resp.ErrCouldNotRetrieveRole, resp.ErrCouldNotStoreRefreshToken",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusInternalServerError"
                         }
@@ -267,9 +510,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/token": {
+        "/login/mfa": {
             "post": {
-                "description": "Token to api.wordbubble.io for authorized use",
+                "description": "Exchange the mfa token returned from /login, along with a code from an authenticator app or a recovery code,\nfor the access and refresh tokens. An mfa token can only be used once, and is rejected after too many wrong codes.\nWrong codes are counted along with failed logins, so the account is locked after too many",
                 "consumes": [
                     "application/json"
                 ],
@@ -279,33 +522,39 @@ const docTemplate = `{
                 "tags": [
                     "auth"
                 ],
-                "summary": "Token to api.wordbubble.io",
+                "summary": "Finish logging in with a second factor",
                 "parameters": [
                     {
-                        "description": "Valid refresh token to gain a new access token",
-                        "name": "Token",
+                        "description": "Mfa token from /login and a code",
+                        "name": "Code",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/req.RefreshTokenRequest"
+                            "$ref": "#/definitions/req.LoginMfaRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Send cookie to receive the tokens as HttpOnly cookies, resp.CookieSessionResponse is returned instead",
+                        "name": "X-Session-Mode",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Valid access and refresh tokens for user",
                         "schema": {
                             "$ref": "#/definitions/resp.TokenResponse"
                         }
                     },
                     "400": {
-                        "description": "resp.ErrParseRefreshToken",
+                        "description": "resp.ErrParseMfaToken, resp.ErrMfaNotEnrolled",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusBadRequest"
                         }
                     },
                     "401": {
-                        "description": "resp.ErrTokenIsExpired, resp.ErrCouldNotValidateRefreshToken",
+                        "description": "resp.ErrInvalidMfaToken, resp.ErrInvalidMfaCode",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusUnauthorized"
                         }
@@ -316,76 +565,2626 @@ const docTemplate = `{
                             "$ref": "#/definitions/resp.StatusMethodNotAllowed"
                         }
                     },
+                    "423": {
+                        "description": "resp.ErrAccountLocked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusLocked"
+                        }
+                    },
+                    "429": {
+                        "description": "resp.ErrTooManyLoginAttempts",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusTooManyRequests"
+                        }
+                    },
                     "500": {
-                        "description": "resp.ErrCouldNotStoreRefreshToken",
+                        "description": "resp.ErrCouldNotCheckMfa, resp.ErrCouldNotCheckLoginAttempts, resp.ErrCouldNotRecordLoginAttempt, resp.ErrCouldNotRetrieveRole, resp.ErrCouldNotStoreRefreshToken",
                         "schema": {
                             "$ref": "#/definitions/resp.StatusInternalServerError"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
+        },
+        "/login/oidc": {
+            "post": {
+                "description": "Start a login with the identity provider, the user is sent to the url returned and comes back to /login/oidc/callback.\nThe request body is optional, every scope is granted when it's not sent",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Login with an identity provider",
+                "parameters": [
+                    {
+                        "description": "Scope the tokens are granted",
+                        "name": "Login",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/req.OidcLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.OidcAuthorizationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParseOidcLogin, resp.ErrInvalidScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "404": {
+                        "description": "resp.ErrOidcNotConfigured",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusNotFound"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotReachOidcProvider, resp.ErrCouldNotStoreOidcState",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/login/oidc/callback": {
+            "get": {
+                "description": "The identity provider sends the user back here from /login/oidc or /oidc/link.\nA login returns the access and refresh token of the user the identity is linked to, an identity that isn't linked yet is linked to the user with its verified email when the user verified it too,\nand a user is created when no user has the email. When two-factor authentication is enabled, an mfa token is returned instead, which is exchanged along with a code at /login/mfa.\nBrowsers can ask for the tokens to be set as cookies, every state changing request must then send the wb_csrf cookie back in the X-CSRF-Token header",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Complete a login with an identity provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization code from the identity provider",
+                        "name": "code",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "State the login was started with",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Error from the identity provider, when the user didn't login",
+                        "name": "error",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Send cookie to receive the tokens as HttpOnly cookies, resp.CookieSessionResponse is returned instead",
+                        "name": "X-Session-Mode",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Valid access and refresh tokens for user, or resp.IdentityLinkedResponse when an identity was linked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.TokenResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Mfa token when two-factor authentication is enabled",
+                        "schema": {
+                            "$ref": "#/definitions/resp.MfaChallengeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrNoOidcCode, resp.ErrUserWithEmailAlreadyExists",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrOidcLoginDenied, resp.ErrInvalidOidcState, resp.ErrOidcCodeRejected, resp.ErrInvalidIdToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrOidcEmailNotVerified, resp.ErrOidcUserNotVerified",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "404": {
+                        "description": "resp.ErrOidcNotConfigured",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusNotFound"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "409": {
+                        "description": "resp.ErrIdentityAlreadyLinked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusConflict"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotUseOidcState, resp.ErrCouldNotReachOidcProvider, resp.ErrCouldNotCheckIdentity, resp.ErrCouldNotLinkIdentity, resp.ErrSQLMappingError, resp.ErrCouldNotDetermineUserExistence, resp.ErrCouldNotChooseUsername, resp.ErrCouldNotAddUser, resp.ErrCouldNotCheckMfa, resp.ErrCouldNotRetrieveRole, resp.ErrCouldNotStoreRefreshToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/login/passkey": {
+            "post": {
+                "description": "Login with the passkey the browser returned for the options from /login/passkey/options, the binary fields of the credential are base64url encoded.\nWhen two-factor authentication is enabled and the authenticator didn't verify the user, an mfa token is returned instead, which is exchanged along with a code at /login/mfa.\nBrowsers can ask for the tokens to be set as cookies, every state changing request must then send the wb_csrf cookie back in the X-CSRF-Token header",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Login with a passkey",
+                "parameters": [
+                    {
+                        "description": "Credential returned by the authenticator",
+                        "name": "Credential",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.PasskeyCredential"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Send cookie to receive the tokens as HttpOnly cookies, resp.CookieSessionResponse is returned instead",
+                        "name": "X-Session-Mode",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Valid access and refresh tokens for user",
+                        "schema": {
+                            "$ref": "#/definitions/resp.TokenResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Mfa token when two-factor authentication is enabled",
+                        "schema": {
+                            "$ref": "#/definitions/resp.MfaChallengeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParsePasskey",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrInvalidPasskey, resp.ErrInvalidPasskeyChallenge",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "404": {
+                        "description": "resp.ErrPasskeysNotConfigured",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusNotFound"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotUsePasskeyChallenge, resp.ErrCouldNotCheckPasskey, resp.ErrCouldNotStorePasskey, resp.ErrCouldNotCheckMfa, resp.ErrCouldNotStoreMfaChallenge, resp.ErrCouldNotRetrieveRole, resp.ErrCouldNotStoreRefreshToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/login/passkey/options": {
+            "post": {
+                "description": "Start a login with a passkey, the options returned are passed to navigator.credentials.get() in the browser,\nand the credential it returns is sent to /login/passkey. The request body is optional, every scope is granted when it's not sent",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Start a login with a passkey",
+                "parameters": [
+                    {
+                        "description": "Scope the tokens are granted",
+                        "name": "Login",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/req.BeginPasskeyLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.PasskeyRequestOptions"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParsePasskey, resp.ErrInvalidScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "404": {
+                        "description": "resp.ErrPasskeysNotConfigured",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusNotFound"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotStorePasskeyChallenge",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/logout": {
+            "post": {
+                "description": "Logout of api.wordbubble.io by revoking the refresh token used on this device.\nA browser session can send an empty body, the refresh token cookie is revoked instead and the session cookies are cleared,\nwhich needs the wb_csrf cookie sent back in the X-CSRF-Token header",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Logout of api.wordbubble.io",
+                "parameters": [
+                    {
+                        "description": "Refresh token that will be revoked",
+                        "name": "Token",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/req.RefreshTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.LogoutResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParseRefreshToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrTokenIsExpired, resp.ErrSessionIsExpired, resp.ErrCouldNotValidateRefreshToken, resp.ErrRefreshTokenReused",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInvalidCsrfToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotRevokeRefreshToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/logout/all": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Logout of api.wordbubble.io on every device by revoking all of a user's refresh tokens and the access token used,\nthe session cookies of a browser session are cleared",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Logout of api.wordbubble.io everywhere",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.LogoutResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope, resp.ErrInvalidCsrfToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotRevokeRefreshToken, resp.ErrCouldNotRevokeAccessToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/mfa/totp": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a totp secret and its provisioning uri, which can be shown as a QR code.\nThe secret isn't required at login until it's confirmed with a code at /mfa/totp/confirm",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "mfa"
+                ],
+                "summary": "Enroll a totp secret",
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/resp.EnrollTotpResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrUnknownUser",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "409": {
+                        "description": "resp.ErrMfaAlreadyEnabled",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusConflict"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrSQLMappingError, resp.ErrCouldNotStoreTotpSecret",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Remove the user's totp secret and recovery codes, a current code or an unused recovery code is required",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "mfa"
+                ],
+                "summary": "Disable two-factor authentication",
+                "parameters": [
+                    {
+                        "description": "Code from an authenticator app, or a recovery code",
+                        "name": "Code",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.MfaCodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.DisableMfaResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParseMfaCode, resp.ErrMfaNotEnrolled",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked, resp.ErrInvalidMfaCode",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotCheckMfa, resp.ErrCouldNotDisableMfa",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/mfa/totp/confirm": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Confirm the enrolled totp secret with a code from an authenticator app, which enables two-factor authentication.\nRecovery codes are only returned in this response, each one can be used once in place of a code",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "mfa"
+                ],
+                "summary": "Confirm a totp secret",
+                "parameters": [
+                    {
+                        "description": "Code from an authenticator app",
+                        "name": "Code",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.MfaCodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.RecoveryCodesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParseMfaCode, resp.ErrMfaNotEnrolled",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked, resp.ErrInvalidMfaCode",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "409": {
+                        "description": "resp.ErrMfaAlreadyEnabled",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusConflict"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotCheckMfa, resp.ErrCouldNotStoreRecoveryCodes",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/authorize": {
+            "get": {
+                "description": "Validate the request a third party app sent the user with, returning what should be shown to the user before they approve it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Review an oauth authorization",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Must be code",
+                        "name": "response_type",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Id of the oauth client",
+                        "name": "client_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Must match the redirect uri the client registered",
+                        "name": "redirect_uri",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Scope requested, wordbubble:push and wordbubble:read when empty",
+                        "name": "scope",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Returned to the app untouched",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "S256 PKCE code challenge",
+                        "name": "code_challenge",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Must be S256",
+                        "name": "code_challenge_method",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ConsentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrUnsupportedResponseType, resp.ErrUnknownClient, resp.ErrRedirectUriMismatch, resp.ErrInvalidCodeChallenge, resp.ErrInvalidScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Approve or deny a third party app's access to the user's account, the user is then sent to the redirect uri returned.\nThe app is granted no more than the scope of the access token used to approve it",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Approve an oauth authorization",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Must be code",
+                        "name": "response_type",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Id of the oauth client",
+                        "name": "client_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Must match the redirect uri the client registered",
+                        "name": "redirect_uri",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Scope requested, wordbubble:push and wordbubble:read when empty",
+                        "name": "scope",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Returned to the app untouched",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "S256 PKCE code challenge",
+                        "name": "code_challenge",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Must be S256",
+                        "name": "code_challenge_method",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "Whether the user approved the app",
+                        "name": "Consent",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.ConsentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.AuthorizeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrUnsupportedResponseType, resp.ErrUnknownClient, resp.ErrRedirectUriMismatch, resp.ErrInvalidCodeChallenge, resp.ErrInvalidScope, resp.ErrParseConsent",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotStoreAuthorizationCode",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/clients": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Register a third party app, users are sent to /oauth/authorize with the client id returned.\nClients are public, so every authorization must use PKCE",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Register an oauth client",
+                "parameters": [
+                    {
+                        "description": "Name and redirect uri of the app",
+                        "name": "Client",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.RegisterClientRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/resp.RegisterClientResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParseClient, resp.ErrClientNameIsMissing, resp.ErrClientNameIsTooLong, resp.ErrInvalidRedirectUri",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotStoreClient",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/token": {
+            "post": {
+                "description": "Exchange an authorization code and its PKCE code verifier for tokens, or rotate a refresh token, as defined by RFC 6749.\nThe tokens work on every endpoint that accepts an access token, within the scope the user approved.\nA refresh token can only be rotated by the client it was issued to",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Token to api.wordbubble.io for third party apps",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "authorization_code or refresh_token",
+                        "name": "grant_type",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Id of the oauth client",
+                        "name": "client_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Authorization code, required for authorization_code",
+                        "name": "code",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Redirect uri the code was sent to, required for authorization_code",
+                        "name": "redirect_uri",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "PKCE code verifier, required for authorization_code",
+                        "name": "code_verifier",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Refresh token, required for refresh_token",
+                        "name": "refresh_token",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Narrows the scope of the access token, only for refresh_token",
+                        "name": "scope",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.OAuthTokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrUnsupportedGrantType, resp.ErrInvalidAuthorizationCode, resp.ErrInvalidCodeVerifier, resp.ErrInvalidScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrInvalidClient, resp.ErrTokenIsExpired, resp.ErrSessionIsExpired, resp.ErrCouldNotValidateRefreshToken, resp.ErrRefreshTokenReused",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotUseAuthorizationCode, resp.ErrCouldNotStoreRefreshToken, resp.ErrCouldNotRevokeRefreshToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/oidc/link": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Start linking the user's identity at the identity provider, the user is sent to the url returned and comes back to /login/oidc/callback.\nOnce linked, the user can login with the identity provider, even when it doesn't share a verified email",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Link an identity",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.OidcAuthorizationResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "404": {
+                        "description": "resp.ErrOidcNotConfigured",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusNotFound"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotReachOidcProvider, resp.ErrCouldNotStoreOidcState",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/passkeys": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "List every passkey of the user, along with the last time each passkey was used to login",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "passkeys"
+                ],
+                "summary": "List passkeys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.PasskeysResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotListPasskeys",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Register the passkey created with the options from /passkeys/options, the binary fields of the credential are base64url encoded.\nOnce registered, the passkey can be used to login at /login/passkey",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "passkeys"
+                ],
+                "summary": "Register a passkey",
+                "parameters": [
+                    {
+                        "description": "Name of the passkey and the credential created by the authenticator",
+                        "name": "Passkey",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.PasskeyRegistrationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/resp.Passkey"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParsePasskey, resp.ErrPasskeyNameIsMissing, resp.ErrPasskeyNameIsTooLong, resp.ErrInvalidPasskeyRegistration, resp.ErrUnsupportedPasskeyAlgorithm",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked, resp.ErrInvalidPasskeyChallenge",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "404": {
+                        "description": "resp.ErrPasskeysNotConfigured",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusNotFound"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "409": {
+                        "description": "resp.ErrPasskeyAlreadyRegistered",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusConflict"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotUsePasskeyChallenge, resp.ErrCouldNotCheckPasskey, resp.ErrCouldNotStorePasskey",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/passkeys/options": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Start registering a passkey, the options returned are passed to navigator.credentials.create() in the browser,\nand the credential it creates is sent to /passkeys along with a name. The options expire after five minutes",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "passkeys"
+                ],
+                "summary": "Start registering a passkey",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.PasskeyCreationOptions"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrUnknownUser",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "404": {
+                        "description": "resp.ErrPasskeysNotConfigured",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusNotFound"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrSQLMappingError, resp.ErrCouldNotListPasskeys, resp.ErrCouldNotStorePasskeyChallenge",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/passkeys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Revoke one of the user's passkeys, the passkey stays on the authenticator but logins with it are rejected",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "passkeys"
+                ],
+                "summary": "Revoke a passkey",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Id of the passkey to revoke",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.RevokePasskeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrNoPasskeyId",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "404": {
+                        "description": "resp.ErrUnknownPasskey",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusNotFound"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotRevokePasskey",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/password/forgot": {
+            "post": {
+                "description": "Email a single use password reset token to the user, which can be sent to /password/reset.\nThe same response is returned whether or not the user exists",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "password"
+                ],
+                "summary": "Request a password reset",
+                "parameters": [
+                    {
+                        "description": "Username or email of the user",
+                        "name": "User",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.ForgotPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ForgotPasswordResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParseUser, resp.ErrNoUser, resp.ErrCouldNotDetermineUserType",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrSQLMappingError, resp.ErrCouldNotStorePasswordReset, resp.ErrCouldNotSendEmail",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/password/reset": {
+            "post": {
+                "description": "Choose a new password using the token emailed from /password/forgot, the token can only be used once.\nEvery refresh token of the user is revoked, so each device has to login again",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "password"
+                ],
+                "summary": "Reset a password",
+                "parameters": [
+                    {
+                        "description": "Reset token and the new password",
+                        "name": "Reset",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.ResetPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ResetPasswordResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParsePasswordReset, resp.ErrInvalidResetToken, InvalidPassword, resp.ErrPasswordIsCommon, resp.ErrPasswordIsTooGuessable",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotBeHashPassword, resp.ErrCouldNotResetPassword, resp.ErrCouldNotRevokeRefreshToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/pop": {
+            "delete": {
+                "description": "Pop removes and returns a wordbubble for a user.\nBrowsers with a cookie session must send the wb_csrf cookie back in the X-CSRF-Token header",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "wordbubble"
+                ],
+                "summary": "Pop a wordbubble",
+                "parameters": [
+                    {
+                        "description": "Username or email that the wordbubble will come from",
+                        "name": "UnauthenticatedUser",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.PopUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Latest Wordbubble for user passed",
+                        "schema": {
+                            "$ref": "#/definitions/resp.WordbubbleResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "resp.ErrNoWordbubble",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusNoContent"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParseUser, resp.ErrNoUser, resp.ErrUnknownUser, resp.ErrCouldNotDetermineUserType",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrInvalidCredentials",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInvalidCsrfToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrSQLMappingError, resp.ErrCouldNotStoreRefreshToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/push": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "PersonalApiKey": []
+                    }
+                ],
+                "description": "Push adds a new wordbubble to a user's queue, the user's email may need to be verified first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "wordbubble"
+                ],
+                "summary": "Push a wordbubble",
+                "parameters": [
+                    {
+                        "description": "Wordbubble containing the text to be stored",
+                        "name": "Wordbubble",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.WordbubbleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/resp.PushResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParseWordbubble, InvalidWordbubble",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked, resp.ErrInvalidApiKey",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope, resp.ErrEmailNotVerified",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "409": {
+                        "description": "resp.ErrMaxAmountOfWordbubblesReached",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusConflict"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrSQLMappingError, resp.UnknownError",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "List every active session of the user, along with the device each session was last used on",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "List sessions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.SessionsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotListSessions",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Revoke one of the user's sessions, the device using it will have to login again once its access token expires",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Revoke a session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Id of the session to revoke",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.RevokeSessionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrNoSessionId",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "404": {
+                        "description": "resp.ErrUnknownSession",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusNotFound"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrCouldNotRevokeRefreshToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/signup": {
+            "post": {
+                "description": "Signup to api.wordbubble.io using a unique email and username, a verification token is emailed to the user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Signup to api.wordbubble.io",
+                "parameters": [
+                    {
+                        "description": "User information required to signup",
+                        "name": "User",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.SignupUserRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Send cookie to receive the tokens as HttpOnly cookies, resp.CookieSessionResponse is returned instead",
+                        "name": "X-Session-Mode",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParseUser, resp.ErrEmailIsNotValid, resp.ErrEmailIsTooLong, resp.ErrUsernameIsTooLong, resp.ErrUsernameIsNotLongEnough, resp.ErrUsernameInvalidChars, resp.ErrUserWithUsernameAlreadyExists, resp.ErrUserWithEmailAlreadyExists, resp.ErrCouldNotDetermineUserExistence, InvalidPassword, resp.ErrPasswordIsCommon, resp.ErrPasswordIsTooGuessable",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotBeHashPassword, resp.ErrCouldNotAddUser, resp.ErrCouldNotStoreRefreshToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/token": {
+            "post": {
+                "description": "Token to api.wordbubble.io for authorized use, the refresh token passed is revoked and replaced.\nThe access token can be granted a narrower scope than the refresh token. Refresh tokens issued to oauth clients are refreshed at /oauth/token instead.\nThe replacement never outlives the max session lifetime counted from login, the user must login again after it.\nA browser session can send an empty body, the refresh token cookie is used instead and the replacements are set as cookies,\nwhich needs the wb_csrf cookie sent back in the X-CSRF-Token header",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Token to api.wordbubble.io",
+                "parameters": [
+                    {
+                        "description": "Valid refresh token to gain a new access token",
+                        "name": "Token",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/req.RefreshTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrParseRefreshToken, resp.ErrClientRefreshToken, resp.ErrInvalidScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrTokenIsExpired, resp.ErrSessionIsExpired, resp.ErrCouldNotValidateRefreshToken, resp.ErrRefreshTokenReused",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInvalidCsrfToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotRetrieveRole, resp.ErrCouldNotStoreRefreshToken, resp.ErrCouldNotRevokeRefreshToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/verify": {
+            "get": {
+                "description": "Verify the email of a user with the token sent at signup or from /verify/resend, the token can only be used once",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "verify"
+                ],
+                "summary": "Verify an email",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Verification token from the email",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.VerifyEmailResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrNoVerificationToken, resp.ErrInvalidVerificationToken",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotVerifyEmail",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/verify/resend": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Email another verification token to the user, tokens sent before stay valid until they expire",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "verify"
+                ],
+                "summary": "Resend a verification email",
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/resp.VerificationSentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "resp.ErrUnknownUser",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusBadRequest"
+                        }
+                    },
+                    "401": {
+                        "description": "resp.ErrUnauthorized, resp.ErrMalformedToken, resp.ErrInvalidTokenSignature, resp.ErrTokenIsExpired, resp.ErrTokenIsNotYetValid, resp.ErrInvalidTokenType, resp.ErrInvalidTokenClaims, resp.ErrTokenIsRevoked",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusUnauthorized"
+                        }
+                    },
+                    "403": {
+                        "description": "resp.ErrInsufficientScope",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusForbidden"
+                        }
+                    },
+                    "405": {
+                        "description": "resp.ErrInvalidHttpMethod",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusMethodNotAllowed"
+                        }
+                    },
+                    "409": {
+                        "description": "resp.ErrEmailAlreadyVerified",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusConflict"
+                        }
+                    },
+                    "500": {
+                        "description": "resp.ErrCouldNotCheckDenylist, resp.ErrSQLMappingError, resp.ErrCouldNotStoreVerification, resp.ErrCouldNotSendEmail",
+                        "schema": {
+                            "$ref": "#/definitions/resp.StatusInternalServerError"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "req.AssignRoleRequest": {
+            "description": "AssignRoleRequest contains the username or email of a user, and the role they're assigned",
+            "type": "object",
+            "properties": {
+                "role": {
+                    "description": "user, moderator or admin",
+                    "type": "string",
+                    "example": "moderator"
+                },
+                "user": {
+                    "type": "string",
+                    "example": "ben"
+                }
+            }
+        },
+        "req.BeginPasskeyLoginRequest": {
+            "description": "BeginPasskeyLoginRequest optionally contains the scope the tokens are granted after logging in with a passkey",
+            "type": "object",
+            "properties": {
+                "remember_me": {
+                    "description": "optional, the session lasts longer when true",
+                    "type": "boolean",
+                    "example": true
+                },
+                "scope": {
+                    "description": "optional, every scope when empty",
+                    "type": "string",
+                    "example": "wordbubble:push wordbubble:read"
+                }
+            }
+        },
+        "req.ConsentRequest": {
+            "description": "ConsentRequest contains whether a user approved a third party app's access to their account",
+            "type": "object",
+            "properties": {
+                "approve": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "req.CreateApiKeyRequest": {
+            "description": "CreateApiKeyRequest contains the name of a new api key",
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "nightly cron"
+                },
+                "scope": {
+                    "description": "optional, the scope of the access token when empty",
+                    "type": "string",
+                    "example": "wordbubble:push"
+                }
+            }
+        },
+        "req.ForgotPasswordRequest": {
+            "description": "ForgotPasswordRequest contains the username or email of a user who forgot their password",
+            "type": "object",
+            "properties": {
+                "user": {
+                    "type": "string",
+                    "example": "ben"
+                }
+            }
+        },
+        "req.LoginLinkRequest": {
+            "description": "LoginLinkRequest contains the email a login link is sent to, and optionally the scope the tokens are granted",
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "ben@wordbubble.com"
+                },
+                "remember_me": {
+                    "description": "optional, the session lasts longer when true",
+                    "type": "boolean",
+                    "example": true
+                },
+                "scope": {
+                    "description": "optional, every scope when empty",
+                    "type": "string",
+                    "example": "wordbubble:push wordbubble:read"
+                }
+            }
+        },
+        "req.LoginMfaRequest": {
+            "description": "LoginMfaRequest contains the mfa token returned from /login, and a code from an authenticator app or a recovery code",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "287082"
+                },
+                "mfa_token": {
+                    "type": "string",
+                    "example": "7d793037a0760186574b0282f2f435e7"
+                },
+                "remember_me": {
+                    "description": "optional, the session lasts longer when true",
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
         "req.LoginUserRequest": {
             "description": "LoginUserRequest is the body sent to the /login operation",
             "type": "object",
             "properties": {
-                "password": {
+                "password": {
+                    "type": "string",
+                    "example": "SomePassword_123"
+                },
+                "remember_me": {
+                    "description": "optional, the session lasts longer when true",
+                    "type": "boolean",
+                    "example": true
+                },
+                "scope": {
+                    "description": "optional, every scope when empty",
+                    "type": "string",
+                    "example": "wordbubble:push wordbubble:read"
+                },
+                "user": {
+                    "type": "string",
+                    "example": "ben"
+                }
+            }
+        },
+        "req.MfaCodeRequest": {
+            "description": "MfaCodeRequest contains a code from an authenticator app, or a recovery code",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "287082"
+                }
+            }
+        },
+        "req.OidcLoginRequest": {
+            "description": "OidcLoginRequest optionally contains the scope the tokens are granted after logging in with the identity provider",
+            "type": "object",
+            "properties": {
+                "remember_me": {
+                    "description": "optional, the session lasts longer when true",
+                    "type": "boolean",
+                    "example": true
+                },
+                "scope": {
+                    "description": "optional, every scope when empty",
+                    "type": "string",
+                    "example": "wordbubble:push wordbubble:read"
+                }
+            }
+        },
+        "req.PasskeyAuthenticatorResponse": {
+            "description": "PasskeyAuthenticatorResponse is what the authenticator responded with, the attestation object is sent on registration, and the authenticator data, signature and user handle are sent on login",
+            "type": "object",
+            "properties": {
+                "attestationObject": {
+                    "description": "registration only",
+                    "type": "string",
+                    "example": "o2NmbXRkbm9uZWdhdHRTdG10oGhhdXRoRGF0YQ"
+                },
+                "authenticatorData": {
+                    "description": "login only",
+                    "type": "string",
+                    "example": "SZYN5YgOjGh0NBcPZHZgW4_krrmihjLHmVzzuoMdl2M"
+                },
+                "clientDataJSON": {
+                    "type": "string",
+                    "example": "eyJ0eXBlIjoid2ViYXV0aG4uZ2V0In0"
+                },
+                "signature": {
+                    "description": "login only",
+                    "type": "string",
+                    "example": "MEUCIQDw"
+                },
+                "userHandle": {
+                    "description": "login only, optional",
+                    "type": "string",
+                    "example": "AAAAAAAAAAI"
+                }
+            }
+        },
+        "req.PasskeyCredential": {
+            "description": "PasskeyCredential is the credential a browser returns from navigator.credentials.create or navigator.credentials.get, serialized with toJSON as defined by WebAuthn, every binary field is base64url encoded",
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "Z2Nvb2dsZS1wYXNza2V5"
+                },
+                "rawId": {
+                    "type": "string",
+                    "example": "Z2Nvb2dsZS1wYXNza2V5"
+                },
+                "response": {
+                    "$ref": "#/definitions/req.PasskeyAuthenticatorResponse"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "public-key"
+                }
+            }
+        },
+        "req.PasskeyRegistrationRequest": {
+            "description": "PasskeyRegistrationRequest contains a name for a passkey, and the credential the browser returned from navigator.credentials.create",
+            "type": "object",
+            "properties": {
+                "credential": {
+                    "$ref": "#/definitions/req.PasskeyCredential"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "work laptop"
+                }
+            }
+        },
+        "req.PopUserRequest": {
+            "description": "PopUserRequest contains the data to remove and return a wordbubble",
+            "type": "object",
+            "properties": {
+                "user": {
+                    "type": "string",
+                    "example": "ben"
+                }
+            }
+        },
+        "req.RefreshTokenRequest": {
+            "description": "RefreshTokenRequest contains the token string of a refresh token",
+            "type": "object",
+            "properties": {
+                "refresh_token": {
+                    "type": "string",
+                    "example": "xxx.yyy.zzz"
+                },
+                "scope": {
+                    "description": "optional, narrows the scope of the access token",
+                    "type": "string",
+                    "example": "wordbubble:push"
+                }
+            }
+        },
+        "req.RegisterClientRequest": {
+            "description": "RegisterClientRequest contains the name and redirect uri of a third party app",
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "wordbubble for desktop"
+                },
+                "redirect_uri": {
+                    "type": "string",
+                    "example": "https://app.example.com/callback"
+                }
+            }
+        },
+        "req.ResetPasswordRequest": {
+            "description": "ResetPasswordRequest contains the token emailed from /password/forgot, and the new password",
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "SomePassword_123"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "9c1185a5c5e9fc54612808977ee8f548b2258d31"
+                }
+            }
+        },
+        "req.SignupUserRequest": {
+            "description": "SignupUserRequest contains the data to signup a new user",
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "benchadwick87@gmail.com"
+                },
+                "password": {
+                    "type": "string",
+                    "example": "Hello123!"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "ben"
+                }
+            }
+        },
+        "req.WordbubbleRequest": {
+            "description": "WordbubbleRequest contains the data sent from a user",
+            "type": "object",
+            "properties": {
+                "text": {
+                    "type": "string",
+                    "example": "Hello world, this is just an example of a wordbubble"
+                }
+            }
+        },
+        "resp.ApiKey": {
+            "description": "ApiKey is a long lived key a user created for scripts, the key itself is never returned again",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "integer",
+                    "example": 1665964800
+                },
+                "id": {
+                    "type": "string",
+                    "example": "9f86d081"
+                },
+                "last_used_at": {
+                    "type": "integer",
+                    "example": 1665968400
+                },
+                "name": {
+                    "type": "string",
+                    "example": "nightly cron"
+                },
+                "scope": {
+                    "type": "string",
+                    "example": "wordbubble:push"
+                }
+            }
+        },
+        "resp.ApiKeysResponse": {
+            "description": "ApiKeysResponse contains every api key of a user",
+            "type": "object",
+            "properties": {
+                "api_keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/resp.ApiKey"
+                    }
+                }
+            }
+        },
+        "resp.AuthorizeResponse": {
+            "description": "AuthorizeResponse contains the url the user is sent back to the third party app with, holding either a code or an error",
+            "type": "object",
+            "properties": {
+                "redirect_uri": {
+                    "type": "string",
+                    "example": "https://app.example.com/callback?code=9a0364b9e99bb480dd25e1f0284c8555\u0026state=af0ifjsldkj"
+                }
+            }
+        },
+        "resp.ConsentResponse": {
+            "description": "ConsentResponse contains what a third party app is asking for, shown to the user before they approve it",
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "string",
+                    "example": "5d41402abc4b2a76b9719d911017c592"
+                },
+                "client_name": {
+                    "type": "string",
+                    "example": "wordbubble for desktop"
+                },
+                "redirect_uri": {
+                    "type": "string",
+                    "example": "https://app.example.com/callback"
+                },
+                "scope": {
+                    "type": "string",
+                    "example": "wordbubble:push wordbubble:read"
+                }
+            }
+        },
+        "resp.CreateApiKeyResponse": {
+            "description": "CreateApiKeyResponse contains a new api key, the key is only ever returned once",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "integer",
+                    "example": 1665964800
+                },
+                "id": {
+                    "type": "string",
+                    "example": "9f86d081"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "wb_2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "nightly cron"
+                },
+                "scope": {
+                    "type": "string",
+                    "example": "wordbubble:push"
+                }
+            }
+        },
+        "resp.DisableMfaResponse": {
+            "description": "DisableMfaResponse contains the success text response from disabling two-factor authentication",
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "two-factor authentication has been disabled"
+                }
+            }
+        },
+        "resp.EnrollTotpResponse": {
+            "description": "EnrollTotpResponse contains a new totp secret, it isn't required at login until it's confirmed with a code",
+            "type": "object",
+            "properties": {
+                "provisioning_uri": {
+                    "type": "string",
+                    "example": "otpauth://totp/wordbubble:ben?algorithm=SHA1\u0026digits=6\u0026issuer=wordbubble\u0026period=30\u0026secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+                },
+                "secret": {
+                    "type": "string",
+                    "example": "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+                }
+            }
+        },
+        "resp.ForgotPasswordResponse": {
+            "description": "ForgotPasswordResponse contains the success text response from requesting a password reset",
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "if the user exists, a password reset email has been sent"
+                }
+            }
+        },
+        "resp.JWK": {
+            "description": "JWK is a public key used to verify tokens, as defined by RFC 7517",
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string",
+                    "example": "EdDSA"
+                },
+                "crv": {
+                    "type": "string",
+                    "example": "Ed25519"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string",
+                    "example": "2022-10"
+                },
+                "kty": {
+                    "type": "string",
+                    "example": "OKP"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string",
+                    "example": "sig"
+                },
+                "x": {
+                    "type": "string",
+                    "example": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"
+                }
+            }
+        },
+        "resp.JWKSResponse": {
+            "description": "JWKSResponse contains the public keys used to verify tokens",
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/resp.JWK"
+                    }
+                }
+            }
+        },
+        "resp.LoginLinkResponse": {
+            "description": "LoginLinkResponse contains the success text response from requesting a login link",
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "if an account uses this email, a login link has been sent"
+                }
+            }
+        },
+        "resp.LogoutResponse": {
+            "description": "LogoutResponse contains the success text response from revoking refresh tokens",
+            "type": "object",
+            "properties": {
+                "message": {
                     "type": "string",
-                    "example": "SomePassword_123"
+                    "example": "you have been logged out"
+                }
+            }
+        },
+        "resp.MfaChallengeResponse": {
+            "description": "MfaChallengeResponse is returned from /login when two-factor authentication is enabled, the token is exchanged at /login/mfa",
+            "type": "object",
+            "properties": {
+                "expires_in": {
+                    "type": "integer",
+                    "example": 300
                 },
-                "user": {
+                "mfa_token": {
                     "type": "string",
-                    "example": "ben"
+                    "example": "7d793037a0760186574b0282f2f435e7"
                 }
             }
         },
-        "req.PopUserRequest": {
-            "description": "PopUserRequest contains the data to remove and return a wordbubble",
+        "resp.OAuthTokenResponse": {
+            "description": "OAuthTokenResponse contains the tokens issued to a third party app, as defined by RFC 6749",
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string",
+                    "example": "xxx.yyy.zzz"
+                },
+                "expires_in": {
+                    "type": "integer",
+                    "example": 30
+                },
+                "refresh_token": {
+                    "type": "string",
+                    "example": "xxx.yyy.zzz"
+                },
+                "scope": {
+                    "type": "string",
+                    "example": "wordbubble:push wordbubble:read"
+                },
+                "token_type": {
+                    "type": "string",
+                    "example": "Bearer"
+                }
+            }
+        },
+        "resp.OidcAuthorizationResponse": {
+            "description": "OidcAuthorizationResponse contains the url of the identity provider the user is sent to, to login",
+            "type": "object",
+            "properties": {
+                "authorization_url": {
+                    "type": "string",
+                    "example": "https://accounts.example.com/authorize?client_id=wordbubble\u0026response_type=code\u0026state=9c1185a5c5e9fc54"
+                }
+            }
+        },
+        "resp.Passkey": {
+            "description": "Passkey is a passkey a user registered, its public key is never returned",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "integer",
+                    "example": 1665964800
+                },
+                "id": {
+                    "type": "string",
+                    "example": "Z2Nvb2dsZS1wYXNza2V5"
+                },
+                "last_used_at": {
+                    "type": "integer",
+                    "example": 1665968400
+                },
+                "name": {
+                    "type": "string",
+                    "example": "work laptop"
+                }
+            }
+        },
+        "resp.PasskeyAuthenticatorSelection": {
+            "description": "PasskeyAuthenticatorSelection asks for a passkey the authenticator can find without being told its id",
+            "type": "object",
+            "properties": {
+                "requireResidentKey": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "residentKey": {
+                    "type": "string",
+                    "example": "required"
+                },
+                "userVerification": {
+                    "type": "string",
+                    "example": "preferred"
+                }
+            }
+        },
+        "resp.PasskeyCreationOptions": {
+            "description": "PasskeyCreationOptions are passed to navigator.credentials.create to register a passkey, after parsing them with PublicKeyCredential.parseCreationOptionsFromJSON, as defined by WebAuthn. The challenge can only be used once",
             "type": "object",
             "properties": {
+                "attestation": {
+                    "type": "string",
+                    "example": "none"
+                },
+                "authenticatorSelection": {
+                    "$ref": "#/definitions/resp.PasskeyAuthenticatorSelection"
+                },
+                "challenge": {
+                    "type": "string",
+                    "example": "q83vEjRWeJq83vEjRWeJq83vEjRWeJq83vEjRWeJq80"
+                },
+                "excludeCredentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/resp.PasskeyCredentialDescriptor"
+                    }
+                },
+                "pubKeyCredParams": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/resp.PasskeyCredentialParameters"
+                    }
+                },
+                "rp": {
+                    "$ref": "#/definitions/resp.PasskeyRelyingParty"
+                },
+                "timeout": {
+                    "description": "milliseconds",
+                    "type": "integer",
+                    "example": 300000
+                },
                 "user": {
+                    "$ref": "#/definitions/resp.PasskeyUser"
+                }
+            }
+        },
+        "resp.PasskeyCredentialDescriptor": {
+            "description": "PasskeyCredentialDescriptor identifies a passkey that's already registered",
+            "type": "object",
+            "properties": {
+                "id": {
                     "type": "string",
-                    "example": "ben"
+                    "example": "Z2Nvb2dsZS1wYXNza2V5"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "public-key"
                 }
             }
         },
-        "req.RefreshTokenRequest": {
-            "description": "RefreshTokenRequest contains the token string of a refresh token",
+        "resp.PasskeyCredentialParameters": {
+            "description": "PasskeyCredentialParameters is an algorithm a passkey can sign with, as a COSE algorithm identifier",
             "type": "object",
             "properties": {
-                "refresh_token": {
+                "alg": {
+                    "type": "integer",
+                    "example": -7
+                },
+                "type": {
                     "type": "string",
-                    "example": "xxx.yyy.zzz"
+                    "example": "public-key"
                 }
             }
         },
-        "req.SignupUserRequest": {
-            "description": "SignupUserRequest contains the data to signup a new user",
+        "resp.PasskeyRelyingParty": {
+            "description": "PasskeyRelyingParty is the site a passkey is registered for",
             "type": "object",
             "properties": {
-                "email": {
+                "id": {
                     "type": "string",
-                    "example": "benchadwick87@gmail.com"
+                    "example": "wordbubble.com"
                 },
-                "password": {
+                "name": {
                     "type": "string",
-                    "example": "Hello123!"
+                    "example": "wordbubble"
+                }
+            }
+        },
+        "resp.PasskeyRequestOptions": {
+            "description": "PasskeyRequestOptions are passed to navigator.credentials.get to login with a passkey, after parsing them with PublicKeyCredential.parseRequestOptionsFromJSON, as defined by WebAuthn. The challenge can only be used once",
+            "type": "object",
+            "properties": {
+                "allowCredentials": {
+                    "description": "empty, any passkey registered for wordbubble can be used",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/resp.PasskeyCredentialDescriptor"
+                    }
                 },
-                "username": {
+                "challenge": {
                     "type": "string",
-                    "example": "ben"
+                    "example": "q83vEjRWeJq83vEjRWeJq83vEjRWeJq83vEjRWeJq80"
+                },
+                "rpId": {
+                    "type": "string",
+                    "example": "wordbubble.com"
+                },
+                "timeout": {
+                    "description": "milliseconds",
+                    "type": "integer",
+                    "example": 300000
+                },
+                "userVerification": {
+                    "type": "string",
+                    "example": "preferred"
                 }
             }
         },
-        "req.WordbubbleRequest": {
-            "description": "WordbubbleRequest contains the data sent from a user",
+        "resp.PasskeyUser": {
+            "description": "PasskeyUser is the user a passkey is registered to, the id is the user handle returned on login",
             "type": "object",
             "properties": {
-                "text": {
+                "displayName": {
                     "type": "string",
-                    "example": "Hello world, this is just an example of a wordbubble"
+                    "example": "ben"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "AAAAAAAAAAI"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "ben"
+                }
+            }
+        },
+        "resp.PasskeysResponse": {
+            "description": "PasskeysResponse contains every passkey of a user",
+            "type": "object",
+            "properties": {
+                "passkeys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/resp.Passkey"
+                    }
                 }
             }
         },
@@ -399,6 +3198,136 @@ const docTemplate = `{
                 }
             }
         },
+        "resp.RecoveryCodesResponse": {
+            "description": "RecoveryCodesResponse contains one time codes that can be used in place of a totp code, they're only ever returned once",
+            "type": "object",
+            "properties": {
+                "recovery_codes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "4f1a9-c2e07-8b3d5-17f6a",
+                        "09e2c-d41b7-a6f30-5c8e1"
+                    ]
+                }
+            }
+        },
+        "resp.RegisterClientResponse": {
+            "description": "RegisterClientResponse contains a new oauth client, apps use the id to send users to /oauth/authorize",
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "string",
+                    "example": "5d41402abc4b2a76b9719d911017c592"
+                },
+                "created_at": {
+                    "type": "integer",
+                    "example": 1665964800
+                },
+                "name": {
+                    "type": "string",
+                    "example": "wordbubble for desktop"
+                },
+                "redirect_uri": {
+                    "type": "string",
+                    "example": "https://app.example.com/callback"
+                }
+            }
+        },
+        "resp.ResetPasswordResponse": {
+            "description": "ResetPasswordResponse contains the success text response from resetting a password",
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "your password has been reset, please login again"
+                }
+            }
+        },
+        "resp.RevokeApiKeyResponse": {
+            "description": "RevokeApiKeyResponse contains the success text response from revoking an api key",
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "api key has been revoked"
+                }
+            }
+        },
+        "resp.RevokePasskeyResponse": {
+            "description": "RevokePasskeyResponse contains the success text response from revoking a passkey",
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "passkey has been revoked"
+                }
+            }
+        },
+        "resp.RevokeSessionResponse": {
+            "description": "RevokeSessionResponse contains the success text response from revoking a session",
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "session has been revoked"
+                }
+            }
+        },
+        "resp.RoleResponse": {
+            "description": "RoleResponse contains the user a role was assigned to, and the role they have now",
+            "type": "object",
+            "properties": {
+                "role": {
+                    "type": "string",
+                    "example": "moderator"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "ben"
+                }
+            }
+        },
+        "resp.Session": {
+            "description": "Session is a login on a device, it stays active as long as its refresh token is rotated",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "integer",
+                    "example": 1665964800
+                },
+                "id": {
+                    "type": "string",
+                    "example": "1b4e28ba2fa1f1d2883f0b6c4e7a9d05"
+                },
+                "ip_address": {
+                    "type": "string",
+                    "example": "203.0.113.7"
+                },
+                "last_used_at": {
+                    "type": "integer",
+                    "example": 1665968400
+                },
+                "user_agent": {
+                    "type": "string",
+                    "example": "Mozilla/5.0 (X11; Linux x86_64; rv:105.0) Gecko/20100101 Firefox/105.0"
+                }
+            }
+        },
+        "resp.SessionsResponse": {
+            "description": "SessionsResponse contains every active session of a user",
+            "type": "object",
+            "properties": {
+                "sessions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/resp.Session"
+                    }
+                }
+            }
+        },
         "resp.StatusBadRequest": {
             "description": "StatusBadRequest - 400",
             "type": "object",
@@ -427,6 +3356,20 @@ const docTemplate = `{
                 }
             }
         },
+        "resp.StatusForbidden": {
+            "description": "StatusForbidden - 403",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer",
+                    "example": 403
+                },
+                "message": {
+                    "type": "string",
+                    "example": "token does not have the scope required for this operation"
+                }
+            }
+        },
         "resp.StatusInternalServerError": {
             "description": "StatusInternalServerError - 500",
             "type": "object",
@@ -441,6 +3384,20 @@ const docTemplate = `{
                 }
             }
         },
+        "resp.StatusLocked": {
+            "description": "StatusLocked - 423",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer",
+                    "example": 423
+                },
+                "message": {
+                    "type": "string",
+                    "example": "account is temporarily locked after too many failed login attempts"
+                }
+            }
+        },
         "resp.StatusMethodNotAllowed": {
             "description": "StatusMethodNotAllowed - 405",
             "type": "object",
@@ -469,6 +3426,34 @@ const docTemplate = `{
                 }
             }
         },
+        "resp.StatusNotFound": {
+            "description": "StatusNotFound - 404",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "message": {
+                    "type": "string",
+                    "example": "could not find an active session with this id"
+                }
+            }
+        },
+        "resp.StatusTooManyRequests": {
+            "description": "StatusTooManyRequests - 429",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer",
+                    "example": 429
+                },
+                "message": {
+                    "type": "string",
+                    "example": "too many failed login attempts, please wait before trying again"
+                }
+            }
+        },
         "resp.StatusUnauthorized": {
             "description": "StatusUnauthorized - 401",
             "type": "object",
@@ -497,6 +3482,26 @@ const docTemplate = `{
                 }
             }
         },
+        "resp.VerificationSentResponse": {
+            "description": "VerificationSentResponse contains the success text response from resending a verification email",
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "a verification email has been sent"
+                }
+            }
+        },
+        "resp.VerifyEmailResponse": {
+            "description": "VerifyEmailResponse contains the success text response from verifying an email",
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "your email has been verified"
+                }
+            }
+        },
         "resp.WordbubbleResponse": {
             "description": "WordbubbleResponse contains the text returned from the database",
             "type": "object",
@@ -514,6 +3519,12 @@ const docTemplate = `{
             "type": "apiKey",
             "name": "Authorization",
             "in": "header"
+        },
+        "PersonalApiKey": {
+            "description": "Personal api key created from /apikeys, sent as \"ApiKey wb_...\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
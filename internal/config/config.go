@@ -2,8 +2,13 @@ package config
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bchadwic/wordbubble/util"
 	_ "github.com/lib/pq"
@@ -15,33 +20,108 @@ type Config interface {
 	DB() *sql.DB
 	Port() string
 	Timer() util.Timer
+	Keys() util.KeyProvider
+	Mailer() util.Mailer
+	PasswordHasher() util.PasswordHasher
+	TokenHashKey() []byte
+	TokenLifetimes() util.TokenLifetimes
+	RequireVerifiedEmail() bool
+	OidcProvider() util.OidcProvider
+	RelyingParty() util.WebauthnRelyingParty
+	BootstrapAdmin() string
+	TrustedProxies() util.TrustedProxies
 }
 
 type config struct {
-	db *sql.DB
+	db        *sql.DB
+	keys      util.KeyProvider
+	mailer    util.Mailer
+	hasher    util.PasswordHasher
+	token     []byte
+	lifetimes util.TokenLifetimes
+	oidc      util.OidcProvider
+	rp        util.WebauthnRelyingParty
+	proxies   util.TrustedProxies
 }
 
 type testConfig struct {
-	db    *sql.DB
-	timer util.Timer
+	db                   *sql.DB
+	timer                util.Timer
+	keys                 util.KeyProvider
+	mailer               util.Mailer
+	hasher               util.PasswordHasher
+	tokenHashKey         []byte
+	lifetimes            util.TokenLifetimes
+	requireVerifiedEmail bool
+	oidcProvider         util.OidcProvider
+	relyingParty         util.WebauthnRelyingParty
+	bootstrapAdmin       string
+	trustedProxies       util.TrustedProxies
 }
 
+const defaultSigningKeyGracePeriod = 24 * time.Hour
+
 // NewConfig sets the configuration for the api using the environment settings
 // the config returned may be nil if not all the dependencies could be successfully created
 func NewConfig() *config {
 	var cfg config
 	log := cfg.NewLogger("config")
 	log.Info("generating configuration")
-	util.SigningKey = func() []byte {
-		if s := os.Getenv("WB_SIGNING_KEY"); s != "" {
-			return []byte(s)
-		}
+	keys, err := newKeyProvider()
+	if err != nil {
+		log.Error("signing keys could not be loaded: " + err.Error())
+		return nil
+	}
+	cfg.keys = keys
+	mailer, err := newMailer()
+	if err != nil {
+		log.Error("mailer could not be created: " + err.Error())
+		return nil
+	}
+	cfg.mailer = mailer
+	hasher, err := newPasswordHasher()
+	if err != nil {
+		log.Error("password hasher could not be created: " + err.Error())
+		return nil
+	}
+	cfg.hasher = hasher
+	cfg.token = []byte(os.Getenv("WB_TOKEN_HASH_KEY"))
+	if len(cfg.token) == 0 {
+		log.Warn("WB_TOKEN_HASH_KEY is not set, refresh tokens are hashed with the signing key")
+		cfg.token = []byte(os.Getenv("WB_SIGNING_KEY"))
+	}
+	lifetimes, err := newTokenLifetimes()
+	if err != nil {
+		log.Error("token lifetimes are not valid: " + err.Error())
+		return nil
+	}
+	cfg.lifetimes = lifetimes
+	oidc, err := newOidcProvider()
+	if err != nil {
+		log.Error("oidc provider is not valid: " + err.Error())
+		return nil
+	}
+	cfg.oidc = oidc
+	rp, err := newRelyingParty()
+	if err != nil {
+		log.Error("webauthn relying party is not valid: " + err.Error())
 		return nil
 	}
-	if util.SigningKey() == nil {
-		log.Error("signing key is not set")
+	cfg.rp = rp
+	proxies, err := util.ParseTrustedProxies(os.Getenv("WB_TRUSTED_PROXIES"))
+	if err != nil {
+		log.Error("trusted proxies are not valid: " + err.Error())
 		return nil
 	}
+	cfg.proxies = proxies
+	if path := os.Getenv("WB_COMMON_PASSWORDS_FILE"); path != "" {
+		if err := util.LoadCommonPasswords(path); err != nil {
+			log.Error("common password list could not be loaded: " + err.Error())
+			return nil
+		}
+	} else {
+		log.Warn("WB_COMMON_PASSWORDS_FILE is not set, passwords are only screened against the small embedded list of common passwords")
+	}
 	db, err := sql.Open("postgres", os.Getenv("DSN"))
 	if err != nil {
 		log.Error("db creation failed: " + err.Error())
@@ -55,10 +135,205 @@ func NewConfig() *config {
 	return &cfg
 }
 
+// newKeyProvider creates the signing keys using the environment settings.
+// WB_SIGNING_KEY is the active key, with an optional id of WB_SIGNING_KEY_ID, signing with WB_SIGNING_ALGORITHM.
+// The algorithm is HS256 by default, WB_SIGNING_KEY is a PEM encoded private key when it's RS256 or EdDSA.
+// WB_RETIRED_SIGNING_KEYS holds keys that verify tokens during the grace period, WB_SIGNING_KEY_GRACE_PERIOD,
+// in the format "id:retired_at:secret,..." where retired_at is the unix time the key stopped signing tokens
+func newKeyProvider() (util.KeyProvider, error) {
+	policy, err := newClaimsPolicy()
+	if err != nil {
+		return nil, err
+	}
+	secret := os.Getenv("WB_SIGNING_KEY")
+	if secret == "" {
+		return nil, errors.New("signing key is not set")
+	}
+	id := util.DefaultKeyId
+	if s := os.Getenv("WB_SIGNING_KEY_ID"); s != "" {
+		id = s
+	}
+	algorithm := util.HS256
+	if s := os.Getenv("WB_SIGNING_ALGORITHM"); s != "" {
+		algorithm = s
+	}
+	active, err := util.NewKey(id, algorithm, []byte(secret))
+	if err != nil {
+		return nil, err
+	}
+	gracePeriod := defaultSigningKeyGracePeriod
+	if s := os.Getenv("WB_SIGNING_KEY_GRACE_PERIOD"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("grace period is not a valid duration: %s", s)
+		}
+		gracePeriod = d
+	}
+	var retired []*util.Key
+	if s := os.Getenv("WB_RETIRED_SIGNING_KEYS"); s != "" {
+		for _, entry := range strings.Split(s, ",") {
+			parts := strings.SplitN(entry, ":", 3)
+			if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+				return nil, errors.New("retired signing keys must be in the format id:retired_at:secret")
+			}
+			if parts[0] == active.Id {
+				return nil, fmt.Errorf("retired signing key has the same id as the active key: %s", parts[0])
+			}
+			retiredAt, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("retired signing key %s has an invalid retired_at: %s", parts[0], parts[1])
+			}
+			key, err := util.NewKey(parts[0], "", []byte(parts[2])) // algorithm is determined by the secret
+			if err != nil {
+				return nil, err
+			}
+			key.RetiredAt = retiredAt
+			retired = append(retired, key)
+		}
+	}
+	return util.NewKeyProvider(util.NewTimer(), gracePeriod, policy, active, retired...), nil
+}
+
+// newClaimsPolicy creates the policy for the claims of tokens using the environment settings.
+// WB_TOKEN_ISSUER and WB_TOKEN_AUDIENCE are set as the iss and aud of every token, which must match when it's parsed.
+// WB_TOKEN_LEEWAY is how far apart the clocks of the instances can be when the times of a token are checked
+func newClaimsPolicy() (util.ClaimsPolicy, error) {
+	policy := util.DefaultClaimsPolicy()
+	if s := os.Getenv("WB_TOKEN_ISSUER"); s != "" {
+		policy.Issuer = s
+	}
+	if s := os.Getenv("WB_TOKEN_AUDIENCE"); s != "" {
+		policy.Audience = s
+	}
+	if s := os.Getenv("WB_TOKEN_LEEWAY"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return policy, fmt.Errorf("WB_TOKEN_LEEWAY is not a valid duration: %s", s)
+		}
+		policy.Leeway = d
+	}
+	return policy, policy.Validate()
+}
+
+// newMailer creates the mailer using the environment settings.
+// Emails are appended to the file WB_MAIL_FILE, or written to stdout when it's not set,
+// until a delivery provider is configured
+func newMailer() (util.Mailer, error) {
+	path := os.Getenv("WB_MAIL_FILE")
+	if path == "" {
+		return util.NewWriterMailer(os.Stdout), nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return util.NewWriterMailer(f), nil
+}
+
+// newPasswordHasher creates the password hasher using the environment settings.
+// New passwords are hashed with WB_PASSWORD_ALGORITHM, argon2id by default, or bcrypt.
+// The argon2id parameters are WB_ARGON2_MEMORY in KiB, WB_ARGON2_ITERATIONS and WB_ARGON2_PARALLELISM,
+// and the bcrypt parameter is WB_BCRYPT_COST. Passwords hashed with anything weaker are rehashed at login
+func newPasswordHasher() (util.PasswordHasher, error) {
+	params := util.DefaultPasswordParams()
+	if s := os.Getenv("WB_PASSWORD_ALGORITHM"); s != "" {
+		params.Algorithm = s
+	}
+	for env, param := range map[string]*uint32{
+		"WB_ARGON2_MEMORY":     &params.Memory,
+		"WB_ARGON2_ITERATIONS": &params.Iterations,
+	} {
+		if s := os.Getenv(env); s != "" {
+			n, err := strconv.ParseUint(s, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a valid number: %s", env, s)
+			}
+			*param = uint32(n)
+		}
+	}
+	if s := os.Getenv("WB_ARGON2_PARALLELISM"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("WB_ARGON2_PARALLELISM is not a valid number: %s", s)
+		}
+		params.Parallelism = uint8(n)
+	}
+	if s := os.Getenv("WB_BCRYPT_COST"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("WB_BCRYPT_COST is not a valid number: %s", s)
+		}
+		params.BcryptCost = n
+	}
+	return util.NewPasswordHasher(params)
+}
+
+// newTokenLifetimes reads how long tokens last using the environment settings, each is a duration like "30s" or "720h".
+// WB_ACCESS_TOKEN_LIFETIME and WB_REFRESH_TOKEN_LIFETIME are how long each token lasts, WB_REMEMBER_ME_LIFETIME is
+// how long a refresh token lasts when the user asks to be remembered, and WB_TOKEN_RENEWAL_WINDOW is how long before a
+// refresh token expires that clients should renew it. WB_MAX_SESSION_LIFETIME is the longest a login lasts, however often it's renewed,
+// and WB_LOGIN_LINK_LIFETIME is how long an emailed login link can be used
+func newTokenLifetimes() (util.TokenLifetimes, error) {
+	lifetimes := util.DefaultTokenLifetimes()
+	for _, setting := range []struct {
+		env      string
+		lifetime *time.Duration
+	}{
+		{"WB_ACCESS_TOKEN_LIFETIME", &lifetimes.AccessToken},
+		{"WB_REFRESH_TOKEN_LIFETIME", &lifetimes.RefreshToken},
+		{"WB_REMEMBER_ME_LIFETIME", &lifetimes.RememberMe},
+		{"WB_TOKEN_RENEWAL_WINDOW", &lifetimes.RenewalWindow},
+		{"WB_MAX_SESSION_LIFETIME", &lifetimes.MaxSession},
+		{"WB_LOGIN_LINK_LIFETIME", &lifetimes.LoginLink},
+	} {
+		if s := os.Getenv(setting.env); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return lifetimes, fmt.Errorf("%s is not a valid duration: %s", setting.env, s)
+			}
+			*setting.lifetime = d
+		}
+	}
+	return lifetimes, lifetimes.Validate()
+}
+
+// newOidcProvider reads the OpenID Connect provider users can login with using the environment settings.
+// WB_OIDC_ISSUER is the url of the provider, federated login is turned off when it's not set.
+// WB_OIDC_CLIENT_ID and WB_OIDC_CLIENT_SECRET are the credentials wordbubble was registered with,
+// and WB_OIDC_REDIRECT_URI is where the provider sends users back to
+func newOidcProvider() (util.OidcProvider, error) {
+	provider := util.OidcProvider{
+		Issuer:       os.Getenv("WB_OIDC_ISSUER"),
+		ClientId:     os.Getenv("WB_OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("WB_OIDC_CLIENT_SECRET"),
+		RedirectUri:  os.Getenv("WB_OIDC_REDIRECT_URI"),
+	}
+	return provider, provider.Validate()
+}
+
+// newRelyingParty reads the site passkeys are registered for using the environment settings.
+// WB_WEBAUTHN_RP_ID is the domain passkeys are scoped to, passkey login is turned off when it's not set.
+// WB_WEBAUTHN_ORIGIN is where the frontend is served from, and WB_WEBAUTHN_RP_NAME is shown by authenticators, wordbubble by default
+func newRelyingParty() (util.WebauthnRelyingParty, error) {
+	rp := util.WebauthnRelyingParty{
+		Id:     os.Getenv("WB_WEBAUTHN_RP_ID"),
+		Name:   os.Getenv("WB_WEBAUTHN_RP_NAME"),
+		Origin: os.Getenv("WB_WEBAUTHN_ORIGIN"),
+	}
+	if rp.Name == "" {
+		rp.Name = "wordbubble"
+	}
+	return rp, rp.Validate()
+}
+
 // TestConfig is used for unit testing only, do not use for any other scenario
 func TestConfig() *testConfig {
-	util.SigningKey = func() []byte { return []byte("test key") }
 	var cfg testConfig
+	cfg.keys = util.TestKeyProvider()
+	cfg.mailer = util.TestMailer()
+	cfg.hasher = util.TestPasswordHasher()
+	cfg.tokenHashKey = []byte("test-token-hash-key")
+	cfg.lifetimes = util.DefaultTokenLifetimes()
 	db, err := sql.Open("sqlite3", ":memory:")
 	if err != nil {
 		log.Fatal(err)
@@ -71,7 +346,8 @@ func TestConfig() *testConfig {
 			updated_timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			username TEXT UNIQUE NOT NULL,
 			email TEXT UNIQUE NOT NULL,
-			password TEXT NOT NULL
+			password TEXT NOT NULL,
+			verified BOOLEAN NOT NULL DEFAULT FALSE
 		);
 		CREATE TABLE IF NOT EXISTS wordbubbles (
 			wordbubble_id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -82,7 +358,140 @@ func TestConfig() *testConfig {
 		CREATE TABLE IF NOT EXISTS tokens (
 			user_id INTEGER NOT NULL,  
 			refresh_token TEXT NOT NULL,
-			issued_at INTEGER NOT NULL
+			issued_at INTEGER NOT NULL,
+			token_id TEXT NOT NULL DEFAULT '',
+			family_id TEXT NOT NULL DEFAULT '',
+			parent_id TEXT NOT NULL DEFAULT '',
+			revoked BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at INTEGER NOT NULL DEFAULT 0,
+			user_agent TEXT NOT NULL DEFAULT '',
+			ip_address TEXT NOT NULL DEFAULT '',
+			expires_at INTEGER NOT NULL DEFAULT 0,
+			remember_me BOOLEAN NOT NULL DEFAULT FALSE
+		);
+		CREATE TABLE IF NOT EXISTS denied_tokens (
+			token_id TEXT PRIMARY KEY,
+			expires_at INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS api_keys (
+			key_id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			key_hash TEXT UNIQUE NOT NULL,
+			created_at INTEGER NOT NULL,
+			last_used_at INTEGER NOT NULL DEFAULT 0,
+			scope TEXT NOT NULL DEFAULT ''
+		);
+		CREATE TABLE IF NOT EXISTS oauth_clients (
+			client_id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			redirect_uri TEXT NOT NULL,
+			created_at INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS oauth_codes (
+			code_hash TEXT PRIMARY KEY,
+			client_id TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			redirect_uri TEXT NOT NULL,
+			scope TEXT NOT NULL,
+			code_challenge TEXT NOT NULL,
+			expires_at INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS totp_secrets (
+			user_id INTEGER PRIMARY KEY,
+			secret TEXT NOT NULL,
+			confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+			last_used_step INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE TABLE IF NOT EXISTS recovery_codes (
+			user_id INTEGER NOT NULL,
+			code_hash TEXT NOT NULL,
+			PRIMARY KEY (user_id, code_hash)
+		);
+		CREATE TABLE IF NOT EXISTS mfa_challenges (
+			challenge_hash TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			scope TEXT NOT NULL,
+			expires_at INTEGER NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE TABLE IF NOT EXISTS password_resets (
+			token_hash TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS email_verifications (
+			token_hash TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS login_links (
+			link_id TEXT PRIMARY KEY,
+			address TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			scope TEXT NOT NULL,
+			remember_me BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL,
+			redeemed BOOLEAN NOT NULL DEFAULT FALSE
+		);
+		CREATE TABLE IF NOT EXISTS oidc_states (
+			state_hash TEXT PRIMARY KEY,
+			nonce TEXT NOT NULL,
+			code_verifier TEXT NOT NULL,
+			link_user_id INTEGER NOT NULL DEFAULT 0,
+			scope TEXT NOT NULL,
+			remember_me BOOLEAN NOT NULL DEFAULT FALSE,
+			expires_at INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS oidc_identities (
+			issuer TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			created_at INTEGER NOT NULL,
+			PRIMARY KEY (issuer, subject)
+		);
+		CREATE TABLE IF NOT EXISTS passkey_challenges (
+			challenge_hash TEXT PRIMARY KEY,
+			ceremony TEXT NOT NULL,
+			user_id INTEGER NOT NULL DEFAULT 0,
+			scope TEXT NOT NULL DEFAULT '',
+			remember_me BOOLEAN NOT NULL DEFAULT FALSE,
+			expires_at INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS passkeys (
+			credential_id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			public_key TEXT NOT NULL,
+			sign_count INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL,
+			last_used_at INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE TABLE IF NOT EXISTS user_roles (
+			user_id INTEGER PRIMARY KEY,
+			role TEXT NOT NULL,
+			assigned_by INTEGER NOT NULL DEFAULT 0,
+			updated_at INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS login_attempts (
+			attempt_key TEXT PRIMARY KEY,
+			failures INTEGER NOT NULL,
+			last_failure_at INTEGER NOT NULL,
+			blocked_until INTEGER NOT NULL,
+			locked BOOLEAN NOT NULL DEFAULT FALSE
+		);
+		CREATE TABLE IF NOT EXISTS lockout_events (
+			attempt_key TEXT NOT NULL,
+			ip_address TEXT NOT NULL,
+			locked_at INTEGER NOT NULL,
+			locked_until INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS job_locks (
+			job_name TEXT PRIMARY KEY,
+			owner TEXT NOT NULL,
+			locked_until INTEGER NOT NULL
 		);
 	`)
 	if err != nil {
@@ -111,6 +520,56 @@ func (cfg *config) Timer() util.Timer {
 	return util.NewTimer()
 }
 
+func (cfg *config) Keys() util.KeyProvider {
+	return cfg.keys
+}
+
+func (cfg *config) Mailer() util.Mailer {
+	return cfg.mailer
+}
+
+func (cfg *config) PasswordHasher() util.PasswordHasher {
+	return cfg.hasher
+}
+
+// TokenHashKey is set with WB_TOKEN_HASH_KEY, the key refresh tokens are hashed with before they're stored.
+// The signing key is used when it's not set, then rotating the signing key logs out every user
+func (cfg *config) TokenHashKey() []byte {
+	return cfg.token
+}
+
+func (cfg *config) TokenLifetimes() util.TokenLifetimes {
+	return cfg.lifetimes
+}
+
+// RequireVerifiedEmail is set with WB_REQUIRE_VERIFIED_EMAIL, when true users can't push until their email is verified
+func (cfg *config) RequireVerifiedEmail() bool {
+	required, _ := strconv.ParseBool(os.Getenv("WB_REQUIRE_VERIFIED_EMAIL"))
+	return required
+}
+
+// OidcProvider is the OpenID Connect provider users can login with, see newOidcProvider
+func (cfg *config) OidcProvider() util.OidcProvider {
+	return cfg.oidc
+}
+
+// RelyingParty is the site passkeys are registered for, see newRelyingParty
+func (cfg *config) RelyingParty() util.WebauthnRelyingParty {
+	return cfg.rp
+}
+
+// BootstrapAdmin is set with WB_BOOTSTRAP_ADMIN, the username or email of a verified user that's made an admin when the api starts,
+// so the first admin can be assigned before there's an admin to assign them
+func (cfg *config) BootstrapAdmin() string {
+	return strings.TrimSpace(os.Getenv("WB_BOOTSTRAP_ADMIN"))
+}
+
+// TrustedProxies is set with WB_TRUSTED_PROXIES, the addresses or CIDR ranges of the proxies in front of the api.
+// Client addresses are only read from X-Forwarded-For when a request comes from one of them
+func (cfg *config) TrustedProxies() util.TrustedProxies {
+	return cfg.proxies
+}
+
 func (cfg *testConfig) NewLogger(namespace string) util.Logger {
 	return util.TestLogger()
 }
@@ -130,3 +589,79 @@ func (cfg *testConfig) Timer() util.Timer {
 func (cfg *testConfig) SetTimer(timer util.Timer) {
 	cfg.timer = timer
 }
+
+func (cfg *testConfig) Keys() util.KeyProvider {
+	return cfg.keys
+}
+
+func (cfg *testConfig) SetKeys(keys util.KeyProvider) {
+	cfg.keys = keys
+}
+
+func (cfg *testConfig) Mailer() util.Mailer {
+	return cfg.mailer
+}
+
+func (cfg *testConfig) SetMailer(mailer util.Mailer) {
+	cfg.mailer = mailer
+}
+
+func (cfg *testConfig) PasswordHasher() util.PasswordHasher {
+	return cfg.hasher
+}
+
+func (cfg *testConfig) SetPasswordHasher(hasher util.PasswordHasher) {
+	cfg.hasher = hasher
+}
+
+func (cfg *testConfig) TokenHashKey() []byte {
+	return cfg.tokenHashKey
+}
+
+func (cfg *testConfig) TokenLifetimes() util.TokenLifetimes {
+	return cfg.lifetimes
+}
+
+func (cfg *testConfig) SetTokenLifetimes(lifetimes util.TokenLifetimes) {
+	cfg.lifetimes = lifetimes
+}
+
+func (cfg *testConfig) RequireVerifiedEmail() bool {
+	return cfg.requireVerifiedEmail
+}
+
+func (cfg *testConfig) SetRequireVerifiedEmail(required bool) {
+	cfg.requireVerifiedEmail = required
+}
+
+func (cfg *testConfig) OidcProvider() util.OidcProvider {
+	return cfg.oidcProvider
+}
+
+func (cfg *testConfig) SetOidcProvider(provider util.OidcProvider) {
+	cfg.oidcProvider = provider
+}
+
+func (cfg *testConfig) RelyingParty() util.WebauthnRelyingParty {
+	return cfg.relyingParty
+}
+
+func (cfg *testConfig) SetRelyingParty(rp util.WebauthnRelyingParty) {
+	cfg.relyingParty = rp
+}
+
+func (cfg *testConfig) BootstrapAdmin() string {
+	return cfg.bootstrapAdmin
+}
+
+func (cfg *testConfig) SetBootstrapAdmin(user string) {
+	cfg.bootstrapAdmin = user
+}
+
+func (cfg *testConfig) TrustedProxies() util.TrustedProxies {
+	return cfg.trustedProxies
+}
+
+func (cfg *testConfig) SetTrustedProxies(proxies util.TrustedProxies) {
+	cfg.trustedProxies = proxies
+}
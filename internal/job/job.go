@@ -0,0 +1,42 @@
+package job
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	AcquireLock  = `INSERT INTO job_locks (job_name, owner, locked_until) VALUES ($1, $2, $3) ON CONFLICT (job_name) DO UPDATE SET owner = $4, locked_until = $5 WHERE job_locks.locked_until <= $6 OR job_locks.owner = $7`
+	ReleaseLocks = `UPDATE job_locks SET locked_until = 0 WHERE owner = $1`
+)
+
+// Job is a named unit of work that the scheduler runs periodically
+type Job struct {
+	// Name identifies the job, only one replica of the api can hold the lock for a name at a time
+	Name string
+	// Interval is the time between runs of the job, the lock is held for the full interval
+	Interval time.Duration
+	// Run does the work of the job, the context is cancelled when the scheduler is shutting down
+	Run func(ctx context.Context) error
+}
+
+// Scheduler is the interface that the application
+// uses to run jobs in the background
+type Scheduler interface {
+	// Register adds a job to the scheduler, jobs must be registered before the scheduler runs
+	Register(job Job)
+	// Run starts every job registered and blocks until the context is done and each job has stopped
+	Run(ctx context.Context)
+}
+
+// LockRepo is the interface that the scheduler
+// uses to make sure a job only runs on one replica at a time
+type LockRepo interface {
+	// acquireLock takes the lock for a job until the time passed, the lock can be taken if it has expired or is already held by the owner.
+	// bool is true if the lock was acquired.
+	// error can be (500) resp.ErrCouldNotAcquireJobLock or nil.
+	acquireLock(jobName, owner string, now, until int64) (bool, error)
+	// releaseLocks expires every lock held by the owner, so another replica can take them right away.
+	// error can be (500) resp.ErrCouldNotReleaseJobLocks or nil.
+	releaseLocks(owner string) error
+}
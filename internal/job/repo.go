@@ -0,0 +1,38 @@
+package job
+
+import (
+	"database/sql"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type lockRepo struct {
+	db  *sql.DB
+	log util.Logger
+}
+
+func NewLockRepo(config cfg.Config) *lockRepo {
+	return &lockRepo{
+		log: config.NewLogger("job_repo"),
+		db:  config.DB(),
+	}
+}
+
+func (repo *lockRepo) acquireLock(jobName, owner string, now, until int64) (bool, error) {
+	rs, err := repo.db.Exec(AcquireLock, jobName, owner, until, owner, until, now, owner)
+	if err != nil {
+		repo.log.Error("could not acquire lock for job: %s, error: %s", jobName, err)
+		return false, resp.ErrCouldNotAcquireJobLock
+	}
+	amt, _ := rs.RowsAffected()
+	return amt > 0, nil
+}
+
+func (repo *lockRepo) releaseLocks(owner string) error {
+	if _, err := repo.db.Exec(ReleaseLocks, owner); err != nil {
+		return resp.ErrCouldNotReleaseJobLocks
+	}
+	return nil
+}
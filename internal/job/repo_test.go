@@ -0,0 +1,63 @@
+package job
+
+import (
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HappyPath(t *testing.T) {
+	repo := NewLockRepo(cfg.TestConfig())
+
+	// The first replica to tick takes the lock for the interval
+	acquired, err := repo.acquireLock("cleanup", "replica-a", 100, 130)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// Another replica ticks during the same interval and has to skip the job
+	acquired, err = repo.acquireLock("cleanup", "replica-b", 110, 140)
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	// A different job can be locked by the other replica
+	acquired, err = repo.acquireLock("other", "replica-b", 110, 140)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// The owner can keep taking its own lock
+	acquired, err = repo.acquireLock("cleanup", "replica-a", 120, 150)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// Once the lock expires anyone can take it
+	acquired, err = repo.acquireLock("cleanup", "replica-b", 150, 180)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// A replica shutting down releases its locks right away
+	err = repo.releaseLocks("replica-b")
+	assert.NoError(t, err)
+	acquired, err = repo.acquireLock("cleanup", "replica-a", 151, 181)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	acquired, err = repo.acquireLock("other", "replica-a", 151, 181)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+}
+
+func Test_NotSoHappyPath(t *testing.T) {
+	repo := NewLockRepo(cfg.TestConfig())
+
+	// db closed
+	repo.db.Close()
+	acquired, err := repo.acquireLock("cleanup", "replica-a", 100, 130)
+	assert.False(t, acquired)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotAcquireJobLock.Error(), err.Error())
+
+	err = repo.releaseLocks("replica-a")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotReleaseJobLocks.Error(), err.Error())
+}
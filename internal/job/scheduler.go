@@ -0,0 +1,73 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type scheduler struct {
+	log   util.Logger
+	timer util.Timer
+	repo  LockRepo
+	owner string
+	jobs  []Job
+}
+
+func NewScheduler(cfg cfg.Config, repo LockRepo) *scheduler {
+	return &scheduler{
+		log:   cfg.NewLogger("scheduler"),
+		timer: cfg.Timer(),
+		repo:  repo,
+		owner: util.RandomString(8),
+	}
+}
+
+func (s *scheduler) Register(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+func (s *scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			s.log.Info("starting job: %s with an interval of: %gs", job.Name, job.Interval.Seconds())
+			tick, stop := s.timer.Tick(job.Interval)
+			defer stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case now := <-tick:
+					s.runOnce(ctx, job, now)
+				}
+			}
+		}(job)
+	}
+	wg.Wait()
+	if err := s.repo.releaseLocks(s.owner); err != nil {
+		s.log.Error("could not release job locks for owner: %s, error: %s", s.owner, err)
+	}
+	s.log.Info("scheduler stopped")
+}
+
+// runs the job if the lock can be taken, the lock is held for an entire interval
+// so that other replicas ticking during the same interval skip the job
+func (s *scheduler) runOnce(ctx context.Context, job Job, now time.Time) {
+	acquired, err := s.repo.acquireLock(job.Name, s.owner, now.Unix(), now.Add(job.Interval).Unix())
+	if err != nil {
+		return
+	}
+	if !acquired {
+		s.log.Debug("skipping job: %s, lock is held by another replica", job.Name)
+		return
+	}
+	if err := job.Run(ctx); err != nil {
+		s.log.Error("job: %s failed, error: %s", job.Name, err)
+	}
+}
@@ -0,0 +1,91 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Scheduler(t *testing.T) {
+	config := cfg.TestConfig()
+	repo := NewLockRepo(config)
+	runs := make(chan string, 10)
+	newReplica := func(name string) (*scheduler, chan time.Time) {
+		timer := util.TestTimerFromUnix(1000)
+		tick := make(chan time.Time)
+		timer.SetTick(tick)
+		config.SetTimer(timer)
+		s := NewScheduler(config, repo)
+		s.Register(Job{
+			Name:     "cleanup",
+			Interval: 30 * time.Second,
+			Run: func(ctx context.Context) error {
+				runs <- name
+				return errors.New("failures are only logged")
+			},
+		})
+		return s, tick
+	}
+	a, tickA := newReplica("a")
+	b, tickB := newReplica("b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	doneA, doneB := make(chan struct{}), make(chan struct{})
+	go func() { a.Run(ctx); close(doneA) }()
+	go func() { b.Run(ctx); close(doneB) }()
+
+	// replica a ticks first and runs the job
+	tickA <- time.Unix(1000, 0)
+	assert.Equal(t, "a", <-runs)
+
+	// replica b ticks during the same interval, the second tick is only received after the first is handled
+	tickB <- time.Unix(1010, 0)
+	tickB <- time.Unix(1020, 0)
+	assert.Empty(t, runs)
+
+	// once the interval has passed replica b can run the job
+	tickB <- time.Unix(1000+30, 0)
+	assert.Equal(t, "b", <-runs)
+
+	// shutting down stops every job
+	cancel()
+	<-doneA
+	<-doneB
+	assert.Empty(t, runs)
+}
+
+func Test_SchedulerLockError(t *testing.T) {
+	config := cfg.TestConfig()
+	timer := util.TestTimerFromUnix(1000)
+	tick := make(chan time.Time)
+	timer.SetTick(tick)
+	config.SetTimer(timer)
+	repo := NewLockRepo(config)
+	repo.db.Close()
+
+	ran := false
+	s := NewScheduler(config, repo)
+	s.Register(Job{
+		Name:     "cleanup",
+		Interval: 30 * time.Second,
+		Run: func(ctx context.Context) error {
+			ran = true
+			return nil
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { s.Run(ctx); close(done) }()
+
+	// the lock can't be acquired, so the job is skipped
+	tick <- time.Unix(1000, 0)
+	tick <- time.Unix(1030, 0)
+	cancel()
+	<-done
+	assert.False(t, ran)
+}
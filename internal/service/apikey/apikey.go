@@ -0,0 +1,61 @@
+package apikey
+
+import (
+	"github.com/bchadwic/wordbubble/model/resp"
+)
+
+const (
+	// KeyPrefix starts every api key, so a leaked key is easy to recognize
+	KeyPrefix = "wb_"
+	// lastUsedWindow is how stale a key's last used time can get before it's updated again,
+	// so a key used by a busy script doesn't write to the database on every request
+	lastUsedWindow = 60
+
+	StoreApiKey          = `INSERT INTO api_keys (key_id, user_id, name, key_hash, created_at, scope) VALUES ($1, $2, $3, $4, $5, $6)`
+	GetApiKey            = `SELECT key_id, user_id, last_used_at, scope FROM api_keys WHERE key_hash = $1`
+	UpdateApiKeyLastUsed = `UPDATE api_keys SET last_used_at = $1 WHERE key_id = $2`
+	ListApiKeys          = `SELECT key_id, name, created_at, last_used_at, scope FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`
+	RevokeApiKey         = `DELETE FROM api_keys WHERE user_id = $1 AND key_id = $2`
+)
+
+// ApiKeyService is the interface that the application
+// uses to interact with personal api keys
+type ApiKeyService interface {
+	// CreateApiKey creates a named api key for a user granted the scope passed, the key itself is only returned here and never stored
+	// *resp.CreateApiKeyResponse is the key created, can be nil.
+	// error could be (400) resp.ErrApiKeyNameIsMissing, (400) resp.ErrApiKeyNameIsTooLong, (500) resp.ErrCouldNotStoreApiKey or nil.
+	CreateApiKey(userId int64, name, scope string) (*resp.CreateApiKeyResponse, error)
+	// ValidateApiKey finds the user an api key belongs to, and marks the key as used
+	// int64 is the user id of the key's owner, or zero.
+	// string is the scope granted to the key, or empty string.
+	// error could be (401) resp.ErrInvalidApiKey or nil.
+	ValidateApiKey(key string) (int64, string, error)
+	// ListApiKeys returns every api key a user has, the newest first
+	// []resp.ApiKey is the user's keys, can be empty.
+	// error could be (500) resp.ErrCouldNotListApiKeys or nil.
+	ListApiKeys(userId int64) ([]resp.ApiKey, error)
+	// RevokeApiKey removes an api key, the key must belong to the user
+	// error could be (404) resp.ErrUnknownApiKey, (500) resp.ErrCouldNotRevokeApiKey or nil.
+	RevokeApiKey(userId int64, keyId string) error
+}
+
+// ApiKeyRepo is the interface that the service layer
+// uses to interact with api keys in the database
+type ApiKeyRepo interface {
+	// storeApiKey stores the hash of an api key.
+	// error can be (500) resp.ErrCouldNotStoreApiKey or nil.
+	storeApiKey(keyId string, userId int64, name, keyHash string, createdAt int64, scope string) error
+	// getApiKey finds the api key with the hash passed.
+	// *apiKey is the key found, holding its id, owner, scope and the last time it was used, can be nil.
+	// error can be (401) resp.ErrInvalidApiKey or nil.
+	getApiKey(keyHash string) (*apiKey, error)
+	// updateApiKeyLastUsed sets the last time an api key was used.
+	// error can be (500) resp.ErrCouldNotStoreApiKey or nil.
+	updateApiKeyLastUsed(keyId string, lastUsedAt int64) error
+	// listApiKeys finds every api key a user has.
+	// error can be (500) resp.ErrCouldNotListApiKeys or nil.
+	listApiKeys(userId int64) ([]resp.ApiKey, error)
+	// revokeApiKey removes a user's api key.
+	// error can be (404) resp.ErrUnknownApiKey, (500) resp.ErrCouldNotRevokeApiKey or nil.
+	revokeApiKey(userId int64, keyId string) error
+}
@@ -0,0 +1,78 @@
+package apikey
+
+import (
+	"database/sql"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type apiKeyRepo struct {
+	db  *sql.DB
+	log util.Logger
+}
+
+func NewApiKeyRepo(config cfg.Config) *apiKeyRepo {
+	return &apiKeyRepo{
+		log: config.NewLogger("apikey_repo"),
+		db:  config.DB(),
+	}
+}
+
+func (repo *apiKeyRepo) storeApiKey(keyId string, userId int64, name, keyHash string, createdAt int64, scope string) error {
+	if _, err := repo.db.Exec(StoreApiKey, keyId, userId, name, keyHash, createdAt, scope); err != nil {
+		repo.log.Error("could not store api key for user: %d, error: %s", userId, err)
+		return resp.ErrCouldNotStoreApiKey
+	}
+	return nil
+}
+
+func (repo *apiKeyRepo) getApiKey(keyHash string) (*apiKey, error) {
+	row := repo.db.QueryRow(GetApiKey, keyHash)
+	var key apiKey
+	if err := row.Scan(&key.id, &key.userId, &key.lastUsedAt, &key.scope); err != nil {
+		return nil, resp.ErrInvalidApiKey
+	}
+	return &key, nil
+}
+
+func (repo *apiKeyRepo) updateApiKeyLastUsed(keyId string, lastUsedAt int64) error {
+	if _, err := repo.db.Exec(UpdateApiKeyLastUsed, lastUsedAt, keyId); err != nil {
+		return resp.ErrCouldNotStoreApiKey
+	}
+	return nil
+}
+
+func (repo *apiKeyRepo) listApiKeys(userId int64) ([]resp.ApiKey, error) {
+	rows, err := repo.db.Query(ListApiKeys, userId)
+	if err != nil {
+		repo.log.Error("could not list api keys for user: %d, error: %s", userId, err)
+		return nil, resp.ErrCouldNotListApiKeys
+	}
+	defer rows.Close()
+	keys := make([]resp.ApiKey, 0)
+	for rows.Next() {
+		var key resp.ApiKey
+		if err := rows.Scan(&key.Id, &key.Name, &key.CreatedAt, &key.LastUsedAt, &key.Scope); err != nil {
+			repo.log.Error("could not map api key for user: %d, error: %s", userId, err)
+			return nil, resp.ErrCouldNotListApiKeys
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, resp.ErrCouldNotListApiKeys
+	}
+	return keys, nil
+}
+
+func (repo *apiKeyRepo) revokeApiKey(userId int64, keyId string) error {
+	rs, err := repo.db.Exec(RevokeApiKey, userId, keyId)
+	if err != nil {
+		return resp.ErrCouldNotRevokeApiKey
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 {
+		return resp.ErrUnknownApiKey
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package apikey
+
+import (
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HappyPath(t *testing.T) {
+	repo := NewApiKeyRepo(cfg.TestConfig())
+	userId := int64(5)
+
+	// A user creates a key for a cron job, and another for a bot
+	assert.NoError(t, repo.storeApiKey("cron", userId, "nightly cron", "hash.cron", 100, "wordbubble:push"))
+	assert.NoError(t, repo.storeApiKey("bot", userId, "chat bot", "hash.bot", 200, "wordbubble:push wordbubble:read"))
+
+	// The cron job uses its key
+	key, err := repo.getApiKey("hash.cron")
+	assert.NoError(t, err)
+	assert.Equal(t, &apiKey{id: "cron", userId: userId, scope: "wordbubble:push"}, key)
+	assert.NoError(t, repo.updateApiKeyLastUsed(key.id, 300))
+
+	// The user lists their keys, the newest first
+	keys, err := repo.listApiKeys(userId)
+	assert.NoError(t, err)
+	assert.Equal(t, []resp.ApiKey{
+		{Id: "bot", Name: "chat bot", Scope: "wordbubble:push wordbubble:read", CreatedAt: 200},
+		{Id: "cron", Name: "nightly cron", Scope: "wordbubble:push", CreatedAt: 100, LastUsedAt: 300},
+	}, keys)
+
+	// Another user can't revoke the user's key
+	assert.Equal(t, resp.ErrUnknownApiKey, repo.revokeApiKey(userId+1, "cron"))
+
+	// The user revokes the cron job's key, it can no longer be used
+	assert.NoError(t, repo.revokeApiKey(userId, "cron"))
+	key, err = repo.getApiKey("hash.cron")
+	assert.Nil(t, key)
+	assert.Equal(t, resp.ErrInvalidApiKey, err)
+	assert.Equal(t, resp.ErrUnknownApiKey, repo.revokeApiKey(userId, "cron"))
+	keys, err = repo.listApiKeys(userId)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
+
+func Test_NotSoHappyPath(t *testing.T) {
+	repo := NewApiKeyRepo(cfg.TestConfig())
+
+	// db closed
+	repo.db.Close()
+	err := repo.storeApiKey("cron", 5, "nightly cron", "hash.cron", 100, "wordbubble:push")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotStoreApiKey.Error(), err.Error())
+
+	_, err = repo.getApiKey("hash.cron")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrInvalidApiKey.Error(), err.Error())
+
+	err = repo.updateApiKeyLastUsed("cron", 300)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotStoreApiKey.Error(), err.Error())
+
+	keys, err := repo.listApiKeys(5)
+	assert.Nil(t, keys)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotListApiKeys.Error(), err.Error())
+
+	err = repo.revokeApiKey(5, "cron")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotRevokeApiKey.Error(), err.Error())
+}
@@ -0,0 +1,78 @@
+package apikey
+
+import (
+	"strings"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type apiKey struct {
+	id         string
+	userId     int64
+	scope      string
+	lastUsedAt int64
+}
+
+type apiKeyService struct {
+	log   util.Logger
+	timer util.Timer
+	repo  ApiKeyRepo
+}
+
+func NewApiKeyService(cfg cfg.Config, repo ApiKeyRepo) *apiKeyService {
+	return &apiKeyService{
+		log:   cfg.NewLogger("apikeys"),
+		timer: cfg.Timer(),
+		repo:  repo,
+	}
+}
+
+func (svc *apiKeyService) CreateApiKey(userId int64, name, scope string) (*resp.CreateApiKeyResponse, error) {
+	name = strings.TrimSpace(name)
+	if err := util.ValidApiKeyName(name); err != nil {
+		return nil, err
+	}
+	keyId, key := util.RandomString(8), KeyPrefix+util.RandomString(32)
+	createdAt := svc.timer.Now().Unix()
+	if err := svc.repo.storeApiKey(keyId, userId, name, util.HashToken(key), createdAt, scope); err != nil {
+		return nil, err
+	}
+	svc.log.Info("created api key: %s for user: %d", keyId, userId)
+	return &resp.CreateApiKeyResponse{
+		Id:        keyId,
+		Name:      name,
+		Key:       key,
+		Scope:     scope,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+func (svc *apiKeyService) ValidateApiKey(key string) (int64, string, error) {
+	if !strings.HasPrefix(key, KeyPrefix) {
+		return 0, "", resp.ErrInvalidApiKey
+	}
+	found, err := svc.repo.getApiKey(util.HashToken(key))
+	if err != nil {
+		return 0, "", err
+	}
+	if now := svc.timer.Now().Unix(); now-found.lastUsedAt >= lastUsedWindow {
+		if err = svc.repo.updateApiKeyLastUsed(found.id, now); err != nil { // the key is still valid, only its usage is stale
+			svc.log.Warn("could not update last used time of api key: %s, error: %s", found.id, err)
+		}
+	}
+	return found.userId, found.scope, nil
+}
+
+func (svc *apiKeyService) ListApiKeys(userId int64) ([]resp.ApiKey, error) {
+	return svc.repo.listApiKeys(userId)
+}
+
+func (svc *apiKeyService) RevokeApiKey(userId int64, keyId string) error {
+	if err := svc.repo.revokeApiKey(userId, keyId); err != nil {
+		return err
+	}
+	svc.log.Info("revoked api key: %s for user: %d", keyId, userId)
+	return nil
+}
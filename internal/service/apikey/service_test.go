@@ -0,0 +1,232 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CreateApiKey(t *testing.T) {
+	tests := map[string]struct {
+		name         string
+		repo         *testApiKeyRepo
+		expectedName string
+		expectedErr  error
+	}{
+		"valid": {
+			name:         "nightly cron",
+			repo:         &testApiKeyRepo{},
+			expectedName: "nightly cron",
+		},
+		"valid, name is trimmed": {
+			name:         "  nightly cron ",
+			repo:         &testApiKeyRepo{},
+			expectedName: "nightly cron",
+		},
+		"invalid, no name": {
+			name:        "   ",
+			repo:        &testApiKeyRepo{},
+			expectedErr: resp.ErrApiKeyNameIsMissing,
+		},
+		"invalid, database error": {
+			name: "nightly cron",
+			repo: &testApiKeyRepo{
+				err: resp.ErrCouldNotStoreApiKey,
+			},
+			expectedErr: resp.ErrCouldNotStoreApiKey,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(1000))
+			svc := NewApiKeyService(cfg, tcase.repo)
+			key, err := svc.CreateApiKey(5, tcase.name, "wordbubble:push")
+			if tcase.expectedErr != nil {
+				assert.Equal(t, tcase.expectedErr, err)
+				assert.Nil(t, key)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.expectedName, key.Name)
+			assert.Equal(t, int64(1000), key.CreatedAt)
+			assert.Equal(t, "wordbubble:push", key.Scope)
+			assert.Equal(t, "wordbubble:push", tcase.repo.storedScope)
+			assert.True(t, strings.HasPrefix(key.Key, KeyPrefix))
+			// only the hash of the key is stored
+			assert.Equal(t, key.Id, tcase.repo.storedId)
+			assert.Equal(t, util.HashToken(key.Key), tcase.repo.storedHash)
+			assert.NotContains(t, tcase.repo.storedHash, key.Key)
+		})
+	}
+}
+
+func Test_ValidateApiKey(t *testing.T) {
+	tests := map[string]struct {
+		key              string
+		repo             *testApiKeyRepo
+		expectedUserId   int64
+		expectedScope    string
+		expectedLastUsed int64
+		expectedErr      error
+	}{
+		"valid": {
+			key: "wb_2c26b46b",
+			repo: &testApiKeyRepo{
+				keyId:  "9f86d081",
+				userId: 5,
+				scope:  "wordbubble:push",
+			},
+			expectedUserId:   5,
+			expectedScope:    "wordbubble:push",
+			expectedLastUsed: 1000,
+		},
+		"valid, used recently so the last used time isn't updated": {
+			key: "wb_2c26b46b",
+			repo: &testApiKeyRepo{
+				keyId:      "9f86d081",
+				userId:     5,
+				lastUsedAt: 1000 - lastUsedWindow + 1,
+			},
+			expectedUserId: 5,
+		},
+		"valid, last used time couldn't be updated": {
+			key: "wb_2c26b46b",
+			repo: &testApiKeyRepo{
+				keyId:     "9f86d081",
+				userId:    5,
+				errUpdate: resp.ErrCouldNotStoreApiKey,
+			},
+			expectedUserId:   5,
+			expectedLastUsed: 1000,
+		},
+		"invalid, unknown key": {
+			key: "wb_2c26b46b",
+			repo: &testApiKeyRepo{
+				errGet: resp.ErrInvalidApiKey,
+			},
+			expectedErr: resp.ErrInvalidApiKey,
+		},
+		"invalid, not an api key": {
+			key:         "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9",
+			repo:        &testApiKeyRepo{},
+			expectedErr: resp.ErrInvalidApiKey,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(1000))
+			svc := NewApiKeyService(cfg, tcase.repo)
+			userId, scope, err := svc.ValidateApiKey(tcase.key)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedUserId, userId)
+			assert.Equal(t, tcase.expectedScope, scope)
+			assert.Equal(t, tcase.expectedLastUsed, tcase.repo.updatedLastUsed)
+		})
+	}
+}
+
+func Test_ListApiKeys(t *testing.T) {
+	tests := map[string]struct {
+		repo         *testApiKeyRepo
+		expectedKeys []resp.ApiKey
+		expectedErr  error
+	}{
+		"valid": {
+			repo: &testApiKeyRepo{
+				keys: []resp.ApiKey{{Id: "9f86d081", Name: "nightly cron", CreatedAt: 100}},
+			},
+			expectedKeys: []resp.ApiKey{{Id: "9f86d081", Name: "nightly cron", CreatedAt: 100}},
+		},
+		"invalid, database error": {
+			repo: &testApiKeyRepo{
+				errList: resp.ErrCouldNotListApiKeys,
+			},
+			expectedErr: resp.ErrCouldNotListApiKeys,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			svc := NewApiKeyService(cfg.TestConfig(), tcase.repo)
+			keys, err := svc.ListApiKeys(5)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedKeys, keys)
+		})
+	}
+}
+
+func Test_RevokeApiKey(t *testing.T) {
+	tests := map[string]struct {
+		repo        *testApiKeyRepo
+		expectedErr error
+	}{
+		"valid": {
+			repo: &testApiKeyRepo{},
+		},
+		"invalid, unknown key": {
+			repo: &testApiKeyRepo{
+				errRevoke: resp.ErrUnknownApiKey,
+			},
+			expectedErr: resp.ErrUnknownApiKey,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			svc := NewApiKeyService(cfg.TestConfig(), tcase.repo)
+			err := svc.RevokeApiKey(5, "9f86d081")
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, "9f86d081", tcase.repo.revokedId)
+		})
+	}
+}
+
+type testApiKeyRepo struct {
+	err             error
+	storedId        string
+	storedHash      string
+	storedScope     string
+	keyId           string
+	userId          int64
+	scope           string
+	lastUsedAt      int64
+	errGet          error
+	errUpdate       error
+	updatedLastUsed int64
+	keys            []resp.ApiKey
+	errList         error
+	errRevoke       error
+	revokedId       string
+}
+
+func (trepo *testApiKeyRepo) storeApiKey(keyId string, userId int64, name, keyHash string, createdAt int64, scope string) error {
+	trepo.storedId = keyId
+	trepo.storedHash = keyHash
+	trepo.storedScope = scope
+	return trepo.err
+}
+
+func (trepo *testApiKeyRepo) getApiKey(keyHash string) (*apiKey, error) {
+	if trepo.errGet != nil {
+		return nil, trepo.errGet
+	}
+	return &apiKey{id: trepo.keyId, userId: trepo.userId, scope: trepo.scope, lastUsedAt: trepo.lastUsedAt}, nil
+}
+
+func (trepo *testApiKeyRepo) updateApiKeyLastUsed(keyId string, lastUsedAt int64) error {
+	trepo.updatedLastUsed = lastUsedAt
+	return trepo.errUpdate
+}
+
+func (trepo *testApiKeyRepo) listApiKeys(userId int64) ([]resp.ApiKey, error) {
+	return trepo.keys, trepo.errList
+}
+
+func (trepo *testApiKeyRepo) revokeApiKey(userId int64, keyId string) error {
+	trepo.revokedId = keyId
+	return trepo.errRevoke
+}
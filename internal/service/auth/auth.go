@@ -1,52 +1,142 @@
 package auth
 
-import "time"
+import (
+	"time"
+
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+)
 
 const (
-	refreshTokenTimeLimit    = 36000 // 10 minutes
-	accessTokenTimeLimit     = 30 * time.Second
-	RefreshTokenCleanerRate  = 30 * time.Second
-	ImminentExpirationWindow = int64(float64(refreshTokenTimeLimit) * .2) // TODO make better?
-
-	CleanupExpiredRefreshTokens = `DELETE FROM tokens WHERE issued_at < $1`
-	StoreRefreshToken           = `INSERT INTO tokens (user_id, refresh_token, issued_at) VALUES ($1, $2, $3)`
-	ValidateRefreshToken        = `SELECT issued_at FROM tokens WHERE user_id = $1 AND refresh_token = $2`
-	GetLatestRefreshToken       = `SELECT refresh_token, issued_at FROM tokens WHERE user_id = $1 ORDER BY issued_at DESC LIMIT 1`
+	// legacyRefreshTokenTimeLimit is the lifetime, in seconds, of refresh tokens stored before expires_at existed
+	legacyRefreshTokenTimeLimit = 36000
+	RefreshTokenCleanerRate     = 30 * time.Second
+
+	// rows stored before expires_at existed have zero, so they expire based on issued_at
+	CleanupExpiredRefreshTokens = `DELETE FROM tokens WHERE expires_at < $1 AND (expires_at <> 0 OR issued_at < $2)`
+	StoreRefreshToken           = `INSERT INTO tokens (user_id, refresh_token, issued_at, token_id, family_id, parent_id, created_at, user_agent, ip_address, expires_at, remember_me) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	ValidateRefreshToken        = `SELECT issued_at, family_id, parent_id, revoked, created_at, remember_me FROM tokens WHERE user_id = $1 AND refresh_token = $2`
+	RevokeRefreshToken          = `UPDATE tokens SET revoked = TRUE WHERE token_id = $1 AND revoked = FALSE`
+	RevokeRefreshTokenFamily    = `DELETE FROM tokens WHERE family_id = $1`
+	RevokeAllRefreshTokens      = `DELETE FROM tokens WHERE user_id = $1`
+	ListSessions                = `SELECT family_id, user_agent, ip_address, created_at, issued_at FROM tokens WHERE user_id = $1 AND revoked = FALSE AND family_id <> '' AND (expires_at > $2 OR (expires_at = 0 AND issued_at > $3)) ORDER BY issued_at DESC`
+	RevokeSession               = `DELETE FROM tokens WHERE user_id = $1 AND family_id = $2`
+	DenyAccessToken             = `INSERT INTO denied_tokens (token_id, expires_at) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	GetDeniedAccessToken        = `SELECT expires_at FROM denied_tokens WHERE token_id = $1`
+	CleanupDeniedAccessTokens   = `DELETE FROM denied_tokens WHERE expires_at < $1`
+	// refresh tokens are jwts, which always contain a '.', and their hashes are hex, which never do
+	GetPlaintextRefreshTokens = `SELECT refresh_token FROM tokens WHERE refresh_token LIKE '%.%'`
+	HashRefreshToken          = `UPDATE tokens SET refresh_token = $1 WHERE refresh_token = $2`
 )
 
 // AuthService is the interface that the application
 // uses to interact with access and refresh tokens
 type AuthService interface {
-	// GenerateAccessToken generates an access token
+	// GenerateAccessToken generates an access token granted the scope passed, carrying the role the user has now.
+	// The role isn't checked again until the token expires, so a role that's taken away lasts at most an access token lifetime
 	// string is the access token's string
-	GenerateAccessToken(userId int64) string
-	// GenerateRefreshToken generates a refresh token, an error is generated when the token couldn't be successfully saved to the database
+	GenerateAccessToken(userId int64, scope, role string) string
+	// ValidateAccessToken validates the access token string passed using the signing key and the claims policy, then by checking the denylist
+	// *model.TokenClaims are the claims of the access token, can be nil.
+	// error could be (401) resp.ErrMalformedToken, (401) resp.ErrInvalidTokenSignature, (401) resp.ErrTokenIsExpired,
+	// (401) resp.ErrTokenIsNotYetValid, (401) resp.ErrInvalidTokenType, (401) resp.ErrInvalidTokenClaims, (401) resp.ErrTokenIsRevoked,
+	// (500) resp.ErrCouldNotCheckDenylist or nil.
+	ValidateAccessToken(tokenStr string) (*model.TokenClaims, error)
+	// RevokeAccessToken adds the access token the principal authenticated with to the denylist until it expires,
+	// api keys and tokens issued without an id can't be revoked, so nothing is added for them
+	// error could be (500) resp.ErrCouldNotRevokeAccessToken or nil.
+	RevokeAccessToken(principal *model.Principal) error
+	// GenerateRefreshToken generates a refresh token granted the scope passed that starts a new session on the device passed,
+	// the token lasts for the remember me lifetime instead of the refresh token lifetime when rememberMe is true.
+	// An error is generated when the token couldn't be successfully saved to the database
 	// string is the refresh token's string, or empty string.
 	// error could be (500) resp.ErrCouldNotStoreRefreshToken or nil.
-	GenerateRefreshToken(userId int64) (string, error)
-	// ValidateRefreshToken validates the refresh token string passed using the signing key and by checking the auth datasource
-	// error could be (401) ErrTokenIsExpired, (401) resp.ErrCouldNotValidateRefreshToken or nil.
+	GenerateRefreshToken(userId int64, scope string, rememberMe bool, device *model.Device) (string, error)
+	// GenerateClientRefreshToken generates a refresh token like GenerateRefreshToken, issued to the oauth client passed.
+	// Every token the family is rotated into is issued to the client too, so it can only be refreshed at /oauth/token
+	// string is the refresh token, or empty string.
+	// error could be (500) resp.ErrCouldNotStoreRefreshToken or nil.
+	GenerateClientRefreshToken(userId int64, clientId, scope string, device *model.Device) (string, error)
+	// ValidateRefreshToken validates the refresh token string passed using the signing key and by checking the auth datasource.
+	// A token that has already been rotated is considered reused, which revokes every token in its family.
+	// error could be (401) ErrTokenIsExpired, (401) resp.ErrSessionIsExpired, (401) resp.ErrCouldNotValidateRefreshToken,
+	// (401) resp.ErrRefreshTokenReused or nil.
 	ValidateRefreshToken(token *RefreshToken) error
+	// RotateRefreshToken validates the refresh token, revokes it, and generates its replacement in the same family.
+	// The replacement keeps the scope and lifetime of the token, and the session is marked as last used on the device passed.
+	// The replacement never outlives the max session lifetime, counted from the login that started the family
+	// string is the new refresh token's string, or empty string.
+	// error could be (401) ErrTokenIsExpired, (401) resp.ErrSessionIsExpired, (401) resp.ErrCouldNotValidateRefreshToken,
+	// (401) resp.ErrRefreshTokenReused, (500) resp.ErrCouldNotRevokeRefreshToken, (500) resp.ErrCouldNotStoreRefreshToken or nil.
+	RotateRefreshToken(token *RefreshToken, device *model.Device) (string, error)
+	// RevokeRefreshToken validates the refresh token, then removes it and every token in its family from the auth datasource
+	// error could be (401) ErrTokenIsExpired, (401) resp.ErrSessionIsExpired, (401) resp.ErrCouldNotValidateRefreshToken,
+	// (401) resp.ErrRefreshTokenReused, (500) resp.ErrCouldNotRevokeRefreshToken or nil.
+	RevokeRefreshToken(token *RefreshToken) error
+	// RevokeAllRefreshTokens removes every refresh token a user has from the auth datasource
+	// error could be (500) resp.ErrCouldNotRevokeRefreshToken or nil.
+	RevokeAllRefreshTokens(userId int64) error
+	// ListSessions returns every session of a user that hasn't been revoked or expired, the most recently used first.
+	// A session is a family of refresh tokens, started by a login and kept alive by rotating its refresh token
+	// []resp.Session is the user's sessions, can be empty.
+	// error could be (500) resp.ErrCouldNotListSessions or nil.
+	ListSessions(userId int64) ([]resp.Session, error)
+	// RevokeSession removes every refresh token in the session passed, the session must belong to the user
+	// error could be (404) resp.ErrUnknownSession, (500) resp.ErrCouldNotRevokeRefreshToken or nil.
+	RevokeSession(userId int64, sessionId string) error
 }
 
 // AuthRepo is the interface that the service layer
 // uses to interact with refresh tokens in the database
 type AuthRepo interface {
-	// storeRefreshToken stores a refresh token in the database.
+	// storeRefreshToken stores the keyed hash of a refresh token in the database, never the token itself.
 	// error can be (500) resp.ErrCouldNotStoreRefreshToken or nil.
 	storeRefreshToken(token *RefreshToken) error
-	// validateRefreshToken validates a refresh token against database.
+	// validateRefreshToken looks up a refresh token by its keyed hash, filling in the family, lifetime and revoked state of the token.
 	// error can be (401) resp.ErrCouldNotValidateRefreshToken or nil.
 	validateRefreshToken(token *RefreshToken) error
-	// getLatestRefreshToken find and return the latest refresh token for a user in the database.
-	// refreshToken can be nil if there is no latest refresh token
-	getLatestRefreshToken(userId int64) *RefreshToken
+	// revokeRefreshToken marks a refresh token as revoked, only one caller can revoke a token.
+	// error can be (401) resp.ErrRefreshTokenReused, (500) resp.ErrCouldNotRevokeRefreshToken or nil.
+	revokeRefreshToken(token *RefreshToken) error
+	// revokeRefreshTokenFamily removes every refresh token that descended from the same login.
+	// error can be (500) resp.ErrCouldNotRevokeRefreshToken or nil.
+	revokeRefreshTokenFamily(familyId string) error
+	// revokeAllRefreshTokens removes every refresh token for a user.
+	// error can be (500) resp.ErrCouldNotRevokeRefreshToken or nil.
+	revokeAllRefreshTokens(userId int64) error
+	// listSessions finds the active refresh token of every session a user has, that hasn't expired by the time passed.
+	// error can be (500) resp.ErrCouldNotListSessions or nil.
+	listSessions(userId, now int64) ([]resp.Session, error)
+	// revokeSession removes every refresh token in a user's session.
+	// error can be (404) resp.ErrUnknownSession, (500) resp.ErrCouldNotRevokeRefreshToken or nil.
+	revokeSession(userId int64, sessionId string) error
+	// denyAccessToken adds an access token id to the denylist until the time it expires.
+	// error can be (500) resp.ErrCouldNotRevokeAccessToken or nil.
+	denyAccessToken(tokenId string, expiresAt int64) error
+	// isAccessTokenDenied checks the denylist for an access token id.
+	// bool is true if the token id was found, and the token hasn't expired.
+	// int64 is the time the token expires, or zero.
+	// error can be (500) resp.ErrCouldNotCheckDenylist or nil.
+	isAccessTokenDenied(tokenId string, now int64) (bool, int64, error)
 }
 
 // AuthCleaner is the interface that the application
 // uses to clean up expired refresh tokens
 type AuthCleaner interface {
-	// CleanupExpiredRefreshTokens remove any refresh tokens from the database that are expired.
+	// CleanupExpiredRefreshTokens remove any refresh tokens from the database that would have expired by now.
 	// error can be (500) resp.ErrCouldNotCleanupTokens or nil
-	CleanupExpiredRefreshTokens(since int64) error
+	CleanupExpiredRefreshTokens(now int64) error
+	// CleanupExpiredAccessTokenDenials remove any denied access tokens from the database that would have expired by now.
+	// error can be (500) resp.ErrCouldNotCleanupTokens or nil
+	CleanupExpiredAccessTokenDenials(now int64) error
+}
+
+// AuthMigrator is the interface that the application
+// uses to migrate refresh tokens stored before they were hashed
+type AuthMigrator interface {
+	// HashPlaintextRefreshTokens replaces every refresh token stored in plaintext with its keyed hash,
+	// tokens that are already hashed are left alone so it's safe to run at every start.
+	// int64 is the amount of tokens hashed.
+	// error can be (500) resp.ErrCouldNotMigrateRefreshTokens or nil
+	HashPlaintextRefreshTokens() (int64, error)
 }
@@ -0,0 +1,46 @@
+package auth
+
+import "sync"
+
+// denylist is an in-process cache of revoked access token ids, each id is kept until its token expires.
+// Only denied ids are cached, an id missing from the cache still needs to be checked against the database
+// since another instance of the api may have revoked it
+type denylist struct {
+	mu      sync.RWMutex
+	entries map[string]int64
+}
+
+func newDenylist() *denylist {
+	return &denylist{
+		entries: make(map[string]int64),
+	}
+}
+
+// add caches a denied token id until it expires
+func (dl *denylist) add(tokenId string, expiresAt int64) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.entries[tokenId] = expiresAt
+}
+
+// contains returns true if the token id is cached and its token hasn't expired yet
+func (dl *denylist) contains(tokenId string, now int64) bool {
+	dl.mu.RLock()
+	expiresAt, ok := dl.entries[tokenId]
+	dl.mu.RUnlock()
+	return ok && expiresAt > now
+}
+
+// prune removes every token id whose token has expired, an expired token would be rejected anyway
+func (dl *denylist) prune(now int64) int {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	pruned := 0
+	for tokenId, expiresAt := range dl.entries {
+		if expiresAt <= now {
+			delete(dl.entries, tokenId)
+			pruned++
+		}
+	}
+	return pruned
+}
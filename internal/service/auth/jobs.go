@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/bchadwic/wordbubble/internal/job"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// NewRefreshTokenCleanupJob creates a job that removes refresh tokens that have expired,
+// so they can never be validated again
+func NewRefreshTokenCleanupJob(timer util.Timer, cleaner AuthCleaner) job.Job {
+	return job.Job{
+		Name:     "refresh_token_cleanup",
+		Interval: RefreshTokenCleanerRate,
+		Run: func(ctx context.Context) error {
+			return cleaner.CleanupExpiredRefreshTokens(timer.Now().Unix())
+		},
+	}
+}
+
+// NewDenylistCleanupJob creates a job that removes denied access tokens that have expired
+func NewDenylistCleanupJob(timer util.Timer, cleaner AuthCleaner) job.Job {
+	return job.Job{
+		Name:     "denylist_cleanup",
+		Interval: RefreshTokenCleanerRate,
+		Run: func(ctx context.Context) error {
+			return cleaner.CleanupExpiredAccessTokenDenials(timer.Now().Unix())
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CleanupJobs(t *testing.T) {
+	tests := map[string]struct {
+		err                error
+		expectedRefreshNow int64
+		expectedNow        int64
+	}{
+		"valid": {
+			expectedRefreshNow: 100000,
+			expectedNow:        100000,
+		},
+		"invalid, database couldn't cleanup tokens": {
+			err:                resp.ErrCouldNotCleanupTokens,
+			expectedRefreshNow: 100000,
+			expectedNow:        100000,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cleaner := &testAuthCleaner{err: tcase.err}
+			timer := util.TestTimerFromUnix(100000)
+
+			err := NewRefreshTokenCleanupJob(timer, cleaner).Run(context.Background())
+			assert.Equal(t, tcase.err, err)
+			assert.Equal(t, tcase.expectedRefreshNow, cleaner.refreshNow)
+
+			err = NewDenylistCleanupJob(timer, cleaner).Run(context.Background())
+			assert.Equal(t, tcase.err, err)
+			assert.Equal(t, tcase.expectedNow, cleaner.now)
+		})
+	}
+}
+
+type testAuthCleaner struct {
+	err        error
+	refreshNow int64
+	now        int64
+}
+
+func (cleaner *testAuthCleaner) CleanupExpiredRefreshTokens(now int64) error {
+	cleaner.refreshNow = now
+	return cleaner.err
+}
+
+func (cleaner *testAuthCleaner) CleanupExpiredAccessTokenDenials(now int64) error {
+	cleaner.now = now
+	return cleaner.err
+}
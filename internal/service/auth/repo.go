@@ -1,7 +1,11 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 
 	cfg "github.com/bchadwic/wordbubble/internal/config"
 	"github.com/bchadwic/wordbubble/model/resp"
@@ -9,19 +13,30 @@ import (
 )
 
 type authRepo struct {
-	db  *sql.DB
-	log util.Logger
+	db      *sql.DB
+	log     util.Logger
+	hashKey []byte
 }
 
 func NewAuthRepo(config cfg.Config) *authRepo {
 	return &authRepo{
-		log: config.NewLogger("auth_repo"),
-		db:  config.DB(),
+		log:     config.NewLogger("auth_repo"),
+		db:      config.DB(),
+		hashKey: config.TokenHashKey(),
 	}
 }
 
+// hash is the keyed hash a refresh token is stored and looked up by,
+// anyone who can read the database but not the key can't use the tokens stored
+func (repo *authRepo) hash(tokenStr string) string {
+	mac := hmac.New(sha256.New, repo.hashKey)
+	mac.Write([]byte(tokenStr))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func (repo *authRepo) storeRefreshToken(token *RefreshToken) error {
-	_, err := repo.db.Exec(StoreRefreshToken, token.UserId(), token.string, token.issuedAt)
+	_, err := repo.db.Exec(StoreRefreshToken, token.UserId(), repo.hash(token.string), token.issuedAt, token.id, token.familyId, token.parentId,
+		token.createdAt, token.device.UserAgent, token.device.IPAddress, token.expiresAt, token.rememberMe)
 	if err != nil {
 		return resp.ErrCouldNotStoreRefreshToken
 	}
@@ -29,31 +44,110 @@ func (repo *authRepo) storeRefreshToken(token *RefreshToken) error {
 }
 
 func (repo *authRepo) validateRefreshToken(token *RefreshToken) error {
-	row := repo.db.QueryRow(ValidateRefreshToken, token.UserId(), token.string)
-	var issuedAt int64
-	if err := row.Scan(&issuedAt); err != nil {
+	row := repo.db.QueryRow(ValidateRefreshToken, token.UserId(), repo.hash(token.string))
+	var issuedAt, createdAt int64
+	var familyId, parentId string
+	var revoked, rememberMe bool
+	if err := row.Scan(&issuedAt, &familyId, &parentId, &revoked, &createdAt, &rememberMe); err != nil {
 		return resp.ErrCouldNotValidateRefreshToken
 	}
 	token.issuedAt = issuedAt
+	token.familyId = familyId
+	token.parentId = parentId
+	token.revoked = revoked
+	token.createdAt = createdAt
+	token.rememberMe = rememberMe
+	return nil
+}
+
+func (repo *authRepo) revokeRefreshToken(token *RefreshToken) error {
+	rs, err := repo.db.Exec(RevokeRefreshToken, token.id)
+	if err != nil {
+		return resp.ErrCouldNotRevokeRefreshToken
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 {
+		return resp.ErrRefreshTokenReused
+	}
+	return nil
+}
+
+func (repo *authRepo) revokeRefreshTokenFamily(familyId string) error {
+	rs, err := repo.db.Exec(RevokeRefreshTokenFamily, familyId)
+	if err != nil {
+		return resp.ErrCouldNotRevokeRefreshToken
+	}
+	amt, _ := rs.RowsAffected()
+	repo.log.Info("revoked: %d tokens from family: %s", amt, familyId)
+	return nil
+}
+
+func (repo *authRepo) revokeAllRefreshTokens(userId int64) error {
+	rs, err := repo.db.Exec(RevokeAllRefreshTokens, userId)
+	if err != nil {
+		return resp.ErrCouldNotRevokeRefreshToken
+	}
+	amt, _ := rs.RowsAffected()
+	repo.log.Info("revoked: %d tokens for user: %d", amt, userId)
+	return nil
+}
+
+func (repo *authRepo) listSessions(userId, now int64) ([]resp.Session, error) {
+	rows, err := repo.db.Query(ListSessions, userId, now, now-legacyRefreshTokenTimeLimit)
+	if err != nil {
+		repo.log.Error("could not list sessions for user: %d, error: %s", userId, err)
+		return nil, resp.ErrCouldNotListSessions
+	}
+	defer rows.Close()
+	sessions := make([]resp.Session, 0)
+	for rows.Next() {
+		var session resp.Session
+		if err := rows.Scan(&session.Id, &session.UserAgent, &session.IPAddress, &session.CreatedAt, &session.LastUsedAt); err != nil {
+			repo.log.Error("could not map session for user: %d, error: %s", userId, err)
+			return nil, resp.ErrCouldNotListSessions
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, resp.ErrCouldNotListSessions
+	}
+	return sessions, nil
+}
+
+func (repo *authRepo) revokeSession(userId int64, sessionId string) error {
+	rs, err := repo.db.Exec(RevokeSession, userId, sessionId)
+	if err != nil {
+		return resp.ErrCouldNotRevokeRefreshToken
+	}
+	amt, _ := rs.RowsAffected()
+	if amt <= 0 {
+		return resp.ErrUnknownSession
+	}
+	repo.log.Info("revoked: %d tokens from session: %s", amt, sessionId)
 	return nil
 }
 
-func (repo *authRepo) getLatestRefreshToken(userId int64) *RefreshToken {
-	row := repo.db.QueryRow(GetLatestRefreshToken, userId)
-	var issuedAt int64
-	var val string
-	if err := row.Scan(&val, &issuedAt); err != nil {
-		return nil
+func (repo *authRepo) denyAccessToken(tokenId string, expiresAt int64) error {
+	if _, err := repo.db.Exec(DenyAccessToken, tokenId, expiresAt); err != nil {
+		return resp.ErrCouldNotRevokeAccessToken
 	}
-	return &RefreshToken{
-		string:   val,
-		issuedAt: issuedAt,
-		userId:   userId,
+	return nil
+}
+
+func (repo *authRepo) isAccessTokenDenied(tokenId string, now int64) (bool, int64, error) {
+	row := repo.db.QueryRow(GetDeniedAccessToken, tokenId)
+	var expiresAt int64
+	if err := row.Scan(&expiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, 0, nil
+		}
+		repo.log.Error("could not check denylist for token: %s, error: %s", tokenId, err)
+		return false, 0, resp.ErrCouldNotCheckDenylist
 	}
+	return expiresAt > now, expiresAt, nil
 }
 
-func (repo *authRepo) CleanupExpiredRefreshTokens(since int64) error {
-	rs, err := repo.db.Exec(CleanupExpiredRefreshTokens, since)
+func (repo *authRepo) CleanupExpiredRefreshTokens(now int64) error {
+	rs, err := repo.db.Exec(CleanupExpiredRefreshTokens, now, now-legacyRefreshTokenTimeLimit)
 	if err != nil {
 		return resp.ErrCouldNotCleanupTokens
 	}
@@ -61,3 +155,55 @@ func (repo *authRepo) CleanupExpiredRefreshTokens(since int64) error {
 	repo.log.Info("refresh token cleaner deleted: %d tokens", amt)
 	return nil
 }
+
+func (repo *authRepo) CleanupExpiredAccessTokenDenials(now int64) error {
+	rs, err := repo.db.Exec(CleanupDeniedAccessTokens, now)
+	if err != nil {
+		return resp.ErrCouldNotCleanupTokens
+	}
+	amt, _ := rs.RowsAffected()
+	repo.log.Info("denylist cleaner deleted: %d tokens", amt)
+	return nil
+}
+
+func (repo *authRepo) HashPlaintextRefreshTokens() (int64, error) {
+	rows, err := repo.db.Query(GetPlaintextRefreshTokens)
+	if err != nil {
+		repo.log.Error("could not find plaintext refresh tokens, error: %s", err)
+		return 0, resp.ErrCouldNotMigrateRefreshTokens
+	}
+	var plaintext []string
+	for rows.Next() {
+		var tokenStr string
+		if err := rows.Scan(&tokenStr); err != nil {
+			rows.Close()
+			return 0, resp.ErrCouldNotMigrateRefreshTokens
+		}
+		plaintext = append(plaintext, tokenStr)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, resp.ErrCouldNotMigrateRefreshTokens
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, resp.ErrCouldNotMigrateRefreshTokens
+	}
+	defer tx.Rollback()
+	var amt int64
+	for _, tokenStr := range plaintext {
+		rs, err := tx.Exec(HashRefreshToken, repo.hash(tokenStr), tokenStr)
+		if err != nil {
+			repo.log.Error("could not hash a plaintext refresh token, error: %s", err)
+			return 0, resp.ErrCouldNotMigrateRefreshTokens
+		}
+		n, _ := rs.RowsAffected()
+		amt += n
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, resp.ErrCouldNotMigrateRefreshTokens
+	}
+	repo.log.Info("refresh token migration hashed: %d tokens", amt)
+	return amt, nil
+}
@@ -1,22 +1,65 @@
 package auth
 
 import (
+	"database/sql"
+	"os"
 	"testing"
 
 	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model"
 	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
 	"github.com/stretchr/testify/assert"
 )
 
+// testRepos are the databases the refresh token queries are checked against, sqlite always,
+// and postgres when WB_TEST_POSTGRES_DSN is set, using a temporary table so nothing is left behind
+func testRepos(t *testing.T) map[string]*authRepo {
+	repos := map[string]*authRepo{"sqlite": NewAuthRepo(cfg.TestConfig())}
+	dsn := os.Getenv("WB_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Log("WB_TEST_POSTGRES_DSN is not set, only testing sqlite")
+		return repos
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1) // a temporary table only exists on the connection that created it
+	_, err = db.Exec(`
+		CREATE TEMPORARY TABLE tokens (
+			user_id INTEGER NOT NULL,
+			refresh_token TEXT NOT NULL,
+			issued_at INTEGER NOT NULL,
+			token_id TEXT NOT NULL DEFAULT '',
+			family_id TEXT NOT NULL DEFAULT '',
+			parent_id TEXT NOT NULL DEFAULT '',
+			revoked BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at INTEGER NOT NULL DEFAULT 0,
+			user_agent TEXT NOT NULL DEFAULT '',
+			ip_address TEXT NOT NULL DEFAULT '',
+			expires_at INTEGER NOT NULL DEFAULT 0,
+			remember_me BOOLEAN NOT NULL DEFAULT FALSE
+		);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos["postgres"] = &authRepo{db: db, log: util.TestLogger(), hashKey: []byte("test-token-hash-key")}
+	return repos
+}
+
 func Test_HappyPath(t *testing.T) {
 	repo := NewAuthRepo(cfg.TestConfig())
-	tokenStr, userId, issuedAt := "im.a.token", int64(56), int64(234)
+	tokenStr, userId, issuedAt, expiresAt := "im.a.token", int64(56), int64(234), int64(334)
 
-	// A user signs up or logins in. Refresh token is saved after being generated
+	// A user signs up or logins in, asking to be remembered. Refresh token is saved after being generated
 	token := &RefreshToken{
-		string:   tokenStr,
-		userId:   userId,
-		issuedAt: issuedAt,
+		string:     tokenStr,
+		userId:     userId,
+		issuedAt:   issuedAt,
+		expiresAt:  expiresAt,
+		rememberMe: true,
 	}
 	err := repo.storeRefreshToken(token)
 	assert.NoError(t, err)
@@ -29,19 +72,22 @@ func Test_HappyPath(t *testing.T) {
 	err = repo.validateRefreshToken(token)
 	assert.NoError(t, err)
 	assert.Equal(t, issuedAt, token.issuedAt)
+	assert.True(t, token.rememberMe)
 
-	// On a different device, a user uses a close to EOL token thus triggering a GetLatestRefreshToken
-	token = repo.getLatestRefreshToken(userId)
-	assert.NotNil(t, token)
-	assert.Equal(t, tokenStr, token.string)
-	assert.Equal(t, userId, token.UserId())
-	assert.Equal(t, issuedAt, token.issuedAt)
+	// Only the keyed hash of the token is stored, reading the database doesn't give anyone a usable token
+	var stored string
+	assert.NoError(t, repo.db.QueryRow(`SELECT refresh_token FROM tokens WHERE user_id = $1`, userId).Scan(&stored))
+	assert.NotContains(t, stored, tokenStr)
+	assert.Equal(t, repo.hash(tokenStr), stored)
+	err = repo.validateRefreshToken(&RefreshToken{string: stored, userId: userId})
+	assert.Equal(t, resp.ErrCouldNotValidateRefreshToken, err)
+
+	// The token isn't cleaned up before it expires
+	assert.NoError(t, repo.CleanupExpiredRefreshTokens(expiresAt-1))
+	assert.NoError(t, repo.validateRefreshToken(&RefreshToken{string: tokenStr, userId: userId}))
 
 	// The user has been away from some time, time to clean up the token
-	repo.CleanupExpiredRefreshTokens(issuedAt + 1)
-	// when the user comes back, they are faced with a login flow
-	token = repo.getLatestRefreshToken(userId)
-	assert.Nil(t, token)
+	repo.CleanupExpiredRefreshTokens(expiresAt + 1)
 
 	// malicious user tries to validate an old token
 	token = &RefreshToken{
@@ -53,6 +99,189 @@ func Test_HappyPath(t *testing.T) {
 	assert.Equal(t, resp.ErrCouldNotValidateRefreshToken.Error(), err.Error())
 }
 
+func Test_MigrationPath(t *testing.T) {
+	for dialect, repo := range testRepos(t) {
+		t.Run(dialect, func(t *testing.T) {
+			userId := int64(33)
+
+			// Before tokens were hashed, a user logged in on two devices
+			for _, tokenStr := range []string{"old.laptop.token", "old.phone.token"} {
+				_, err := repo.db.Exec(`INSERT INTO tokens (user_id, refresh_token, issued_at) VALUES ($1, $2, $3)`, userId, tokenStr, 100)
+				assert.NoError(t, err)
+			}
+			// and after, on a third
+			assert.NoError(t, repo.storeRefreshToken(&RefreshToken{string: "new.tablet.token", userId: userId, issuedAt: 200}))
+
+			// The plaintext tokens can't be found until they're migrated
+			assert.Equal(t, resp.ErrCouldNotValidateRefreshToken, repo.validateRefreshToken(&RefreshToken{string: "old.laptop.token", userId: userId}))
+
+			// Only the plaintext tokens are hashed, and migrating again does nothing
+			amt, err := repo.HashPlaintextRefreshTokens()
+			assert.NoError(t, err)
+			assert.Equal(t, int64(2), amt)
+			amt, err = repo.HashPlaintextRefreshTokens()
+			assert.NoError(t, err)
+			assert.Zero(t, amt)
+
+			// Every device is still logged in, and no token is left in plaintext
+			for _, tokenStr := range []string{"old.laptop.token", "old.phone.token", "new.tablet.token"} {
+				token := &RefreshToken{string: tokenStr, userId: userId}
+				assert.NoError(t, repo.validateRefreshToken(token), tokenStr)
+			}
+			var plaintext int
+			assert.NoError(t, repo.db.QueryRow(`SELECT COUNT(*) FROM tokens WHERE refresh_token LIKE '%.%'`).Scan(&plaintext))
+			assert.Zero(t, plaintext)
+
+			// None of them stored when they expire, so they're cleaned up once the lifetime they were issued with is over
+			assert.NoError(t, repo.CleanupExpiredRefreshTokens(100+legacyRefreshTokenTimeLimit))
+			assert.NoError(t, repo.validateRefreshToken(&RefreshToken{string: "old.laptop.token", userId: userId}))
+			assert.NoError(t, repo.CleanupExpiredRefreshTokens(101+legacyRefreshTokenTimeLimit))
+			assert.Equal(t, resp.ErrCouldNotValidateRefreshToken, repo.validateRefreshToken(&RefreshToken{string: "old.laptop.token", userId: userId}))
+			assert.NoError(t, repo.validateRefreshToken(&RefreshToken{string: "new.tablet.token", userId: userId}))
+		})
+	}
+}
+
+func Test_RotationPath(t *testing.T) {
+	repo := NewAuthRepo(cfg.TestConfig())
+	userId := int64(12)
+
+	// A user logs in, starting a new family of refresh tokens
+	first := &RefreshToken{string: "first.refresh.token", id: "1", familyId: "1", userId: userId, issuedAt: 100}
+	err := repo.storeRefreshToken(first)
+	assert.NoError(t, err)
+
+	// The user rotates the token, the first token is revoked and replaced by its child
+	err = repo.revokeRefreshToken(first)
+	assert.NoError(t, err)
+	second := &RefreshToken{string: "second.refresh.token", id: "2", familyId: "1", parentId: "1", userId: userId, issuedAt: 200}
+	err = repo.storeRefreshToken(second)
+	assert.NoError(t, err)
+
+	token := &RefreshToken{string: "second.refresh.token", userId: userId}
+	err = repo.validateRefreshToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", token.familyId)
+	assert.Equal(t, "1", token.parentId)
+	assert.False(t, token.revoked)
+
+	// An attacker replays the first token, it's found to be revoked and can't be revoked a second time
+	token = &RefreshToken{string: "first.refresh.token", id: "1", userId: userId}
+	err = repo.validateRefreshToken(token)
+	assert.NoError(t, err)
+	assert.True(t, token.revoked)
+	err = repo.revokeRefreshToken(token)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrRefreshTokenReused.Error(), err.Error())
+
+	// The whole family is revoked, forcing a new login
+	err = repo.revokeRefreshTokenFamily(token.familyId)
+	assert.NoError(t, err)
+	err = repo.validateRefreshToken(&RefreshToken{string: "second.refresh.token", userId: userId})
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotValidateRefreshToken.Error(), err.Error())
+}
+
+func Test_LogoutPath(t *testing.T) {
+	repo := NewAuthRepo(cfg.TestConfig())
+	userId := int64(7)
+
+	// A user logs in on a laptop and a phone
+	laptop := &RefreshToken{string: "laptop.refresh.token", id: "1", familyId: "1", userId: userId, issuedAt: 100}
+	phone := &RefreshToken{string: "phone.refresh.token", id: "2", familyId: "2", userId: userId, issuedAt: 100}
+	assert.NoError(t, repo.storeRefreshToken(laptop))
+	assert.NoError(t, repo.storeRefreshToken(phone))
+
+	// The user logs out of the laptop, the phone is still logged in
+	assert.NoError(t, repo.revokeRefreshTokenFamily(laptop.familyId))
+	assert.Equal(t, resp.ErrCouldNotValidateRefreshToken, repo.validateRefreshToken(&RefreshToken{string: laptop.string, userId: userId}))
+	assert.NoError(t, repo.validateRefreshToken(&RefreshToken{string: phone.string, userId: userId}))
+
+	// The user logs in again on the laptop, then loses it and logs out everywhere
+	assert.NoError(t, repo.storeRefreshToken(&RefreshToken{string: "laptop.refresh.token.2", id: "3", familyId: "3", userId: userId, issuedAt: 200}))
+	assert.NoError(t, repo.revokeAllRefreshTokens(userId))
+	assert.Equal(t, resp.ErrCouldNotValidateRefreshToken, repo.validateRefreshToken(&RefreshToken{string: phone.string, userId: userId}))
+	assert.Equal(t, resp.ErrCouldNotValidateRefreshToken, repo.validateRefreshToken(&RefreshToken{string: "laptop.refresh.token.2", userId: userId}))
+}
+
+func Test_DenylistPath(t *testing.T) {
+	repo := NewAuthRepo(cfg.TestConfig())
+
+	// A user logs out everywhere, the access token they used is denied until it expires
+	err := repo.denyAccessToken("access-token-id", 130)
+	assert.NoError(t, err)
+	err = repo.denyAccessToken("access-token-id", 130) // denying twice is harmless
+	assert.NoError(t, err)
+
+	denied, expiresAt, err := repo.isAccessTokenDenied("access-token-id", 100)
+	assert.NoError(t, err)
+	assert.True(t, denied)
+	assert.Equal(t, int64(130), expiresAt)
+	denied, _, err = repo.isAccessTokenDenied("other-access-token-id", 100)
+	assert.NoError(t, err)
+	assert.False(t, denied)
+
+	// Once the token expires the denial doesn't matter anymore, and gets cleaned up
+	denied, _, _ = repo.isAccessTokenDenied("access-token-id", 130)
+	assert.False(t, denied)
+	err = repo.CleanupExpiredAccessTokenDenials(131)
+	assert.NoError(t, err)
+	denied, expiresAt, _ = repo.isAccessTokenDenied("access-token-id", 0)
+	assert.False(t, denied)
+	assert.Zero(t, expiresAt)
+}
+
+func Test_SessionPath(t *testing.T) {
+	repo := NewAuthRepo(cfg.TestConfig())
+	userId := int64(9)
+	laptop := model.Device{UserAgent: "Mozilla/5.0 (X11; Linux x86_64)", IPAddress: "203.0.113.7"}
+	phone := model.Device{UserAgent: "Mozilla/5.0 (iPhone)", IPAddress: "198.51.100.4"}
+
+	// A user has no sessions until they login
+	sessions, err := repo.listSessions(userId, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	// The user logs in on a laptop, then on a phone
+	assert.NoError(t, repo.storeRefreshToken(&RefreshToken{string: "laptop.1", id: "1", familyId: "1", userId: userId, issuedAt: 100, expiresAt: 1100, createdAt: 100, device: laptop}))
+	assert.NoError(t, repo.storeRefreshToken(&RefreshToken{string: "phone.1", id: "2", familyId: "2", userId: userId, issuedAt: 200, expiresAt: 1200, createdAt: 200, device: phone}))
+
+	// The laptop rotates its token from a new network, the session keeps its created time
+	laptop.IPAddress = "192.0.2.50"
+	assert.NoError(t, repo.revokeRefreshToken(&RefreshToken{id: "1"}))
+	assert.NoError(t, repo.storeRefreshToken(&RefreshToken{string: "laptop.2", id: "3", familyId: "1", parentId: "1", userId: userId, issuedAt: 300, expiresAt: 1300, createdAt: 100, device: laptop}))
+	token := &RefreshToken{string: "laptop.2", userId: userId}
+	assert.NoError(t, repo.validateRefreshToken(token))
+	assert.Equal(t, int64(100), token.createdAt)
+
+	// Each session is listed once, the most recently used first
+	sessions, err = repo.listSessions(userId, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []resp.Session{
+		{Id: "1", UserAgent: laptop.UserAgent, IPAddress: "192.0.2.50", CreatedAt: 100, LastUsedAt: 300},
+		{Id: "2", UserAgent: phone.UserAgent, IPAddress: phone.IPAddress, CreatedAt: 200, LastUsedAt: 200},
+	}, sessions)
+
+	// Sessions whose refresh token has expired aren't listed
+	sessions, err = repo.listSessions(userId, 1250)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+
+	// Another user can't revoke the user's session
+	assert.Equal(t, resp.ErrUnknownSession, repo.revokeSession(userId+1, "2"))
+
+	// The user revokes the phone's session, it can no longer be used
+	assert.NoError(t, repo.revokeSession(userId, "2"))
+	assert.Equal(t, resp.ErrCouldNotValidateRefreshToken, repo.validateRefreshToken(&RefreshToken{string: "phone.1", userId: userId}))
+	sessions, err = repo.listSessions(userId, 0)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, "1", sessions[0].Id)
+
+	// The session is gone, so it can't be revoked again
+	assert.Equal(t, resp.ErrUnknownSession, repo.revokeSession(userId, "2"))
+}
+
 func Test_NotSoHappyPath(t *testing.T) {
 	repo := NewAuthRepo(cfg.TestConfig())
 
@@ -65,4 +294,42 @@ func Test_NotSoHappyPath(t *testing.T) {
 	err = repo.CleanupExpiredRefreshTokens(0)
 	assert.NotNil(t, err)
 	assert.Equal(t, resp.ErrCouldNotCleanupTokens.Error(), err.Error())
+
+	err = repo.revokeRefreshToken(&RefreshToken{})
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotRevokeRefreshToken.Error(), err.Error())
+
+	err = repo.revokeRefreshTokenFamily("")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotRevokeRefreshToken.Error(), err.Error())
+
+	err = repo.revokeAllRefreshTokens(0)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotRevokeRefreshToken.Error(), err.Error())
+
+	err = repo.denyAccessToken("", 0)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotRevokeAccessToken.Error(), err.Error())
+
+	sessions, err := repo.listSessions(0, 0)
+	assert.Nil(t, sessions)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotListSessions.Error(), err.Error())
+
+	err = repo.revokeSession(0, "")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotRevokeRefreshToken.Error(), err.Error())
+
+	denied, _, err := repo.isAccessTokenDenied("", 0)
+	assert.False(t, denied, "a denylist that can't be read never lets a token through")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCheckDenylist.Error(), err.Error())
+
+	err = repo.CleanupExpiredAccessTokenDenials(0)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCleanupTokens.Error(), err.Error())
+
+	_, err = repo.HashPlaintextRefreshTokens()
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotMigrateRefreshTokens.Error(), err.Error())
 }
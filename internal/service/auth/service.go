@@ -1,63 +1,206 @@
 package auth
 
 import (
-	"time"
+	"errors"
 
 	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model"
 	"github.com/bchadwic/wordbubble/model/resp"
 	"github.com/bchadwic/wordbubble/util"
 )
 
 type authService struct {
-	log   util.Logger
-	timer util.Timer
-	repo  AuthRepo
+	log       util.Logger
+	timer     util.Timer
+	repo      AuthRepo
+	keys      util.KeyProvider
+	denylist  *denylist
+	lifetimes util.TokenLifetimes
 }
 
 type RefreshToken struct {
 	string
-	issuedAt int64
-	userId   int64
-	NearEOL  bool
+	id        string
+	familyId  string
+	parentId  string
+	issuedAt  int64
+	expiresAt int64
+	userId    int64
+	revoked   bool
+	scope     string
+	clientId  string
+	NearEOL   bool
+	// session metadata, the time the family was started, the device the token was issued to,
+	// and whether the user asked to be remembered, which makes each token in the family last longer
+	createdAt  int64
+	device     model.Device
+	rememberMe bool
 }
 
 func NewAuthService(cfg cfg.Config, repo AuthRepo) *authService {
 	return &authService{
-		log:   cfg.NewLogger("auth"),
-		timer: cfg.Timer(),
-		repo:  repo,
+		log:       cfg.NewLogger("auth"),
+		timer:     cfg.Timer(),
+		repo:      repo,
+		keys:      cfg.Keys(),
+		denylist:  newDenylist(),
+		lifetimes: cfg.TokenLifetimes(),
 	}
 }
 
-func (svc *authService) GenerateAccessToken(userId int64) string {
+func (svc *authService) GenerateAccessToken(userId int64, scope, role string) string {
 	now := svc.timer.Now()
-	return util.GenerateSignedToken(now.Unix(), now.Add(accessTokenTimeLimit).Unix(), userId)
+	return util.GenerateSignedToken(svc.keys, util.TokenTypeAccess, now.Unix(), now.Add(svc.lifetimes.AccessToken).Unix(), userId, scope, role)
 }
 
-func (svc *authService) GenerateRefreshToken(userId int64) (string, error) {
-	now := svc.timer.Now()
-	token, _ := RefreshTokenFromTokenString(
-		util.GenerateSignedToken(now.Unix(), now.Add(refreshTokenTimeLimit*time.Second).Unix(), userId),
-	)
-	if err := svc.repo.storeRefreshToken(token); err != nil {
-		return "", err
+func (svc *authService) ValidateAccessToken(tokenStr string) (*model.TokenClaims, error) {
+	claims, err := util.ParseWithClaims(svc.keys, util.TokenTypeAccess, tokenStr)
+	if err != nil {
+		return nil, err
 	}
-	return token.string, nil
+	if claims.Id == "" { // tokens issued without an id can't be revoked
+		return claims, nil
+	}
+	now := svc.timer.Now().Unix()
+	if svc.denylist.contains(claims.Id, now) {
+		return nil, resp.ErrTokenIsRevoked
+	}
+	denied, expiresAt, err := svc.repo.isAccessTokenDenied(claims.Id, now)
+	if err != nil { // a revoked token must never be accepted because the denylist couldn't be read
+		return nil, err
+	}
+	if denied {
+		svc.denylist.add(claims.Id, expiresAt) // revoked by another instance
+		return nil, resp.ErrTokenIsRevoked
+	}
+	return claims, nil
+}
+
+func (svc *authService) RevokeAccessToken(principal *model.Principal) error {
+	if principal.TokenId == "" {
+		return nil
+	}
+	if err := svc.repo.denyAccessToken(principal.TokenId, principal.ExpiresAt); err != nil {
+		return err
+	}
+	svc.denylist.prune(svc.timer.Now().Unix())
+	svc.denylist.add(principal.TokenId, principal.ExpiresAt)
+	return nil
+}
+
+func (svc *authService) GenerateRefreshToken(userId int64, scope string, rememberMe bool, device *model.Device) (string, error) {
+	return svc.generateRefreshToken(userId, "", scope, rememberMe, device, nil)
+}
+
+func (svc *authService) GenerateClientRefreshToken(userId int64, clientId, scope string, device *model.Device) (string, error) {
+	return svc.generateRefreshToken(userId, clientId, scope, false, device, nil)
 }
 
 func (svc *authService) ValidateRefreshToken(token *RefreshToken) (err error) {
+	if token.id == "" { // tokens issued before rotation existed can't be tracked, force a login
+		return resp.ErrCouldNotValidateRefreshToken
+	}
 	if err = svc.checkRefreshTokenExpiry(token); err != nil {
 		return
 	}
 	if err = svc.repo.validateRefreshToken(token); err != nil {
 		return
 	}
+	if token.revoked {
+		return svc.revokeRefreshTokenFamily(token)
+	}
+	if token.createdAt != 0 && svc.timer.Now().Unix() >= token.createdAt+int64(svc.lifetimes.MaxSession.Seconds()) {
+		return resp.ErrSessionIsExpired
+	}
 	return
 }
 
-// sets EOL flag for token; returns error if token is expired
+func (svc *authService) RotateRefreshToken(token *RefreshToken, device *model.Device) (string, error) {
+	if err := svc.ValidateRefreshToken(token); err != nil {
+		return "", err
+	}
+	if err := svc.repo.revokeRefreshToken(token); err != nil {
+		if errors.Is(err, resp.ErrRefreshTokenReused) { // someone else rotated this token first
+			return "", svc.revokeRefreshTokenFamily(token)
+		}
+		return "", err
+	}
+	return svc.generateRefreshToken(token.userId, token.clientId, token.scope, token.rememberMe, device, token)
+}
+
+func (svc *authService) RevokeRefreshToken(token *RefreshToken) error {
+	if err := svc.ValidateRefreshToken(token); err != nil {
+		return err
+	}
+	return svc.repo.revokeRefreshTokenFamily(token.familyId)
+}
+
+func (svc *authService) RevokeAllRefreshTokens(userId int64) error {
+	return svc.repo.revokeAllRefreshTokens(userId)
+}
+
+func (svc *authService) ListSessions(userId int64) ([]resp.Session, error) {
+	return svc.repo.listSessions(userId, svc.timer.Now().Unix())
+}
+
+func (svc *authService) RevokeSession(userId int64, sessionId string) error {
+	if err := svc.repo.revokeSession(userId, sessionId); err != nil {
+		return err
+	}
+	svc.log.Info("revoked session: %s for user: %d", sessionId, userId)
+	return nil
+}
+
+// generates and stores a refresh token, the token starts a new family when there is no parent, and is issued to the oauth client
+// when there is a client id. The token expires after its lifetime, or when its family reaches the max session lifetime if that's sooner
+func (svc *authService) generateRefreshToken(userId int64, clientId, scope string, rememberMe bool, device *model.Device, parent *RefreshToken) (string, error) {
+	now := svc.timer.Now()
+	createdAt := now.Unix()
+	if parent != nil && parent.createdAt != 0 { // families started before sessions were tracked begin with their next token
+		createdAt = parent.createdAt
+	}
+	lifetime := svc.lifetimes.RefreshToken
+	if rememberMe {
+		lifetime = svc.lifetimes.RememberMe
+	}
+	expiresAt := now.Add(lifetime).Unix()
+	if sessionEnd := createdAt + int64(svc.lifetimes.MaxSession.Seconds()); sessionEnd < expiresAt {
+		expiresAt = sessionEnd
+	}
+	if expiresAt <= now.Unix() {
+		return "", resp.ErrSessionIsExpired
+	}
+	token, _ := RefreshTokenFromTokenString(
+		svc.keys, util.GenerateSignedClientToken(svc.keys, util.TokenTypeRefresh, now.Unix(), expiresAt, userId, scope, "", clientId),
+	)
+	token.familyId = token.id
+	token.createdAt = createdAt
+	token.rememberMe = rememberMe
+	if parent != nil {
+		token.familyId = parent.familyId
+		token.parentId = parent.id
+	}
+	if device != nil {
+		token.device = *device
+	}
+	if err := svc.repo.storeRefreshToken(token); err != nil {
+		return "", err
+	}
+	return token.string, nil
+}
+
+// a revoked token being presented means it was stolen or replayed, so every token in the family is revoked
+func (svc *authService) revokeRefreshTokenFamily(token *RefreshToken) error {
+	svc.log.Warn("refresh token reuse detected for user: %d, revoking family: %s", token.userId, token.familyId)
+	if err := svc.repo.revokeRefreshTokenFamily(token.familyId); err != nil {
+		return err
+	}
+	return resp.ErrRefreshTokenReused
+}
+
+// sets EOL flag for token when it's inside the renewal window; returns error if token is expired
 func (svc *authService) checkRefreshTokenExpiry(token *RefreshToken) error {
-	if timeLeft := refreshTokenTimeLimit - (svc.timer.Now().Unix() - token.issuedAt); timeLeft < ImminentExpirationWindow {
+	if timeLeft := token.expiresAt - svc.timer.Now().Unix(); timeLeft < int64(svc.lifetimes.RenewalWindow.Seconds()) {
 		token.NearEOL = true
 		if timeLeft <= 0 {
 			return resp.ErrTokenIsExpired
@@ -66,15 +209,19 @@ func (svc *authService) checkRefreshTokenExpiry(token *RefreshToken) error {
 	return nil
 }
 
-func RefreshTokenFromTokenString(tokenStr string) (*RefreshToken, error) {
-	claims, err := util.ParseWithClaims(tokenStr)
+func RefreshTokenFromTokenString(keys util.KeyProvider, tokenStr string) (*RefreshToken, error) {
+	claims, err := util.ParseWithClaims(keys, util.TokenTypeRefresh, tokenStr)
 	if err != nil {
 		return nil, resp.ErrParseRefreshToken
 	}
 	return &RefreshToken{
-		string:   tokenStr,
-		userId:   claims.UserId,
-		issuedAt: claims.IssuedAt,
+		string:    tokenStr,
+		id:        claims.Id,
+		userId:    claims.UserId,
+		issuedAt:  claims.IssuedAt,
+		expiresAt: claims.ExpiresAt,
+		scope:     claims.Scope,
+		clientId:  claims.ClientId,
 	}, nil
 }
 
@@ -87,3 +234,18 @@ func (tkn *RefreshToken) IsNearEndOfLife() bool {
 func (tkn *RefreshToken) UserId() int64 {
 	return tkn.userId
 }
+
+// returns the unix time the token expires
+func (tkn *RefreshToken) ExpiresAt() int64 {
+	return tkn.expiresAt
+}
+
+// returns the scope granted to the token, empty if the token was issued before scopes existed
+func (tkn *RefreshToken) Scope() string {
+	return tkn.scope
+}
+
+// returns the oauth client the token was issued to, empty if it was issued to the user directly
+func (tkn *RefreshToken) ClientId() string {
+	return tkn.clientId
+}
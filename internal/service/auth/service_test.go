@@ -5,12 +5,21 @@ import (
 	"testing"
 
 	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model"
 	"github.com/bchadwic/wordbubble/model/resp"
 	"github.com/bchadwic/wordbubble/util"
 	"github.com/golang-jwt/jwt"
 	"github.com/stretchr/testify/assert"
 )
 
+// lifetimes of the test config, in seconds
+var (
+	refreshTokenLifetime = int64(util.DefaultTokenLifetimes().RefreshToken.Seconds())
+	rememberMeLifetime   = int64(util.DefaultTokenLifetimes().RememberMe.Seconds())
+	renewalWindow        = int64(util.DefaultTokenLifetimes().RenewalWindow.Seconds())
+	maxSessionLifetime   = int64(util.DefaultTokenLifetimes().MaxSession.Seconds())
+)
+
 func Test_GenerateAccessToken(t *testing.T) {
 	tests := map[string]struct {
 		timer  util.Timer
@@ -24,30 +33,164 @@ func Test_GenerateAccessToken(t *testing.T) {
 	for tname, tcase := range tests {
 		t.Run(tname, func(t *testing.T) {
 			jwt.TimeFunc = tcase.timer.Now
-			util.SigningKey = func() []byte { return []byte("test") }
 			cfg := cfg.TestConfig()
 			cfg.SetTimer(tcase.timer)
 			svc := NewAuthService(cfg, nil)
-			tokenStr := svc.GenerateAccessToken(tcase.userId)
+			tokenStr := svc.GenerateAccessToken(tcase.userId, "wordbubble:push", util.RoleModerator)
 			parts := strings.Split(tokenStr, ".")
 			assert.Equal(t, 3, len(parts))
+			claims, _ := util.ParseWithClaims(cfg.Keys(), util.TokenTypeAccess, tokenStr)
+			assert.Equal(t, "wordbubble:push", claims.Scope)
+			assert.Equal(t, util.RoleModerator, claims.Role)
 		})
 	}
 }
 
-func Test_GenerateRefreshToken(t *testing.T) {
+func Test_ValidateAccessToken(t *testing.T) {
 	tests := map[string]struct {
-		timer    util.Timer
-		repo     AuthRepo
-		userId   int64
-		wantsErr bool
+		timer       util.Timer
+		repo        *testAuthRepo
+		cached      bool
+		expectedErr error
 	}{
 		"valid": {
+			timer: util.TestTimerFromUnix(0),
+			repo:  &testAuthRepo{},
+		},
+		"error revoked by this instance": {
+			timer:       util.TestTimerFromUnix(0),
+			repo:        &testAuthRepo{},
+			cached:      true,
+			expectedErr: resp.ErrTokenIsRevoked,
+		},
+		"error revoked by another instance": {
+			timer: util.TestTimerFromUnix(0),
+			repo: &testAuthRepo{
+				denied: true,
+			},
+			expectedErr: resp.ErrTokenIsRevoked,
+		},
+		"error denylist couldn't be checked": {
 			timer: util.TestTimerFromUnix(0),
 			repo: &testAuthRepo{
-				refreshToken: &RefreshToken{string: "hi"},
+				errDenied: resp.ErrCouldNotCheckDenylist,
 			},
-			userId: 96,
+			expectedErr: resp.ErrCouldNotCheckDenylist,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			jwt.TimeFunc = tcase.timer.Now
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(tcase.timer)
+			svc := NewAuthService(cfg, tcase.repo)
+			tokenStr := svc.GenerateAccessToken(14, util.AllScopes(), util.RoleUser)
+			if tcase.cached {
+				claims, _ := util.ParseWithClaims(cfg.Keys(), util.TokenTypeAccess, tokenStr)
+				svc.denylist.add(claims.Id, claims.ExpiresAt)
+			}
+			claims, err := svc.ValidateAccessToken(tokenStr)
+			if tcase.expectedErr != nil {
+				assert.Nil(t, claims)
+				assert.NotNil(t, err)
+				assert.Equal(t, tcase.expectedErr.Error(), err.Error())
+				if tcase.expectedErr == resp.ErrTokenIsRevoked {
+					// once found the denial is cached, so the database isn't needed again
+					tcase.repo.denied = false
+					_, err = svc.ValidateAccessToken(tokenStr)
+					assert.Equal(t, tcase.expectedErr.Error(), err.Error())
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(14), claims.UserId)
+				assert.NotEmpty(t, claims.Id)
+			}
+		})
+	}
+}
+
+func Test_RevokeAccessToken(t *testing.T) {
+	tests := map[string]struct {
+		timer       util.Timer
+		repo        *testAuthRepo
+		principal   *model.Principal
+		expectedErr error
+	}{
+		"valid": {
+			timer:     util.TestTimerFromUnix(100),
+			repo:      &testAuthRepo{},
+			principal: &model.Principal{TokenId: "a", ExpiresAt: 130},
+		},
+		"valid, token without an id can't be revoked": {
+			timer:     util.TestTimerFromUnix(100),
+			repo:      &testAuthRepo{},
+			principal: &model.Principal{ExpiresAt: 130},
+		},
+		"valid, api key can't be revoked": {
+			timer:     util.TestTimerFromUnix(100),
+			repo:      &testAuthRepo{},
+			principal: &model.Principal{UserId: 2, ApiKey: true},
+		},
+		"error from database": {
+			timer: util.TestTimerFromUnix(100),
+			repo: &testAuthRepo{
+				errDeny: resp.ErrCouldNotRevokeAccessToken,
+			},
+			principal:   &model.Principal{TokenId: "a", ExpiresAt: 130},
+			expectedErr: resp.ErrCouldNotRevokeAccessToken,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(tcase.timer)
+			svc := NewAuthService(cfg, tcase.repo)
+			svc.denylist.add("expired", 99)
+			err := svc.RevokeAccessToken(tcase.principal)
+			if tcase.expectedErr != nil {
+				assert.NotNil(t, err)
+				assert.Equal(t, tcase.expectedErr.Error(), err.Error())
+				assert.False(t, svc.denylist.contains(tcase.principal.TokenId, 100))
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tcase.principal.TokenId, tcase.repo.deniedId)
+				if tcase.principal.TokenId != "" {
+					assert.True(t, svc.denylist.contains(tcase.principal.TokenId, 100))
+					assert.False(t, svc.denylist.contains(tcase.principal.TokenId, 130))
+					assert.NotContains(t, svc.denylist.entries, "expired")
+				}
+			}
+		})
+	}
+}
+
+func Test_GenerateRefreshToken(t *testing.T) {
+	tests := map[string]struct {
+		timer             util.Timer
+		repo              *testAuthRepo
+		userId            int64
+		rememberMe        bool
+		expectedExpiresAt int64
+		wantsErr          bool
+	}{
+		"valid": {
+			timer:             util.TestTimerFromUnix(0),
+			repo:              &testAuthRepo{},
+			userId:            96,
+			expectedExpiresAt: refreshTokenLifetime,
+		},
+		"valid, session started now": {
+			timer:             util.TestTimerFromUnix(5000),
+			repo:              &testAuthRepo{},
+			userId:            96,
+			expectedExpiresAt: 5000 + refreshTokenLifetime,
+		},
+		"valid, remember me": {
+			timer:             util.TestTimerFromUnix(5000),
+			repo:              &testAuthRepo{},
+			userId:            96,
+			rememberMe:        true,
+			expectedExpiresAt: 5000 + rememberMeLifetime,
 		},
 		"error from database": {
 			timer: util.TestTimerFromUnix(0),
@@ -61,11 +204,10 @@ func Test_GenerateRefreshToken(t *testing.T) {
 	for tname, tcase := range tests {
 		t.Run(tname, func(t *testing.T) {
 			jwt.TimeFunc = tcase.timer.Now
-			util.SigningKey = func() []byte { return []byte("test") }
 			cfg := cfg.TestConfig()
 			cfg.SetTimer(tcase.timer)
 			svc := NewAuthService(cfg, tcase.repo)
-			tokenStr, err := svc.GenerateRefreshToken(tcase.userId)
+			tokenStr, err := svc.GenerateRefreshToken(tcase.userId, "wordbubble:read", tcase.rememberMe, &model.Device{UserAgent: "curl/7.85.0", IPAddress: "203.0.113.7"})
 			if tcase.wantsErr {
 				assert.Error(t, err)
 				assert.Equal(t, "", tokenStr)
@@ -73,13 +215,35 @@ func Test_GenerateRefreshToken(t *testing.T) {
 				assert.NoError(t, err)
 				parts := strings.Split(tokenStr, ".")
 				assert.Equal(t, 3, len(parts))
-				refreshToken, _ := RefreshTokenFromTokenString(tokenStr)
+				refreshToken, _ := RefreshTokenFromTokenString(cfg.Keys(), tokenStr)
 				assert.Equal(t, tcase.userId, refreshToken.UserId())
+				assert.Equal(t, "wordbubble:read", refreshToken.Scope())
+				assert.Equal(t, tcase.expectedExpiresAt, refreshToken.expiresAt)
+				assert.Equal(t, tcase.expectedExpiresAt, tcase.repo.stored.expiresAt)
+				assert.Equal(t, tcase.rememberMe, tcase.repo.stored.rememberMe)
+				assert.Equal(t, tcase.timer.Now().Unix(), tcase.repo.stored.createdAt)
+				assert.Equal(t, "curl/7.85.0", tcase.repo.stored.device.UserAgent)
+				assert.Equal(t, "203.0.113.7", tcase.repo.stored.device.IPAddress)
 			}
 		})
 	}
 }
 
+func Test_GenerateClientRefreshToken(t *testing.T) {
+	timer := util.TestTimerFromUnix(0)
+	jwt.TimeFunc = timer.Now
+	cfg := cfg.TestConfig()
+	cfg.SetTimer(timer)
+	repo := &testAuthRepo{}
+	tokenStr, err := NewAuthService(cfg, repo).GenerateClientRefreshToken(96, "desktop", "wordbubble:read", &model.Device{UserAgent: "curl/7.85.0"})
+	assert.NoError(t, err)
+	refreshToken, err := RefreshTokenFromTokenString(cfg.Keys(), tokenStr)
+	assert.NoError(t, err)
+	assert.Equal(t, "desktop", refreshToken.ClientId())
+	assert.Equal(t, "desktop", repo.stored.clientId)
+	assert.False(t, repo.stored.rememberMe, "clients are never remembered")
+}
+
 func Test_ValidateRefreshToken(t *testing.T) {
 	tests := map[string]struct {
 		timer        util.Timer
@@ -92,7 +256,9 @@ func Test_ValidateRefreshToken(t *testing.T) {
 			timer: util.TestTimerFromUnix(0),
 			repo:  &testAuthRepo{},
 			refreshToken: &RefreshToken{
-				issuedAt: 2,
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
 			},
 		},
 		"error from database": {
@@ -101,48 +267,103 @@ func Test_ValidateRefreshToken(t *testing.T) {
 				err: resp.ErrCouldNotValidateRefreshToken,
 			},
 			refreshToken: &RefreshToken{
-				issuedAt: 2,
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
+			},
+			expectedErr: resp.ErrCouldNotValidateRefreshToken,
+		},
+		"error token was issued before rotation": {
+			timer: util.TestTimerFromUnix(0),
+			repo:  &testAuthRepo{},
+			refreshToken: &RefreshToken{
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
 			},
 			expectedErr: resp.ErrCouldNotValidateRefreshToken,
 		},
+		"error token was already revoked": {
+			timer: util.TestTimerFromUnix(0),
+			repo: &testAuthRepo{
+				revoked: true,
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
+			},
+			expectedErr: resp.ErrRefreshTokenReused,
+		},
 		"error expired": {
-			timer: util.TestTimerFromUnix(refreshTokenTimeLimit + 30),
+			timer: util.TestTimerFromUnix(refreshTokenLifetime + 30),
 			repo:  &testAuthRepo{},
 			refreshToken: &RefreshToken{
-				issuedAt: 30,
+				id:        "a",
+				issuedAt:  30,
+				expiresAt: refreshTokenLifetime + 30,
 			},
 			expectedErr: resp.ErrTokenIsExpired,
 			expectedEOL: true,
 		},
 		"no error but close to EOL": {
-			timer: util.TestTimerFromUnix(refreshTokenTimeLimit + 30),
+			timer: util.TestTimerFromUnix(refreshTokenLifetime + 30),
 			repo:  &testAuthRepo{},
 			refreshToken: &RefreshToken{
-				issuedAt: refreshTokenTimeLimit*.1 + 30,
+				id:        "a",
+				issuedAt:  renewalWindow/2 + 30,
+				expiresAt: refreshTokenLifetime + renewalWindow/2 + 30,
 			},
 			expectedEOL: true,
 		},
 		"valid almost but not at EOL": {
-			timer: util.TestTimerFromUnix(refreshTokenTimeLimit + 30),
+			timer: util.TestTimerFromUnix(refreshTokenLifetime + 30),
 			repo:  &testAuthRepo{},
 			refreshToken: &RefreshToken{
-				issuedAt: refreshTokenTimeLimit*.2 + 30,
+				id:        "a",
+				issuedAt:  renewalWindow + 30,
+				expiresAt: refreshTokenLifetime + renewalWindow + 30,
 			},
 			expectedEOL: false,
 		},
 		"valid almost expired": {
-			timer: util.TestTimerFromUnix(refreshTokenTimeLimit + 30),
+			timer: util.TestTimerFromUnix(refreshTokenLifetime + 30),
 			repo:  &testAuthRepo{},
 			refreshToken: &RefreshToken{
-				issuedAt: 31,
+				id:        "a",
+				issuedAt:  31,
+				expiresAt: refreshTokenLifetime + 31,
 			},
 			expectedEOL: true,
 		},
+		"valid, the renewal window is the same for a remembered session": {
+			timer: util.TestTimerFromUnix(rememberMeLifetime),
+			repo: &testAuthRepo{
+				createdAt:  1,
+				rememberMe: true,
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  1,
+				expiresAt: rememberMeLifetime + renewalWindow + 1,
+			},
+			expectedEOL: false,
+		},
+		"error session reached its max lifetime": {
+			timer: util.TestTimerFromUnix(maxSessionLifetime + 1),
+			repo: &testAuthRepo{
+				createdAt: 1,
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  maxSessionLifetime,
+				expiresAt: maxSessionLifetime + refreshTokenLifetime,
+			},
+			expectedErr: resp.ErrSessionIsExpired,
+		},
 	}
 	for tname, tcase := range tests {
 		t.Run(tname, func(t *testing.T) {
 			jwt.TimeFunc = tcase.timer.Now
-			util.SigningKey = func() []byte { return []byte("test") }
 			cfg := cfg.TestConfig()
 			cfg.SetTimer(tcase.timer)
 			svc := NewAuthService(cfg, tcase.repo)
@@ -162,25 +383,419 @@ func Test_ValidateRefreshToken(t *testing.T) {
 	}
 }
 
+func Test_RotateRefreshToken(t *testing.T) {
+	tests := map[string]struct {
+		timer             util.Timer
+		repo              *testAuthRepo
+		refreshToken      *RefreshToken
+		expectedErr       error
+		expectedFamily    string
+		expectedExpiresAt int64
+	}{
+		"valid": {
+			timer: util.TestTimerFromUnix(0),
+			repo: &testAuthRepo{
+				familyId:  "fam",
+				createdAt: 1,
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
+				scope:     "wordbubble:read",
+			},
+			expectedExpiresAt: refreshTokenLifetime,
+		},
+		"valid, session started before sessions were tracked": {
+			timer: util.TestTimerFromUnix(0),
+			repo: &testAuthRepo{
+				familyId: "fam",
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
+			},
+			expectedExpiresAt: refreshTokenLifetime,
+		},
+		"valid, token issued to an oauth client is still issued to it": {
+			timer: util.TestTimerFromUnix(0),
+			repo: &testAuthRepo{
+				familyId:  "fam",
+				createdAt: 1,
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
+				clientId:  "desktop",
+			},
+			expectedExpiresAt: refreshTokenLifetime,
+		},
+		"valid, remembered session is still remembered": {
+			timer: util.TestTimerFromUnix(0),
+			repo: &testAuthRepo{
+				familyId:   "fam",
+				createdAt:  1,
+				rememberMe: true,
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + rememberMeLifetime,
+			},
+			expectedExpiresAt: rememberMeLifetime,
+		},
+		"valid, replacement expires when the session reaches its max lifetime": {
+			timer: util.TestTimerFromUnix(maxSessionLifetime - 100),
+			repo: &testAuthRepo{
+				familyId:  "fam",
+				createdAt: 1,
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  maxSessionLifetime - 200,
+				expiresAt: maxSessionLifetime - 200 + refreshTokenLifetime,
+			},
+			expectedExpiresAt: maxSessionLifetime + 1,
+		},
+		"error from database": {
+			timer: util.TestTimerFromUnix(0),
+			repo: &testAuthRepo{
+				err: resp.ErrCouldNotValidateRefreshToken,
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
+			},
+			expectedErr: resp.ErrCouldNotValidateRefreshToken,
+		},
+		"error token was already rotated": {
+			timer: util.TestTimerFromUnix(0),
+			repo: &testAuthRepo{
+				familyId: "fam",
+				revoked:  true,
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
+			},
+			expectedErr:    resp.ErrRefreshTokenReused,
+			expectedFamily: "fam",
+		},
+		"error token was rotated at the same time": {
+			timer: util.TestTimerFromUnix(0),
+			repo: &testAuthRepo{
+				familyId:  "fam",
+				errRevoke: resp.ErrRefreshTokenReused,
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
+			},
+			expectedErr:    resp.ErrRefreshTokenReused,
+			expectedFamily: "fam",
+		},
+		"error revoking token": {
+			timer: util.TestTimerFromUnix(0),
+			repo: &testAuthRepo{
+				errRevoke: resp.ErrCouldNotRevokeRefreshToken,
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
+			},
+			expectedErr: resp.ErrCouldNotRevokeRefreshToken,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			jwt.TimeFunc = tcase.timer.Now
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(tcase.timer)
+			svc := NewAuthService(cfg, tcase.repo)
+			tokenStr, err := svc.RotateRefreshToken(tcase.refreshToken, &model.Device{UserAgent: "curl/7.85.0", IPAddress: "203.0.113.7"})
+			if tcase.expectedErr != nil {
+				assert.NotNil(t, err)
+				assert.Equal(t, tcase.expectedErr.Error(), err.Error())
+				assert.Equal(t, "", tokenStr)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, tcase.repo.stored)
+				assert.Equal(t, tokenStr, tcase.repo.stored.string)
+				assert.Equal(t, "fam", tcase.repo.stored.familyId)
+				assert.Equal(t, "a", tcase.repo.stored.parentId)
+				assert.NotEqual(t, "a", tcase.repo.stored.id)
+				if tcase.repo.createdAt != 0 {
+					assert.Equal(t, tcase.repo.createdAt, tcase.repo.stored.createdAt)
+				} else {
+					assert.Equal(t, tcase.timer.Now().Unix(), tcase.repo.stored.createdAt)
+				}
+				assert.Equal(t, "curl/7.85.0", tcase.repo.stored.device.UserAgent)
+				// the replacement keeps the scope and lifetime of the token it replaced
+				assert.Equal(t, tcase.refreshToken.scope, tcase.repo.stored.scope)
+				assert.Equal(t, tcase.refreshToken.clientId, tcase.repo.stored.clientId)
+				assert.Equal(t, tcase.repo.rememberMe, tcase.repo.stored.rememberMe)
+				assert.Equal(t, tcase.expectedExpiresAt, tcase.repo.stored.expiresAt)
+			}
+			assert.Equal(t, tcase.expectedFamily, tcase.repo.revokedFamily)
+		})
+	}
+}
+
+func Test_RevokeRefreshToken(t *testing.T) {
+	tests := map[string]struct {
+		timer          util.Timer
+		repo           *testAuthRepo
+		refreshToken   *RefreshToken
+		expectedErr    error
+		expectedFamily string
+	}{
+		"valid": {
+			timer: util.TestTimerFromUnix(0),
+			repo: &testAuthRepo{
+				familyId: "fam",
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
+			},
+			expectedFamily: "fam",
+		},
+		"error from database": {
+			timer: util.TestTimerFromUnix(0),
+			repo: &testAuthRepo{
+				err: resp.ErrCouldNotValidateRefreshToken,
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
+			},
+			expectedErr: resp.ErrCouldNotValidateRefreshToken,
+		},
+		"error expired": {
+			timer: util.TestTimerFromUnix(refreshTokenLifetime + 30),
+			repo:  &testAuthRepo{},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  30,
+				expiresAt: refreshTokenLifetime + 30,
+			},
+			expectedErr: resp.ErrTokenIsExpired,
+		},
+		"error revoking family": {
+			timer: util.TestTimerFromUnix(0),
+			repo: &testAuthRepo{
+				familyId:        "fam",
+				errRevokeFamily: resp.ErrCouldNotRevokeRefreshToken,
+			},
+			refreshToken: &RefreshToken{
+				id:        "a",
+				issuedAt:  2,
+				expiresAt: 2 + refreshTokenLifetime,
+			},
+			expectedErr:    resp.ErrCouldNotRevokeRefreshToken,
+			expectedFamily: "fam",
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			jwt.TimeFunc = tcase.timer.Now
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(tcase.timer)
+			svc := NewAuthService(cfg, tcase.repo)
+			err := svc.RevokeRefreshToken(tcase.refreshToken)
+			if tcase.expectedErr != nil {
+				assert.NotNil(t, err)
+				assert.Equal(t, tcase.expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tcase.expectedFamily, tcase.repo.revokedFamily)
+		})
+	}
+}
+
+func Test_RevokeAllRefreshTokens(t *testing.T) {
+	tests := map[string]struct {
+		repo        *testAuthRepo
+		userId      int64
+		expectedErr error
+	}{
+		"valid": {
+			repo:   &testAuthRepo{},
+			userId: 24,
+		},
+		"error from database": {
+			repo: &testAuthRepo{
+				errRevokeAll: resp.ErrCouldNotRevokeRefreshToken,
+			},
+			userId:      24,
+			expectedErr: resp.ErrCouldNotRevokeRefreshToken,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			svc := NewAuthService(cfg.TestConfig(), tcase.repo)
+			err := svc.RevokeAllRefreshTokens(tcase.userId)
+			if tcase.expectedErr != nil {
+				assert.NotNil(t, err)
+				assert.Equal(t, tcase.expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tcase.userId, tcase.repo.revokedUserId)
+		})
+	}
+}
+
+func Test_ListSessions(t *testing.T) {
+	tests := map[string]struct {
+		repo             *testAuthRepo
+		expectedSessions []resp.Session
+		expectedErr      error
+	}{
+		"valid": {
+			repo: &testAuthRepo{
+				sessions: []resp.Session{{Id: "fam", UserAgent: "curl/7.85.0", CreatedAt: 10, LastUsedAt: 50000}},
+			},
+			expectedSessions: []resp.Session{{Id: "fam", UserAgent: "curl/7.85.0", CreatedAt: 10, LastUsedAt: 50000}},
+		},
+		"valid, no sessions": {
+			repo: &testAuthRepo{
+				sessions: []resp.Session{},
+			},
+			expectedSessions: []resp.Session{},
+		},
+		"error from database": {
+			repo: &testAuthRepo{
+				errSessions: resp.ErrCouldNotListSessions,
+			},
+			expectedErr: resp.ErrCouldNotListSessions,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(100000))
+			svc := NewAuthService(cfg, tcase.repo)
+			sessions, err := svc.ListSessions(4)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedSessions, sessions)
+			// sessions whose refresh token has expired by now aren't listed
+			assert.Equal(t, int64(100000), tcase.repo.now)
+		})
+	}
+}
+
+func Test_RevokeSession(t *testing.T) {
+	tests := map[string]struct {
+		repo        *testAuthRepo
+		expectedErr error
+	}{
+		"valid": {
+			repo: &testAuthRepo{},
+		},
+		"error session not found": {
+			repo: &testAuthRepo{
+				errSession: resp.ErrUnknownSession,
+			},
+			expectedErr: resp.ErrUnknownSession,
+		},
+		"error from database": {
+			repo: &testAuthRepo{
+				errSession: resp.ErrCouldNotRevokeRefreshToken,
+			},
+			expectedErr: resp.ErrCouldNotRevokeRefreshToken,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			svc := NewAuthService(cfg.TestConfig(), tcase.repo)
+			err := svc.RevokeSession(4, "fam")
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, "fam", tcase.repo.revokedSession)
+		})
+	}
+}
+
 func Test_TokenFuncs(t *testing.T) {
-	refreshToken, err := RefreshTokenFromTokenString("try parsing this")
+	refreshToken, err := RefreshTokenFromTokenString(util.TestKeyProvider(), "try parsing this")
 	assert.Nil(t, refreshToken)
 	assert.NotNil(t, err)
 }
 
 type testAuthRepo struct {
-	err          error
-	refreshToken *RefreshToken
+	err             error
+	errRevoke       error
+	errRevokeFamily error
+	errRevokeAll    error
+	familyId        string
+	revoked         bool
+	createdAt       int64
+	rememberMe      bool
+	stored          *RefreshToken
+	revokedFamily   string
+	revokedUserId   int64
+	errDeny         error
+	denied          bool
+	errDenied       error
+	deniedId        string
+	sessions        []resp.Session
+	errSessions     error
+	now             int64
+	errSession      error
+	revokedSession  string
 }
 
 func (trepo *testAuthRepo) storeRefreshToken(token *RefreshToken) error {
+	trepo.stored = token
 	return trepo.err
 }
 
 func (trepo *testAuthRepo) validateRefreshToken(token *RefreshToken) error {
+	token.familyId = trepo.familyId
+	token.revoked = trepo.revoked
+	token.createdAt = trepo.createdAt
+	token.rememberMe = trepo.rememberMe
 	return trepo.err
 }
 
-func (trepo *testAuthRepo) getLatestRefreshToken(userId int64) *RefreshToken {
-	return trepo.refreshToken
+func (trepo *testAuthRepo) revokeRefreshToken(token *RefreshToken) error {
+	return trepo.errRevoke
+}
+
+func (trepo *testAuthRepo) revokeRefreshTokenFamily(familyId string) error {
+	trepo.revokedFamily = familyId
+	return trepo.errRevokeFamily
+}
+
+func (trepo *testAuthRepo) revokeAllRefreshTokens(userId int64) error {
+	trepo.revokedUserId = userId
+	return trepo.errRevokeAll
+}
+
+func (trepo *testAuthRepo) listSessions(userId, now int64) ([]resp.Session, error) {
+	trepo.now = now
+	return trepo.sessions, trepo.errSessions
+}
+
+func (trepo *testAuthRepo) revokeSession(userId int64, sessionId string) error {
+	trepo.revokedSession = sessionId
+	return trepo.errSession
+}
+
+func (trepo *testAuthRepo) denyAccessToken(tokenId string, expiresAt int64) error {
+	trepo.deniedId = tokenId
+	return trepo.errDeny
+}
+
+func (trepo *testAuthRepo) isAccessTokenDenied(tokenId string, now int64) (bool, int64, error) {
+	return trepo.denied, now + 30, trepo.errDenied
 }
@@ -0,0 +1,20 @@
+package lockout
+
+import (
+	"context"
+
+	"github.com/bchadwic/wordbubble/internal/job"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// NewLoginAttemptCleanupJob creates a job that removes failed logins that are no longer counted.
+// Lockout events are kept
+func NewLoginAttemptCleanupJob(timer util.Timer, cleaner LockoutCleaner) job.Job {
+	return job.Job{
+		Name:     "login_attempt_cleanup",
+		Interval: LoginAttemptCleanerRate,
+		Run: func(ctx context.Context) error {
+			return cleaner.CleanupStaleLoginAttempts(timer.Now().Unix())
+		},
+	}
+}
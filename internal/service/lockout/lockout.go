@@ -0,0 +1,71 @@
+package lockout
+
+import "time"
+
+const (
+	// accountFreeAttempts is how many logins can fail for an account before each following attempt is delayed
+	accountFreeAttempts = 3
+	// ipFreeAttempts is how many logins can fail from a client before each following attempt is delayed,
+	// it's higher than an account's since many users can share an address
+	ipFreeAttempts = 10
+	// accountLockoutThreshold is how many logins can fail for an account before it's locked
+	accountLockoutThreshold = 10
+	// lockoutDuration is how long an account is locked for, in seconds
+	lockoutDuration = 15 * 60
+	// maxBackoff is the longest an attempt is delayed without locking, in seconds
+	maxBackoff = 5 * 60
+	// failureWindow is how long failures are counted for after the last one, in seconds
+	failureWindow           = 60 * 60
+	LoginAttemptCleanerRate = 10 * time.Minute
+
+	GetLoginAttempts     = `SELECT failures, last_failure_at, blocked_until, locked FROM login_attempts WHERE attempt_key = $1`
+	StoreLoginAttempts   = `INSERT INTO login_attempts (attempt_key, failures, last_failure_at, blocked_until, locked) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (attempt_key) DO UPDATE SET failures = excluded.failures, last_failure_at = excluded.last_failure_at, blocked_until = excluded.blocked_until, locked = excluded.locked`
+	ResetLoginAttempts   = `DELETE FROM login_attempts WHERE attempt_key = $1`
+	StoreLockoutEvent    = `INSERT INTO lockout_events (attempt_key, ip_address, locked_at, locked_until) VALUES ($1, $2, $3, $4)`
+	CleanupLoginAttempts = `DELETE FROM login_attempts WHERE last_failure_at < $1`
+)
+
+// LockoutService is the interface that the application
+// uses to slow down and lock out repeated failed logins
+type LockoutService interface {
+	// CheckLogin checks whether a login for a user from a client ip can be attempted right now.
+	// userId is zero when no user has the login (username or email) sent, which is then checked on its own.
+	// error could be (423) resp.ErrAccountLocked, (429) resp.ErrTooManyLoginAttempts,
+	// (500) resp.ErrCouldNotCheckLoginAttempts or nil.
+	CheckLogin(userId int64, login, ip string) error
+	// LoginFailed counts a failed login for a user and a client ip, delaying the next attempt of each
+	// once too many have failed. An account is locked, and the lockout is recorded, after even more have failed.
+	// Failures are counted per user, so logging in with the username and the email share a counter,
+	// userId is zero when no user has the login sent, which is then counted on its own.
+	// error could be (500) resp.ErrCouldNotCheckLoginAttempts, (500) resp.ErrCouldNotRecordLoginAttempt or nil.
+	LoginFailed(userId int64, login, ip string) error
+	// LoginSucceeded resets the failed logins counted for a user and a client ip
+	// error could be (500) resp.ErrCouldNotRecordLoginAttempt or nil.
+	LoginSucceeded(userId int64, ip string) error
+}
+
+// LockoutRepo is the interface that the service layer
+// uses to interact with failed login attempts in the database
+type LockoutRepo interface {
+	// getLoginAttempts finds the failed logins counted for a key.
+	// *loginAttempts are the failures counted, no failures when the key has none, can be nil.
+	// error can be (500) resp.ErrCouldNotCheckLoginAttempts or nil.
+	getLoginAttempts(key string) (*loginAttempts, error)
+	// storeLoginAttempts replaces the failed logins counted for a key.
+	// error can be (500) resp.ErrCouldNotRecordLoginAttempt or nil.
+	storeLoginAttempts(key string, attempts *loginAttempts) error
+	// resetLoginAttempts removes the failed logins counted for a key.
+	// error can be (500) resp.ErrCouldNotRecordLoginAttempt or nil.
+	resetLoginAttempts(key string) error
+	// storeLockoutEvent records that a key was locked from a client ip, until the time passed.
+	// error can be (500) resp.ErrCouldNotRecordLoginAttempt or nil.
+	storeLockoutEvent(key, ip string, lockedAt, lockedUntil int64) error
+}
+
+// LockoutCleaner is the interface that the application
+// uses to clean up failed logins that are no longer counted
+type LockoutCleaner interface {
+	// CleanupStaleLoginAttempts remove any failed logins from the database that are no longer counted by the time passed.
+	// error can be (500) resp.ErrCouldNotCleanupLoginAttempts or nil
+	CleanupStaleLoginAttempts(now int64) error
+}
@@ -0,0 +1,69 @@
+package lockout
+
+import (
+	"database/sql"
+	"errors"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type lockoutRepo struct {
+	db  *sql.DB
+	log util.Logger
+}
+
+func NewLockoutRepo(config cfg.Config) *lockoutRepo {
+	return &lockoutRepo{
+		log: config.NewLogger("lockout_repo"),
+		db:  config.DB(),
+	}
+}
+
+func (repo *lockoutRepo) getLoginAttempts(key string) (*loginAttempts, error) {
+	row := repo.db.QueryRow(GetLoginAttempts, key)
+	var found loginAttempts
+	if err := row.Scan(&found.failures, &found.lastFailureAt, &found.blockedUntil, &found.locked); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &loginAttempts{}, nil
+		}
+		repo.log.Error("could not find login attempts for: %s, error: %s", key, err)
+		return nil, resp.ErrCouldNotCheckLoginAttempts
+	}
+	return &found, nil
+}
+
+func (repo *lockoutRepo) storeLoginAttempts(key string, attempts *loginAttempts) error {
+	if _, err := repo.db.Exec(StoreLoginAttempts, key, attempts.failures, attempts.lastFailureAt, attempts.blockedUntil, attempts.locked); err != nil {
+		repo.log.Error("could not store login attempts for: %s, error: %s", key, err)
+		return resp.ErrCouldNotRecordLoginAttempt
+	}
+	return nil
+}
+
+func (repo *lockoutRepo) resetLoginAttempts(key string) error {
+	if _, err := repo.db.Exec(ResetLoginAttempts, key); err != nil {
+		repo.log.Error("could not reset login attempts for: %s, error: %s", key, err)
+		return resp.ErrCouldNotRecordLoginAttempt
+	}
+	return nil
+}
+
+func (repo *lockoutRepo) storeLockoutEvent(key, ip string, lockedAt, lockedUntil int64) error {
+	if _, err := repo.db.Exec(StoreLockoutEvent, key, ip, lockedAt, lockedUntil); err != nil {
+		repo.log.Error("could not store lockout event for: %s, error: %s", key, err)
+		return resp.ErrCouldNotRecordLoginAttempt
+	}
+	return nil
+}
+
+func (repo *lockoutRepo) CleanupStaleLoginAttempts(now int64) error {
+	rs, err := repo.db.Exec(CleanupLoginAttempts, now-failureWindow)
+	if err != nil {
+		return resp.ErrCouldNotCleanupLoginAttempts
+	}
+	amt, _ := rs.RowsAffected()
+	repo.log.Info("login attempt cleaner deleted: %d counters", amt)
+	return nil
+}
@@ -0,0 +1,70 @@
+package lockout
+
+import (
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HappyPath(t *testing.T) {
+	repo := NewLockoutRepo(cfg.TestConfig())
+	key := "user:2"
+
+	// Nothing has failed yet
+	found, err := repo.getLoginAttempts(key)
+	assert.NoError(t, err)
+	assert.Equal(t, &loginAttempts{}, found)
+
+	// A login fails, then fails again
+	assert.NoError(t, repo.storeLoginAttempts(key, &loginAttempts{failures: 1, lastFailureAt: 100}))
+	assert.NoError(t, repo.storeLoginAttempts(key, &loginAttempts{failures: 2, lastFailureAt: 200, blockedUntil: 300, locked: true}))
+	found, err = repo.getLoginAttempts(key)
+	assert.NoError(t, err)
+	assert.Equal(t, &loginAttempts{failures: 2, lastFailureAt: 200, blockedUntil: 300, locked: true}, found)
+	assert.NoError(t, repo.storeLockoutEvent(key, "127.0.0.1", 200, 300))
+
+	// A login succeeds
+	assert.NoError(t, repo.resetLoginAttempts(key))
+	found, err = repo.getLoginAttempts(key)
+	assert.NoError(t, err)
+	assert.Equal(t, &loginAttempts{}, found)
+
+	// Failures that are no longer counted are cleaned up
+	assert.NoError(t, repo.storeLoginAttempts("ip:stale", &loginAttempts{failures: 1, lastFailureAt: 100}))
+	assert.NoError(t, repo.storeLoginAttempts("ip:recent", &loginAttempts{failures: 1, lastFailureAt: 300}))
+	assert.NoError(t, repo.CleanupStaleLoginAttempts(200+failureWindow))
+	found, err = repo.getLoginAttempts("ip:stale")
+	assert.NoError(t, err)
+	assert.Equal(t, &loginAttempts{}, found)
+	found, err = repo.getLoginAttempts("ip:recent")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, found.failures)
+}
+
+func Test_NotSoHappyPath(t *testing.T) {
+	repo := NewLockoutRepo(cfg.TestConfig())
+
+	// db closed
+	repo.db.Close()
+	_, err := repo.getLoginAttempts("user:2")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCheckLoginAttempts.Error(), err.Error())
+
+	err = repo.storeLoginAttempts("user:2", &loginAttempts{failures: 1})
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotRecordLoginAttempt.Error(), err.Error())
+
+	err = repo.resetLoginAttempts("user:2")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotRecordLoginAttempt.Error(), err.Error())
+
+	err = repo.storeLockoutEvent("user:2", "127.0.0.1", 100, 200)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotRecordLoginAttempt.Error(), err.Error())
+
+	err = repo.CleanupStaleLoginAttempts(100)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCleanupLoginAttempts.Error(), err.Error())
+}
@@ -0,0 +1,132 @@
+package lockout
+
+import (
+	"strconv"
+	"strings"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type loginAttempts struct {
+	failures      int
+	lastFailureAt int64
+	blockedUntil  int64
+	locked        bool
+}
+
+type lockoutService struct {
+	log   util.Logger
+	timer util.Timer
+	repo  LockoutRepo
+}
+
+func NewLockoutService(cfg cfg.Config, repo LockoutRepo) *lockoutService {
+	return &lockoutService{
+		log:   cfg.NewLogger("lockout"),
+		timer: cfg.Timer(),
+		repo:  repo,
+	}
+}
+
+func (svc *lockoutService) CheckLogin(userId int64, login, ip string) error {
+	now := svc.timer.Now().Unix()
+	attempts, err := svc.repo.getLoginAttempts(accountKey(userId, login))
+	if err != nil {
+		return err
+	}
+	if attempts.blockedUntil > now {
+		if attempts.locked {
+			return resp.ErrAccountLocked
+		}
+		return resp.ErrTooManyLoginAttempts
+	}
+	if ip == "" {
+		return nil
+	}
+	if attempts, err = svc.repo.getLoginAttempts(ipKey(ip)); err != nil {
+		return err
+	}
+	if attempts.blockedUntil > now {
+		return resp.ErrTooManyLoginAttempts
+	}
+	return nil
+}
+
+func (svc *lockoutService) LoginFailed(userId int64, login, ip string) error {
+	now := svc.timer.Now().Unix()
+	key := accountKey(userId, login)
+	attempts, err := svc.fail(key, now, accountFreeAttempts)
+	if err != nil {
+		return err
+	}
+	if attempts.failures >= accountLockoutThreshold {
+		attempts.blockedUntil, attempts.locked = now+lockoutDuration, true
+		if err = svc.repo.storeLockoutEvent(key, ip, now, attempts.blockedUntil); err != nil {
+			return err
+		}
+		svc.log.Warn("locked %s after %d failed logins, last from: %s", key, attempts.failures, ip)
+	}
+	if err = svc.repo.storeLoginAttempts(key, attempts); err != nil {
+		return err
+	}
+	if ip == "" {
+		return nil
+	}
+	if attempts, err = svc.fail(ipKey(ip), now, ipFreeAttempts); err != nil {
+		return err
+	}
+	return svc.repo.storeLoginAttempts(ipKey(ip), attempts)
+}
+
+func (svc *lockoutService) LoginSucceeded(userId int64, ip string) error {
+	if err := svc.repo.resetLoginAttempts(accountKey(userId, "")); err != nil {
+		return err
+	}
+	if ip == "" {
+		return nil
+	}
+	return svc.repo.resetLoginAttempts(ipKey(ip))
+}
+
+// fail counts another failure for a key, failures older than the window are forgotten first
+func (svc *lockoutService) fail(key string, now int64, freeAttempts int) (*loginAttempts, error) {
+	attempts, err := svc.repo.getLoginAttempts(key)
+	if err != nil {
+		return nil, err
+	}
+	if now-attempts.lastFailureAt > failureWindow {
+		attempts = &loginAttempts{}
+	}
+	attempts.failures++
+	attempts.lastFailureAt = now
+	attempts.blockedUntil = now + backoff(attempts.failures, freeAttempts)
+	attempts.locked = false
+	return attempts, nil
+}
+
+// backoff is how long to wait after a failure before another attempt, in seconds.
+// It doubles with each failure past the free attempts, up to maxBackoff
+func backoff(failures, freeAttempts int) int64 {
+	if failures <= freeAttempts {
+		return 0
+	}
+	if exp := failures - freeAttempts; exp < 16 && int64(1)<<exp < maxBackoff {
+		return int64(1) << exp
+	}
+	return maxBackoff
+}
+
+// failures are counted per user, whether they logged in with their username or email.
+// Logins no user has are counted apart from users, by the login sent in any case
+func accountKey(userId int64, login string) string {
+	if userId != 0 {
+		return "user:" + strconv.FormatInt(userId, 10)
+	}
+	return "unknown:" + strings.ToLower(strings.TrimSpace(login))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,246 @@
+package lockout
+
+import (
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+	"github.com/stretchr/testify/assert"
+)
+
+const testNow = int64(10000)
+
+func testService(repo *testLockoutRepo) *lockoutService {
+	config := cfg.TestConfig()
+	config.SetTimer(util.TestTimerFromUnix(testNow))
+	return NewLockoutService(config, repo)
+}
+
+func Test_CheckLogin(t *testing.T) {
+	tests := map[string]struct {
+		ip          string
+		repo        *testLockoutRepo
+		expectedErr error
+	}{
+		"valid, nothing failed": {
+			ip:   "127.0.0.1",
+			repo: &testLockoutRepo{},
+		},
+		"valid, backoff has passed": {
+			ip: "127.0.0.1",
+			repo: &testLockoutRepo{attempts: map[string]*loginAttempts{
+				"user:2":       {failures: 5, lastFailureAt: testNow - 10, blockedUntil: testNow},
+				"ip:127.0.0.1": {failures: 20, lastFailureAt: testNow - 10, blockedUntil: testNow - 5},
+			}},
+		},
+		"valid, no ip": {
+			repo: &testLockoutRepo{attempts: map[string]*loginAttempts{
+				"ip:": {blockedUntil: testNow + 10},
+			}},
+		},
+		"invalid, account is backing off": {
+			ip: "127.0.0.1",
+			repo: &testLockoutRepo{attempts: map[string]*loginAttempts{
+				"user:2": {failures: 5, lastFailureAt: testNow, blockedUntil: testNow + 4},
+			}},
+			expectedErr: resp.ErrTooManyLoginAttempts,
+		},
+		"invalid, account is locked": {
+			ip: "127.0.0.1",
+			repo: &testLockoutRepo{attempts: map[string]*loginAttempts{
+				"user:2": {failures: 10, lastFailureAt: testNow, blockedUntil: testNow + lockoutDuration, locked: true},
+			}},
+			expectedErr: resp.ErrAccountLocked,
+		},
+		"invalid, ip is backing off": {
+			ip: "127.0.0.1",
+			repo: &testLockoutRepo{attempts: map[string]*loginAttempts{
+				"ip:127.0.0.1": {failures: 12, lastFailureAt: testNow, blockedUntil: testNow + 4},
+			}},
+			expectedErr: resp.ErrTooManyLoginAttempts,
+		},
+		"invalid, could not check": {
+			ip:          "127.0.0.1",
+			repo:        &testLockoutRepo{errGet: resp.ErrCouldNotCheckLoginAttempts},
+			expectedErr: resp.ErrCouldNotCheckLoginAttempts,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			err := testService(tcase.repo).CheckLogin(2, "ben", tcase.ip)
+			assert.Equal(t, tcase.expectedErr, err)
+		})
+	}
+}
+
+func Test_LoginFailed(t *testing.T) {
+	tests := map[string]struct {
+		ip               string
+		repo             *testLockoutRepo
+		expectedAccount  *loginAttempts
+		expectedIp       *loginAttempts
+		expectedLockouts int
+		expectedErr      error
+	}{
+		"first failure": {
+			ip:              "127.0.0.1",
+			repo:            &testLockoutRepo{},
+			expectedAccount: &loginAttempts{failures: 1, lastFailureAt: testNow, blockedUntil: testNow},
+			expectedIp:      &loginAttempts{failures: 1, lastFailureAt: testNow, blockedUntil: testNow},
+		},
+		"failure past the free attempts backs off": {
+			ip: "127.0.0.1",
+			repo: &testLockoutRepo{attempts: map[string]*loginAttempts{
+				"user:2": {failures: 5, lastFailureAt: testNow - 10},
+			}},
+			expectedAccount: &loginAttempts{failures: 6, lastFailureAt: testNow, blockedUntil: testNow + 8},
+			expectedIp:      &loginAttempts{failures: 1, lastFailureAt: testNow, blockedUntil: testNow},
+		},
+		"failures outside the window are forgotten": {
+			ip: "127.0.0.1",
+			repo: &testLockoutRepo{attempts: map[string]*loginAttempts{
+				"user:2":       {failures: 9, lastFailureAt: testNow - failureWindow - 1},
+				"ip:127.0.0.1": {failures: 30, lastFailureAt: testNow - failureWindow - 1},
+			}},
+			expectedAccount: &loginAttempts{failures: 1, lastFailureAt: testNow, blockedUntil: testNow},
+			expectedIp:      &loginAttempts{failures: 1, lastFailureAt: testNow, blockedUntil: testNow},
+		},
+		"ip backs off no longer than the max": {
+			ip: "127.0.0.1",
+			repo: &testLockoutRepo{attempts: map[string]*loginAttempts{
+				"ip:127.0.0.1": {failures: 40, lastFailureAt: testNow - 10},
+			}},
+			expectedAccount: &loginAttempts{failures: 1, lastFailureAt: testNow, blockedUntil: testNow},
+			expectedIp:      &loginAttempts{failures: 41, lastFailureAt: testNow, blockedUntil: testNow + maxBackoff},
+		},
+		"account is locked at the threshold": {
+			ip: "127.0.0.1",
+			repo: &testLockoutRepo{attempts: map[string]*loginAttempts{
+				"user:2": {failures: 9, lastFailureAt: testNow - 10},
+			}},
+			expectedAccount:  &loginAttempts{failures: 10, lastFailureAt: testNow, blockedUntil: testNow + lockoutDuration, locked: true},
+			expectedIp:       &loginAttempts{failures: 1, lastFailureAt: testNow, blockedUntil: testNow},
+			expectedLockouts: 1,
+		},
+		"no ip": {
+			repo:            &testLockoutRepo{},
+			expectedAccount: &loginAttempts{failures: 1, lastFailureAt: testNow, blockedUntil: testNow},
+		},
+		"could not check": {
+			ip:          "127.0.0.1",
+			repo:        &testLockoutRepo{errGet: resp.ErrCouldNotCheckLoginAttempts},
+			expectedErr: resp.ErrCouldNotCheckLoginAttempts,
+		},
+		"could not record": {
+			ip:          "127.0.0.1",
+			repo:        &testLockoutRepo{errStore: resp.ErrCouldNotRecordLoginAttempt},
+			expectedErr: resp.ErrCouldNotRecordLoginAttempt,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			err := testService(tcase.repo).LoginFailed(2, "ben", tcase.ip)
+			assert.Equal(t, tcase.expectedErr, err)
+			if tcase.expectedErr != nil {
+				return
+			}
+			assert.Equal(t, tcase.expectedAccount, tcase.repo.attempts["user:2"])
+			assert.Equal(t, tcase.expectedIp, tcase.repo.attempts["ip:"+tcase.ip])
+			assert.Equal(t, tcase.expectedLockouts, tcase.repo.lockouts)
+		})
+	}
+}
+
+func Test_LoginSucceeded(t *testing.T) {
+	tests := map[string]struct {
+		ip          string
+		repo        *testLockoutRepo
+		expectedErr error
+	}{
+		"valid": {
+			ip: "127.0.0.1",
+			repo: &testLockoutRepo{attempts: map[string]*loginAttempts{
+				"user:2":       {failures: 2, lastFailureAt: testNow},
+				"ip:127.0.0.1": {failures: 2, lastFailureAt: testNow},
+			}},
+		},
+		"invalid, could not reset": {
+			ip:          "127.0.0.1",
+			repo:        &testLockoutRepo{errReset: resp.ErrCouldNotRecordLoginAttempt},
+			expectedErr: resp.ErrCouldNotRecordLoginAttempt,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			err := testService(tcase.repo).LoginSucceeded(2, tcase.ip)
+			assert.Equal(t, tcase.expectedErr, err)
+			if tcase.expectedErr == nil {
+				assert.Empty(t, tcase.repo.attempts)
+			}
+		})
+	}
+}
+
+func Test_AccountKey(t *testing.T) {
+	// a user's username and email share a counter, a login no user has is counted apart from users
+	assert.Equal(t, "user:2", accountKey(2, "ben"))
+	assert.Equal(t, "user:2", accountKey(2, "ben@wordbubble.com"))
+	assert.Equal(t, "unknown:ben", accountKey(0, " Ben "))
+	assert.NotEqual(t, accountKey(0, "2"), accountKey(2, ""))
+}
+
+func Test_Backoff(t *testing.T) {
+	assert.Equal(t, int64(0), backoff(3, 3))
+	assert.Equal(t, int64(2), backoff(4, 3))
+	assert.Equal(t, int64(4), backoff(5, 3))
+	assert.Equal(t, int64(256), backoff(11, 3))
+	assert.Equal(t, int64(maxBackoff), backoff(12, 3))
+	assert.Equal(t, int64(maxBackoff), backoff(100, 3))
+}
+
+type testLockoutRepo struct {
+	attempts map[string]*loginAttempts
+	lockouts int
+	errGet   error
+	errStore error
+	errReset error
+}
+
+func (repo *testLockoutRepo) getLoginAttempts(key string) (*loginAttempts, error) {
+	if repo.errGet != nil {
+		return nil, repo.errGet
+	}
+	if found, ok := repo.attempts[key]; ok {
+		copied := *found
+		return &copied, nil
+	}
+	return &loginAttempts{}, nil
+}
+
+func (repo *testLockoutRepo) storeLoginAttempts(key string, attempts *loginAttempts) error {
+	if repo.errStore != nil {
+		return repo.errStore
+	}
+	if repo.attempts == nil {
+		repo.attempts = make(map[string]*loginAttempts)
+	}
+	repo.attempts[key] = attempts
+	return nil
+}
+
+func (repo *testLockoutRepo) resetLoginAttempts(key string) error {
+	if repo.errReset != nil {
+		return repo.errReset
+	}
+	delete(repo.attempts, key)
+	return nil
+}
+
+func (repo *testLockoutRepo) storeLockoutEvent(key, ip string, lockedAt, lockedUntil int64) error {
+	if repo.errStore != nil {
+		return repo.errStore
+	}
+	repo.lockouts++
+	return nil
+}
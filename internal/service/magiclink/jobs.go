@@ -0,0 +1,19 @@
+package magiclink
+
+import (
+	"context"
+
+	"github.com/bchadwic/wordbubble/internal/job"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// NewLoginLinkCleanupJob creates a job that removes login links that expired and no longer count towards the rate limit
+func NewLoginLinkCleanupJob(timer util.Timer, cleaner MagicLinkCleaner) job.Job {
+	return job.Job{
+		Name:     "login_link_cleanup",
+		Interval: LoginLinkCleanerRate,
+		Run: func(ctx context.Context) error {
+			return cleaner.CleanupExpiredLoginLinks(timer.Now().Unix())
+		},
+	}
+}
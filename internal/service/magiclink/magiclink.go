@@ -0,0 +1,61 @@
+package magiclink
+
+import "time"
+
+const (
+	// maxLinksPerAddress is how many login links can be requested for an email address within linkRateWindow
+	maxLinksPerAddress = 3
+	// linkRateWindow is how long a requested login link counts towards the limit of its address, in seconds
+	linkRateWindow       = 15 * 60
+	LoginLinkCleanerRate = 10 * time.Minute
+
+	StoreLoginLink    = `INSERT INTO login_links (link_id, address, user_id, scope, remember_me, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	CountLoginLinks   = `SELECT COUNT(*) FROM login_links WHERE address = $1 AND created_at > $2`
+	GetLoginLink      = `SELECT user_id, scope, remember_me, expires_at, redeemed FROM login_links WHERE link_id = $1`
+	RedeemLoginLink   = `UPDATE login_links SET redeemed = TRUE WHERE link_id = $1 AND redeemed = FALSE`
+	CleanupLoginLinks = `DELETE FROM login_links WHERE expires_at < $1 AND created_at < $2`
+)
+
+// MagicLinkService is the interface that the application
+// uses to let users login with a link emailed to them, instead of a password
+type MagicLinkService interface {
+	// SendLoginLink emails a signed, single use login link to an address, holding the scope requested.
+	// When the address has no user, zero is passed and nothing is sent, but the request is still counted,
+	// so neither the response nor the rate limit can be used to find accounts
+	// error could be (429) resp.ErrTooManyLoginLinks, (500) resp.ErrCouldNotCheckLoginLink,
+	// (500) resp.ErrCouldNotStoreLoginLink, (500) resp.ErrCouldNotSendEmail or nil.
+	SendLoginLink(address string, userId int64, scope string, rememberMe bool) error
+	// RedeemLoginLink checks the signature and the expiry of a login link token, then redeems it so it can't be used again.
+	// int64 is the user id of the link, or zero.
+	// string is the scope requested with the link, or empty string.
+	// bool is true if the user asked to be remembered.
+	// error could be (401) resp.ErrInvalidLoginLink, (500) resp.ErrCouldNotCheckLoginLink or nil.
+	RedeemLoginLink(token string) (int64, string, bool, error)
+}
+
+// MagicLinkRepo is the interface that the service layer
+// uses to interact with login links in the database
+type MagicLinkRepo interface {
+	// storeLoginLink stores a login link requested for an address.
+	// error can be (500) resp.ErrCouldNotStoreLoginLink or nil.
+	storeLoginLink(link *loginLink) error
+	// countLoginLinks counts the login links requested for an address after the time passed.
+	// int is the number of links requested.
+	// error can be (500) resp.ErrCouldNotCheckLoginLink or nil.
+	countLoginLinks(address string, after int64) (int, error)
+	// getLoginLink finds the login link with the id passed.
+	// *loginLink is the link found, can be nil.
+	// error can be (401) resp.ErrInvalidLoginLink or nil.
+	getLoginLink(linkId string) (*loginLink, error)
+	// redeemLoginLink marks a login link as redeemed, so it can only be redeemed once.
+	// error can be (401) resp.ErrInvalidLoginLink, (500) resp.ErrCouldNotCheckLoginLink or nil.
+	redeemLoginLink(linkId string) error
+}
+
+// MagicLinkCleaner is the interface that the application
+// uses to clean up expired login links
+type MagicLinkCleaner interface {
+	// CleanupExpiredLoginLinks remove any login links from the database that are expired and no longer counted towards the rate limit.
+	// error can be (500) resp.ErrCouldNotCleanupLoginLinks or nil
+	CleanupExpiredLoginLinks(now int64) error
+}
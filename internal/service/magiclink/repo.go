@@ -0,0 +1,68 @@
+package magiclink
+
+import (
+	"database/sql"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type magicLinkRepo struct {
+	db  *sql.DB
+	log util.Logger
+}
+
+func NewMagicLinkRepo(config cfg.Config) *magicLinkRepo {
+	return &magicLinkRepo{
+		log: config.NewLogger("magiclink_repo"),
+		db:  config.DB(),
+	}
+}
+
+func (repo *magicLinkRepo) storeLoginLink(link *loginLink) error {
+	if _, err := repo.db.Exec(StoreLoginLink, link.linkId, link.address, link.userId, link.scope, link.rememberMe, link.createdAt, link.expiresAt); err != nil {
+		repo.log.Error("could not store login link for user: %d, error: %s", link.userId, err)
+		return resp.ErrCouldNotStoreLoginLink
+	}
+	return nil
+}
+
+func (repo *magicLinkRepo) countLoginLinks(address string, after int64) (int, error) {
+	var count int
+	if err := repo.db.QueryRow(CountLoginLinks, address, after).Scan(&count); err != nil {
+		repo.log.Error("could not count login links, error: %s", err)
+		return 0, resp.ErrCouldNotCheckLoginLink
+	}
+	return count, nil
+}
+
+func (repo *magicLinkRepo) getLoginLink(linkId string) (*loginLink, error) {
+	row := repo.db.QueryRow(GetLoginLink, linkId)
+	found := loginLink{linkId: linkId}
+	if err := row.Scan(&found.userId, &found.scope, &found.rememberMe, &found.expiresAt, &found.redeemed); err != nil {
+		return nil, resp.ErrInvalidLoginLink
+	}
+	return &found, nil
+}
+
+func (repo *magicLinkRepo) redeemLoginLink(linkId string) error {
+	rs, err := repo.db.Exec(RedeemLoginLink, linkId)
+	if err != nil {
+		return resp.ErrCouldNotCheckLoginLink
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 { // the link was redeemed by another request in the meantime
+		return resp.ErrInvalidLoginLink
+	}
+	return nil
+}
+
+func (repo *magicLinkRepo) CleanupExpiredLoginLinks(now int64) error {
+	rs, err := repo.db.Exec(CleanupLoginLinks, now, now-linkRateWindow)
+	if err != nil {
+		return resp.ErrCouldNotCleanupLoginLinks
+	}
+	amt, _ := rs.RowsAffected()
+	repo.log.Info("login link cleaner deleted: %d links", amt)
+	return nil
+}
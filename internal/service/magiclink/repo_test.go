@@ -0,0 +1,81 @@
+package magiclink
+
+import (
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HappyPath(t *testing.T) {
+	repo := NewMagicLinkRepo(cfg.TestConfig())
+	address := "ben@wordbubble.com"
+
+	// A user requests two login links, only the ones requested after the time passed are counted
+	assert.NoError(t, repo.storeLoginLink(&loginLink{linkId: "first", address: address, userId: 5, scope: "wordbubble:push", rememberMe: true, createdAt: 100, expiresAt: 1000}))
+	assert.NoError(t, repo.storeLoginLink(&loginLink{linkId: "second", address: address, userId: 5, createdAt: 200, expiresAt: 1100}))
+	count, err := repo.countLoginLinks(address, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = repo.countLoginLinks(address, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = repo.countLoginLinks("someone@wordbubble.com", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// The user opens the first link, it can only be redeemed once
+	found, err := repo.getLoginLink("first")
+	assert.NoError(t, err)
+	assert.Equal(t, &loginLink{linkId: "first", userId: 5, scope: "wordbubble:push", rememberMe: true, expiresAt: 1000}, found)
+	assert.NoError(t, repo.redeemLoginLink("first"))
+	assert.Equal(t, resp.ErrInvalidLoginLink, repo.redeemLoginLink("first"))
+	found, err = repo.getLoginLink("first")
+	assert.NoError(t, err)
+	assert.True(t, found.redeemed)
+
+	// A link that was never stored can't be found or redeemed
+	_, err = repo.getLoginLink("unknown")
+	assert.Equal(t, resp.ErrInvalidLoginLink, err)
+	assert.Equal(t, resp.ErrInvalidLoginLink, repo.redeemLoginLink("unknown"))
+
+	// Links are only cleaned up once they expire and no longer count towards the limit
+	assert.NoError(t, repo.CleanupExpiredLoginLinks(1050))
+	_, err = repo.getLoginLink("first")
+	assert.Equal(t, resp.ErrInvalidLoginLink, err)
+	_, err = repo.getLoginLink("second")
+	assert.NoError(t, err)
+	assert.NoError(t, repo.storeLoginLink(&loginLink{linkId: "recent", address: address, createdAt: 1000, expiresAt: 1100}))
+	assert.NoError(t, repo.CleanupExpiredLoginLinks(1200))
+	_, err = repo.getLoginLink("second")
+	assert.Equal(t, resp.ErrInvalidLoginLink, err)
+	_, err = repo.getLoginLink("recent")
+	assert.NoError(t, err)
+}
+
+func Test_NotSoHappyPath(t *testing.T) {
+	repo := NewMagicLinkRepo(cfg.TestConfig())
+
+	// db closed
+	repo.db.Close()
+	err := repo.storeLoginLink(&loginLink{linkId: "first", userId: 5})
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotStoreLoginLink.Error(), err.Error())
+
+	_, err = repo.countLoginLinks("ben@wordbubble.com", 0)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCheckLoginLink.Error(), err.Error())
+
+	_, err = repo.getLoginLink("first")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrInvalidLoginLink.Error(), err.Error())
+
+	err = repo.redeemLoginLink("first")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCheckLoginLink.Error(), err.Error())
+
+	err = repo.CleanupExpiredLoginLinks(300)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCleanupLoginLinks.Error(), err.Error())
+}
@@ -0,0 +1,144 @@
+package magiclink
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type loginLink struct {
+	linkId     string
+	address    string
+	userId     int64
+	scope      string
+	rememberMe bool
+	createdAt  int64
+	expiresAt  int64
+	redeemed   bool
+}
+
+type magicLinkService struct {
+	log      util.Logger
+	timer    util.Timer
+	mailer   util.Mailer
+	repo     MagicLinkRepo
+	key      []byte
+	lifetime int64
+}
+
+func NewMagicLinkService(cfg cfg.Config, repo MagicLinkRepo) *magicLinkService {
+	return &magicLinkService{
+		log:      cfg.NewLogger("magiclink"),
+		timer:    cfg.Timer(),
+		mailer:   cfg.Mailer(),
+		repo:     repo,
+		key:      cfg.TokenHashKey(),
+		lifetime: int64(cfg.TokenLifetimes().LoginLink.Seconds()),
+	}
+}
+
+func (svc *magicLinkService) SendLoginLink(address string, userId int64, scope string, rememberMe bool) error {
+	address = normalizeAddress(address)
+	now := svc.timer.Now().Unix()
+	count, err := svc.repo.countLoginLinks(address, now-linkRateWindow)
+	if err != nil {
+		return err
+	}
+	if count >= maxLinksPerAddress {
+		svc.log.Warn("too many login links requested for an address")
+		return resp.ErrTooManyLoginLinks
+	}
+	link := &loginLink{
+		linkId:     util.RandomString(32),
+		address:    address,
+		userId:     userId,
+		scope:      scope,
+		rememberMe: rememberMe,
+		createdAt:  now,
+		expiresAt:  now + svc.lifetime,
+	}
+	if err = svc.repo.storeLoginLink(link); err != nil {
+		return err
+	}
+	if userId == 0 { // stored only to be counted, it's never sent so it can't be redeemed
+		svc.log.Info("login link requested for an unknown address")
+		return nil
+	}
+	body := fmt.Sprintf("Open /v1/login/link/callback?token=%s within %d minutes to login to wordbubble, it can only be used once."+
+		"\n\nIf you didn't request a login link, you can ignore this email.",
+		svc.sign(link.linkId, link.expiresAt), svc.lifetime/60)
+	if err = svc.mailer.Send(address, "Your wordbubble login link", body); err != nil {
+		svc.log.Error("could not send login link email for user: %d, error: %s", userId, err)
+		return resp.ErrCouldNotSendEmail
+	}
+	svc.log.Info("login link sent for user: %d", userId)
+	return nil
+}
+
+func (svc *magicLinkService) RedeemLoginLink(token string) (int64, string, bool, error) {
+	linkId, expiresAt, ok := svc.verify(token)
+	if !ok {
+		return 0, "", false, resp.ErrInvalidLoginLink
+	}
+	now := svc.timer.Now().Unix()
+	if expiresAt <= now { // expired links are rejected before the database is read
+		return 0, "", false, resp.ErrInvalidLoginLink
+	}
+	found, err := svc.repo.getLoginLink(linkId)
+	if err != nil {
+		return 0, "", false, err
+	}
+	if found.redeemed || found.userId == 0 || found.expiresAt <= now {
+		return 0, "", false, resp.ErrInvalidLoginLink
+	}
+	if err = svc.repo.redeemLoginLink(linkId); err != nil {
+		return 0, "", false, err
+	}
+	svc.log.Info("login link redeemed for user: %d", found.userId)
+	return found.userId, found.scope, found.rememberMe, nil
+}
+
+// sign creates the token sent in a login link, the link id and its expiry followed by their signature,
+// so a link that was guessed or changed is rejected without reading the database
+func (svc *magicLinkService) sign(linkId string, expiresAt int64) string {
+	payload := linkId + "." + strconv.FormatInt(expiresAt, 10)
+	return payload + "." + svc.signature(payload)
+}
+
+// verify checks the signature of a login link token.
+// string is the link id, or empty string.
+// int64 is the unix time the link expires, or zero.
+// bool is true if the signature matches.
+func (svc *magicLinkService) verify(token string) (string, int64, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", 0, false
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(svc.signature(payload))) {
+		return "", 0, false
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return parts[0], expiresAt, true
+}
+
+func (svc *magicLinkService) signature(payload string) string {
+	mac := hmac.New(sha256.New, svc.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// addresses are counted case insensitively, so changing the case doesn't get around the limit
+func normalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
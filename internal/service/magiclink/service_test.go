@@ -0,0 +1,206 @@
+package magiclink
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+	"github.com/stretchr/testify/assert"
+)
+
+const testNow = int64(100000)
+
+func Test_SendLoginLink(t *testing.T) {
+	tests := map[string]struct {
+		userId       int64
+		repo         *testMagicLinkRepo
+		mailErr      error
+		expectedSent bool
+		expectedErr  error
+	}{
+		"valid": {
+			userId:       5,
+			repo:         &testMagicLinkRepo{count: maxLinksPerAddress - 1},
+			expectedSent: true,
+		},
+		"valid, unknown address is stored but not sent": {
+			repo: &testMagicLinkRepo{},
+		},
+		"invalid, too many links": {
+			userId:      5,
+			repo:        &testMagicLinkRepo{count: maxLinksPerAddress},
+			expectedErr: resp.ErrTooManyLoginLinks,
+		},
+		"invalid, too many links for unknown address": {
+			repo:        &testMagicLinkRepo{count: maxLinksPerAddress},
+			expectedErr: resp.ErrTooManyLoginLinks,
+		},
+		"invalid, could not count links": {
+			userId:      5,
+			repo:        &testMagicLinkRepo{errCount: resp.ErrCouldNotCheckLoginLink},
+			expectedErr: resp.ErrCouldNotCheckLoginLink,
+		},
+		"invalid, could not store link": {
+			userId:      5,
+			repo:        &testMagicLinkRepo{errStore: resp.ErrCouldNotStoreLoginLink},
+			expectedErr: resp.ErrCouldNotStoreLoginLink,
+		},
+		"invalid, could not send email": {
+			userId:      5,
+			repo:        &testMagicLinkRepo{},
+			mailErr:     errors.New("smtp is down"),
+			expectedErr: resp.ErrCouldNotSendEmail,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(testNow))
+			mailer := util.TestMailer()
+			mailer.SetErr(tcase.mailErr)
+			cfg.SetMailer(mailer)
+			svc := NewMagicLinkService(cfg, tcase.repo)
+			err := svc.SendLoginLink(" Ben@WordBubble.com", tcase.userId, "wordbubble:push", true)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, "ben@wordbubble.com", tcase.repo.countedAddress)
+			assert.Equal(t, testNow-linkRateWindow, tcase.repo.countedAfter)
+			if !tcase.expectedSent {
+				assert.Empty(t, mailer.Sent())
+				return
+			}
+			assert.Equal(t, &loginLink{
+				linkId:     tcase.repo.stored.linkId,
+				address:    "ben@wordbubble.com",
+				userId:     5,
+				scope:      "wordbubble:push",
+				rememberMe: true,
+				createdAt:  testNow,
+				expiresAt:  testNow + int64(util.DefaultTokenLifetimes().LoginLink.Seconds()),
+			}, tcase.repo.stored)
+			sent := mailer.Sent()
+			assert.Len(t, sent, 1)
+			assert.Equal(t, "ben@wordbubble.com", sent[0].To)
+			assert.Contains(t, sent[0].Body, "/v1/login/link/callback?token="+svc.sign(tcase.repo.stored.linkId, tcase.repo.stored.expiresAt))
+		})
+	}
+}
+
+func Test_RedeemLoginLink(t *testing.T) {
+	link := &loginLink{linkId: "link", userId: 5, scope: "wordbubble:push", rememberMe: true, expiresAt: testNow + 60}
+	svc := NewMagicLinkService(cfg.TestConfig(), nil)
+	token := svc.sign("link", testNow+60)
+	tests := map[string]struct {
+		token          string
+		repo           *testMagicLinkRepo
+		expectedUserId int64
+		expectedErr    error
+	}{
+		"valid": {
+			token:          token,
+			repo:           &testMagicLinkRepo{link: link},
+			expectedUserId: 5,
+		},
+		"invalid, malformed token": {
+			token:       "link.100060",
+			repo:        &testMagicLinkRepo{link: link},
+			expectedErr: resp.ErrInvalidLoginLink,
+		},
+		"invalid, signature": {
+			token:       "link.100060." + strings.Repeat("a", 43),
+			repo:        &testMagicLinkRepo{link: link},
+			expectedErr: resp.ErrInvalidLoginLink,
+		},
+		"invalid, expiry was changed": {
+			token:       strings.Replace(token, "100060", "200060", 1),
+			repo:        &testMagicLinkRepo{link: link},
+			expectedErr: resp.ErrInvalidLoginLink,
+		},
+		"invalid, expired": {
+			token:       svc.sign("link", testNow),
+			repo:        &testMagicLinkRepo{link: link},
+			expectedErr: resp.ErrInvalidLoginLink,
+		},
+		"invalid, expired in the database": {
+			token:       token,
+			repo:        &testMagicLinkRepo{link: &loginLink{linkId: "link", userId: 5, expiresAt: testNow}},
+			expectedErr: resp.ErrInvalidLoginLink,
+		},
+		"invalid, already redeemed": {
+			token:       token,
+			repo:        &testMagicLinkRepo{link: &loginLink{linkId: "link", userId: 5, expiresAt: testNow + 60, redeemed: true}},
+			expectedErr: resp.ErrInvalidLoginLink,
+		},
+		"invalid, link of an unknown address": {
+			token:       token,
+			repo:        &testMagicLinkRepo{link: &loginLink{linkId: "link", expiresAt: testNow + 60}},
+			expectedErr: resp.ErrInvalidLoginLink,
+		},
+		"invalid, link not found": {
+			token:       token,
+			repo:        &testMagicLinkRepo{errGet: resp.ErrInvalidLoginLink},
+			expectedErr: resp.ErrInvalidLoginLink,
+		},
+		"invalid, redeemed by another request": {
+			token:       token,
+			repo:        &testMagicLinkRepo{link: link, errRedeem: resp.ErrInvalidLoginLink},
+			expectedErr: resp.ErrInvalidLoginLink,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(testNow))
+			svc := NewMagicLinkService(cfg, tcase.repo)
+			userId, scope, rememberMe, err := svc.RedeemLoginLink(tcase.token)
+			if tcase.expectedErr != nil {
+				assert.Equal(t, tcase.expectedErr, err)
+				assert.Zero(t, userId)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.expectedUserId, userId)
+			assert.Equal(t, "wordbubble:push", scope)
+			assert.True(t, rememberMe)
+			assert.Equal(t, "link", tcase.repo.redeemedLinkId)
+		})
+	}
+}
+
+type testMagicLinkRepo struct {
+	errStore       error
+	stored         *loginLink
+	errCount       error
+	count          int
+	countedAddress string
+	countedAfter   int64
+	errGet         error
+	link           *loginLink
+	errRedeem      error
+	redeemedLinkId string
+}
+
+func (repo *testMagicLinkRepo) storeLoginLink(link *loginLink) error {
+	repo.stored = link
+	return repo.errStore
+}
+
+func (repo *testMagicLinkRepo) countLoginLinks(address string, after int64) (int, error) {
+	repo.countedAddress = address
+	repo.countedAfter = after
+	return repo.count, repo.errCount
+}
+
+func (repo *testMagicLinkRepo) getLoginLink(linkId string) (*loginLink, error) {
+	if repo.errGet != nil {
+		return nil, repo.errGet
+	}
+	return repo.link, nil
+}
+
+func (repo *testMagicLinkRepo) redeemLoginLink(linkId string) error {
+	repo.redeemedLinkId = linkId
+	return repo.errRedeem
+}
@@ -0,0 +1,19 @@
+package mfa
+
+import (
+	"context"
+
+	"github.com/bchadwic/wordbubble/internal/job"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// NewChallengeCleanupJob creates a job that removes mfa challenges that expired before they were redeemed
+func NewChallengeCleanupJob(timer util.Timer, cleaner MfaCleaner) job.Job {
+	return job.Job{
+		Name:     "mfa_challenge_cleanup",
+		Interval: ChallengeCleanerRate,
+		Run: func(ctx context.Context) error {
+			return cleaner.CleanupExpiredMfaChallenges(timer.Now().Unix())
+		},
+	}
+}
@@ -0,0 +1,118 @@
+package mfa
+
+import (
+	"time"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+)
+
+const (
+	// Issuer is shown next to the account in authenticator apps
+	Issuer = "wordbubble"
+	// recoveryCodeCount is how many recovery codes a user is given when they confirm a totp secret
+	recoveryCodeCount = 10
+	// recoveryCodeLength is how many random bytes are in a recovery code, enough that a leaked hash can't be reversed
+	recoveryCodeLength = 10
+	// challengeTimeLimit is how long the mfa token returned from /login can be exchanged, in seconds
+	challengeTimeLimit = 300
+	// maxChallengeAttempts is how many wrong codes can be sent with an mfa token before it's rejected,
+	// so a six digit code can't be guessed before the token expires
+	maxChallengeAttempts = 5
+	ChallengeCleanerRate = 5 * time.Minute
+
+	StoreTotpSecret      = `INSERT INTO totp_secrets (user_id, secret) VALUES ($1, $2) ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, last_used_step = 0 WHERE totp_secrets.confirmed = FALSE`
+	GetTotpSecret        = `SELECT secret, confirmed, last_used_step FROM totp_secrets WHERE user_id = $1`
+	ConfirmTotpSecret    = `UPDATE totp_secrets SET confirmed = TRUE, last_used_step = $1 WHERE user_id = $2 AND confirmed = FALSE`
+	UseTotpStep          = `UPDATE totp_secrets SET last_used_step = $1 WHERE user_id = $2 AND last_used_step < $1`
+	DeleteTotpSecret     = `DELETE FROM totp_secrets WHERE user_id = $1`
+	StoreRecoveryCode    = `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`
+	UseRecoveryCode      = `DELETE FROM recovery_codes WHERE user_id = $1 AND code_hash = $2`
+	DeleteRecoveryCodes  = `DELETE FROM recovery_codes WHERE user_id = $1`
+	StoreMfaChallenge    = `INSERT INTO mfa_challenges (challenge_hash, user_id, scope, expires_at) VALUES ($1, $2, $3, $4)`
+	GetMfaChallenge      = `SELECT user_id, scope, expires_at, attempts FROM mfa_challenges WHERE challenge_hash = $1`
+	FailMfaChallenge     = `UPDATE mfa_challenges SET attempts = attempts + 1 WHERE challenge_hash = $1`
+	RedeemMfaChallenge   = `DELETE FROM mfa_challenges WHERE challenge_hash = $1`
+	CleanupMfaChallenges = `DELETE FROM mfa_challenges WHERE expires_at < $1`
+)
+
+// MfaService is the interface that the application
+// uses to interact with two-factor authentication
+type MfaService interface {
+	// EnrollTotp creates a new totp secret for a user, replacing any secret that was never confirmed.
+	// The secret isn't required at login until it's confirmed with a code
+	// *resp.EnrollTotpResponse is the secret and its provisioning uri, can be nil.
+	// error could be (409) resp.ErrMfaAlreadyEnabled, (500) resp.ErrCouldNotStoreTotpSecret or nil.
+	EnrollTotp(userId int64, account string) (*resp.EnrollTotpResponse, error)
+	// ConfirmTotp confirms a user's totp secret with a code generated from it, which enables two-factor authentication
+	// *resp.RecoveryCodesResponse are new recovery codes for the user, can be nil.
+	// error could be (400) resp.ErrMfaNotEnrolled, (401) resp.ErrInvalidMfaCode, (409) resp.ErrMfaAlreadyEnabled,
+	// (500) resp.ErrCouldNotCheckMfa, (500) resp.ErrCouldNotStoreRecoveryCodes or nil.
+	ConfirmTotp(userId int64, code string) (*resp.RecoveryCodesResponse, error)
+	// DisableTotp removes a user's totp secret and recovery codes, the user must prove they still have one of them
+	// error could be (400) resp.ErrMfaNotEnrolled, (401) resp.ErrInvalidMfaCode, (500) resp.ErrCouldNotCheckMfa,
+	// (500) resp.ErrCouldNotDisableMfa or nil.
+	DisableTotp(userId int64, code string) error
+	// MfaEnabled checks whether a user has to send a code when logging in
+	// bool is true when the user has confirmed a totp secret.
+	// error could be (500) resp.ErrCouldNotCheckMfa or nil.
+	MfaEnabled(userId int64) (bool, error)
+	// CreateChallenge creates a short lived mfa token for a user that passed the first factor, holding the scope they requested
+	// *resp.MfaChallengeResponse is the mfa token, can be nil.
+	// error could be (500) resp.ErrCouldNotStoreMfaChallenge or nil.
+	CreateChallenge(userId int64, scope string) (*resp.MfaChallengeResponse, error)
+	// ChallengeUser finds the user an mfa token was issued to, so failed codes can be counted against them.
+	// int64 is the user id of the token, or zero.
+	// error could be (401) resp.ErrInvalidMfaToken or nil.
+	ChallengeUser(mfaToken string) (int64, error)
+	// VerifyChallenge redeems an mfa token with a totp code or a recovery code
+	// int64 is the user id of the token, or zero.
+	// string is the scope requested at login, or empty string.
+	// error could be (400) resp.ErrMfaNotEnrolled, (401) resp.ErrInvalidMfaToken, (401) resp.ErrInvalidMfaCode,
+	// (500) resp.ErrCouldNotCheckMfa or nil.
+	VerifyChallenge(mfaToken, code string) (int64, string, error)
+}
+
+// MfaRepo is the interface that the service layer
+// uses to interact with totp secrets, recovery codes and mfa challenges in the database
+type MfaRepo interface {
+	// storeTotpSecret stores an unconfirmed totp secret for a user.
+	// error can be (409) resp.ErrMfaAlreadyEnabled, (500) resp.ErrCouldNotStoreTotpSecret or nil.
+	storeTotpSecret(userId int64, secret string) error
+	// getTotpSecret finds the totp secret of a user.
+	// *totpSecret is the secret, whether it's confirmed, and the last time step a code was used for, can be nil.
+	// error can be (400) resp.ErrMfaNotEnrolled, (500) resp.ErrCouldNotCheckMfa or nil.
+	getTotpSecret(userId int64) (*totpSecret, error)
+	// confirmTotpSecret confirms a totp secret with the time step of the first code used, and stores the hashes of recovery codes.
+	// error can be (409) resp.ErrMfaAlreadyEnabled, (500) resp.ErrCouldNotStoreRecoveryCodes or nil.
+	confirmTotpSecret(userId int64, step int64, recoveryCodeHashes []string) error
+	// useTotpStep records the time step of a code, a code can't be used again once a later or equal step is used.
+	// error can be (401) resp.ErrInvalidMfaCode, (500) resp.ErrCouldNotCheckMfa or nil.
+	useTotpStep(userId int64, step int64) error
+	// useRecoveryCode removes a recovery code, so it can only be used once.
+	// error can be (401) resp.ErrInvalidMfaCode, (500) resp.ErrCouldNotCheckMfa or nil.
+	useRecoveryCode(userId int64, codeHash string) error
+	// deleteTotpSecret removes a user's totp secret and recovery codes.
+	// error can be (500) resp.ErrCouldNotDisableMfa or nil.
+	deleteTotpSecret(userId int64) error
+	// storeMfaChallenge stores the hash of an mfa token.
+	// error can be (500) resp.ErrCouldNotStoreMfaChallenge or nil.
+	storeMfaChallenge(challengeHash string, challenge *mfaChallenge) error
+	// getMfaChallenge finds the mfa challenge with the hash passed.
+	// *mfaChallenge is the challenge found, can be nil.
+	// error can be (401) resp.ErrInvalidMfaToken or nil.
+	getMfaChallenge(challengeHash string) (*mfaChallenge, error)
+	// failMfaChallenge counts a wrong code sent with an mfa token.
+	// error can be (500) resp.ErrCouldNotCheckMfa or nil.
+	failMfaChallenge(challengeHash string) error
+	// redeemMfaChallenge removes an mfa challenge, so it can only be redeemed once.
+	// error can be (401) resp.ErrInvalidMfaToken, (500) resp.ErrCouldNotCheckMfa or nil.
+	redeemMfaChallenge(challengeHash string) error
+}
+
+// MfaCleaner is the interface that the application
+// uses to clean up expired mfa challenges
+type MfaCleaner interface {
+	// CleanupExpiredMfaChallenges remove any mfa challenges from the database that were never redeemed and are expired.
+	// error can be (500) resp.ErrCouldNotCleanupMfaChallenges or nil
+	CleanupExpiredMfaChallenges(now int64) error
+}
@@ -0,0 +1,162 @@
+package mfa
+
+import (
+	"database/sql"
+	"errors"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type mfaRepo struct {
+	db  *sql.DB
+	log util.Logger
+}
+
+func NewMfaRepo(config cfg.Config) *mfaRepo {
+	return &mfaRepo{
+		log: config.NewLogger("mfa_repo"),
+		db:  config.DB(),
+	}
+}
+
+func (repo *mfaRepo) storeTotpSecret(userId int64, secret string) error {
+	rs, err := repo.db.Exec(StoreTotpSecret, userId, secret)
+	if err != nil {
+		repo.log.Error("could not store totp secret for user: %d, error: %s", userId, err)
+		return resp.ErrCouldNotStoreTotpSecret
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 { // a confirmed secret is never replaced
+		return resp.ErrMfaAlreadyEnabled
+	}
+	return nil
+}
+
+func (repo *mfaRepo) getTotpSecret(userId int64) (*totpSecret, error) {
+	row := repo.db.QueryRow(GetTotpSecret, userId)
+	var found totpSecret
+	if err := row.Scan(&found.secret, &found.confirmed, &found.lastUsedStep); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, resp.ErrMfaNotEnrolled
+		}
+		repo.log.Error("could not find totp secret for user: %d, error: %s", userId, err)
+		return nil, resp.ErrCouldNotCheckMfa
+	}
+	return &found, nil
+}
+
+func (repo *mfaRepo) confirmTotpSecret(userId int64, step int64, recoveryCodeHashes []string) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return resp.ErrCouldNotStoreRecoveryCodes
+	}
+	defer tx.Rollback()
+	rs, err := tx.Exec(ConfirmTotpSecret, step, userId)
+	if err != nil {
+		repo.log.Error("could not confirm totp secret for user: %d, error: %s", userId, err)
+		return resp.ErrCouldNotStoreRecoveryCodes
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 {
+		return resp.ErrMfaAlreadyEnabled
+	}
+	if _, err = tx.Exec(DeleteRecoveryCodes, userId); err != nil {
+		return resp.ErrCouldNotStoreRecoveryCodes
+	}
+	for _, codeHash := range recoveryCodeHashes {
+		if _, err = tx.Exec(StoreRecoveryCode, userId, codeHash); err != nil {
+			repo.log.Error("could not store recovery code for user: %d, error: %s", userId, err)
+			return resp.ErrCouldNotStoreRecoveryCodes
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return resp.ErrCouldNotStoreRecoveryCodes
+	}
+	return nil
+}
+
+func (repo *mfaRepo) useTotpStep(userId int64, step int64) error {
+	rs, err := repo.db.Exec(UseTotpStep, step, userId)
+	if err != nil {
+		return resp.ErrCouldNotCheckMfa
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 { // the code, or a later one, has already been used
+		return resp.ErrInvalidMfaCode
+	}
+	return nil
+}
+
+func (repo *mfaRepo) useRecoveryCode(userId int64, codeHash string) error {
+	rs, err := repo.db.Exec(UseRecoveryCode, userId, codeHash)
+	if err != nil {
+		return resp.ErrCouldNotCheckMfa
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 {
+		return resp.ErrInvalidMfaCode
+	}
+	repo.log.Info("recovery code used for user: %d", userId)
+	return nil
+}
+
+func (repo *mfaRepo) deleteTotpSecret(userId int64) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return resp.ErrCouldNotDisableMfa
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec(DeleteTotpSecret, userId); err != nil {
+		return resp.ErrCouldNotDisableMfa
+	}
+	if _, err = tx.Exec(DeleteRecoveryCodes, userId); err != nil {
+		return resp.ErrCouldNotDisableMfa
+	}
+	if err = tx.Commit(); err != nil {
+		return resp.ErrCouldNotDisableMfa
+	}
+	return nil
+}
+
+func (repo *mfaRepo) storeMfaChallenge(challengeHash string, challenge *mfaChallenge) error {
+	if _, err := repo.db.Exec(StoreMfaChallenge, challengeHash, challenge.userId, challenge.scope, challenge.expiresAt); err != nil {
+		repo.log.Error("could not store mfa challenge for user: %d, error: %s", challenge.userId, err)
+		return resp.ErrCouldNotStoreMfaChallenge
+	}
+	return nil
+}
+
+func (repo *mfaRepo) getMfaChallenge(challengeHash string) (*mfaChallenge, error) {
+	row := repo.db.QueryRow(GetMfaChallenge, challengeHash)
+	var found mfaChallenge
+	if err := row.Scan(&found.userId, &found.scope, &found.expiresAt, &found.attempts); err != nil {
+		return nil, resp.ErrInvalidMfaToken
+	}
+	return &found, nil
+}
+
+func (repo *mfaRepo) failMfaChallenge(challengeHash string) error {
+	if _, err := repo.db.Exec(FailMfaChallenge, challengeHash); err != nil {
+		return resp.ErrCouldNotCheckMfa
+	}
+	return nil
+}
+
+func (repo *mfaRepo) redeemMfaChallenge(challengeHash string) error {
+	rs, err := repo.db.Exec(RedeemMfaChallenge, challengeHash)
+	if err != nil {
+		return resp.ErrCouldNotCheckMfa
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 { // the token was redeemed by another request in the meantime
+		return resp.ErrInvalidMfaToken
+	}
+	return nil
+}
+
+func (repo *mfaRepo) CleanupExpiredMfaChallenges(now int64) error {
+	rs, err := repo.db.Exec(CleanupMfaChallenges, now)
+	if err != nil {
+		return resp.ErrCouldNotCleanupMfaChallenges
+	}
+	amt, _ := rs.RowsAffected()
+	repo.log.Info("mfa challenge cleaner deleted: %d challenges", amt)
+	return nil
+}
@@ -0,0 +1,117 @@
+package mfa
+
+import (
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HappyPath(t *testing.T) {
+	repo := NewMfaRepo(cfg.TestConfig())
+	userId := int64(5)
+
+	// A user enrolls, loses the QR code, and enrolls again before confirming
+	assert.NoError(t, repo.storeTotpSecret(userId, "first"))
+	assert.NoError(t, repo.storeTotpSecret(userId, "second"))
+	found, err := repo.getTotpSecret(userId)
+	assert.NoError(t, err)
+	assert.Equal(t, &totpSecret{secret: "second"}, found)
+
+	// The user confirms the secret, and is given recovery codes
+	assert.NoError(t, repo.confirmTotpSecret(userId, 100, []string{"hash.a", "hash.b"}))
+	found, err = repo.getTotpSecret(userId)
+	assert.NoError(t, err)
+	assert.Equal(t, &totpSecret{secret: "second", confirmed: true, lastUsedStep: 100}, found)
+
+	// A confirmed secret can't be replaced, or confirmed again
+	assert.Equal(t, resp.ErrMfaAlreadyEnabled, repo.storeTotpSecret(userId, "third"))
+	assert.Equal(t, resp.ErrMfaAlreadyEnabled, repo.confirmTotpSecret(userId, 101, nil))
+
+	// A code can only be used once, and a code older than one already used is rejected
+	assert.NoError(t, repo.useTotpStep(userId, 102))
+	assert.Equal(t, resp.ErrInvalidMfaCode, repo.useTotpStep(userId, 102))
+	assert.Equal(t, resp.ErrInvalidMfaCode, repo.useTotpStep(userId, 101))
+
+	// A recovery code can only be used once
+	assert.NoError(t, repo.useRecoveryCode(userId, "hash.a"))
+	assert.Equal(t, resp.ErrInvalidMfaCode, repo.useRecoveryCode(userId, "hash.a"))
+	assert.Equal(t, resp.ErrInvalidMfaCode, repo.useRecoveryCode(userId+1, "hash.b"))
+
+	// The user logs in, sends a wrong code, then the right one
+	assert.NoError(t, repo.storeMfaChallenge("hash.challenge", &mfaChallenge{userId: userId, scope: "wordbubble:push", expiresAt: 400}))
+	assert.NoError(t, repo.failMfaChallenge("hash.challenge"))
+	challenge, err := repo.getMfaChallenge("hash.challenge")
+	assert.NoError(t, err)
+	assert.Equal(t, &mfaChallenge{userId: userId, scope: "wordbubble:push", expiresAt: 400, attempts: 1}, challenge)
+	assert.NoError(t, repo.redeemMfaChallenge("hash.challenge"))
+	assert.Equal(t, resp.ErrInvalidMfaToken, repo.redeemMfaChallenge("hash.challenge"))
+	_, err = repo.getMfaChallenge("hash.challenge")
+	assert.Equal(t, resp.ErrInvalidMfaToken, err)
+
+	// Challenges that were never redeemed are cleaned up once they expire
+	assert.NoError(t, repo.storeMfaChallenge("hash.expired", &mfaChallenge{userId: userId, expiresAt: 200}))
+	assert.NoError(t, repo.storeMfaChallenge("hash.active", &mfaChallenge{userId: userId, expiresAt: 400}))
+	assert.NoError(t, repo.CleanupExpiredMfaChallenges(300))
+	_, err = repo.getMfaChallenge("hash.expired")
+	assert.Equal(t, resp.ErrInvalidMfaToken, err)
+	_, err = repo.getMfaChallenge("hash.active")
+	assert.NoError(t, err)
+
+	// The user disables mfa, their secret and recovery codes are removed
+	assert.NoError(t, repo.deleteTotpSecret(userId))
+	_, err = repo.getTotpSecret(userId)
+	assert.Equal(t, resp.ErrMfaNotEnrolled, err)
+	assert.Equal(t, resp.ErrInvalidMfaCode, repo.useRecoveryCode(userId, "hash.b"))
+}
+
+func Test_NotSoHappyPath(t *testing.T) {
+	repo := NewMfaRepo(cfg.TestConfig())
+
+	// db closed
+	repo.db.Close()
+	err := repo.storeTotpSecret(5, "secret")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotStoreTotpSecret.Error(), err.Error())
+
+	_, err = repo.getTotpSecret(5)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCheckMfa.Error(), err.Error())
+
+	err = repo.confirmTotpSecret(5, 100, []string{"hash.a"})
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotStoreRecoveryCodes.Error(), err.Error())
+
+	err = repo.useTotpStep(5, 100)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCheckMfa.Error(), err.Error())
+
+	err = repo.useRecoveryCode(5, "hash.a")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCheckMfa.Error(), err.Error())
+
+	err = repo.deleteTotpSecret(5)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotDisableMfa.Error(), err.Error())
+
+	err = repo.storeMfaChallenge("hash.challenge", &mfaChallenge{userId: 5})
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotStoreMfaChallenge.Error(), err.Error())
+
+	_, err = repo.getMfaChallenge("hash.challenge")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrInvalidMfaToken.Error(), err.Error())
+
+	err = repo.failMfaChallenge("hash.challenge")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCheckMfa.Error(), err.Error())
+
+	err = repo.redeemMfaChallenge("hash.challenge")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCheckMfa.Error(), err.Error())
+
+	err = repo.CleanupExpiredMfaChallenges(300)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCleanupMfaChallenges.Error(), err.Error())
+}
@@ -0,0 +1,185 @@
+package mfa
+
+import (
+	"errors"
+	"strings"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type totpSecret struct {
+	secret       string
+	confirmed    bool
+	lastUsedStep int64
+}
+
+type mfaChallenge struct {
+	userId    int64
+	scope     string
+	expiresAt int64
+	attempts  int
+}
+
+type mfaService struct {
+	log   util.Logger
+	timer util.Timer
+	repo  MfaRepo
+}
+
+func NewMfaService(cfg cfg.Config, repo MfaRepo) *mfaService {
+	return &mfaService{
+		log:   cfg.NewLogger("mfa"),
+		timer: cfg.Timer(),
+		repo:  repo,
+	}
+}
+
+func (svc *mfaService) EnrollTotp(userId int64, account string) (*resp.EnrollTotpResponse, error) {
+	secret := util.NewTotpSecret()
+	if err := svc.repo.storeTotpSecret(userId, secret); err != nil {
+		return nil, err
+	}
+	svc.log.Info("enrolled totp for user: %d", userId)
+	return &resp.EnrollTotpResponse{
+		Secret:          secret,
+		ProvisioningUri: util.TotpProvisioningUri(Issuer, account, secret),
+	}, nil
+}
+
+func (svc *mfaService) ConfirmTotp(userId int64, code string) (*resp.RecoveryCodesResponse, error) {
+	found, err := svc.repo.getTotpSecret(userId)
+	if err != nil {
+		return nil, err
+	}
+	if found.confirmed {
+		return nil, resp.ErrMfaAlreadyEnabled
+	}
+	step := util.MatchTotpCode(found.secret, code, svc.timer.Now())
+	if step == 0 {
+		return nil, resp.ErrInvalidMfaCode
+	}
+	codes, hashes := newRecoveryCodes()
+	if err = svc.repo.confirmTotpSecret(userId, step, hashes); err != nil {
+		return nil, err
+	}
+	svc.log.Info("enabled mfa for user: %d", userId)
+	return &resp.RecoveryCodesResponse{
+		RecoveryCodes: codes,
+	}, nil
+}
+
+func (svc *mfaService) DisableTotp(userId int64, code string) error {
+	if err := svc.verifyCode(userId, code); err != nil {
+		return err
+	}
+	if err := svc.repo.deleteTotpSecret(userId); err != nil {
+		return err
+	}
+	svc.log.Info("disabled mfa for user: %d", userId)
+	return nil
+}
+
+func (svc *mfaService) MfaEnabled(userId int64) (bool, error) {
+	found, err := svc.repo.getTotpSecret(userId)
+	if errors.Is(err, resp.ErrMfaNotEnrolled) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return found.confirmed, nil
+}
+
+func (svc *mfaService) CreateChallenge(userId int64, scope string) (*resp.MfaChallengeResponse, error) {
+	mfaToken := util.RandomString(16)
+	if err := svc.repo.storeMfaChallenge(util.HashToken(mfaToken), &mfaChallenge{
+		userId:    userId,
+		scope:     scope,
+		expiresAt: svc.timer.Now().Unix() + challengeTimeLimit,
+	}); err != nil {
+		return nil, err
+	}
+	return &resp.MfaChallengeResponse{
+		MfaToken:  mfaToken,
+		ExpiresIn: challengeTimeLimit,
+	}, nil
+}
+
+func (svc *mfaService) ChallengeUser(mfaToken string) (int64, error) {
+	found, err := svc.getChallenge(util.HashToken(mfaToken))
+	if err != nil {
+		return 0, err
+	}
+	return found.userId, nil
+}
+
+func (svc *mfaService) VerifyChallenge(mfaToken, code string) (int64, string, error) {
+	challengeHash := util.HashToken(mfaToken)
+	found, err := svc.getChallenge(challengeHash)
+	if err != nil {
+		return 0, "", err
+	}
+	if err = svc.verifyCode(found.userId, code); err != nil {
+		if errors.Is(err, resp.ErrInvalidMfaCode) {
+			if err := svc.repo.failMfaChallenge(challengeHash); err != nil {
+				return 0, "", err
+			}
+			svc.log.Warn("invalid mfa code sent for user: %d, attempt: %d", found.userId, found.attempts+1)
+		}
+		return 0, "", err
+	}
+	if err = svc.repo.redeemMfaChallenge(challengeHash); err != nil {
+		return 0, "", err
+	}
+	return found.userId, found.scope, nil
+}
+
+// getChallenge finds an mfa challenge that can still be redeemed
+// error could be (401) resp.ErrInvalidMfaToken or nil.
+func (svc *mfaService) getChallenge(challengeHash string) (*mfaChallenge, error) {
+	found, err := svc.repo.getMfaChallenge(challengeHash)
+	if err != nil {
+		return nil, err
+	}
+	if found.expiresAt <= svc.timer.Now().Unix() || found.attempts >= maxChallengeAttempts {
+		return nil, resp.ErrInvalidMfaToken
+	}
+	return found, nil
+}
+
+// verifyCode checks a code against a user's confirmed totp secret, falling back to their recovery codes.
+// Either kind of code can only be used once
+func (svc *mfaService) verifyCode(userId int64, code string) error {
+	found, err := svc.repo.getTotpSecret(userId)
+	if err != nil {
+		return err
+	}
+	if !found.confirmed {
+		return resp.ErrMfaNotEnrolled
+	}
+	if step := util.MatchTotpCode(found.secret, code, svc.timer.Now()); step != 0 {
+		return svc.repo.useTotpStep(userId, step)
+	}
+	return svc.repo.useRecoveryCode(userId, util.HashToken(normalizeRecoveryCode(code)))
+}
+
+// newRecoveryCodes returns recovery codes formatted to be written down in groups of five, along with the hashes that are stored
+func newRecoveryCodes() ([]string, []string) {
+	codes, hashes := make([]string, recoveryCodeCount), make([]string, recoveryCodeCount)
+	for i := range codes {
+		code := util.RandomString(recoveryCodeLength)
+		groups := make([]string, 0, len(code)/5)
+		for j := 0; j < len(code); j += 5 {
+			groups = append(groups, code[j:j+5])
+		}
+		codes[i] = strings.Join(groups, "-")
+		hashes[i] = util.HashToken(code)
+	}
+	return codes, hashes
+}
+
+// normalizeRecoveryCode removes the formatting a user may or may not have typed in
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
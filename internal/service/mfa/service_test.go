@@ -0,0 +1,492 @@
+package mfa
+
+import (
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// secret from the test vectors in RFC 6238 appendix B
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+const testNow = int64(1000) // totp step 33
+
+func testCode(t *testing.T, step int64) string {
+	code, err := util.TotpCode(testSecret, step)
+	assert.NoError(t, err)
+	return code
+}
+
+func Test_EnrollTotp(t *testing.T) {
+	tests := map[string]struct {
+		repo        *testMfaRepo
+		expectedErr error
+	}{
+		"valid": {
+			repo: &testMfaRepo{},
+		},
+		"invalid, already enabled": {
+			repo: &testMfaRepo{
+				errStore: resp.ErrMfaAlreadyEnabled,
+			},
+			expectedErr: resp.ErrMfaAlreadyEnabled,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			svc := NewMfaService(cfg.TestConfig(), tcase.repo)
+			enrolled, err := svc.EnrollTotp(5, "ben")
+			if tcase.expectedErr != nil {
+				assert.Equal(t, tcase.expectedErr, err)
+				assert.Nil(t, enrolled)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.repo.storedSecret, enrolled.Secret)
+			assert.Equal(t, util.TotpProvisioningUri(Issuer, "ben", enrolled.Secret), enrolled.ProvisioningUri)
+		})
+	}
+}
+
+func Test_ConfirmTotp(t *testing.T) {
+	tests := map[string]struct {
+		code         string
+		repo         *testMfaRepo
+		expectedStep int64
+		expectedErr  error
+	}{
+		"valid": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				secret: &totpSecret{secret: testSecret},
+			},
+			expectedStep: 33,
+		},
+		"valid, previous code": {
+			code: testCode(t, 32),
+			repo: &testMfaRepo{
+				secret: &totpSecret{secret: testSecret},
+			},
+			expectedStep: 32,
+		},
+		"invalid, code": {
+			code: testCode(t, 30),
+			repo: &testMfaRepo{
+				secret: &totpSecret{secret: testSecret},
+			},
+			expectedErr: resp.ErrInvalidMfaCode,
+		},
+		"invalid, not enrolled": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				errGet: resp.ErrMfaNotEnrolled,
+			},
+			expectedErr: resp.ErrMfaNotEnrolled,
+		},
+		"invalid, already enabled": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				secret: &totpSecret{secret: testSecret, confirmed: true},
+			},
+			expectedErr: resp.ErrMfaAlreadyEnabled,
+		},
+		"invalid, database error": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				secret:     &totpSecret{secret: testSecret},
+				errConfirm: resp.ErrCouldNotStoreRecoveryCodes,
+			},
+			expectedErr: resp.ErrCouldNotStoreRecoveryCodes,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(testNow))
+			svc := NewMfaService(cfg, tcase.repo)
+			confirmed, err := svc.ConfirmTotp(5, tcase.code)
+			if tcase.expectedErr != nil {
+				assert.Equal(t, tcase.expectedErr, err)
+				assert.Nil(t, confirmed)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.expectedStep, tcase.repo.confirmedStep)
+			assert.Len(t, confirmed.RecoveryCodes, recoveryCodeCount)
+			assert.Len(t, tcase.repo.storedRecoveryCodeHashes, recoveryCodeCount)
+			for i, code := range confirmed.RecoveryCodes {
+				assert.Len(t, code, 23)
+				assert.Equal(t, util.HashToken(normalizeRecoveryCode(code)), tcase.repo.storedRecoveryCodeHashes[i])
+			}
+		})
+	}
+}
+
+func Test_DisableTotp(t *testing.T) {
+	tests := map[string]struct {
+		code                 string
+		repo                 *testMfaRepo
+		expectedRecoveryHash string
+		expectedDeleted      bool
+		expectedErr          error
+	}{
+		"valid, totp code": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				secret: &totpSecret{secret: testSecret, confirmed: true},
+			},
+			expectedDeleted: true,
+		},
+		"valid, recovery code": {
+			code: "ABCDE-12345-FEDCB-54321",
+			repo: &testMfaRepo{
+				secret: &totpSecret{secret: testSecret, confirmed: true},
+			},
+			expectedRecoveryHash: util.HashToken("abcde12345fedcb54321"),
+			expectedDeleted:      true,
+		},
+		"invalid, wrong code": {
+			code: "ABCDE-12345-FEDCB-54321",
+			repo: &testMfaRepo{
+				secret:      &totpSecret{secret: testSecret, confirmed: true},
+				errRecovery: resp.ErrInvalidMfaCode,
+			},
+			expectedRecoveryHash: util.HashToken("abcde12345fedcb54321"),
+			expectedErr:          resp.ErrInvalidMfaCode,
+		},
+		"invalid, totp code already used": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				secret:  &totpSecret{secret: testSecret, confirmed: true, lastUsedStep: 33},
+				errStep: resp.ErrInvalidMfaCode,
+			},
+			expectedErr: resp.ErrInvalidMfaCode,
+		},
+		"invalid, not confirmed": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				secret: &totpSecret{secret: testSecret},
+			},
+			expectedErr: resp.ErrMfaNotEnrolled,
+		},
+		"invalid, database error": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				secret:    &totpSecret{secret: testSecret, confirmed: true},
+				errDelete: resp.ErrCouldNotDisableMfa,
+			},
+			expectedDeleted: true,
+			expectedErr:     resp.ErrCouldNotDisableMfa,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(testNow))
+			svc := NewMfaService(cfg, tcase.repo)
+			err := svc.DisableTotp(5, tcase.code)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedRecoveryHash, tcase.repo.usedRecoveryCodeHash)
+			assert.Equal(t, tcase.expectedDeleted, tcase.repo.deleted)
+		})
+	}
+}
+
+func Test_MfaEnabled(t *testing.T) {
+	tests := map[string]struct {
+		repo            *testMfaRepo
+		expectedEnabled bool
+		expectedErr     error
+	}{
+		"enabled": {
+			repo: &testMfaRepo{
+				secret: &totpSecret{secret: testSecret, confirmed: true},
+			},
+			expectedEnabled: true,
+		},
+		"enrolled, not confirmed": {
+			repo: &testMfaRepo{
+				secret: &totpSecret{secret: testSecret},
+			},
+		},
+		"not enrolled": {
+			repo: &testMfaRepo{
+				errGet: resp.ErrMfaNotEnrolled,
+			},
+		},
+		"database error": {
+			repo: &testMfaRepo{
+				errGet: resp.ErrCouldNotCheckMfa,
+			},
+			expectedErr: resp.ErrCouldNotCheckMfa,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			svc := NewMfaService(cfg.TestConfig(), tcase.repo)
+			enabled, err := svc.MfaEnabled(5)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedEnabled, enabled)
+		})
+	}
+}
+
+func Test_CreateChallenge(t *testing.T) {
+	tests := map[string]struct {
+		repo        *testMfaRepo
+		expectedErr error
+	}{
+		"valid": {
+			repo: &testMfaRepo{},
+		},
+		"invalid, database error": {
+			repo: &testMfaRepo{
+				errStore: resp.ErrCouldNotStoreMfaChallenge,
+			},
+			expectedErr: resp.ErrCouldNotStoreMfaChallenge,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(testNow))
+			svc := NewMfaService(cfg, tcase.repo)
+			challenge, err := svc.CreateChallenge(5, "wordbubble:push")
+			if tcase.expectedErr != nil {
+				assert.Equal(t, tcase.expectedErr, err)
+				assert.Nil(t, challenge)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, int64(challengeTimeLimit), challenge.ExpiresIn)
+			assert.Equal(t, util.HashToken(challenge.MfaToken), tcase.repo.storedChallengeHash)
+			assert.Equal(t, &mfaChallenge{
+				userId:    5,
+				scope:     "wordbubble:push",
+				expiresAt: testNow + challengeTimeLimit,
+			}, tcase.repo.storedChallenge)
+		})
+	}
+}
+
+func Test_VerifyChallenge(t *testing.T) {
+	valid := func() *mfaChallenge {
+		return &mfaChallenge{userId: 5, scope: "wordbubble:push", expiresAt: testNow + 60}
+	}
+	tests := map[string]struct {
+		code           string
+		repo           *testMfaRepo
+		expectedFailed bool
+		expectedErr    error
+	}{
+		"valid": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				secret:    &totpSecret{secret: testSecret, confirmed: true},
+				challenge: valid(),
+			},
+		},
+		"valid, recovery code": {
+			code: "abcde 12345 fedcb 54321",
+			repo: &testMfaRepo{
+				secret:    &totpSecret{secret: testSecret, confirmed: true},
+				challenge: valid(),
+			},
+		},
+		"invalid, unknown token": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				errGetChallenge: resp.ErrInvalidMfaToken,
+			},
+			expectedErr: resp.ErrInvalidMfaToken,
+		},
+		"invalid, expired token": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				secret:    &totpSecret{secret: testSecret, confirmed: true},
+				challenge: &mfaChallenge{userId: 5, expiresAt: testNow},
+			},
+			expectedErr: resp.ErrInvalidMfaToken,
+		},
+		"invalid, too many attempts": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				secret:    &totpSecret{secret: testSecret, confirmed: true},
+				challenge: &mfaChallenge{userId: 5, expiresAt: testNow + 60, attempts: maxChallengeAttempts},
+			},
+			expectedErr: resp.ErrInvalidMfaToken,
+		},
+		"invalid, wrong code": {
+			code: "000000",
+			repo: &testMfaRepo{
+				secret:      &totpSecret{secret: testSecret, confirmed: true},
+				challenge:   valid(),
+				errRecovery: resp.ErrInvalidMfaCode,
+			},
+			expectedFailed: true,
+			expectedErr:    resp.ErrInvalidMfaCode,
+		},
+		"invalid, token redeemed concurrently": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				secret:    &totpSecret{secret: testSecret, confirmed: true},
+				challenge: valid(),
+				errRedeem: resp.ErrInvalidMfaToken,
+			},
+			expectedErr: resp.ErrInvalidMfaToken,
+		},
+		"invalid, database error": {
+			code: testCode(t, 33),
+			repo: &testMfaRepo{
+				challenge: valid(),
+				errGet:    resp.ErrCouldNotCheckMfa,
+			},
+			expectedErr: resp.ErrCouldNotCheckMfa,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(testNow))
+			svc := NewMfaService(cfg, tcase.repo)
+			userId, scope, err := svc.VerifyChallenge("token", tcase.code)
+			assert.Equal(t, tcase.expectedFailed, tcase.repo.failedChallengeHash == util.HashToken("token"))
+			if tcase.expectedErr != nil {
+				assert.Equal(t, tcase.expectedErr, err)
+				assert.Zero(t, userId)
+				assert.Empty(t, scope)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, int64(5), userId)
+			assert.Equal(t, "wordbubble:push", scope)
+			assert.Equal(t, util.HashToken("token"), tcase.repo.redeemedChallengeHash)
+		})
+	}
+}
+
+func Test_ChallengeUser(t *testing.T) {
+	tests := map[string]struct {
+		repo           *testMfaRepo
+		expectedUserId int64
+		expectedErr    error
+	}{
+		"valid": {
+			repo:           &testMfaRepo{challenge: &mfaChallenge{userId: 5, expiresAt: testNow + 60}},
+			expectedUserId: 5,
+		},
+		"invalid, unknown token": {
+			repo:        &testMfaRepo{errGetChallenge: resp.ErrInvalidMfaToken},
+			expectedErr: resp.ErrInvalidMfaToken,
+		},
+		"invalid, expired token": {
+			repo:        &testMfaRepo{challenge: &mfaChallenge{userId: 5, expiresAt: testNow}},
+			expectedErr: resp.ErrInvalidMfaToken,
+		},
+		"invalid, too many attempts": {
+			repo:        &testMfaRepo{challenge: &mfaChallenge{userId: 5, expiresAt: testNow + 60, attempts: maxChallengeAttempts}},
+			expectedErr: resp.ErrInvalidMfaToken,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(testNow))
+			userId, err := NewMfaService(cfg, tcase.repo).ChallengeUser("token")
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedUserId, userId)
+		})
+	}
+}
+
+func Test_NewRecoveryCodes(t *testing.T) {
+	codes, hashes := newRecoveryCodes()
+	assert.Len(t, codes, recoveryCodeCount)
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		assert.Regexp(t, `^[0-9a-f]{5}(-[0-9a-f]{5}){3}$`, code)
+		assert.Equal(t, util.HashToken(normalizeRecoveryCode(code)), hashes[i])
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+}
+
+type testMfaRepo struct {
+	errStore                 error
+	storedSecret             string
+	secret                   *totpSecret
+	errGet                   error
+	errConfirm               error
+	confirmedStep            int64
+	storedRecoveryCodeHashes []string
+	errStep                  error
+	errRecovery              error
+	usedRecoveryCodeHash     string
+	errDelete                error
+	deleted                  bool
+	storedChallengeHash      string
+	storedChallenge          *mfaChallenge
+	challenge                *mfaChallenge
+	errGetChallenge          error
+	failedChallengeHash      string
+	errRedeem                error
+	redeemedChallengeHash    string
+}
+
+func (trepo *testMfaRepo) storeTotpSecret(userId int64, secret string) error {
+	trepo.storedSecret = secret
+	return trepo.errStore
+}
+
+func (trepo *testMfaRepo) getTotpSecret(userId int64) (*totpSecret, error) {
+	if trepo.errGet != nil {
+		return nil, trepo.errGet
+	}
+	return trepo.secret, nil
+}
+
+func (trepo *testMfaRepo) confirmTotpSecret(userId int64, step int64, recoveryCodeHashes []string) error {
+	trepo.confirmedStep = step
+	trepo.storedRecoveryCodeHashes = recoveryCodeHashes
+	return trepo.errConfirm
+}
+
+func (trepo *testMfaRepo) useTotpStep(userId int64, step int64) error {
+	return trepo.errStep
+}
+
+func (trepo *testMfaRepo) useRecoveryCode(userId int64, codeHash string) error {
+	trepo.usedRecoveryCodeHash = codeHash
+	return trepo.errRecovery
+}
+
+func (trepo *testMfaRepo) deleteTotpSecret(userId int64) error {
+	trepo.deleted = true
+	return trepo.errDelete
+}
+
+func (trepo *testMfaRepo) storeMfaChallenge(challengeHash string, challenge *mfaChallenge) error {
+	trepo.storedChallengeHash = challengeHash
+	trepo.storedChallenge = challenge
+	return trepo.errStore
+}
+
+func (trepo *testMfaRepo) getMfaChallenge(challengeHash string) (*mfaChallenge, error) {
+	if trepo.errGetChallenge != nil {
+		return nil, trepo.errGetChallenge
+	}
+	return trepo.challenge, nil
+}
+
+func (trepo *testMfaRepo) failMfaChallenge(challengeHash string) error {
+	trepo.failedChallengeHash = challengeHash
+	return nil
+}
+
+func (trepo *testMfaRepo) redeemMfaChallenge(challengeHash string) error {
+	trepo.redeemedChallengeHash = challengeHash
+	return trepo.errRedeem
+}
@@ -0,0 +1,19 @@
+package oauth
+
+import (
+	"context"
+
+	"github.com/bchadwic/wordbubble/internal/job"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// NewCodeCleanupJob creates a job that removes authorization codes that expired before they were redeemed
+func NewCodeCleanupJob(timer util.Timer, cleaner OAuthCleaner) job.Job {
+	return job.Job{
+		Name:     "authorization_code_cleanup",
+		Interval: CodeCleanerRate,
+		Run: func(ctx context.Context) error {
+			return cleaner.CleanupExpiredCodes(timer.Now().Unix())
+		},
+	}
+}
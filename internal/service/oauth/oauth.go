@@ -0,0 +1,82 @@
+package oauth
+
+import (
+	"time"
+
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+const (
+	// ResponseType is the only response type supported, an authorization code exchanged at /oauth/token
+	ResponseType = "code"
+	// CodeChallengeMethod is the only PKCE method supported, a plain challenge would be the verifier itself
+	CodeChallengeMethod = "S256"
+	// DefaultScope is granted to an app that doesn't request a scope, an app must ask to manage the account
+	DefaultScope = util.ScopeWordbubblePush + " " + util.ScopeWordbubbleRead
+	// codeTimeLimit is how long an authorization code can be exchanged for tokens, in seconds
+	codeTimeLimit       = 60
+	codeChallengeLength = 43 // base64url encoded sha256 hash, without padding
+	CodeCleanerRate     = 5 * time.Minute
+
+	StoreClient             = `INSERT INTO oauth_clients (client_id, user_id, name, redirect_uri, created_at) VALUES ($1, $2, $3, $4, $5)`
+	GetClient               = `SELECT client_id, name, redirect_uri FROM oauth_clients WHERE client_id = $1`
+	StoreAuthorizationCode  = `INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scope, code_challenge, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	GetAuthorizationCode    = `SELECT client_id, user_id, redirect_uri, scope, code_challenge, expires_at FROM oauth_codes WHERE code_hash = $1`
+	RedeemAuthorizationCode = `DELETE FROM oauth_codes WHERE code_hash = $1`
+	CleanupExpiredCodes     = `DELETE FROM oauth_codes WHERE expires_at < $1`
+)
+
+// OAuthService is the interface that the application
+// uses to interact with third party apps and their authorization codes
+type OAuthService interface {
+	// RegisterClient registers a third party app owned by a user, the app can only send users back to the redirect uri passed
+	// *resp.RegisterClientResponse is the client registered, can be nil.
+	// error could be (400) resp.ErrClientNameIsMissing, (400) resp.ErrClientNameIsTooLong, (400) resp.ErrInvalidRedirectUri,
+	// (500) resp.ErrCouldNotStoreClient or nil.
+	RegisterClient(userId int64, name, redirectUri string) (*resp.RegisterClientResponse, error)
+	// ValidateAuthorizeRequest validates the request a third party app sent a user to /oauth/authorize with
+	// *resp.ConsentResponse is what the app is asking for, the scope is DefaultScope when none was requested, can be nil.
+	// error could be (400) resp.ErrUnsupportedResponseType, (400) resp.ErrUnknownClient, (400) resp.ErrRedirectUriMismatch,
+	// (400) resp.ErrInvalidCodeChallenge, (400) resp.ErrInvalidScope or nil.
+	ValidateAuthorizeRequest(request *req.AuthorizeRequest) (*resp.ConsentResponse, error)
+	// CreateAuthorizationCode creates a single use code the app exchanges for tokens granted the scope passed,
+	// the code itself is only returned here and never stored
+	// string is the authorization code, or empty string.
+	// error could be (500) resp.ErrCouldNotStoreAuthorizationCode or nil.
+	CreateAuthorizationCode(userId int64, clientId, redirectUri, scope, codeChallenge string) (string, error)
+	// ExchangeAuthorizationCode redeems an authorization code, the code verifier must match the challenge it was created with
+	// int64 is the user id of the user that approved the app, or zero.
+	// string is the scope the user approved, or empty string.
+	// error could be (400) resp.ErrInvalidAuthorizationCode, (400) resp.ErrInvalidCodeVerifier,
+	// (500) resp.ErrCouldNotUseAuthorizationCode or nil.
+	ExchangeAuthorizationCode(clientId, code, redirectUri, codeVerifier string) (int64, string, error)
+}
+
+// OAuthRepo is the interface that the service layer
+// uses to interact with oauth clients and authorization codes in the database
+type OAuthRepo interface {
+	// storeClient stores a third party app.
+	// error can be (500) resp.ErrCouldNotStoreClient or nil.
+	storeClient(clientId string, userId int64, name, redirectUri string, createdAt int64) error
+	// getClient finds the third party app with the id passed.
+	// *client is the client found, holding its id, name and redirect uri, can be nil.
+	// error can be (400) resp.ErrUnknownClient or nil.
+	getClient(clientId string) (*client, error)
+	// storeAuthorizationCode stores the hash of an authorization code.
+	// error can be (500) resp.ErrCouldNotStoreAuthorizationCode or nil.
+	storeAuthorizationCode(codeHash string, code *authorizationCode) error
+	// redeemAuthorizationCode finds the authorization code with the hash passed and removes it, so it can only be redeemed once.
+	// *authorizationCode is the code redeemed, can be nil.
+	// error can be (400) resp.ErrInvalidAuthorizationCode, (500) resp.ErrCouldNotUseAuthorizationCode or nil.
+	redeemAuthorizationCode(codeHash string) (*authorizationCode, error)
+}
+
+// OAuthCleaner is the interface that the application
+// uses to clean up expired authorization codes
+type OAuthCleaner interface {
+	// CleanupExpiredCodes remove any authorization codes from the database that were never redeemed and are expired.
+	// error can be (500) resp.ErrCouldNotCleanupCodes or nil
+	CleanupExpiredCodes(now int64) error
+}
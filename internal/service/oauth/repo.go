@@ -0,0 +1,78 @@
+package oauth
+
+import (
+	"database/sql"
+	"errors"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type oauthRepo struct {
+	db  *sql.DB
+	log util.Logger
+}
+
+func NewOAuthRepo(config cfg.Config) *oauthRepo {
+	return &oauthRepo{
+		log: config.NewLogger("oauth_repo"),
+		db:  config.DB(),
+	}
+}
+
+func (repo *oauthRepo) storeClient(clientId string, userId int64, name, redirectUri string, createdAt int64) error {
+	if _, err := repo.db.Exec(StoreClient, clientId, userId, name, redirectUri, createdAt); err != nil {
+		repo.log.Error("could not store oauth client for user: %d, error: %s", userId, err)
+		return resp.ErrCouldNotStoreClient
+	}
+	return nil
+}
+
+func (repo *oauthRepo) getClient(clientId string) (*client, error) {
+	row := repo.db.QueryRow(GetClient, clientId)
+	var found client
+	if err := row.Scan(&found.id, &found.name, &found.redirectUri); err != nil {
+		return nil, resp.ErrUnknownClient
+	}
+	return &found, nil
+}
+
+func (repo *oauthRepo) storeAuthorizationCode(codeHash string, code *authorizationCode) error {
+	if _, err := repo.db.Exec(StoreAuthorizationCode, codeHash, code.clientId, code.userId, code.redirectUri, code.scope, code.codeChallenge, code.expiresAt); err != nil {
+		repo.log.Error("could not store authorization code for user: %d, error: %s", code.userId, err)
+		return resp.ErrCouldNotStoreAuthorizationCode
+	}
+	return nil
+}
+
+func (repo *oauthRepo) redeemAuthorizationCode(codeHash string) (*authorizationCode, error) {
+	row := repo.db.QueryRow(GetAuthorizationCode, codeHash)
+	var code authorizationCode
+	if err := row.Scan(&code.clientId, &code.userId, &code.redirectUri, &code.scope, &code.codeChallenge, &code.expiresAt); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			repo.log.Error("could not find authorization code, error: %s", err)
+			return nil, resp.ErrCouldNotUseAuthorizationCode
+		}
+		return nil, resp.ErrInvalidAuthorizationCode
+	}
+	rs, err := repo.db.Exec(RedeemAuthorizationCode, codeHash)
+	if err != nil {
+		repo.log.Error("could not redeem authorization code, error: %s", err)
+		return nil, resp.ErrCouldNotUseAuthorizationCode
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 { // the code was redeemed by another request in the meantime
+		return nil, resp.ErrInvalidAuthorizationCode
+	}
+	return &code, nil
+}
+
+func (repo *oauthRepo) CleanupExpiredCodes(now int64) error {
+	rs, err := repo.db.Exec(CleanupExpiredCodes, now)
+	if err != nil {
+		return resp.ErrCouldNotCleanupCodes
+	}
+	amt, _ := rs.RowsAffected()
+	repo.log.Info("authorization code cleaner deleted: %d codes", amt)
+	return nil
+}
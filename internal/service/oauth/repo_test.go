@@ -0,0 +1,75 @@
+package oauth
+
+import (
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HappyPath(t *testing.T) {
+	repo := NewOAuthRepo(cfg.TestConfig())
+	userId := int64(5)
+
+	// A developer registers their app
+	assert.NoError(t, repo.storeClient("desktop", userId, "wordbubble for desktop", "https://app.example.com/callback", 100))
+	found, err := repo.getClient("desktop")
+	assert.NoError(t, err)
+	assert.Equal(t, &client{id: "desktop", name: "wordbubble for desktop", redirectUri: "https://app.example.com/callback"}, found)
+
+	// A user approves the app, and the app redeems the code
+	code := &authorizationCode{
+		clientId:      "desktop",
+		userId:        7,
+		redirectUri:   "https://app.example.com/callback",
+		scope:         "wordbubble:push",
+		codeChallenge: "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		expiresAt:     200,
+	}
+	assert.NoError(t, repo.storeAuthorizationCode("hash.code", code))
+	redeemed, err := repo.redeemAuthorizationCode("hash.code")
+	assert.NoError(t, err)
+	assert.Equal(t, code, redeemed)
+
+	// The code can't be redeemed twice
+	redeemed, err = repo.redeemAuthorizationCode("hash.code")
+	assert.Nil(t, redeemed)
+	assert.Equal(t, resp.ErrInvalidAuthorizationCode, err)
+
+	// Codes that were never redeemed are cleaned up once they expire
+	assert.NoError(t, repo.storeAuthorizationCode("hash.expired", &authorizationCode{clientId: "desktop", expiresAt: 200}))
+	assert.NoError(t, repo.storeAuthorizationCode("hash.active", &authorizationCode{clientId: "desktop", expiresAt: 400}))
+	assert.NoError(t, repo.CleanupExpiredCodes(300))
+	_, err = repo.redeemAuthorizationCode("hash.expired")
+	assert.Equal(t, resp.ErrInvalidAuthorizationCode, err)
+	_, err = repo.redeemAuthorizationCode("hash.active")
+	assert.NoError(t, err)
+}
+
+func Test_NotSoHappyPath(t *testing.T) {
+	repo := NewOAuthRepo(cfg.TestConfig())
+
+	// unknown client
+	found, err := repo.getClient("desktop")
+	assert.Nil(t, found)
+	assert.Equal(t, resp.ErrUnknownClient, err)
+
+	// db closed
+	repo.db.Close()
+	err = repo.storeClient("desktop", 5, "wordbubble for desktop", "https://app.example.com/callback", 100)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotStoreClient.Error(), err.Error())
+
+	err = repo.storeAuthorizationCode("hash.code", &authorizationCode{})
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotStoreAuthorizationCode.Error(), err.Error())
+
+	_, err = repo.redeemAuthorizationCode("hash.code")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotUseAuthorizationCode.Error(), err.Error())
+
+	err = repo.CleanupExpiredCodes(300)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCleanupCodes.Error(), err.Error())
+}
@@ -0,0 +1,128 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type client struct {
+	id          string
+	name        string
+	redirectUri string
+}
+
+type authorizationCode struct {
+	clientId      string
+	userId        int64
+	redirectUri   string
+	scope         string
+	codeChallenge string
+	expiresAt     int64
+}
+
+type oauthService struct {
+	log   util.Logger
+	timer util.Timer
+	repo  OAuthRepo
+}
+
+func NewOAuthService(cfg cfg.Config, repo OAuthRepo) *oauthService {
+	return &oauthService{
+		log:   cfg.NewLogger("oauth"),
+		timer: cfg.Timer(),
+		repo:  repo,
+	}
+}
+
+func (svc *oauthService) RegisterClient(userId int64, name, redirectUri string) (*resp.RegisterClientResponse, error) {
+	name = strings.TrimSpace(name)
+	if err := util.ValidClientName(name); err != nil {
+		return nil, err
+	}
+	if err := util.ValidRedirectUri(redirectUri); err != nil {
+		return nil, err
+	}
+	clientId := util.RandomString(16)
+	createdAt := svc.timer.Now().Unix()
+	if err := svc.repo.storeClient(clientId, userId, name, redirectUri, createdAt); err != nil {
+		return nil, err
+	}
+	svc.log.Info("registered oauth client: %s for user: %d", clientId, userId)
+	return &resp.RegisterClientResponse{
+		ClientId:    clientId,
+		Name:        name,
+		RedirectUri: redirectUri,
+		CreatedAt:   createdAt,
+	}, nil
+}
+
+func (svc *oauthService) ValidateAuthorizeRequest(request *req.AuthorizeRequest) (*resp.ConsentResponse, error) {
+	if request.ResponseType != ResponseType {
+		return nil, resp.ErrUnsupportedResponseType
+	}
+	found, err := svc.repo.getClient(request.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	if request.RedirectUri != found.redirectUri { // exact match, so a code can't be sent anywhere else
+		return nil, resp.ErrRedirectUriMismatch
+	}
+	if request.CodeChallengeMethod != CodeChallengeMethod || len(request.CodeChallenge) != codeChallengeLength {
+		return nil, resp.ErrInvalidCodeChallenge
+	}
+	scope := DefaultScope
+	if request.Scope != "" {
+		if scope, err = util.ValidScope(request.Scope); err != nil {
+			return nil, err
+		}
+	}
+	return &resp.ConsentResponse{
+		ClientId:    found.id,
+		ClientName:  found.name,
+		RedirectUri: found.redirectUri,
+		Scope:       scope,
+	}, nil
+}
+
+func (svc *oauthService) CreateAuthorizationCode(userId int64, clientId, redirectUri, scope, codeChallenge string) (string, error) {
+	code := util.RandomString(32)
+	if err := svc.repo.storeAuthorizationCode(util.HashToken(code), &authorizationCode{
+		clientId:      clientId,
+		userId:        userId,
+		redirectUri:   redirectUri,
+		scope:         scope,
+		codeChallenge: codeChallenge,
+		expiresAt:     svc.timer.Now().Unix() + codeTimeLimit,
+	}); err != nil {
+		return "", err
+	}
+	svc.log.Info("user: %d authorized oauth client: %s", userId, clientId)
+	return code, nil
+}
+
+func (svc *oauthService) ExchangeAuthorizationCode(clientId, code, redirectUri, codeVerifier string) (int64, string, error) {
+	found, err := svc.repo.redeemAuthorizationCode(util.HashToken(code))
+	if err != nil {
+		return 0, "", err
+	}
+	if found.clientId != clientId || found.redirectUri != redirectUri || found.expiresAt <= svc.timer.Now().Unix() {
+		return 0, "", resp.ErrInvalidAuthorizationCode
+	}
+	if subtle.ConstantTimeCompare([]byte(codeChallenge(codeVerifier)), []byte(found.codeChallenge)) != 1 {
+		return 0, "", resp.ErrInvalidCodeVerifier
+	}
+	return found.userId, found.scope, nil
+}
+
+// codeChallenge derives the S256 challenge of a code verifier, as defined by RFC 7636
+func codeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
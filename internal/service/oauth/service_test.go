@@ -0,0 +1,312 @@
+package oauth
+
+import (
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// verifier and challenge from the example in RFC 7636 appendix B
+const (
+	testCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func Test_RegisterClient(t *testing.T) {
+	tests := map[string]struct {
+		name         string
+		redirectUri  string
+		repo         *testOAuthRepo
+		expectedName string
+		expectedErr  error
+	}{
+		"valid": {
+			name:         " wordbubble for desktop ",
+			redirectUri:  "https://app.example.com/callback",
+			repo:         &testOAuthRepo{},
+			expectedName: "wordbubble for desktop",
+		},
+		"invalid, no name": {
+			name:        "",
+			redirectUri: "https://app.example.com/callback",
+			repo:        &testOAuthRepo{},
+			expectedErr: resp.ErrClientNameIsMissing,
+		},
+		"invalid, redirect uri": {
+			name:        "wordbubble for desktop",
+			redirectUri: "http://app.example.com/callback",
+			repo:        &testOAuthRepo{},
+			expectedErr: resp.ErrInvalidRedirectUri,
+		},
+		"invalid, database error": {
+			name:        "wordbubble for desktop",
+			redirectUri: "https://app.example.com/callback",
+			repo: &testOAuthRepo{
+				errStore: resp.ErrCouldNotStoreClient,
+			},
+			expectedErr: resp.ErrCouldNotStoreClient,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(1000))
+			svc := NewOAuthService(cfg, tcase.repo)
+			client, err := svc.RegisterClient(5, tcase.name, tcase.redirectUri)
+			if tcase.expectedErr != nil {
+				assert.Equal(t, tcase.expectedErr, err)
+				assert.Nil(t, client)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.expectedName, client.Name)
+			assert.Equal(t, tcase.redirectUri, client.RedirectUri)
+			assert.Equal(t, int64(1000), client.CreatedAt)
+			assert.Equal(t, tcase.repo.storedClientId, client.ClientId)
+		})
+	}
+}
+
+func Test_ValidateAuthorizeRequest(t *testing.T) {
+	valid := func() *req.AuthorizeRequest {
+		return &req.AuthorizeRequest{
+			ResponseType:        "code",
+			ClientId:            "desktop",
+			RedirectUri:         "https://app.example.com/callback",
+			CodeChallenge:       testCodeChallenge,
+			CodeChallengeMethod: "S256",
+		}
+	}
+	tests := map[string]struct {
+		modify        func(request *req.AuthorizeRequest)
+		repo          *testOAuthRepo
+		expectedScope string
+		expectedErr   error
+	}{
+		"valid, default scope": {
+			modify:        func(request *req.AuthorizeRequest) {},
+			expectedScope: "wordbubble:push wordbubble:read",
+		},
+		"valid, scope requested": {
+			modify:        func(request *req.AuthorizeRequest) { request.Scope = "wordbubble:read" },
+			expectedScope: "wordbubble:read",
+		},
+		"invalid, response type": {
+			modify:      func(request *req.AuthorizeRequest) { request.ResponseType = "token" },
+			expectedErr: resp.ErrUnsupportedResponseType,
+		},
+		"invalid, unknown client": {
+			modify: func(request *req.AuthorizeRequest) {},
+			repo: &testOAuthRepo{
+				errGet: resp.ErrUnknownClient,
+			},
+			expectedErr: resp.ErrUnknownClient,
+		},
+		"invalid, redirect uri doesn't match": {
+			modify:      func(request *req.AuthorizeRequest) { request.RedirectUri = "https://evil.example.com/callback" },
+			expectedErr: resp.ErrRedirectUriMismatch,
+		},
+		"invalid, plain code challenge": {
+			modify:      func(request *req.AuthorizeRequest) { request.CodeChallengeMethod = "plain" },
+			expectedErr: resp.ErrInvalidCodeChallenge,
+		},
+		"invalid, no code challenge": {
+			modify:      func(request *req.AuthorizeRequest) { request.CodeChallenge = "" },
+			expectedErr: resp.ErrInvalidCodeChallenge,
+		},
+		"invalid, unknown scope": {
+			modify:      func(request *req.AuthorizeRequest) { request.Scope = "wordbubble:delete" },
+			expectedErr: resp.ErrInvalidScope,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repo := tcase.repo
+			if repo == nil {
+				repo = &testOAuthRepo{client: &client{id: "desktop", name: "wordbubble for desktop", redirectUri: "https://app.example.com/callback"}}
+			}
+			svc := NewOAuthService(cfg.TestConfig(), repo)
+			request := valid()
+			tcase.modify(request)
+			consent, err := svc.ValidateAuthorizeRequest(request)
+			if tcase.expectedErr != nil {
+				assert.Equal(t, tcase.expectedErr, err)
+				assert.Nil(t, consent)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, &resp.ConsentResponse{
+				ClientId:    "desktop",
+				ClientName:  "wordbubble for desktop",
+				RedirectUri: "https://app.example.com/callback",
+				Scope:       tcase.expectedScope,
+			}, consent)
+		})
+	}
+}
+
+func Test_CreateAuthorizationCode(t *testing.T) {
+	tests := map[string]struct {
+		repo        *testOAuthRepo
+		expectedErr error
+	}{
+		"valid": {
+			repo: &testOAuthRepo{},
+		},
+		"invalid, database error": {
+			repo: &testOAuthRepo{
+				errStore: resp.ErrCouldNotStoreAuthorizationCode,
+			},
+			expectedErr: resp.ErrCouldNotStoreAuthorizationCode,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(1000))
+			svc := NewOAuthService(cfg, tcase.repo)
+			code, err := svc.CreateAuthorizationCode(7, "desktop", "https://app.example.com/callback", "wordbubble:push", testCodeChallenge)
+			if tcase.expectedErr != nil {
+				assert.Equal(t, tcase.expectedErr, err)
+				assert.Empty(t, code)
+				return
+			}
+			assert.NoError(t, err)
+			// only the hash of the code is stored
+			assert.Equal(t, util.HashToken(code), tcase.repo.storedCodeHash)
+			assert.Equal(t, &authorizationCode{
+				clientId:      "desktop",
+				userId:        7,
+				redirectUri:   "https://app.example.com/callback",
+				scope:         "wordbubble:push",
+				codeChallenge: testCodeChallenge,
+				expiresAt:     1000 + codeTimeLimit,
+			}, tcase.repo.storedCode)
+		})
+	}
+}
+
+func Test_ExchangeAuthorizationCode(t *testing.T) {
+	tests := map[string]struct {
+		clientId       string
+		redirectUri    string
+		codeVerifier   string
+		now            int64
+		repo           *testOAuthRepo
+		expectedUserId int64
+		expectedScope  string
+		expectedErr    error
+	}{
+		"valid": {
+			clientId:       "desktop",
+			redirectUri:    "https://app.example.com/callback",
+			codeVerifier:   testCodeVerifier,
+			now:            1000,
+			expectedUserId: 7,
+			expectedScope:  "wordbubble:push",
+		},
+		"invalid, code is expired": {
+			clientId:     "desktop",
+			redirectUri:  "https://app.example.com/callback",
+			codeVerifier: testCodeVerifier,
+			now:          1000 + codeTimeLimit,
+			expectedErr:  resp.ErrInvalidAuthorizationCode,
+		},
+		"invalid, code was issued to another client": {
+			clientId:     "mobile",
+			redirectUri:  "https://app.example.com/callback",
+			codeVerifier: testCodeVerifier,
+			now:          1000,
+			expectedErr:  resp.ErrInvalidAuthorizationCode,
+		},
+		"invalid, redirect uri doesn't match": {
+			clientId:     "desktop",
+			redirectUri:  "https://app.example.com/other",
+			codeVerifier: testCodeVerifier,
+			now:          1000,
+			expectedErr:  resp.ErrInvalidAuthorizationCode,
+		},
+		"invalid, code verifier doesn't match": {
+			clientId:     "desktop",
+			redirectUri:  "https://app.example.com/callback",
+			codeVerifier: "stolen code without the verifier",
+			now:          1000,
+			expectedErr:  resp.ErrInvalidCodeVerifier,
+		},
+		"invalid, code was already redeemed": {
+			clientId:     "desktop",
+			redirectUri:  "https://app.example.com/callback",
+			codeVerifier: testCodeVerifier,
+			now:          1000,
+			repo: &testOAuthRepo{
+				errRedeem: resp.ErrInvalidAuthorizationCode,
+			},
+			expectedErr: resp.ErrInvalidAuthorizationCode,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repo := tcase.repo
+			if repo == nil {
+				repo = &testOAuthRepo{code: &authorizationCode{
+					clientId:      "desktop",
+					userId:        7,
+					redirectUri:   "https://app.example.com/callback",
+					scope:         "wordbubble:push",
+					codeChallenge: testCodeChallenge,
+					expiresAt:     1000 + codeTimeLimit - 1,
+				}}
+			}
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(tcase.now))
+			svc := NewOAuthService(cfg, repo)
+			userId, scope, err := svc.ExchangeAuthorizationCode(tcase.clientId, "9a0364b9", tcase.redirectUri, tcase.codeVerifier)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedUserId, userId)
+			assert.Equal(t, tcase.expectedScope, scope)
+			assert.Equal(t, util.HashToken("9a0364b9"), repo.redeemedCodeHash)
+		})
+	}
+}
+
+type testOAuthRepo struct {
+	errStore         error
+	storedClientId   string
+	storedCodeHash   string
+	storedCode       *authorizationCode
+	client           *client
+	errGet           error
+	code             *authorizationCode
+	errRedeem        error
+	redeemedCodeHash string
+}
+
+func (trepo *testOAuthRepo) storeClient(clientId string, userId int64, name, redirectUri string, createdAt int64) error {
+	trepo.storedClientId = clientId
+	return trepo.errStore
+}
+
+func (trepo *testOAuthRepo) getClient(clientId string) (*client, error) {
+	if trepo.errGet != nil {
+		return nil, trepo.errGet
+	}
+	return trepo.client, nil
+}
+
+func (trepo *testOAuthRepo) storeAuthorizationCode(codeHash string, code *authorizationCode) error {
+	trepo.storedCodeHash = codeHash
+	trepo.storedCode = code
+	return trepo.errStore
+}
+
+func (trepo *testOAuthRepo) redeemAuthorizationCode(codeHash string) (*authorizationCode, error) {
+	trepo.redeemedCodeHash = codeHash
+	if trepo.errRedeem != nil {
+		return nil, trepo.errRedeem
+	}
+	return trepo.code, nil
+}
@@ -0,0 +1,19 @@
+package oidc
+
+import (
+	"context"
+
+	"github.com/bchadwic/wordbubble/internal/job"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// NewStateCleanupJob creates a job that removes the states of logins at the provider that were never finished
+func NewStateCleanupJob(timer util.Timer, cleaner OidcCleaner) job.Job {
+	return job.Job{
+		Name:     "oidc_state_cleanup",
+		Interval: StateCleanerRate,
+		Run: func(ctx context.Context) error {
+			return cleaner.CleanupExpiredOidcStates(timer.Now().Unix())
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package oidc
+
+import (
+	"time"
+
+	"github.com/bchadwic/wordbubble/model"
+)
+
+const (
+	// DiscoveryPath is where a provider serves its discovery document, below its issuer
+	DiscoveryPath = "/.well-known/openid-configuration"
+	// RequestedScope is what's asked of the provider, enough to identify the user and find their email
+	RequestedScope = "openid email profile"
+	// stateTimeLimit is how long a user has to login at the provider, in seconds
+	stateTimeLimit = 10 * 60
+	// keyRefreshInterval is how often the keys of the provider can be fetched again, when an id token is signed with an unknown key
+	keyRefreshInterval = time.Minute
+	providerTimeout    = 10 * time.Second
+	StateCleanerRate   = 10 * time.Minute
+
+	StoreOidcState    = `INSERT INTO oidc_states (state_hash, nonce, code_verifier, link_user_id, scope, remember_me, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	GetOidcState      = `SELECT nonce, code_verifier, link_user_id, scope, remember_me, expires_at FROM oidc_states WHERE state_hash = $1`
+	RedeemOidcState   = `DELETE FROM oidc_states WHERE state_hash = $1`
+	CleanupOidcStates = `DELETE FROM oidc_states WHERE expires_at < $1`
+	GetLinkedUser     = `SELECT user_id FROM oidc_identities WHERE issuer = $1 AND subject = $2`
+	LinkIdentity      = `INSERT INTO oidc_identities (issuer, subject, user_id, created_at) VALUES ($1, $2, $3, $4)`
+)
+
+// OidcService is the interface that the application
+// uses to let users login with an external OpenID Connect provider
+type OidcService interface {
+	// AuthorizationUrl starts a login at the provider, the user is sent to the url returned and comes back to the redirect uri.
+	// When a user id is passed, the identity is linked to that user instead of logging in.
+	// string is the url of the provider's authorization endpoint, or empty string.
+	// error could be (404) resp.ErrOidcNotConfigured, (500) resp.ErrCouldNotReachOidcProvider,
+	// (500) resp.ErrCouldNotStoreOidcState or nil.
+	AuthorizationUrl(linkUserId int64, scope string, rememberMe bool) (string, error)
+	// CompleteLogin redeems the state of a login, then exchanges the code for an id token that's verified with the provider's keys.
+	// *model.FederatedLogin is who the user is at the provider, and what was asked for when the login started, can be nil.
+	// error could be (404) resp.ErrOidcNotConfigured, (401) resp.ErrInvalidOidcState, (401) resp.ErrOidcCodeRejected,
+	// (401) resp.ErrInvalidIdToken, (500) resp.ErrCouldNotUseOidcState, (500) resp.ErrCouldNotReachOidcProvider or nil.
+	CompleteLogin(code, state string) (*model.FederatedLogin, error)
+	// LinkedUser finds the user an identity is linked to.
+	// int64 is the user id, or zero when the identity isn't linked.
+	// error could be (500) resp.ErrCouldNotCheckIdentity or nil.
+	LinkedUser(identity *model.FederatedIdentity) (int64, error)
+	// LinkIdentity links an identity to a user, so the user can login with it. Linking an identity to the same user again does nothing.
+	// error could be (409) resp.ErrIdentityAlreadyLinked, (500) resp.ErrCouldNotCheckIdentity, (500) resp.ErrCouldNotLinkIdentity or nil.
+	LinkIdentity(userId int64, identity *model.FederatedIdentity) error
+}
+
+// OidcRepo is the interface that the service layer
+// uses to interact with login states and linked identities in the database
+type OidcRepo interface {
+	// storeState stores the hash of the state of a login at the provider.
+	// error can be (500) resp.ErrCouldNotStoreOidcState or nil.
+	storeState(stateHash string, state *loginState) error
+	// redeemState finds the state with the hash passed and removes it, so it can only be redeemed once.
+	// *loginState is the state redeemed, can be nil.
+	// error can be (401) resp.ErrInvalidOidcState, (500) resp.ErrCouldNotUseOidcState or nil.
+	redeemState(stateHash string) (*loginState, error)
+	// getLinkedUser finds the user an identity is linked to.
+	// int64 is the user id, or zero when the identity isn't linked.
+	// error can be (500) resp.ErrCouldNotCheckIdentity or nil.
+	getLinkedUser(issuer, subject string) (int64, error)
+	// linkIdentity links an identity to a user.
+	// error can be (500) resp.ErrCouldNotLinkIdentity or nil.
+	linkIdentity(issuer, subject string, userId, createdAt int64) error
+}
+
+// OidcCleaner is the interface that the application
+// uses to clean up the states of logins that were never finished
+type OidcCleaner interface {
+	// CleanupExpiredOidcStates remove any login states from the database that were never redeemed and are expired.
+	// error can be (500) resp.ErrCouldNotCleanupOidcStates or nil
+	CleanupExpiredOidcStates(now int64) error
+}
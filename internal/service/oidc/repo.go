@@ -0,0 +1,81 @@
+package oidc
+
+import (
+	"database/sql"
+	"errors"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type oidcRepo struct {
+	db  *sql.DB
+	log util.Logger
+}
+
+func NewOidcRepo(config cfg.Config) *oidcRepo {
+	return &oidcRepo{
+		log: config.NewLogger("oidc_repo"),
+		db:  config.DB(),
+	}
+}
+
+func (repo *oidcRepo) storeState(stateHash string, state *loginState) error {
+	if _, err := repo.db.Exec(StoreOidcState, stateHash, state.nonce, state.codeVerifier, state.linkUserId, state.scope, state.rememberMe, state.expiresAt); err != nil {
+		repo.log.Error("could not store oidc state, error: %s", err)
+		return resp.ErrCouldNotStoreOidcState
+	}
+	return nil
+}
+
+func (repo *oidcRepo) redeemState(stateHash string) (*loginState, error) {
+	row := repo.db.QueryRow(GetOidcState, stateHash)
+	var state loginState
+	if err := row.Scan(&state.nonce, &state.codeVerifier, &state.linkUserId, &state.scope, &state.rememberMe, &state.expiresAt); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			repo.log.Error("could not find oidc state, error: %s", err)
+			return nil, resp.ErrCouldNotUseOidcState
+		}
+		return nil, resp.ErrInvalidOidcState
+	}
+	rs, err := repo.db.Exec(RedeemOidcState, stateHash)
+	if err != nil {
+		repo.log.Error("could not redeem oidc state, error: %s", err)
+		return nil, resp.ErrCouldNotUseOidcState
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 { // the state was redeemed by another request in the meantime
+		return nil, resp.ErrInvalidOidcState
+	}
+	return &state, nil
+}
+
+func (repo *oidcRepo) getLinkedUser(issuer, subject string) (int64, error) {
+	var userId int64
+	if err := repo.db.QueryRow(GetLinkedUser, issuer, subject).Scan(&userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		repo.log.Error("could not find linked identity, error: %s", err)
+		return 0, resp.ErrCouldNotCheckIdentity
+	}
+	return userId, nil
+}
+
+func (repo *oidcRepo) linkIdentity(issuer, subject string, userId, createdAt int64) error {
+	if _, err := repo.db.Exec(LinkIdentity, issuer, subject, userId, createdAt); err != nil {
+		repo.log.Error("could not link identity to user: %d, error: %s", userId, err)
+		return resp.ErrCouldNotLinkIdentity
+	}
+	return nil
+}
+
+func (repo *oidcRepo) CleanupExpiredOidcStates(now int64) error {
+	rs, err := repo.db.Exec(CleanupOidcStates, now)
+	if err != nil {
+		return resp.ErrCouldNotCleanupOidcStates
+	}
+	amt, _ := rs.RowsAffected()
+	repo.log.Info("oidc state cleaner deleted: %d states", amt)
+	return nil
+}
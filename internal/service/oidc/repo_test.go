@@ -0,0 +1,73 @@
+package oidc
+
+import (
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HappyPath(t *testing.T) {
+	repo := NewOidcRepo(cfg.TestConfig())
+	issuer, subject := "https://accounts.example.com", "248289761001"
+
+	// A user starts a login, then comes back from the provider, the state can only be redeemed once
+	state := &loginState{nonce: "nonce", codeVerifier: "verifier", linkUserId: 5, scope: "wordbubble:push", rememberMe: true, expiresAt: 400}
+	assert.NoError(t, repo.storeState("hash.state", state))
+	redeemed, err := repo.redeemState("hash.state")
+	assert.NoError(t, err)
+	assert.Equal(t, state, redeemed)
+	_, err = repo.redeemState("hash.state")
+	assert.Equal(t, resp.ErrInvalidOidcState, err)
+
+	// The identity isn't linked until the user links it
+	userId, err := repo.getLinkedUser(issuer, subject)
+	assert.NoError(t, err)
+	assert.Zero(t, userId)
+	assert.NoError(t, repo.linkIdentity(issuer, subject, 5, 100))
+	userId, err = repo.getLinkedUser(issuer, subject)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), userId)
+	userId, err = repo.getLinkedUser("https://another.example.com", subject)
+	assert.NoError(t, err)
+	assert.Zero(t, userId)
+
+	// An identity can only be linked to one user
+	assert.Equal(t, resp.ErrCouldNotLinkIdentity, repo.linkIdentity(issuer, subject, 6, 100))
+
+	// States of logins that were never finished are cleaned up once they expire
+	assert.NoError(t, repo.storeState("hash.expired", &loginState{expiresAt: 200}))
+	assert.NoError(t, repo.storeState("hash.active", &loginState{expiresAt: 400}))
+	assert.NoError(t, repo.CleanupExpiredOidcStates(300))
+	_, err = repo.redeemState("hash.expired")
+	assert.Equal(t, resp.ErrInvalidOidcState, err)
+	_, err = repo.redeemState("hash.active")
+	assert.NoError(t, err)
+}
+
+func Test_NotSoHappyPath(t *testing.T) {
+	repo := NewOidcRepo(cfg.TestConfig())
+
+	// db closed
+	repo.db.Close()
+	err := repo.storeState("hash.state", &loginState{})
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotStoreOidcState.Error(), err.Error())
+
+	_, err = repo.redeemState("hash.state")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotUseOidcState.Error(), err.Error())
+
+	_, err = repo.getLinkedUser("https://accounts.example.com", "248289761001")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCheckIdentity.Error(), err.Error())
+
+	err = repo.linkIdentity("https://accounts.example.com", "248289761001", 5, 100)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotLinkIdentity.Error(), err.Error())
+
+	err = repo.CleanupExpiredOidcStates(300)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCleanupOidcStates.Error(), err.Error())
+}
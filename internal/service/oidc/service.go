@@ -0,0 +1,368 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+	"github.com/golang-jwt/jwt"
+)
+
+type loginState struct {
+	nonce        string
+	codeVerifier string
+	linkUserId   int64
+	scope        string
+	rememberMe   bool
+	expiresAt    int64
+}
+
+// discovery is the part of a provider's discovery document needed to login
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// providerKey is a public key the provider signs id tokens with
+type providerKey struct {
+	public    interface{}
+	algorithm string
+}
+
+// audience is the aud claim of an id token, which is either a single string or an array of strings
+type audience []string
+
+func (aud *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*aud = audience{single}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(aud))
+}
+
+func (aud audience) contains(clientId string) bool {
+	for _, a := range aud {
+		if a == clientId {
+			return true
+		}
+	}
+	return false
+}
+
+type idTokenClaims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp,omitempty"`
+	ExpiresAt         int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email,omitempty"`
+	EmailVerified     bool     `json:"email_verified,omitempty"`
+	PreferredUsername string   `json:"preferred_username,omitempty"`
+}
+
+// Valid is never used, the claims are checked in verifyIdToken with the leeway of the api
+func (claims *idTokenClaims) Valid() error {
+	return nil
+}
+
+type oidcService struct {
+	log      util.Logger
+	timer    util.Timer
+	repo     OidcRepo
+	provider util.OidcProvider
+	leeway   int64
+	client   *http.Client
+
+	mu            sync.Mutex
+	discovery     *discovery
+	keys          map[string]providerKey
+	keysFetchedAt time.Time
+}
+
+func NewOidcService(cfg cfg.Config, repo OidcRepo) *oidcService {
+	return &oidcService{
+		log:      cfg.NewLogger("oidc"),
+		timer:    cfg.Timer(),
+		repo:     repo,
+		provider: cfg.OidcProvider(),
+		leeway:   int64(cfg.Keys().Policy().Leeway / time.Second),
+		client:   &http.Client{Timeout: providerTimeout},
+	}
+}
+
+func (svc *oidcService) AuthorizationUrl(linkUserId int64, scope string, rememberMe bool) (string, error) {
+	if !svc.provider.Enabled() {
+		return "", resp.ErrOidcNotConfigured
+	}
+	disc, err := svc.discover()
+	if err != nil {
+		return "", err
+	}
+	state := util.RandomString(32)
+	login := &loginState{
+		nonce:        util.RandomString(32),
+		codeVerifier: util.RandomString(32),
+		linkUserId:   linkUserId,
+		scope:        scope,
+		rememberMe:   rememberMe,
+		expiresAt:    svc.timer.Now().Unix() + stateTimeLimit,
+	}
+	if err = svc.repo.storeState(util.HashToken(state), login); err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(login.codeVerifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {svc.provider.ClientId},
+		"redirect_uri":          {svc.provider.RedirectUri},
+		"scope":                 {RequestedScope},
+		"state":                 {state},
+		"nonce":                 {login.nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	authorizationUrl, err := url.Parse(disc.AuthorizationEndpoint)
+	if err != nil { // endpoints are checked when the discovery document is read
+		return "", resp.ErrCouldNotReachOidcProvider
+	}
+	query := authorizationUrl.Query()
+	for key := range params {
+		query.Set(key, params.Get(key))
+	}
+	authorizationUrl.RawQuery = query.Encode()
+	return authorizationUrl.String(), nil
+}
+
+func (svc *oidcService) CompleteLogin(code, state string) (*model.FederatedLogin, error) {
+	if !svc.provider.Enabled() {
+		return nil, resp.ErrOidcNotConfigured
+	}
+	login, err := svc.repo.redeemState(util.HashToken(state))
+	if err != nil {
+		return nil, err
+	}
+	if login.expiresAt <= svc.timer.Now().Unix() {
+		return nil, resp.ErrInvalidOidcState
+	}
+	disc, err := svc.discover()
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := svc.exchangeCode(disc, code, login.codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := svc.verifyIdToken(disc, idToken, login.nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &model.FederatedLogin{
+		Identity: model.FederatedIdentity{
+			Issuer:        claims.Issuer,
+			Subject:       claims.Subject,
+			Email:         claims.Email,
+			EmailVerified: claims.EmailVerified,
+			Username:      claims.PreferredUsername,
+		},
+		LinkUserId: login.linkUserId,
+		Scope:      login.scope,
+		RememberMe: login.rememberMe,
+	}, nil
+}
+
+func (svc *oidcService) LinkedUser(identity *model.FederatedIdentity) (int64, error) {
+	return svc.repo.getLinkedUser(identity.Issuer, identity.Subject)
+}
+
+func (svc *oidcService) LinkIdentity(userId int64, identity *model.FederatedIdentity) error {
+	linkedUserId, err := svc.repo.getLinkedUser(identity.Issuer, identity.Subject)
+	if err != nil {
+		return err
+	}
+	if linkedUserId == userId {
+		return nil
+	}
+	if linkedUserId != 0 {
+		svc.log.Warn("user: %d tried to link an identity that's linked to user: %d", userId, linkedUserId)
+		return resp.ErrIdentityAlreadyLinked
+	}
+	if err = svc.repo.linkIdentity(identity.Issuer, identity.Subject, userId, svc.timer.Now().Unix()); err != nil {
+		return err
+	}
+	svc.log.Info("linked identity to user: %d", userId)
+	return nil
+}
+
+// discover reads the provider's discovery document, it's only read once since the endpoints rarely change
+func (svc *oidcService) discover() (*discovery, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if svc.discovery != nil {
+		return svc.discovery, nil
+	}
+	var disc discovery
+	if err := svc.getJson(strings.TrimSuffix(svc.provider.Issuer, "/")+DiscoveryPath, &disc); err != nil {
+		return nil, err
+	}
+	if disc.Issuer != svc.provider.Issuer || disc.AuthorizationEndpoint == "" || disc.TokenEndpoint == "" || disc.JwksUri == "" {
+		svc.log.Error("discovery document of the oidc provider is missing endpoints or is for another issuer: %s", disc.Issuer)
+		return nil, resp.ErrCouldNotReachOidcProvider
+	}
+	svc.discovery = &disc
+	return svc.discovery, nil
+}
+
+// exchangeCode sends the code and its verifier to the provider's token endpoint, authenticating with the client secret when there is one.
+// string is the id token returned, or empty string.
+// error could be (401) resp.ErrOidcCodeRejected, (401) resp.ErrInvalidIdToken, (500) resp.ErrCouldNotReachOidcProvider or nil.
+func (svc *oidcService) exchangeCode(disc *discovery, code, codeVerifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {svc.provider.RedirectUri},
+		"client_id":     {svc.provider.ClientId},
+		"code_verifier": {codeVerifier},
+	}
+	request, err := http.NewRequest(http.MethodPost, disc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", resp.ErrCouldNotReachOidcProvider
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if svc.provider.ClientSecret != "" { // RFC 6749 section 2.3.1, the credentials are form encoded before they're sent as basic auth
+		request.SetBasicAuth(url.QueryEscape(svc.provider.ClientId), url.QueryEscape(svc.provider.ClientSecret))
+	}
+	response, err := svc.client.Do(request)
+	if err != nil {
+		svc.log.Error("could not reach the token endpoint of the oidc provider, error: %s", err)
+		return "", resp.ErrCouldNotReachOidcProvider
+	}
+	defer response.Body.Close()
+	switch {
+	case response.StatusCode == http.StatusBadRequest || response.StatusCode == http.StatusUnauthorized:
+		return "", resp.ErrOidcCodeRejected
+	case response.StatusCode != http.StatusOK:
+		svc.log.Error("token endpoint of the oidc provider responded with: %d", response.StatusCode)
+		return "", resp.ErrCouldNotReachOidcProvider
+	}
+	var tokens struct {
+		IdToken string `json:"id_token"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&tokens); err != nil || tokens.IdToken == "" {
+		return "", resp.ErrInvalidIdToken
+	}
+	return tokens.IdToken, nil
+}
+
+// verifyIdToken checks the signature of an id token with the provider's keys, then that it was issued by the provider,
+// for this client and this login, and hasn't expired.
+// *idTokenClaims is the claims of the id token, can be nil.
+// error could be (401) resp.ErrInvalidIdToken, (500) resp.ErrCouldNotReachOidcProvider or nil.
+func (svc *oidcService) verifyIdToken(disc *discovery, idToken, nonce string) (*idTokenClaims, error) {
+	var claims idTokenClaims
+	var keyErr error
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(idToken, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := svc.providerKey(disc, kid)
+		if err != nil {
+			keyErr = err
+			return nil, err
+		}
+		if key == nil || t.Method.Alg() != key.algorithm {
+			return nil, resp.ErrInvalidIdToken
+		}
+		return key.public, nil
+	})
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	if err != nil {
+		svc.log.Warn("id token from the oidc provider could not be verified, error: %s", err)
+		return nil, resp.ErrInvalidIdToken
+	}
+	now := svc.timer.Now().Unix()
+	valid := claims.Issuer == disc.Issuer && claims.Subject != "" && claims.Audience.contains(svc.provider.ClientId) &&
+		(claims.AuthorizedParty == "" || claims.AuthorizedParty == svc.provider.ClientId) &&
+		claims.ExpiresAt != 0 && now-svc.leeway <= claims.ExpiresAt && now+svc.leeway >= claims.IssuedAt &&
+		subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) == 1
+	if valid {
+		return &claims, nil
+	}
+	svc.log.Warn("id token from the oidc provider has invalid claims")
+	return nil, resp.ErrInvalidIdToken
+}
+
+// providerKey finds the provider's key with the id passed, the keys are fetched again when none match since the provider may have rotated them.
+// A token without a key id can only be verified when the provider has a single key.
+// *providerKey is the key found, can be nil.
+// error could be (500) resp.ErrCouldNotReachOidcProvider or nil.
+func (svc *oidcService) providerKey(disc *discovery, kid string) (*providerKey, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	key, ok := svc.findKey(kid)
+	if !ok && svc.timer.Now().Sub(svc.keysFetchedAt) >= keyRefreshInterval {
+		var jwks resp.JWKSResponse
+		if err := svc.getJson(disc.JwksUri, &jwks); err != nil {
+			return nil, err
+		}
+		svc.keys = make(map[string]providerKey, len(jwks.Keys))
+		for _, jwk := range jwks.Keys {
+			public, algorithm, err := util.PublicKeyFromJWK(jwk)
+			if err != nil || (jwk.Use != "" && jwk.Use != "sig") {
+				continue // keys that can't verify an id token are skipped
+			}
+			svc.keys[jwk.Kid] = providerKey{public: public, algorithm: algorithm}
+		}
+		svc.keysFetchedAt = svc.timer.Now()
+		key, ok = svc.findKey(kid)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &key, nil
+}
+
+func (svc *oidcService) findKey(kid string) (providerKey, bool) {
+	if kid == "" && len(svc.keys) == 1 {
+		for _, key := range svc.keys {
+			return key, true
+		}
+	}
+	key, ok := svc.keys[kid]
+	return key, ok
+}
+
+// getJson reads a json document from the provider
+// error could be (500) resp.ErrCouldNotReachOidcProvider or nil.
+func (svc *oidcService) getJson(uri string, v interface{}) error {
+	response, err := svc.client.Get(uri)
+	if err != nil {
+		svc.log.Error("could not reach the oidc provider, error: %s", err)
+		return resp.ErrCouldNotReachOidcProvider
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		svc.log.Error("oidc provider responded with: %d from: %s", response.StatusCode, uri)
+		return resp.ErrCouldNotReachOidcProvider
+	}
+	if err = json.NewDecoder(response.Body).Decode(v); err != nil {
+		svc.log.Error("could not read the response of the oidc provider from: %s, error: %s", uri, err)
+		return resp.ErrCouldNotReachOidcProvider
+	}
+	return nil
+}
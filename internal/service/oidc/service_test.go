@@ -0,0 +1,519 @@
+package oidc
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testNow          = int64(100000)
+	testClientId     = "wordbubble"
+	testClientSecret = "client secret"
+	testRedirectUri  = "https://wordbubble.com/login/callback"
+)
+
+var idpKey = newIdpKey()
+
+func newIdpKey() *util.Key {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return &util.Key{Id: "idp-1", Algorithm: util.RS256, Private: private}
+}
+
+// mockIdp is an OpenID Connect provider running in process, it issues an id token for the last authorization request it was sent
+type mockIdp struct {
+	*httptest.Server
+	keys         []*util.Key // keys published, the first one signs id tokens
+	signer       *util.Key   // signs id tokens instead of the first key published, when set
+	issuer       string      // issuer of the discovery document, the url of the server when empty
+	code         string      // code the token endpoint accepts
+	challenge    string      // code challenge of the last authorization request
+	nonce        string      // nonce of the last authorization request
+	claims       jwt.MapClaims
+	tokenStatus  int // status the token endpoint responds with instead of issuing tokens, when set
+	jwksRequests int
+}
+
+func newMockIdp(t *testing.T) *mockIdp {
+	idp := &mockIdp{keys: []*util.Key{idpKey}, code: "idp-code"}
+	mux := http.NewServeMux()
+	mux.HandleFunc(DiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		issuer := idp.issuer
+		if issuer == "" {
+			issuer = idp.URL
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": idp.URL + "/authorize?prompt=login",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.jwksRequests++
+		jwks := resp.JWKSResponse{}
+		for _, key := range idp.keys {
+			jwks.Keys = append(jwks.Keys, *key.PublicJWK())
+		}
+		json.NewEncoder(w).Encode(jwks)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if idp.tokenStatus != 0 {
+			w.WriteHeader(idp.tokenStatus)
+			return
+		}
+		clientId, secret, _ := r.BasicAuth()
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if r.Method != http.MethodPost || clientId != testClientId || secret != url.QueryEscape(testClientSecret) ||
+			r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("code") != idp.code ||
+			r.PostFormValue("redirect_uri") != testRedirectUri ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "idp-access-token",
+			"token_type":   "Bearer",
+			"id_token":     idp.idToken(t),
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// idToken signs the claims of the user logging in, any claim set on the idp replaces the default, or removes it when nil
+func (idp *mockIdp) idToken(t *testing.T) string {
+	claims := jwt.MapClaims{
+		"iss":                idp.URL,
+		"sub":                "248289761001",
+		"aud":                testClientId,
+		"exp":                testNow + 300,
+		"iat":                testNow,
+		"nonce":              idp.nonce,
+		"email":              "ben@wordbubble.com",
+		"email_verified":     true,
+		"preferred_username": "ben",
+	}
+	for name, value := range idp.claims {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	key := idp.keys[0]
+	if idp.signer != nil {
+		key = idp.signer
+	}
+	method := jwt.SigningMethod(jwt.SigningMethodRS256)
+	if key.Algorithm == util.EdDSA {
+		method = jwt.SigningMethodEdDSA
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.Id
+	signed, err := token.SignedString(key.Private)
+	assert.NoError(t, err)
+	return signed
+}
+
+// authorize starts a login, then sends the user to the idp, who logs in
+// string is the state the idp sends the user back with
+func (idp *mockIdp) authorize(t *testing.T, svc *oidcService) string {
+	authorizationUrl, err := svc.AuthorizationUrl(0, "wordbubble:push", true)
+	assert.NoError(t, err)
+	u, err := url.Parse(authorizationUrl)
+	assert.NoError(t, err)
+	query := u.Query()
+	idp.challenge = query.Get("code_challenge")
+	idp.nonce = query.Get("nonce")
+	return query.Get("state")
+}
+
+func testService(idp *mockIdp, repo *testOidcRepo) *oidcService {
+	cfg := cfg.TestConfig()
+	cfg.SetTimer(util.TestTimerFromUnix(testNow))
+	cfg.SetOidcProvider(util.OidcProvider{
+		Issuer:       idp.URL,
+		ClientId:     testClientId,
+		ClientSecret: testClientSecret,
+		RedirectUri:  testRedirectUri,
+	})
+	return NewOidcService(cfg, repo)
+}
+
+func Test_AuthorizationUrl(t *testing.T) {
+	idp := newMockIdp(t)
+	repo := &testOidcRepo{}
+	svc := testService(idp, repo)
+
+	authorizationUrl, err := svc.AuthorizationUrl(5, "wordbubble:push", true)
+	assert.NoError(t, err)
+	u, err := url.Parse(authorizationUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, idp.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	query := u.Query()
+	assert.Equal(t, "login", query.Get("prompt"))
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, testClientId, query.Get("client_id"))
+	assert.Equal(t, testRedirectUri, query.Get("redirect_uri"))
+	assert.Equal(t, RequestedScope, query.Get("scope"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+
+	stored := repo.states[util.HashToken(query.Get("state"))]
+	assert.NotNil(t, stored)
+	challenge := sha256.Sum256([]byte(stored.codeVerifier))
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(challenge[:]), query.Get("code_challenge"))
+	assert.Equal(t, &loginState{
+		nonce:        query.Get("nonce"),
+		codeVerifier: stored.codeVerifier,
+		linkUserId:   5,
+		scope:        "wordbubble:push",
+		rememberMe:   true,
+		expiresAt:    testNow + stateTimeLimit,
+	}, stored)
+
+	// the discovery document is only read once
+	idp.Close()
+	_, err = svc.AuthorizationUrl(0, "", false)
+	assert.NoError(t, err)
+}
+
+func Test_AuthorizationUrlNotSoHappyPath(t *testing.T) {
+	tests := map[string]struct {
+		change      func(idp *mockIdp, provider *util.OidcProvider, repo *testOidcRepo)
+		expectedErr error
+	}{
+		"invalid, not configured": {
+			change:      func(idp *mockIdp, provider *util.OidcProvider, repo *testOidcRepo) { *provider = util.OidcProvider{} },
+			expectedErr: resp.ErrOidcNotConfigured,
+		},
+		"invalid, discovery document is for another issuer": {
+			change: func(idp *mockIdp, provider *util.OidcProvider, repo *testOidcRepo) {
+				idp.issuer = "https://evil.example.com"
+			},
+			expectedErr: resp.ErrCouldNotReachOidcProvider,
+		},
+		"invalid, provider is down": {
+			change:      func(idp *mockIdp, provider *util.OidcProvider, repo *testOidcRepo) { idp.Close() },
+			expectedErr: resp.ErrCouldNotReachOidcProvider,
+		},
+		"invalid, state could not be stored": {
+			change: func(idp *mockIdp, provider *util.OidcProvider, repo *testOidcRepo) {
+				repo.errStore = resp.ErrCouldNotStoreOidcState
+			},
+			expectedErr: resp.ErrCouldNotStoreOidcState,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			idp := newMockIdp(t)
+			repo := &testOidcRepo{}
+			provider := util.OidcProvider{Issuer: idp.URL, ClientId: testClientId, RedirectUri: testRedirectUri}
+			tcase.change(idp, &provider, repo)
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(testNow))
+			cfg.SetOidcProvider(provider)
+			authorizationUrl, err := NewOidcService(cfg, repo).AuthorizationUrl(0, "", false)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Empty(t, authorizationUrl)
+		})
+	}
+}
+
+func Test_CompleteLogin(t *testing.T) {
+	tests := map[string]struct {
+		change      func(idp *mockIdp)
+		code        string
+		state       string
+		expiredAt   int64 // when set, the state expires at this time
+		expectedErr error
+	}{
+		"valid": {},
+		"valid, audience array with authorized party": {
+			change: func(idp *mockIdp) {
+				idp.claims = jwt.MapClaims{"aud": []string{testClientId, "another-client"}, "azp": testClientId}
+			},
+		},
+		"valid, expired within the leeway": {
+			change: func(idp *mockIdp) {
+				idp.claims = jwt.MapClaims{"exp": testNow - 2}
+			},
+		},
+		"valid, signed with EdDSA": {
+			change: func(idp *mockIdp) {
+				_, private, _ := ed25519.GenerateKey(rand.Reader)
+				idp.keys = []*util.Key{{Id: "idp-ed", Algorithm: util.EdDSA, Private: private}}
+			},
+		},
+		"invalid, unknown state": {
+			state:       "forged-state",
+			expectedErr: resp.ErrInvalidOidcState,
+		},
+		"invalid, expired state": {
+			expiredAt:   testNow,
+			expectedErr: resp.ErrInvalidOidcState,
+		},
+		"invalid, code": {
+			code:        "another-code",
+			expectedErr: resp.ErrOidcCodeRejected,
+		},
+		"invalid, code verifier": {
+			change:      func(idp *mockIdp) { idp.challenge = "another-challenge" },
+			expectedErr: resp.ErrOidcCodeRejected,
+		},
+		"invalid, token endpoint is down": {
+			change:      func(idp *mockIdp) { idp.tokenStatus = http.StatusServiceUnavailable },
+			expectedErr: resp.ErrCouldNotReachOidcProvider,
+		},
+		"invalid, nonce": {
+			change:      func(idp *mockIdp) { idp.nonce = "replayed-nonce" },
+			expectedErr: resp.ErrInvalidIdToken,
+		},
+		"invalid, no nonce": {
+			change:      func(idp *mockIdp) { idp.claims = jwt.MapClaims{"nonce": nil} },
+			expectedErr: resp.ErrInvalidIdToken,
+		},
+		"invalid, issuer": {
+			change:      func(idp *mockIdp) { idp.claims = jwt.MapClaims{"iss": "https://evil.example.com"} },
+			expectedErr: resp.ErrInvalidIdToken,
+		},
+		"invalid, audience": {
+			change:      func(idp *mockIdp) { idp.claims = jwt.MapClaims{"aud": "another-client"} },
+			expectedErr: resp.ErrInvalidIdToken,
+		},
+		"invalid, authorized party": {
+			change: func(idp *mockIdp) {
+				idp.claims = jwt.MapClaims{"aud": []string{testClientId, "another-client"}, "azp": "another-client"}
+			},
+			expectedErr: resp.ErrInvalidIdToken,
+		},
+		"invalid, no subject": {
+			change:      func(idp *mockIdp) { idp.claims = jwt.MapClaims{"sub": nil} },
+			expectedErr: resp.ErrInvalidIdToken,
+		},
+		"invalid, expired": {
+			change:      func(idp *mockIdp) { idp.claims = jwt.MapClaims{"exp": testNow - 60} },
+			expectedErr: resp.ErrInvalidIdToken,
+		},
+		"invalid, no expiry": {
+			change:      func(idp *mockIdp) { idp.claims = jwt.MapClaims{"exp": nil} },
+			expectedErr: resp.ErrInvalidIdToken,
+		},
+		"invalid, issued in the future": {
+			change:      func(idp *mockIdp) { idp.claims = jwt.MapClaims{"iat": testNow + 60} },
+			expectedErr: resp.ErrInvalidIdToken,
+		},
+		"invalid, signed with a key that isn't published": {
+			change: func(idp *mockIdp) {
+				_, private, _ := ed25519.GenerateKey(rand.Reader)
+				idp.signer = &util.Key{Id: idpKey.Id, Algorithm: util.EdDSA, Private: private}
+			},
+			expectedErr: resp.ErrInvalidIdToken,
+		},
+		"invalid, signed with an unknown key": {
+			change: func(idp *mockIdp) {
+				_, private, _ := ed25519.GenerateKey(rand.Reader)
+				idp.signer = &util.Key{Id: "unknown", Algorithm: util.EdDSA, Private: private}
+			},
+			expectedErr: resp.ErrInvalidIdToken,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			idp := newMockIdp(t)
+			repo := &testOidcRepo{}
+			svc := testService(idp, repo)
+			state := idp.authorize(t, svc)
+			if tcase.change != nil {
+				tcase.change(idp)
+			}
+			if tcase.expiredAt != 0 {
+				repo.states[util.HashToken(state)].expiresAt = tcase.expiredAt
+			}
+			if tcase.state != "" {
+				state = tcase.state
+			}
+			code := idp.code
+			if tcase.code != "" {
+				code = tcase.code
+			}
+			login, err := svc.CompleteLogin(code, state)
+			if tcase.state == "" {
+				assert.Empty(t, repo.states, "a state can only be redeemed once")
+			}
+			if tcase.expectedErr != nil {
+				assert.Equal(t, tcase.expectedErr, err)
+				assert.Nil(t, login)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, &model.FederatedLogin{
+				Identity: model.FederatedIdentity{
+					Issuer:        idp.URL,
+					Subject:       "248289761001",
+					Email:         "ben@wordbubble.com",
+					EmailVerified: true,
+					Username:      "ben",
+				},
+				Scope:      "wordbubble:push",
+				RememberMe: true,
+			}, login)
+		})
+	}
+}
+
+func Test_CompleteLoginNotConfigured(t *testing.T) {
+	svc := NewOidcService(cfg.TestConfig(), &testOidcRepo{})
+	login, err := svc.CompleteLogin("idp-code", "state")
+	assert.Equal(t, resp.ErrOidcNotConfigured, err)
+	assert.Nil(t, login)
+}
+
+func Test_ProviderKeyRotation(t *testing.T) {
+	idp := newMockIdp(t)
+	timer := util.TestTimerFromUnix(testNow)
+	svc := testService(idp, &testOidcRepo{})
+	svc.timer = timer
+
+	_, err := svc.CompleteLogin(idp.code, idp.authorize(t, svc))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, idp.jwksRequests)
+
+	// the provider rotates its keys, the new key is fetched once it signs a token
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	idp.keys = []*util.Key{{Id: "idp-2", Algorithm: util.EdDSA, Private: private}, idpKey}
+	timer.SetNow(time.Unix(testNow, 0).Add(keyRefreshInterval))
+	_, err = svc.CompleteLogin(idp.code, idp.authorize(t, svc))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, idp.jwksRequests)
+
+	// tokens signed with unknown keys don't make the keys be fetched again until the interval has passed
+	_, private, _ = ed25519.GenerateKey(rand.Reader)
+	idp.keys = []*util.Key{{Id: "idp-3", Algorithm: util.EdDSA, Private: private}}
+	_, err = svc.CompleteLogin(idp.code, idp.authorize(t, svc))
+	assert.Equal(t, resp.ErrInvalidIdToken, err)
+	_, err = svc.CompleteLogin(idp.code, idp.authorize(t, svc))
+	assert.Equal(t, resp.ErrInvalidIdToken, err)
+	assert.Equal(t, 2, idp.jwksRequests)
+}
+
+func Test_LinkIdentity(t *testing.T) {
+	identity := &model.FederatedIdentity{Issuer: "https://accounts.example.com", Subject: "248289761001"}
+	tests := map[string]struct {
+		repo           *testOidcRepo
+		expectedLinked bool
+		expectedErr    error
+	}{
+		"valid": {
+			repo:           &testOidcRepo{},
+			expectedLinked: true,
+		},
+		"valid, already linked to the user": {
+			repo: &testOidcRepo{linkedUserId: 5},
+		},
+		"invalid, linked to another user": {
+			repo:        &testOidcRepo{linkedUserId: 6},
+			expectedErr: resp.ErrIdentityAlreadyLinked,
+		},
+		"invalid, could not check identity": {
+			repo:        &testOidcRepo{errGetLinked: resp.ErrCouldNotCheckIdentity},
+			expectedErr: resp.ErrCouldNotCheckIdentity,
+		},
+		"invalid, could not link identity": {
+			repo:           &testOidcRepo{errLink: resp.ErrCouldNotLinkIdentity},
+			expectedLinked: true,
+			expectedErr:    resp.ErrCouldNotLinkIdentity,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(testNow))
+			svc := NewOidcService(cfg, tcase.repo)
+			err := svc.LinkIdentity(5, identity)
+			assert.Equal(t, tcase.expectedErr, err)
+			if tcase.expectedLinked {
+				assert.Equal(t, int64(5), tcase.repo.linkedUserIdStored)
+				assert.Equal(t, testNow, tcase.repo.linkedAt)
+			} else {
+				assert.Zero(t, tcase.repo.linkedUserIdStored)
+			}
+		})
+	}
+}
+
+type testOidcRepo struct {
+	errStore           error
+	states             map[string]*loginState
+	errGetLinked       error
+	linkedUserId       int64
+	errLink            error
+	linkedUserIdStored int64
+	linkedAt           int64
+}
+
+func (repo *testOidcRepo) storeState(stateHash string, state *loginState) error {
+	if repo.errStore != nil {
+		return repo.errStore
+	}
+	if repo.states == nil {
+		repo.states = map[string]*loginState{}
+	}
+	repo.states[stateHash] = state
+	return nil
+}
+
+func (repo *testOidcRepo) redeemState(stateHash string) (*loginState, error) {
+	state, ok := repo.states[stateHash]
+	if !ok {
+		return nil, resp.ErrInvalidOidcState
+	}
+	delete(repo.states, stateHash)
+	return state, nil
+}
+
+func (repo *testOidcRepo) getLinkedUser(issuer, subject string) (int64, error) {
+	return repo.linkedUserId, repo.errGetLinked
+}
+
+func (repo *testOidcRepo) linkIdentity(issuer, subject string, userId, createdAt int64) error {
+	repo.linkedUserIdStored = userId
+	repo.linkedAt = createdAt
+	return repo.errLink
+}
+
+func Test_Audience(t *testing.T) {
+	for raw, expected := range map[string]audience{
+		`"wordbubble"`:           {"wordbubble"},
+		`["wordbubble","other"]`: {"wordbubble", "other"},
+	} {
+		var aud audience
+		assert.NoError(t, json.Unmarshal([]byte(raw), &aud))
+		assert.Equal(t, expected, aud)
+		assert.True(t, aud.contains("wordbubble"))
+		assert.False(t, aud.contains(strings.ToUpper("wordbubble")))
+	}
+	var aud audience
+	assert.NotNil(t, json.Unmarshal([]byte(`5`), &aud))
+}
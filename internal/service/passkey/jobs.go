@@ -0,0 +1,19 @@
+package passkey
+
+import (
+	"context"
+
+	"github.com/bchadwic/wordbubble/internal/job"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// NewChallengeCleanupJob creates a job that removes the challenges of ceremonies that were never finished
+func NewChallengeCleanupJob(timer util.Timer, cleaner PasskeyCleaner) job.Job {
+	return job.Job{
+		Name:     "passkey_challenge_cleanup",
+		Interval: ChallengeCleanerRate,
+		Run: func(ctx context.Context) error {
+			return cleaner.CleanupExpiredPasskeyChallenges(timer.Now().Unix())
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package passkey
+
+import (
+	"time"
+
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+)
+
+const (
+	// challengeTimeLimit is how long a user has to respond to a challenge with their authenticator, in seconds
+	challengeTimeLimit   = 5 * 60
+	challengeLength      = 32 // bytes
+	ChallengeCleanerRate = 10 * time.Minute
+
+	StorePasskeyChallenge    = `INSERT INTO passkey_challenges (challenge_hash, ceremony, user_id, scope, remember_me, expires_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	GetPasskeyChallenge      = `SELECT ceremony, user_id, scope, remember_me, expires_at FROM passkey_challenges WHERE challenge_hash = $1`
+	RedeemPasskeyChallenge   = `DELETE FROM passkey_challenges WHERE challenge_hash = $1`
+	CleanupPasskeyChallenges = `DELETE FROM passkey_challenges WHERE expires_at < $1`
+	StorePasskey             = `INSERT INTO passkeys (credential_id, user_id, name, public_key, sign_count, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	GetPasskey               = `SELECT credential_id, user_id, name, public_key, sign_count, created_at, last_used_at FROM passkeys WHERE credential_id = $1`
+	UpdatePasskeyUse         = `UPDATE passkeys SET sign_count = $1, last_used_at = $2 WHERE credential_id = $3`
+	ListPasskeys             = `SELECT credential_id, name, created_at, last_used_at FROM passkeys WHERE user_id = $1 ORDER BY created_at DESC`
+	RevokePasskey            = `DELETE FROM passkeys WHERE user_id = $1 AND credential_id = $2`
+)
+
+// PasskeyService is the interface that the application
+// uses to register passkeys and let users login with them, using WebAuthn
+type PasskeyService interface {
+	// BeginRegistration issues a challenge for the user to register a passkey with, passkeys the user already has are excluded.
+	// *resp.PasskeyCreationOptions is what the browser is passed to create the passkey, can be nil.
+	// error could be (404) resp.ErrPasskeysNotConfigured, (500) resp.ErrCouldNotListPasskeys,
+	// (500) resp.ErrCouldNotStorePasskeyChallenge or nil.
+	BeginRegistration(user *model.User) (*resp.PasskeyCreationOptions, error)
+	// FinishRegistration redeems the challenge the browser responded to, then verifies and stores the passkey the authenticator created.
+	// *resp.Passkey is the passkey registered, can be nil.
+	// error could be (400) resp.ErrPasskeyNameIsMissing, (400) resp.ErrPasskeyNameIsTooLong, (400) resp.ErrInvalidPasskeyRegistration,
+	// (400) resp.ErrUnsupportedPasskeyAlgorithm, (401) resp.ErrInvalidPasskeyChallenge, (404) resp.ErrPasskeysNotConfigured,
+	// (409) resp.ErrPasskeyAlreadyRegistered, (500) resp.ErrCouldNotUsePasskeyChallenge, (500) resp.ErrCouldNotCheckPasskey,
+	// (500) resp.ErrCouldNotStorePasskey or nil.
+	FinishRegistration(userId int64, request *req.PasskeyRegistrationRequest) (*resp.Passkey, error)
+	// BeginLogin issues a challenge for any passkey to login with, granting the scope passed once the login finishes.
+	// *resp.PasskeyRequestOptions is what the browser is passed to find a passkey, can be nil.
+	// error could be (404) resp.ErrPasskeysNotConfigured, (500) resp.ErrCouldNotStorePasskeyChallenge or nil.
+	BeginLogin(scope string, rememberMe bool) (*resp.PasskeyRequestOptions, error)
+	// FinishLogin redeems the challenge the browser responded to, then verifies the passkey's signature and updates its counter.
+	// *model.PasskeyLogin is the user the passkey belongs to, and what was asked for when the login started, can be nil.
+	// error could be (401) resp.ErrInvalidPasskey, (401) resp.ErrInvalidPasskeyChallenge, (404) resp.ErrPasskeysNotConfigured,
+	// (500) resp.ErrCouldNotUsePasskeyChallenge, (500) resp.ErrCouldNotCheckPasskey, (500) resp.ErrCouldNotStorePasskey or nil.
+	FinishLogin(credential *req.PasskeyCredential) (*model.PasskeyLogin, error)
+	// ListPasskeys returns every passkey a user has, the newest first
+	// []resp.Passkey is the user's passkeys, can be empty.
+	// error could be (500) resp.ErrCouldNotListPasskeys or nil.
+	ListPasskeys(userId int64) ([]resp.Passkey, error)
+	// RevokePasskey removes a passkey, the passkey must belong to the user
+	// error could be (404) resp.ErrUnknownPasskey, (500) resp.ErrCouldNotRevokePasskey or nil.
+	RevokePasskey(userId int64, passkeyId string) error
+}
+
+// PasskeyRepo is the interface that the service layer
+// uses to interact with passkeys and their challenges in the database
+type PasskeyRepo interface {
+	// storeChallenge stores the hash of a challenge issued for a ceremony.
+	// error can be (500) resp.ErrCouldNotStorePasskeyChallenge or nil.
+	storeChallenge(challengeHash string, c *challenge) error
+	// redeemChallenge finds the challenge with the hash passed and removes it, so it can only be redeemed once.
+	// *challenge is the challenge redeemed, can be nil.
+	// error can be (401) resp.ErrInvalidPasskeyChallenge, (500) resp.ErrCouldNotUsePasskeyChallenge or nil.
+	redeemChallenge(challengeHash string) (*challenge, error)
+	// storePasskey stores a passkey a user registered.
+	// error can be (500) resp.ErrCouldNotStorePasskey or nil.
+	storePasskey(passkey *model.Passkey) error
+	// getPasskey finds the passkey with the credential id passed.
+	// *model.Passkey is the passkey found, or nil when no passkey has the id.
+	// error can be (500) resp.ErrCouldNotCheckPasskey or nil.
+	getPasskey(credentialId string) (*model.Passkey, error)
+	// updatePasskeyUse sets the signature counter of a passkey, and the last time it was used to login.
+	// error can be (500) resp.ErrCouldNotStorePasskey or nil.
+	updatePasskeyUse(credentialId string, signCount uint32, lastUsedAt int64) error
+	// listPasskeys finds every passkey a user has.
+	// error can be (500) resp.ErrCouldNotListPasskeys or nil.
+	listPasskeys(userId int64) ([]resp.Passkey, error)
+	// revokePasskey removes a user's passkey.
+	// error can be (404) resp.ErrUnknownPasskey, (500) resp.ErrCouldNotRevokePasskey or nil.
+	revokePasskey(userId int64, credentialId string) error
+}
+
+// PasskeyCleaner is the interface that the application
+// uses to clean up challenges that were never responded to
+type PasskeyCleaner interface {
+	// CleanupExpiredPasskeyChallenges remove any challenges from the database that were never redeemed and are expired.
+	// error can be (500) resp.ErrCouldNotCleanupChallenges or nil
+	CleanupExpiredPasskeyChallenges(now int64) error
+}
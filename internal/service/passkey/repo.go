@@ -0,0 +1,133 @@
+package passkey
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type passkeyRepo struct {
+	db  *sql.DB
+	log util.Logger
+}
+
+func NewPasskeyRepo(config cfg.Config) *passkeyRepo {
+	return &passkeyRepo{
+		log: config.NewLogger("passkey_repo"),
+		db:  config.DB(),
+	}
+}
+
+func (repo *passkeyRepo) storeChallenge(challengeHash string, c *challenge) error {
+	if _, err := repo.db.Exec(StorePasskeyChallenge, challengeHash, c.ceremony, c.userId, c.scope, c.rememberMe, c.expiresAt); err != nil {
+		repo.log.Error("could not store passkey challenge, error: %s", err)
+		return resp.ErrCouldNotStorePasskeyChallenge
+	}
+	return nil
+}
+
+func (repo *passkeyRepo) redeemChallenge(challengeHash string) (*challenge, error) {
+	row := repo.db.QueryRow(GetPasskeyChallenge, challengeHash)
+	var c challenge
+	if err := row.Scan(&c.ceremony, &c.userId, &c.scope, &c.rememberMe, &c.expiresAt); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			repo.log.Error("could not find passkey challenge, error: %s", err)
+			return nil, resp.ErrCouldNotUsePasskeyChallenge
+		}
+		return nil, resp.ErrInvalidPasskeyChallenge
+	}
+	rs, err := repo.db.Exec(RedeemPasskeyChallenge, challengeHash)
+	if err != nil {
+		repo.log.Error("could not redeem passkey challenge, error: %s", err)
+		return nil, resp.ErrCouldNotUsePasskeyChallenge
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 { // the challenge was redeemed by another request in the meantime
+		return nil, resp.ErrInvalidPasskeyChallenge
+	}
+	return &c, nil
+}
+
+// public keys are stored as base64url text, so the table is the same in sqlite and postgres
+func (repo *passkeyRepo) storePasskey(passkey *model.Passkey) error {
+	publicKey := base64.RawURLEncoding.EncodeToString(passkey.PublicKey)
+	if _, err := repo.db.Exec(StorePasskey, passkey.Id, passkey.UserId, passkey.Name, publicKey, passkey.SignCount, passkey.CreatedAt); err != nil {
+		repo.log.Error("could not store passkey for user: %d, error: %s", passkey.UserId, err)
+		return resp.ErrCouldNotStorePasskey
+	}
+	return nil
+}
+
+func (repo *passkeyRepo) getPasskey(credentialId string) (*model.Passkey, error) {
+	row := repo.db.QueryRow(GetPasskey, credentialId)
+	var passkey model.Passkey
+	var publicKey string
+	if err := row.Scan(&passkey.Id, &passkey.UserId, &passkey.Name, &publicKey, &passkey.SignCount, &passkey.CreatedAt, &passkey.LastUsedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		repo.log.Error("could not find passkey, error: %s", err)
+		return nil, resp.ErrCouldNotCheckPasskey
+	}
+	var err error
+	if passkey.PublicKey, err = base64.RawURLEncoding.DecodeString(publicKey); err != nil {
+		repo.log.Error("could not decode public key of passkey for user: %d, error: %s", passkey.UserId, err)
+		return nil, resp.ErrCouldNotCheckPasskey
+	}
+	return &passkey, nil
+}
+
+func (repo *passkeyRepo) updatePasskeyUse(credentialId string, signCount uint32, lastUsedAt int64) error {
+	if _, err := repo.db.Exec(UpdatePasskeyUse, signCount, lastUsedAt, credentialId); err != nil {
+		repo.log.Error("could not update passkey use, error: %s", err)
+		return resp.ErrCouldNotStorePasskey
+	}
+	return nil
+}
+
+func (repo *passkeyRepo) listPasskeys(userId int64) ([]resp.Passkey, error) {
+	rows, err := repo.db.Query(ListPasskeys, userId)
+	if err != nil {
+		repo.log.Error("could not list passkeys for user: %d, error: %s", userId, err)
+		return nil, resp.ErrCouldNotListPasskeys
+	}
+	defer rows.Close()
+	passkeys := make([]resp.Passkey, 0)
+	for rows.Next() {
+		var passkey resp.Passkey
+		if err := rows.Scan(&passkey.Id, &passkey.Name, &passkey.CreatedAt, &passkey.LastUsedAt); err != nil {
+			repo.log.Error("could not map passkey for user: %d, error: %s", userId, err)
+			return nil, resp.ErrCouldNotListPasskeys
+		}
+		passkeys = append(passkeys, passkey)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, resp.ErrCouldNotListPasskeys
+	}
+	return passkeys, nil
+}
+
+func (repo *passkeyRepo) revokePasskey(userId int64, credentialId string) error {
+	rs, err := repo.db.Exec(RevokePasskey, userId, credentialId)
+	if err != nil {
+		return resp.ErrCouldNotRevokePasskey
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 {
+		return resp.ErrUnknownPasskey
+	}
+	return nil
+}
+
+func (repo *passkeyRepo) CleanupExpiredPasskeyChallenges(now int64) error {
+	rs, err := repo.db.Exec(CleanupPasskeyChallenges, now)
+	if err != nil {
+		return resp.ErrCouldNotCleanupChallenges
+	}
+	amt, _ := rs.RowsAffected()
+	repo.log.Info("passkey challenge cleaner deleted: %d challenges", amt)
+	return nil
+}
@@ -0,0 +1,107 @@
+package passkey
+
+import (
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HappyPath(t *testing.T) {
+	repo := NewPasskeyRepo(cfg.TestConfig())
+
+	// A user starts registering a passkey, the challenge can only be redeemed once
+	registration := &challenge{ceremony: "webauthn.create", userId: 5, expiresAt: 400}
+	assert.NoError(t, repo.storeChallenge("hash.registration", registration))
+	redeemed, err := repo.redeemChallenge("hash.registration")
+	assert.NoError(t, err)
+	assert.Equal(t, registration, redeemed)
+	_, err = repo.redeemChallenge("hash.registration")
+	assert.Equal(t, resp.ErrInvalidPasskeyChallenge, err)
+
+	// The passkey is stored once the registration finishes
+	passkey, err := repo.getPasskey("Z2Nvb2dsZS1wYXNza2V5")
+	assert.NoError(t, err)
+	assert.Nil(t, passkey)
+	stored := &model.Passkey{Id: "Z2Nvb2dsZS1wYXNza2V5", UserId: 5, Name: "work laptop", PublicKey: []byte{0xa5, 0x01, 0x02}, SignCount: 3, CreatedAt: 100}
+	assert.NoError(t, repo.storePasskey(stored))
+	assert.NoError(t, repo.storePasskey(&model.Passkey{Id: "cGhvbmU", UserId: 5, Name: "phone", PublicKey: []byte{0xa5}, CreatedAt: 200}))
+	assert.Equal(t, resp.ErrCouldNotStorePasskey, repo.storePasskey(&model.Passkey{Id: "cGhvbmU", UserId: 6, Name: "phone", PublicKey: []byte{0xa5}, CreatedAt: 200}))
+	passkey, err = repo.getPasskey("Z2Nvb2dsZS1wYXNza2V5")
+	assert.NoError(t, err)
+	assert.Equal(t, stored, passkey)
+
+	// A user logs in with the passkey
+	login := &challenge{ceremony: "webauthn.get", scope: "wordbubble:push", rememberMe: true, expiresAt: 400}
+	assert.NoError(t, repo.storeChallenge("hash.login", login))
+	redeemed, err = repo.redeemChallenge("hash.login")
+	assert.NoError(t, err)
+	assert.Equal(t, login, redeemed)
+	assert.NoError(t, repo.updatePasskeyUse("Z2Nvb2dsZS1wYXNza2V5", 4, 300))
+	passkey, err = repo.getPasskey("Z2Nvb2dsZS1wYXNza2V5")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(4), passkey.SignCount)
+	assert.Equal(t, int64(300), passkey.LastUsedAt)
+
+	// The user's passkeys are listed newest first, and can only be revoked by the user
+	passkeys, err := repo.listPasskeys(5)
+	assert.NoError(t, err)
+	assert.Equal(t, []resp.Passkey{
+		{Id: "cGhvbmU", Name: "phone", CreatedAt: 200},
+		{Id: "Z2Nvb2dsZS1wYXNza2V5", Name: "work laptop", CreatedAt: 100, LastUsedAt: 300},
+	}, passkeys)
+	assert.Equal(t, resp.ErrUnknownPasskey, repo.revokePasskey(6, "Z2Nvb2dsZS1wYXNza2V5"))
+	assert.NoError(t, repo.revokePasskey(5, "Z2Nvb2dsZS1wYXNza2V5"))
+	passkey, err = repo.getPasskey("Z2Nvb2dsZS1wYXNza2V5")
+	assert.NoError(t, err)
+	assert.Nil(t, passkey)
+
+	// Challenges that were never responded to are cleaned up once they expire
+	assert.NoError(t, repo.storeChallenge("hash.expired", &challenge{ceremony: "webauthn.get", expiresAt: 200}))
+	assert.NoError(t, repo.storeChallenge("hash.active", &challenge{ceremony: "webauthn.get", expiresAt: 400}))
+	assert.NoError(t, repo.CleanupExpiredPasskeyChallenges(300))
+	_, err = repo.redeemChallenge("hash.expired")
+	assert.Equal(t, resp.ErrInvalidPasskeyChallenge, err)
+	_, err = repo.redeemChallenge("hash.active")
+	assert.NoError(t, err)
+}
+
+func Test_NotSoHappyPath(t *testing.T) {
+	repo := NewPasskeyRepo(cfg.TestConfig())
+
+	// db closed
+	repo.db.Close()
+	err := repo.storeChallenge("hash.challenge", &challenge{})
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotStorePasskeyChallenge.Error(), err.Error())
+
+	_, err = repo.redeemChallenge("hash.challenge")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotUsePasskeyChallenge.Error(), err.Error())
+
+	err = repo.storePasskey(&model.Passkey{Id: "Z2Nvb2dsZS1wYXNza2V5"})
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotStorePasskey.Error(), err.Error())
+
+	_, err = repo.getPasskey("Z2Nvb2dsZS1wYXNza2V5")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCheckPasskey.Error(), err.Error())
+
+	err = repo.updatePasskeyUse("Z2Nvb2dsZS1wYXNza2V5", 4, 300)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotStorePasskey.Error(), err.Error())
+
+	_, err = repo.listPasskeys(5)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotListPasskeys.Error(), err.Error())
+
+	err = repo.revokePasskey(5, "Z2Nvb2dsZS1wYXNza2V5")
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotRevokePasskey.Error(), err.Error())
+
+	err = repo.CleanupExpiredPasskeyChallenges(300)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotCleanupChallenges.Error(), err.Error())
+}
@@ -0,0 +1,253 @@
+package passkey
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// challenge is a challenge issued for a ceremony, registration challenges are bound to the user registering
+type challenge struct {
+	ceremony   string // util.WebauthnCreate or util.WebauthnGet
+	userId     int64  // user registering a passkey, zero for a login
+	scope      string
+	rememberMe bool
+	expiresAt  int64
+}
+
+type passkeyService struct {
+	log   util.Logger
+	timer util.Timer
+	repo  PasskeyRepo
+	rp    util.WebauthnRelyingParty
+}
+
+func NewPasskeyService(cfg cfg.Config, repo PasskeyRepo) *passkeyService {
+	return &passkeyService{
+		log:   cfg.NewLogger("passkey"),
+		timer: cfg.Timer(),
+		repo:  repo,
+		rp:    cfg.RelyingParty(),
+	}
+}
+
+func (svc *passkeyService) BeginRegistration(user *model.User) (*resp.PasskeyCreationOptions, error) {
+	if !svc.rp.Enabled() {
+		return nil, resp.ErrPasskeysNotConfigured
+	}
+	existing, err := svc.repo.listPasskeys(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	challengeStr, err := svc.issueChallenge(&challenge{ceremony: util.WebauthnCreate, userId: user.Id})
+	if err != nil {
+		return nil, err
+	}
+	params := make([]resp.PasskeyCredentialParameters, 0, len(util.PasskeyAlgorithms))
+	for _, alg := range util.PasskeyAlgorithms {
+		params = append(params, resp.PasskeyCredentialParameters{Type: "public-key", Alg: alg})
+	}
+	exclude := make([]resp.PasskeyCredentialDescriptor, 0, len(existing))
+	for _, passkey := range existing { // an authenticator that already holds a passkey for the user doesn't create another
+		exclude = append(exclude, resp.PasskeyCredentialDescriptor{Type: "public-key", Id: passkey.Id})
+	}
+	return &resp.PasskeyCreationOptions{
+		Challenge: challengeStr,
+		Rp: resp.PasskeyRelyingParty{
+			Id:   svc.rp.Id,
+			Name: svc.rp.Name,
+		},
+		User: resp.PasskeyUser{
+			Id:          userHandle(user.Id),
+			Name:        user.Username,
+			DisplayName: user.Username,
+		},
+		PubKeyCredParams:   params,
+		Timeout:            challengeTimeLimit * 1000,
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: resp.PasskeyAuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "preferred",
+		},
+		Attestation: "none",
+	}, nil
+}
+
+func (svc *passkeyService) FinishRegistration(userId int64, request *req.PasskeyRegistrationRequest) (*resp.Passkey, error) {
+	if !svc.rp.Enabled() {
+		return nil, resp.ErrPasskeysNotConfigured
+	}
+	name := strings.TrimSpace(request.Name)
+	if err := util.ValidPasskeyName(name); err != nil {
+		return nil, err
+	}
+	credential := &request.Credential
+	clientDataJSON, err1 := decodeField(credential.Response.ClientDataJSON)
+	attestationObject, err2 := decodeField(credential.Response.AttestationObject)
+	if err1 != nil || err2 != nil || credential.Type != "public-key" {
+		return nil, resp.ErrInvalidPasskeyRegistration
+	}
+	challengeStr, err := svc.rp.ParseClientData(clientDataJSON, util.WebauthnCreate)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = svc.redeemChallenge(challengeStr, util.WebauthnCreate, userId); err != nil {
+		return nil, err
+	}
+	attestation, err := svc.rp.VerifyAttestation(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	credentialId := base64.RawURLEncoding.EncodeToString(attestation.CredentialId)
+	if credentialId != strings.TrimRight(credential.Id, "=") {
+		return nil, resp.ErrInvalidPasskeyRegistration
+	}
+	registered, err := svc.repo.getPasskey(credentialId)
+	if err != nil {
+		return nil, err
+	}
+	if registered != nil {
+		svc.log.Warn("user: %d tried to register a passkey that's registered to user: %d", userId, registered.UserId)
+		return nil, resp.ErrPasskeyAlreadyRegistered
+	}
+	passkey := &model.Passkey{
+		Id:        credentialId,
+		UserId:    userId,
+		Name:      name,
+		PublicKey: attestation.PublicKey,
+		SignCount: attestation.SignCount,
+		CreatedAt: svc.timer.Now().Unix(),
+	}
+	if err = svc.repo.storePasskey(passkey); err != nil {
+		return nil, err
+	}
+	svc.log.Info("registered passkey for user: %d", userId)
+	return &resp.Passkey{
+		Id:        passkey.Id,
+		Name:      passkey.Name,
+		CreatedAt: passkey.CreatedAt,
+	}, nil
+}
+
+func (svc *passkeyService) BeginLogin(scope string, rememberMe bool) (*resp.PasskeyRequestOptions, error) {
+	if !svc.rp.Enabled() {
+		return nil, resp.ErrPasskeysNotConfigured
+	}
+	challengeStr, err := svc.issueChallenge(&challenge{ceremony: util.WebauthnGet, scope: scope, rememberMe: rememberMe})
+	if err != nil {
+		return nil, err
+	}
+	return &resp.PasskeyRequestOptions{
+		Challenge:        challengeStr,
+		Timeout:          challengeTimeLimit * 1000,
+		RpId:             svc.rp.Id,
+		AllowCredentials: []resp.PasskeyCredentialDescriptor{}, // passkeys are discoverable, the user picks one without entering a username
+		UserVerification: "preferred",
+	}, nil
+}
+
+func (svc *passkeyService) FinishLogin(credential *req.PasskeyCredential) (*model.PasskeyLogin, error) {
+	if !svc.rp.Enabled() {
+		return nil, resp.ErrPasskeysNotConfigured
+	}
+	clientDataJSON, err1 := decodeField(credential.Response.ClientDataJSON)
+	authenticatorData, err2 := decodeField(credential.Response.AuthenticatorData)
+	signature, err3 := decodeField(credential.Response.Signature)
+	if err1 != nil || err2 != nil || err3 != nil || credential.Type != "public-key" {
+		return nil, resp.ErrInvalidPasskey
+	}
+	challengeStr, err := svc.rp.ParseClientData(clientDataJSON, util.WebauthnGet)
+	if err != nil {
+		return nil, err
+	}
+	login, err := svc.redeemChallenge(challengeStr, util.WebauthnGet, 0)
+	if err != nil {
+		return nil, err
+	}
+	passkey, err := svc.repo.getPasskey(strings.TrimRight(credential.Id, "="))
+	if err != nil {
+		return nil, err
+	}
+	if passkey == nil { // revoked, or never registered
+		return nil, resp.ErrInvalidPasskey
+	}
+	if handle := strings.TrimRight(credential.Response.UserHandle, "="); handle != "" && handle != userHandle(passkey.UserId) {
+		svc.log.Warn("passkey of user: %d was sent with the user handle of another user", passkey.UserId)
+		return nil, resp.ErrInvalidPasskey
+	}
+	signCount, userVerified, err := svc.rp.VerifyAssertion(passkey.PublicKey, clientDataJSON, authenticatorData, signature)
+	if err != nil {
+		return nil, err
+	}
+	if (signCount != 0 || passkey.SignCount != 0) && signCount <= passkey.SignCount { // WebAuthn section 6.1.1
+		svc.log.Warn("signature counter of a passkey of user: %d went backwards, the authenticator may have been cloned", passkey.UserId)
+		return nil, resp.ErrInvalidPasskey
+	}
+	if err = svc.repo.updatePasskeyUse(passkey.Id, signCount, svc.timer.Now().Unix()); err != nil {
+		return nil, err
+	}
+	return &model.PasskeyLogin{
+		UserId:       passkey.UserId,
+		Scope:        login.scope,
+		RememberMe:   login.rememberMe,
+		UserVerified: userVerified,
+	}, nil
+}
+
+func (svc *passkeyService) ListPasskeys(userId int64) ([]resp.Passkey, error) {
+	return svc.repo.listPasskeys(userId)
+}
+
+func (svc *passkeyService) RevokePasskey(userId int64, passkeyId string) error {
+	if err := svc.repo.revokePasskey(userId, passkeyId); err != nil {
+		return err
+	}
+	svc.log.Info("revoked passkey for user: %d", userId)
+	return nil
+}
+
+// issueChallenge stores a new challenge for a ceremony, only its hash is stored
+// string is the base64url encoded challenge, or empty string.
+// error could be (500) resp.ErrCouldNotStorePasskeyChallenge or nil.
+func (svc *passkeyService) issueChallenge(c *challenge) (string, error) {
+	challengeStr := base64.RawURLEncoding.EncodeToString(util.RandomBytes(challengeLength))
+	c.expiresAt = svc.timer.Now().Unix() + challengeTimeLimit
+	if err := svc.repo.storeChallenge(util.HashToken(challengeStr), c); err != nil {
+		return "", err
+	}
+	return challengeStr, nil
+}
+
+// redeemChallenge redeems the challenge an authenticator responded to, which must have been issued for the ceremony and user passed
+// *challenge is the challenge redeemed, can be nil.
+// error could be (401) resp.ErrInvalidPasskeyChallenge, (500) resp.ErrCouldNotUsePasskeyChallenge or nil.
+func (svc *passkeyService) redeemChallenge(challengeStr, ceremony string, userId int64) (*challenge, error) {
+	c, err := svc.repo.redeemChallenge(util.HashToken(strings.TrimRight(challengeStr, "=")))
+	if err != nil {
+		return nil, err
+	}
+	if c.ceremony != ceremony || c.userId != userId || c.expiresAt <= svc.timer.Now().Unix() {
+		return nil, resp.ErrInvalidPasskeyChallenge
+	}
+	return c, nil
+}
+
+// userHandle is the id a user's passkeys are registered to, authenticators return it on login.
+// It's the user id as 8 big endian bytes, so it never holds a username or email
+func userHandle(userId int64) string {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(userId))
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+// decodeField decodes a binary field of a credential, browsers leave off the padding but some libraries send it
+func decodeField(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,472 @@
+package passkey
+
+import (
+	"testing"
+	"time"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+	"github.com/stretchr/testify/assert"
+)
+
+const testNow = 1665964800
+
+// clock is the test timer, so a test can move time forward
+type clock interface {
+	SetNow(now time.Time)
+}
+
+var testUser = &model.User{Id: 2, Username: "ben", Email: "ben@wordbubble.com"}
+
+func newTestService(repo PasskeyRepo, rp util.WebauthnRelyingParty) (*passkeyService, clock) {
+	timer := util.TestTimerFromUnix(testNow)
+	config := cfg.TestConfig()
+	config.SetTimer(timer)
+	config.SetRelyingParty(rp)
+	return NewPasskeyService(config, repo), timer
+}
+
+func Test_BeginRegistration(t *testing.T) {
+	repo := &testPasskeyRepo{passkeys: map[string]*model.Passkey{
+		"Z2Nvb2dsZS1wYXNza2V5": {Id: "Z2Nvb2dsZS1wYXNza2V5", UserId: 2},
+		"b3RoZXItdXNlcg":       {Id: "b3RoZXItdXNlcg", UserId: 3},
+	}}
+	svc, _ := newTestService(repo, util.TestRelyingParty())
+	options, err := svc.BeginRegistration(testUser)
+	assert.NoError(t, err)
+	assert.Len(t, options.Challenge, 43) // 32 bytes, base64url encoded
+	assert.Equal(t, resp.PasskeyRelyingParty{Id: "wordbubble.com", Name: "wordbubble"}, options.Rp)
+	assert.Equal(t, resp.PasskeyUser{Id: "AAAAAAAAAAI", Name: "ben", DisplayName: "ben"}, options.User)
+	assert.Equal(t, []resp.PasskeyCredentialParameters{{Type: "public-key", Alg: -7}, {Type: "public-key", Alg: -8}, {Type: "public-key", Alg: -257}}, options.PubKeyCredParams)
+	assert.Equal(t, []resp.PasskeyCredentialDescriptor{{Type: "public-key", Id: "Z2Nvb2dsZS1wYXNza2V5"}}, options.ExcludeCredentials)
+	assert.Equal(t, int64(300000), options.Timeout)
+	assert.Equal(t, "none", options.Attestation)
+	stored := repo.challenges[util.HashToken(options.Challenge)]
+	assert.Equal(t, &challenge{ceremony: util.WebauthnCreate, userId: 2, expiresAt: testNow + challengeTimeLimit}, stored)
+
+	// a challenge is never issued when passkeys aren't configured, or when it can't be stored
+	svc, _ = newTestService(&testPasskeyRepo{}, util.WebauthnRelyingParty{})
+	_, err = svc.BeginRegistration(testUser)
+	assert.Equal(t, resp.ErrPasskeysNotConfigured, err)
+	svc, _ = newTestService(&testPasskeyRepo{errList: resp.ErrCouldNotListPasskeys}, util.TestRelyingParty())
+	_, err = svc.BeginRegistration(testUser)
+	assert.Equal(t, resp.ErrCouldNotListPasskeys, err)
+	svc, _ = newTestService(&testPasskeyRepo{errStoreChallenge: resp.ErrCouldNotStorePasskeyChallenge}, util.TestRelyingParty())
+	_, err = svc.BeginRegistration(testUser)
+	assert.Equal(t, resp.ErrCouldNotStorePasskeyChallenge, err)
+}
+
+func Test_FinishRegistration(t *testing.T) {
+	tests := map[string]struct {
+		repo *testPasskeyRepo
+		// register runs the registration ceremony, the challenge passed was issued to testUser
+		register    func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error)
+		expectedErr error
+	}{
+		"valid": {
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				return svc.FinishRegistration(2, &req.PasskeyRegistrationRequest{Name: " work laptop ", Credential: *ta.Register(challenge, "AAAAAAAAAAI")})
+			},
+		},
+		"valid, padded fields": {
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				credential := ta.Register(challenge, "AAAAAAAAAAI")
+				credential.Response.ClientDataJSON += "=="
+				credential.Id += "="
+				return svc.FinishRegistration(2, &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *credential})
+			},
+		},
+		"invalid, no name": {
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				return svc.FinishRegistration(2, &req.PasskeyRegistrationRequest{Name: "  ", Credential: *ta.Register(challenge, "AAAAAAAAAAI")})
+			},
+			expectedErr: resp.ErrPasskeyNameIsMissing,
+		},
+		"invalid, not base64url": {
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				credential := ta.Register(challenge, "AAAAAAAAAAI")
+				credential.Response.AttestationObject = "o2Nm+/"
+				return svc.FinishRegistration(2, &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *credential})
+			},
+			expectedErr: resp.ErrInvalidPasskeyRegistration,
+		},
+		"invalid, phishing origin": {
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				ta.Origin = "https://wordbubble.co"
+				return svc.FinishRegistration(2, &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *ta.Register(challenge, "AAAAAAAAAAI")})
+			},
+			expectedErr: resp.ErrInvalidPasskeyRegistration,
+		},
+		"invalid, challenge was never issued": {
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				return svc.FinishRegistration(2, &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *ta.Register("q83vEjRWeJq83vEjRWeJq83vEjRWeJq83vEjRWeJq80", "AAAAAAAAAAI")})
+			},
+			expectedErr: resp.ErrInvalidPasskeyChallenge,
+		},
+		"invalid, challenge was issued to another user": {
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				return svc.FinishRegistration(3, &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *ta.Register(challenge, "AAAAAAAAAAI")})
+			},
+			expectedErr: resp.ErrInvalidPasskeyChallenge,
+		},
+		"invalid, challenge was issued for a login": {
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				options, _ := svc.BeginLogin("", false)
+				return svc.FinishRegistration(0, &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *ta.Register(options.Challenge, "AAAAAAAAAAA")})
+			},
+			expectedErr: resp.ErrInvalidPasskeyChallenge,
+		},
+		"invalid, challenge expired": {
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				timer.SetNow(time.Unix(testNow+challengeTimeLimit, 0))
+				return svc.FinishRegistration(2, &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *ta.Register(challenge, "AAAAAAAAAAI")})
+			},
+			expectedErr: resp.ErrInvalidPasskeyChallenge,
+		},
+		"invalid, challenge redeemed twice": {
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				request := &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *ta.Register(challenge, "AAAAAAAAAAI")}
+				request.Credential.Type = "password"
+				svc.FinishRegistration(2, request) // rejected before the challenge is redeemed
+				request.Credential.Type, request.Credential.Id = "public-key", "Z2Nvb2dsZS1wYXNza2V5"
+				svc.FinishRegistration(2, request) // rejected after the challenge is redeemed
+				request.Credential.Id = ta.Id()
+				return svc.FinishRegistration(2, request)
+			},
+			expectedErr: resp.ErrInvalidPasskeyChallenge,
+		},
+		"invalid, credential id does not match": {
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				credential := ta.Register(challenge, "AAAAAAAAAAI")
+				credential.Id = "Z2Nvb2dsZS1wYXNza2V5"
+				return svc.FinishRegistration(2, &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *credential})
+			},
+			expectedErr: resp.ErrInvalidPasskeyRegistration,
+		},
+		"invalid, already registered": {
+			repo: &testPasskeyRepo{registered: true},
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				return svc.FinishRegistration(2, &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *ta.Register(challenge, "AAAAAAAAAAI")})
+			},
+			expectedErr: resp.ErrPasskeyAlreadyRegistered,
+		},
+		"invalid, could not check passkey": {
+			repo: &testPasskeyRepo{errGet: resp.ErrCouldNotCheckPasskey},
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				return svc.FinishRegistration(2, &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *ta.Register(challenge, "AAAAAAAAAAI")})
+			},
+			expectedErr: resp.ErrCouldNotCheckPasskey,
+		},
+		"invalid, could not store passkey": {
+			repo: &testPasskeyRepo{errStore: resp.ErrCouldNotStorePasskey},
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				return svc.FinishRegistration(2, &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *ta.Register(challenge, "AAAAAAAAAAI")})
+			},
+			expectedErr: resp.ErrCouldNotStorePasskey,
+		},
+		"invalid, passkeys not configured": {
+			register: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator, challenge string) (*resp.Passkey, error) {
+				svc.rp = util.WebauthnRelyingParty{}
+				return svc.FinishRegistration(2, &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *ta.Register(challenge, "AAAAAAAAAAI")})
+			},
+			expectedErr: resp.ErrPasskeysNotConfigured,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repo := tcase.repo
+			if repo == nil {
+				repo = &testPasskeyRepo{}
+			}
+			svc, timer := newTestService(repo, util.TestRelyingParty())
+			ta := util.NewTestAuthenticator(util.TestRelyingParty())
+			options, err := svc.BeginRegistration(testUser)
+			assert.NoError(t, err)
+
+			passkey, err := tcase.register(svc, timer, ta, options.Challenge)
+			assert.Equal(t, tcase.expectedErr, err)
+			if tcase.expectedErr != nil {
+				assert.Nil(t, passkey)
+				assert.Nil(t, repo.passkeys[ta.Id()])
+				return
+			}
+			assert.Equal(t, &resp.Passkey{Id: ta.Id(), Name: "work laptop", CreatedAt: testNow}, passkey)
+			assert.Equal(t, &model.Passkey{Id: ta.Id(), UserId: 2, Name: "work laptop", PublicKey: ta.PublicKey(), CreatedAt: testNow}, repo.passkeys[ta.Id()])
+			assert.Empty(t, repo.challenges)
+		})
+	}
+}
+
+func Test_Login(t *testing.T) {
+	tests := map[string]struct {
+		repo *testPasskeyRepo
+		// login runs the login ceremony with the passkey of testUser, which was registered with the authenticator passed
+		login         func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error)
+		expectedLogin *model.PasskeyLogin
+		expectedErr   error
+	}{
+		"valid": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				options, _ := svc.BeginLogin("wordbubble:read", true)
+				return svc.FinishLogin(ta.Login(options.Challenge))
+			},
+			expectedLogin: &model.PasskeyLogin{UserId: 2, Scope: "wordbubble:read", RememberMe: true, UserVerified: true},
+		},
+		"valid, logged in twice": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				options, _ := svc.BeginLogin("", false)
+				svc.FinishLogin(ta.Login(options.Challenge))
+				options, _ = svc.BeginLogin("", false)
+				return svc.FinishLogin(ta.Login(options.Challenge))
+			},
+			expectedLogin: &model.PasskeyLogin{UserId: 2, UserVerified: true},
+		},
+		"valid, user not verified and no user handle": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				ta.UserVerified = false
+				options, _ := svc.BeginLogin("", false)
+				credential := ta.Login(options.Challenge)
+				credential.Response.UserHandle = ""
+				return svc.FinishLogin(credential)
+			},
+			expectedLogin: &model.PasskeyLogin{UserId: 2},
+		},
+		"valid, authenticator without a counter": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				ta.Counter, ta.SignCount = false, 0
+				options, _ := svc.BeginLogin("", false)
+				return svc.FinishLogin(ta.Login(options.Challenge))
+			},
+			expectedLogin: &model.PasskeyLogin{UserId: 2, UserVerified: true},
+		},
+		"invalid, counter went backwards": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				clone := *ta
+				options, _ := svc.BeginLogin("", false)
+				svc.FinishLogin(ta.Login(options.Challenge))
+				options, _ = svc.BeginLogin("", false)
+				return svc.FinishLogin(clone.Login(options.Challenge))
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+		"invalid, phishing origin": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				ta.Origin = "https://wordbubble.co"
+				options, _ := svc.BeginLogin("", false)
+				return svc.FinishLogin(ta.Login(options.Challenge))
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+		"invalid, signed by an unregistered passkey": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				options, _ := svc.BeginLogin("", false)
+				return svc.FinishLogin(util.NewTestAuthenticator(util.TestRelyingParty()).Login(options.Challenge))
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+		"invalid, signed by another passkey": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				options, _ := svc.BeginLogin("", false)
+				credential := util.NewTestAuthenticator(util.TestRelyingParty()).Login(options.Challenge)
+				credential.Id = ta.Id()
+				return svc.FinishLogin(credential)
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+		"invalid, user handle of another user": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				options, _ := svc.BeginLogin("", false)
+				credential := ta.Login(options.Challenge)
+				credential.Response.UserHandle = "AAAAAAAAAAM"
+				return svc.FinishLogin(credential)
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+		"invalid, not base64url": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				options, _ := svc.BeginLogin("", false)
+				credential := ta.Login(options.Challenge)
+				credential.Response.Signature = "MEUC+/"
+				return svc.FinishLogin(credential)
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+		"invalid, challenge was issued for a registration": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				options, _ := svc.BeginRegistration(testUser)
+				return svc.FinishLogin(ta.Login(options.Challenge))
+			},
+			expectedErr: resp.ErrInvalidPasskeyChallenge,
+		},
+		"invalid, challenge expired": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				options, _ := svc.BeginLogin("", false)
+				timer.SetNow(time.Unix(testNow+challengeTimeLimit, 0))
+				return svc.FinishLogin(ta.Login(options.Challenge))
+			},
+			expectedErr: resp.ErrInvalidPasskeyChallenge,
+		},
+		"invalid, challenge redeemed twice": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				options, _ := svc.BeginLogin("", false)
+				svc.FinishLogin(ta.Login(options.Challenge))
+				return svc.FinishLogin(ta.Login(options.Challenge))
+			},
+			expectedErr: resp.ErrInvalidPasskeyChallenge,
+		},
+		"invalid, could not update passkey": {
+			repo: &testPasskeyRepo{errUpdate: resp.ErrCouldNotStorePasskey},
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				options, _ := svc.BeginLogin("", false)
+				return svc.FinishLogin(ta.Login(options.Challenge))
+			},
+			expectedErr: resp.ErrCouldNotStorePasskey,
+		},
+		"invalid, passkeys not configured": {
+			login: func(svc *passkeyService, timer clock, ta *util.TestAuthenticator) (*model.PasskeyLogin, error) {
+				options, _ := svc.BeginLogin("", false)
+				svc.rp = util.WebauthnRelyingParty{}
+				return svc.FinishLogin(ta.Login(options.Challenge))
+			},
+			expectedErr: resp.ErrPasskeysNotConfigured,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repo := tcase.repo
+			if repo == nil {
+				repo = &testPasskeyRepo{}
+			}
+			svc, timer := newTestService(repo, util.TestRelyingParty())
+			ta := util.NewTestAuthenticator(util.TestRelyingParty())
+			options, err := svc.BeginRegistration(testUser)
+			assert.NoError(t, err)
+			_, err = svc.FinishRegistration(2, &req.PasskeyRegistrationRequest{Name: "work laptop", Credential: *ta.Register(options.Challenge, options.User.Id)})
+			assert.NoError(t, err)
+
+			login, err := tcase.login(svc, timer, ta)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedLogin, login)
+			if tcase.expectedErr == nil {
+				assert.Equal(t, ta.SignCount, repo.passkeys[ta.Id()].SignCount)
+				assert.Equal(t, int64(testNow), repo.passkeys[ta.Id()].LastUsedAt)
+			}
+		})
+	}
+}
+
+func Test_BeginLogin(t *testing.T) {
+	repo := &testPasskeyRepo{}
+	svc, _ := newTestService(repo, util.TestRelyingParty())
+	options, err := svc.BeginLogin("wordbubble:push", true)
+	assert.NoError(t, err)
+	assert.Equal(t, &resp.PasskeyRequestOptions{
+		Challenge:        options.Challenge,
+		Timeout:          300000,
+		RpId:             "wordbubble.com",
+		AllowCredentials: []resp.PasskeyCredentialDescriptor{},
+		UserVerification: "preferred",
+	}, options)
+	stored := repo.challenges[util.HashToken(options.Challenge)]
+	assert.Equal(t, &challenge{ceremony: util.WebauthnGet, scope: "wordbubble:push", rememberMe: true, expiresAt: testNow + challengeTimeLimit}, stored)
+
+	svc, _ = newTestService(&testPasskeyRepo{}, util.WebauthnRelyingParty{})
+	_, err = svc.BeginLogin("", false)
+	assert.Equal(t, resp.ErrPasskeysNotConfigured, err)
+	svc, _ = newTestService(&testPasskeyRepo{errStoreChallenge: resp.ErrCouldNotStorePasskeyChallenge}, util.TestRelyingParty())
+	_, err = svc.BeginLogin("", false)
+	assert.Equal(t, resp.ErrCouldNotStorePasskeyChallenge, err)
+}
+
+func Test_RevokePasskey(t *testing.T) {
+	repo := &testPasskeyRepo{passkeys: map[string]*model.Passkey{"Z2Nvb2dsZS1wYXNza2V5": {Id: "Z2Nvb2dsZS1wYXNza2V5", UserId: 2, Name: "work laptop", CreatedAt: testNow}}}
+	svc, _ := newTestService(repo, util.TestRelyingParty())
+	assert.Equal(t, resp.ErrUnknownPasskey, svc.RevokePasskey(3, "Z2Nvb2dsZS1wYXNza2V5"))
+	passkeys, err := svc.ListPasskeys(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []resp.Passkey{{Id: "Z2Nvb2dsZS1wYXNza2V5", Name: "work laptop", CreatedAt: testNow}}, passkeys)
+	assert.NoError(t, svc.RevokePasskey(2, "Z2Nvb2dsZS1wYXNza2V5"))
+	passkeys, err = svc.ListPasskeys(2)
+	assert.NoError(t, err)
+	assert.Empty(t, passkeys)
+}
+
+type testPasskeyRepo struct {
+	challenges        map[string]*challenge
+	passkeys          map[string]*model.Passkey
+	registered        bool // every passkey is already registered to user 3
+	errStoreChallenge error
+	errStore          error
+	errGet            error
+	errUpdate         error
+	errList           error
+}
+
+func (repo *testPasskeyRepo) storeChallenge(challengeHash string, c *challenge) error {
+	if repo.errStoreChallenge != nil {
+		return repo.errStoreChallenge
+	}
+	if repo.challenges == nil {
+		repo.challenges = map[string]*challenge{}
+	}
+	repo.challenges[challengeHash] = c
+	return nil
+}
+
+func (repo *testPasskeyRepo) redeemChallenge(challengeHash string) (*challenge, error) {
+	c, ok := repo.challenges[challengeHash]
+	if !ok {
+		return nil, resp.ErrInvalidPasskeyChallenge
+	}
+	delete(repo.challenges, challengeHash)
+	return c, nil
+}
+
+func (repo *testPasskeyRepo) storePasskey(passkey *model.Passkey) error {
+	if repo.errStore != nil {
+		return repo.errStore
+	}
+	if repo.passkeys == nil {
+		repo.passkeys = map[string]*model.Passkey{}
+	}
+	repo.passkeys[passkey.Id] = passkey
+	return nil
+}
+
+func (repo *testPasskeyRepo) getPasskey(credentialId string) (*model.Passkey, error) {
+	if repo.registered {
+		return &model.Passkey{Id: credentialId, UserId: 3}, nil
+	}
+	return repo.passkeys[credentialId], repo.errGet
+}
+
+func (repo *testPasskeyRepo) updatePasskeyUse(credentialId string, signCount uint32, lastUsedAt int64) error {
+	if repo.errUpdate != nil {
+		return repo.errUpdate
+	}
+	repo.passkeys[credentialId].SignCount = signCount
+	repo.passkeys[credentialId].LastUsedAt = lastUsedAt
+	return nil
+}
+
+func (repo *testPasskeyRepo) listPasskeys(userId int64) ([]resp.Passkey, error) {
+	passkeys := make([]resp.Passkey, 0)
+	for _, passkey := range repo.passkeys {
+		if passkey.UserId == userId {
+			passkeys = append(passkeys, resp.Passkey{Id: passkey.Id, Name: passkey.Name, CreatedAt: passkey.CreatedAt, LastUsedAt: passkey.LastUsedAt})
+		}
+	}
+	return passkeys, repo.errList
+}
+
+func (repo *testPasskeyRepo) revokePasskey(userId int64, credentialId string) error {
+	passkey, ok := repo.passkeys[credentialId]
+	if !ok || passkey.UserId != userId {
+		return resp.ErrUnknownPasskey
+	}
+	delete(repo.passkeys, credentialId)
+	return nil
+}
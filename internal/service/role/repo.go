@@ -0,0 +1,41 @@
+package role
+
+import (
+	"database/sql"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type roleRepo struct {
+	db  *sql.DB
+	log util.Logger
+}
+
+func NewRoleRepo(config cfg.Config) *roleRepo {
+	return &roleRepo{
+		log: config.NewLogger("role_repo"),
+		db:  config.DB(),
+	}
+}
+
+func (repo *roleRepo) getRole(userId int64) (string, error) {
+	var role string
+	err := repo.db.QueryRow(GetRole, userId).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		repo.log.Error("could not retrieve role for user: %d, error: %s", userId, err)
+		return "", resp.ErrCouldNotRetrieveRole
+	}
+	return role, nil
+}
+
+func (repo *roleRepo) assignRole(userId int64, role string, assignedBy, updatedAt int64) error {
+	if _, err := repo.db.Exec(AssignRole, userId, role, assignedBy, updatedAt); err != nil {
+		repo.log.Error("could not assign role: %s to user: %d, error: %s", role, userId, err)
+		return resp.ErrCouldNotAssignRole
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package role
+
+import (
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HappyPath(t *testing.T) {
+	repo := NewRoleRepo(cfg.TestConfig())
+	userId := int64(5)
+
+	// A user that was never assigned a role has none stored
+	role, err := repo.getRole(userId)
+	assert.NoError(t, err)
+	assert.Empty(t, role)
+
+	// The bootstrapped admin makes the user a moderator
+	assert.NoError(t, repo.assignRole(userId, util.RoleModerator, 2, 100))
+	role, err = repo.getRole(userId)
+	assert.NoError(t, err)
+	assert.Equal(t, util.RoleModerator, role)
+
+	// Then later an admin, replacing the role they had
+	assert.NoError(t, repo.assignRole(userId, util.RoleAdmin, 2, 200))
+	role, err = repo.getRole(userId)
+	assert.NoError(t, err)
+	assert.Equal(t, util.RoleAdmin, role)
+
+	// Other users are untouched
+	role, err = repo.getRole(userId + 1)
+	assert.NoError(t, err)
+	assert.Empty(t, role)
+}
+
+func Test_NotSoHappyPath(t *testing.T) {
+	repo := NewRoleRepo(cfg.TestConfig())
+
+	// db closed
+	repo.db.Close()
+	_, err := repo.getRole(5)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotRetrieveRole.Error(), err.Error())
+
+	err = repo.assignRole(5, util.RoleAdmin, 2, 100)
+	assert.NotNil(t, err)
+	assert.Equal(t, resp.ErrCouldNotAssignRole.Error(), err.Error())
+}
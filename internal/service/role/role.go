@@ -0,0 +1,42 @@
+package role
+
+import (
+	"github.com/bchadwic/wordbubble/model"
+)
+
+const (
+	// users without a row have never been assigned a role, and are a user
+	GetRole    = `SELECT role FROM user_roles WHERE user_id = $1`
+	AssignRole = `INSERT INTO user_roles (user_id, role, assigned_by, updated_at) VALUES ($1, $2, $3, $4) ` +
+		`ON CONFLICT (user_id) DO UPDATE SET role = excluded.role, assigned_by = excluded.assigned_by, updated_at = excluded.updated_at`
+)
+
+// RoleService is the interface that the application
+// uses to interact with the roles of users
+type RoleService interface {
+	// Role finds the role a user has, the role every access token of the user is issued with.
+	// string is the role, util.RoleUser when the user was never assigned one, or empty string.
+	// error could be (500) resp.ErrCouldNotRetrieveRole or nil.
+	Role(userId int64) (string, error)
+	// AssignRole gives a user a role, the principal assigning it must have the permission to manage roles, and can't change their own role,
+	// so there's always an admin left to assign roles. The user gets the role when their access token is next refreshed
+	// error could be (401) resp.ErrUnauthorized, (403) resp.ErrInsufficientRole, (400) resp.ErrInvalidRole,
+	// (403) resp.ErrCannotChangeOwnRole, (500) resp.ErrCouldNotAssignRole or nil.
+	AssignRole(assigner *model.Principal, userId int64, role string) error
+	// BootstrapAdmin makes a user an admin without anyone assigning it, for the user set in the config when the api starts.
+	// Nothing changes once the user has a role, so an admin can still demote them
+	// error could be (500) resp.ErrCouldNotRetrieveRole, (500) resp.ErrCouldNotAssignRole or nil.
+	BootstrapAdmin(userId int64) error
+}
+
+// RoleRepo is the interface that the service layer
+// uses to interact with roles in the database
+type RoleRepo interface {
+	// getRole finds the role assigned to a user.
+	// string is the role, or empty string when the user was never assigned one.
+	// error can be (500) resp.ErrCouldNotRetrieveRole or nil.
+	getRole(userId int64) (string, error)
+	// assignRole stores the role of a user, replacing the role they had, along with who assigned it, zero when it was bootstrapped.
+	// error can be (500) resp.ErrCouldNotAssignRole or nil.
+	assignRole(userId int64, role string, assignedBy, updatedAt int64) error
+}
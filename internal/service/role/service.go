@@ -0,0 +1,65 @@
+package role
+
+import (
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+type roleService struct {
+	log   util.Logger
+	timer util.Timer
+	repo  RoleRepo
+}
+
+func NewRoleService(cfg cfg.Config, repo RoleRepo) *roleService {
+	return &roleService{
+		log:   cfg.NewLogger("roles"),
+		timer: cfg.Timer(),
+		repo:  repo,
+	}
+}
+
+func (svc *roleService) Role(userId int64) (string, error) {
+	role, err := svc.repo.getRole(userId)
+	if err != nil {
+		return "", err
+	}
+	if role == "" {
+		return util.RoleUser, nil
+	}
+	return role, nil
+}
+
+func (svc *roleService) AssignRole(assigner *model.Principal, userId int64, role string) error {
+	if err := util.Authorize(assigner, util.PermissionManageRoles); err != nil {
+		return err
+	}
+	if err := util.ValidRole(role); err != nil {
+		return err
+	}
+	if assigner.UserId == userId {
+		return resp.ErrCannotChangeOwnRole
+	}
+	if err := svc.repo.assignRole(userId, role, assigner.UserId, svc.timer.Now().Unix()); err != nil {
+		return err
+	}
+	svc.log.Info("user: %d assigned role: %s to user: %d", assigner.UserId, role, userId)
+	return nil
+}
+
+func (svc *roleService) BootstrapAdmin(userId int64) error {
+	role, err := svc.repo.getRole(userId)
+	if err != nil {
+		return err
+	}
+	if role != "" { // the api was started before, or the user's role was assigned since and isn't overridden
+		return nil
+	}
+	if err = svc.repo.assignRole(userId, util.RoleAdmin, 0, svc.timer.Now().Unix()); err != nil {
+		return err
+	}
+	svc.log.Info("bootstrapped user: %d as an admin", userId)
+	return nil
+}
@@ -0,0 +1,181 @@
+package role
+
+import (
+	"testing"
+
+	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Role(t *testing.T) {
+	tests := map[string]struct {
+		repo         *testRoleRepo
+		expectedRole string
+		expectedErr  error
+	}{
+		"valid": {
+			repo:         &testRoleRepo{role: util.RoleModerator},
+			expectedRole: util.RoleModerator,
+		},
+		"valid, never assigned a role": {
+			repo:         &testRoleRepo{},
+			expectedRole: util.RoleUser,
+		},
+		"invalid, database error": {
+			repo:        &testRoleRepo{errGet: resp.ErrCouldNotRetrieveRole},
+			expectedErr: resp.ErrCouldNotRetrieveRole,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			role, err := NewRoleService(cfg.TestConfig(), tcase.repo).Role(2)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedRole, role)
+		})
+	}
+}
+
+func Test_AssignRole(t *testing.T) {
+	admin := &model.Principal{UserId: 2, Role: util.RoleAdmin}
+	tests := map[string]struct {
+		assigner    *model.Principal
+		userId      int64
+		role        string
+		repo        *testRoleRepo
+		expectedErr error
+	}{
+		"valid": {
+			assigner: admin,
+			userId:   5,
+			role:     util.RoleModerator,
+			repo:     &testRoleRepo{},
+		},
+		"valid, admin takes a role away": {
+			assigner: admin,
+			userId:   5,
+			role:     util.RoleUser,
+			repo:     &testRoleRepo{},
+		},
+		"invalid, moderator can't assign roles": {
+			assigner:    &model.Principal{UserId: 3, Role: util.RoleModerator},
+			userId:      5,
+			role:        util.RoleModerator,
+			repo:        &testRoleRepo{},
+			expectedErr: resp.ErrInsufficientRole,
+		},
+		"invalid, user can't make themselves an admin": {
+			assigner:    &model.Principal{UserId: 5, Role: util.RoleUser},
+			userId:      5,
+			role:        util.RoleAdmin,
+			repo:        &testRoleRepo{},
+			expectedErr: resp.ErrInsufficientRole,
+		},
+		"invalid, no assigner": {
+			userId:      5,
+			role:        util.RoleModerator,
+			repo:        &testRoleRepo{},
+			expectedErr: resp.ErrUnauthorized,
+		},
+		"invalid, unknown role": {
+			assigner:    admin,
+			userId:      5,
+			role:        "superuser",
+			repo:        &testRoleRepo{},
+			expectedErr: resp.ErrInvalidRole,
+		},
+		"invalid, admin changes their own role": {
+			assigner:    admin,
+			userId:      2,
+			role:        util.RoleUser,
+			repo:        &testRoleRepo{},
+			expectedErr: resp.ErrCannotChangeOwnRole,
+		},
+		"invalid, database error": {
+			assigner:    admin,
+			userId:      5,
+			role:        util.RoleModerator,
+			repo:        &testRoleRepo{errAssign: resp.ErrCouldNotAssignRole},
+			expectedErr: resp.ErrCouldNotAssignRole,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(100))
+			err := NewRoleService(cfg, tcase.repo).AssignRole(tcase.assigner, tcase.userId, tcase.role)
+			assert.Equal(t, tcase.expectedErr, err)
+			if tcase.expectedErr != nil {
+				assert.Empty(t, tcase.repo.assigned, "no role is stored")
+				return
+			}
+			assert.Equal(t, &assignment{userId: tcase.userId, role: tcase.role, assignedBy: tcase.assigner.UserId, updatedAt: 100}, tcase.repo.assigned)
+		})
+	}
+}
+
+func Test_BootstrapAdmin(t *testing.T) {
+	tests := map[string]struct {
+		repo             *testRoleRepo
+		expectedAssigned *assignment
+		expectedErr      error
+	}{
+		"valid": {
+			repo:             &testRoleRepo{},
+			expectedAssigned: &assignment{userId: 5, role: util.RoleAdmin, updatedAt: 100},
+		},
+		"valid, already an admin": {
+			repo: &testRoleRepo{role: util.RoleAdmin},
+		},
+		"valid, demoted admin isn't promoted again": {
+			repo: &testRoleRepo{role: util.RoleUser},
+		},
+		"invalid, role couldn't be retrieved": {
+			repo:        &testRoleRepo{errGet: resp.ErrCouldNotRetrieveRole},
+			expectedErr: resp.ErrCouldNotRetrieveRole,
+		},
+		"invalid, role couldn't be assigned": {
+			repo:        &testRoleRepo{errAssign: resp.ErrCouldNotAssignRole},
+			expectedErr: resp.ErrCouldNotAssignRole,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(100))
+			err := NewRoleService(cfg, tcase.repo).BootstrapAdmin(5)
+			assert.Equal(t, tcase.expectedErr, err)
+			if tcase.expectedErr == nil {
+				assert.Equal(t, tcase.expectedAssigned, tcase.repo.assigned)
+			}
+		})
+	}
+}
+
+type assignment struct {
+	userId     int64
+	role       string
+	assignedBy int64
+	updatedAt  int64
+}
+
+type testRoleRepo struct {
+	role      string
+	errGet    error
+	errAssign error
+	assigned  *assignment
+}
+
+func (trepo *testRoleRepo) getRole(userId int64) (string, error) {
+	return trepo.role, trepo.errGet
+}
+
+func (trepo *testRoleRepo) assignRole(userId int64, role string, assignedBy, updatedAt int64) error {
+	if trepo.errAssign != nil {
+		return trepo.errAssign
+	}
+	trepo.assigned = &assignment{userId: userId, role: role, assignedBy: assignedBy, updatedAt: updatedAt}
+	return nil
+}
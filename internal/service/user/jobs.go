@@ -0,0 +1,30 @@
+package user
+
+import (
+	"context"
+
+	"github.com/bchadwic/wordbubble/internal/job"
+	"github.com/bchadwic/wordbubble/util"
+)
+
+// NewPasswordResetCleanupJob creates a job that removes password reset tokens that expired before they were used
+func NewPasswordResetCleanupJob(timer util.Timer, cleaner UserCleaner) job.Job {
+	return job.Job{
+		Name:     "password_reset_cleanup",
+		Interval: PasswordResetCleanerRate,
+		Run: func(ctx context.Context) error {
+			return cleaner.CleanupExpiredPasswordResets(timer.Now().Unix())
+		},
+	}
+}
+
+// NewVerificationCleanupJob creates a job that removes email verification tokens that expired before they were used
+func NewVerificationCleanupJob(timer util.Timer, cleaner UserCleaner) job.Job {
+	return job.Job{
+		Name:     "verification_cleanup",
+		Interval: VerificationCleanerRate,
+		Run: func(ctx context.Context) error {
+			return cleaner.CleanupExpiredVerifications(timer.Now().Unix())
+		},
+	}
+}
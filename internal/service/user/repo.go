@@ -42,9 +42,149 @@ func (repo *userRepo) retrieveUserByUsername(username string) (*model.User, erro
 	return repo.mapUserRow(repo.db.QueryRow(RetrieveUserByUsername, username))
 }
 
+func (repo *userRepo) retrieveUserById(userId int64) (*model.User, error) {
+	return repo.mapUserRow(repo.db.QueryRow(RetrieveUserById, userId))
+}
+
+func (repo *userRepo) updatePassword(userId int64, password string) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return resp.ErrCouldNotResetPassword
+	}
+	defer tx.Rollback()
+	rs, err := tx.Exec(UpdatePassword, password, userId)
+	if err != nil {
+		repo.log.Error("could not update password for user: %d, error: %s", userId, err)
+		return resp.ErrCouldNotResetPassword
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 {
+		return resp.ErrCouldNotResetPassword
+	}
+	if _, err = tx.Exec(DeletePasswordResets, userId); err != nil {
+		return resp.ErrCouldNotResetPassword
+	}
+	if err = tx.Commit(); err != nil {
+		return resp.ErrCouldNotResetPassword
+	}
+	return nil
+}
+
+func (repo *userRepo) rehashPassword(userId int64, oldHash, newHash string) error {
+	rs, err := repo.db.Exec(RehashPassword, newHash, userId, oldHash)
+	if err != nil {
+		repo.log.Error("could not rehash password for user: %d, error: %s", userId, err)
+		return resp.ErrCouldNotRehashPassword
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 {
+		repo.log.Info("password of user: %d changed before it could be rehashed", userId)
+	}
+	return nil
+}
+
+func (repo *userRepo) storePasswordReset(tokenHash string, userId, expiresAt int64) error {
+	if _, err := repo.db.Exec(StorePasswordReset, tokenHash, userId, expiresAt); err != nil {
+		repo.log.Error("could not store password reset for user: %d, error: %s", userId, err)
+		return resp.ErrCouldNotStorePasswordReset
+	}
+	return nil
+}
+
+func (repo *userRepo) redeemPasswordReset(tokenHash string) (int64, int64, error) {
+	row := repo.db.QueryRow(GetPasswordReset, tokenHash)
+	var userId, expiresAt int64
+	if err := row.Scan(&userId, &expiresAt); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			repo.log.Error("could not find password reset, error: %s", err)
+			return 0, 0, resp.ErrCouldNotResetPassword
+		}
+		return 0, 0, resp.ErrInvalidResetToken
+	}
+	rs, err := repo.db.Exec(RedeemPasswordReset, tokenHash)
+	if err != nil {
+		repo.log.Error("could not redeem password reset, error: %s", err)
+		return 0, 0, resp.ErrCouldNotResetPassword
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 { // the token was redeemed by another request in the meantime
+		return 0, 0, resp.ErrInvalidResetToken
+	}
+	return userId, expiresAt, nil
+}
+
+func (repo *userRepo) CleanupExpiredPasswordResets(now int64) error {
+	rs, err := repo.db.Exec(CleanupPasswordResets, now)
+	if err != nil {
+		return resp.ErrCouldNotCleanupPasswordResets
+	}
+	amt, _ := rs.RowsAffected()
+	repo.log.Info("password reset cleaner deleted: %d tokens", amt)
+	return nil
+}
+
+func (repo *userRepo) verifyUser(userId int64) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return resp.ErrCouldNotVerifyEmail
+	}
+	defer tx.Rollback()
+	rs, err := tx.Exec(VerifyUser, userId)
+	if err != nil {
+		repo.log.Error("could not verify email for user: %d, error: %s", userId, err)
+		return resp.ErrCouldNotVerifyEmail
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 {
+		return resp.ErrCouldNotVerifyEmail
+	}
+	if _, err = tx.Exec(DeleteVerifications, userId); err != nil {
+		return resp.ErrCouldNotVerifyEmail
+	}
+	if err = tx.Commit(); err != nil {
+		return resp.ErrCouldNotVerifyEmail
+	}
+	return nil
+}
+
+func (repo *userRepo) storeVerification(tokenHash string, userId, expiresAt int64) error {
+	if _, err := repo.db.Exec(StoreVerification, tokenHash, userId, expiresAt); err != nil {
+		repo.log.Error("could not store verification for user: %d, error: %s", userId, err)
+		return resp.ErrCouldNotStoreVerification
+	}
+	return nil
+}
+
+func (repo *userRepo) redeemVerification(tokenHash string) (int64, int64, error) {
+	row := repo.db.QueryRow(GetVerification, tokenHash)
+	var userId, expiresAt int64
+	if err := row.Scan(&userId, &expiresAt); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			repo.log.Error("could not find verification, error: %s", err)
+			return 0, 0, resp.ErrCouldNotVerifyEmail
+		}
+		return 0, 0, resp.ErrInvalidVerificationToken
+	}
+	rs, err := repo.db.Exec(RedeemVerification, tokenHash)
+	if err != nil {
+		repo.log.Error("could not redeem verification, error: %s", err)
+		return 0, 0, resp.ErrCouldNotVerifyEmail
+	}
+	if amt, _ := rs.RowsAffected(); amt <= 0 { // the token was redeemed by another request in the meantime
+		return 0, 0, resp.ErrInvalidVerificationToken
+	}
+	return userId, expiresAt, nil
+}
+
+func (repo *userRepo) CleanupExpiredVerifications(now int64) error {
+	rs, err := repo.db.Exec(CleanupVerifications, now)
+	if err != nil {
+		return resp.ErrCouldNotCleanupVerifications
+	}
+	amt, _ := rs.RowsAffected()
+	repo.log.Info("verification cleaner deleted: %d tokens", amt)
+	return nil
+}
+
 func (repo *userRepo) mapUserRow(row *sql.Row) (*model.User, error) {
 	var dbUser model.User
-	if err := row.Scan(&dbUser.Id, &dbUser.Username, &dbUser.Email, &dbUser.Password); err != nil {
+	if err := row.Scan(&dbUser.Id, &dbUser.Username, &dbUser.Email, &dbUser.Password, &dbUser.Verified); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, resp.ErrUnknownUser
 		}
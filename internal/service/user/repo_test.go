@@ -40,11 +40,78 @@ func Test_HappyPath(t *testing.T) {
 	assert.Equal(t, expected.Email, actual.Email)
 	assert.Equal(t, expected.Password, actual.Password)
 
+	// same as previous step but with the user id from a token
+	actual, err = repo.retrieveUserById(expected.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, expected.Username, actual.Username)
+	assert.Equal(t, expected.Email, actual.Email)
+
 	// misstyped my email logining in
 	actual, err = repo.retrieveUserByEmail("benchadwic87@gmail.com")
 	assert.NotNil(t, err)
 	assert.ErrorIs(t, resp.ErrUnknownUser, err)
 	assert.Nil(t, actual)
+
+	// verified my email with the second of two emails sent
+	assert.NoError(t, repo.storeVerification("hash.verify.first", expected.Id, 1000))
+	assert.NoError(t, repo.storeVerification("hash.verify.second", expected.Id, 2000))
+	userId, expiresAt, err := repo.redeemVerification("hash.verify.second")
+	assert.NoError(t, err)
+	assert.Equal(t, expected.Id, userId)
+	assert.Equal(t, int64(2000), expiresAt)
+	_, _, err = repo.redeemVerification("hash.verify.second")
+	assert.Equal(t, resp.ErrInvalidVerificationToken, err)
+	assert.NoError(t, repo.verifyUser(expected.Id))
+	actual, err = repo.retrieveUserById(expected.Id)
+	assert.NoError(t, err)
+	assert.True(t, actual.Verified)
+	_, _, err = repo.redeemVerification("hash.verify.first")
+	assert.Equal(t, resp.ErrInvalidVerificationToken, err)
+
+	// verification tokens that were never used are cleaned up once they expire
+	assert.NoError(t, repo.storeVerification("hash.verify.expired", expected.Id, 1000))
+	assert.NoError(t, repo.storeVerification("hash.verify.active", expected.Id, 3000))
+	assert.NoError(t, repo.CleanupExpiredVerifications(2000))
+	_, _, err = repo.redeemVerification("hash.verify.expired")
+	assert.Equal(t, resp.ErrInvalidVerificationToken, err)
+	_, _, err = repo.redeemVerification("hash.verify.active")
+	assert.NoError(t, err)
+
+	// forgot my password, and asked for a reset twice
+	assert.NoError(t, repo.storePasswordReset("hash.first", expected.Id, 1000))
+	assert.NoError(t, repo.storePasswordReset("hash.second", expected.Id, 2000))
+
+	// the first email arrives, the token can only be used once
+	userId, expiresAt, err = repo.redeemPasswordReset("hash.first")
+	assert.NoError(t, err)
+	assert.Equal(t, expected.Id, userId)
+	assert.Equal(t, int64(1000), expiresAt)
+	_, _, err = repo.redeemPasswordReset("hash.first")
+	assert.Equal(t, resp.ErrInvalidResetToken, err)
+
+	// choosing a new password removes the other token
+	assert.NoError(t, repo.updatePassword(expected.Id, "new-test-password"))
+	actual, err = repo.retrieveUserById(expected.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "new-test-password", actual.Password)
+	_, _, err = repo.redeemPasswordReset("hash.second")
+	assert.Equal(t, resp.ErrInvalidResetToken, err)
+
+	// an outdated hash is rehashed at login, but not after the password was changed
+	assert.NoError(t, repo.rehashPassword(expected.Id, "new-test-password", "rehashed-test-password"))
+	assert.NoError(t, repo.rehashPassword(expected.Id, "new-test-password", "stale-test-password"))
+	actual, err = repo.retrieveUserById(expected.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "rehashed-test-password", actual.Password)
+
+	// tokens that were never used are cleaned up once they expire
+	assert.NoError(t, repo.storePasswordReset("hash.expired", expected.Id, 1000))
+	assert.NoError(t, repo.storePasswordReset("hash.active", expected.Id, 3000))
+	assert.NoError(t, repo.CleanupExpiredPasswordResets(2000))
+	_, _, err = repo.redeemPasswordReset("hash.expired")
+	assert.Equal(t, resp.ErrInvalidResetToken, err)
+	_, _, err = repo.redeemPasswordReset("hash.active")
+	assert.NoError(t, err)
 }
 
 func Test_NotSoHappyPath(t *testing.T) {
@@ -63,3 +130,35 @@ func Test_NotSoHappyPath(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.ErrorIs(t, resp.ErrSQLMappingError, err)
 }
+
+func Test_ClosedDatabase(t *testing.T) {
+	repo := NewUserRepo(cfg.TestConfig())
+
+	repo.db.Close()
+	err := repo.updatePassword(1, "new-test-password")
+	assert.Equal(t, resp.ErrCouldNotResetPassword, err)
+
+	err = repo.rehashPassword(1, "test-password", "new-test-password")
+	assert.Equal(t, resp.ErrCouldNotRehashPassword, err)
+
+	err = repo.storePasswordReset("hash.token", 1, 1000)
+	assert.Equal(t, resp.ErrCouldNotStorePasswordReset, err)
+
+	_, _, err = repo.redeemPasswordReset("hash.token")
+	assert.Equal(t, resp.ErrCouldNotResetPassword, err)
+
+	err = repo.CleanupExpiredPasswordResets(2000)
+	assert.Equal(t, resp.ErrCouldNotCleanupPasswordResets, err)
+
+	err = repo.verifyUser(1)
+	assert.Equal(t, resp.ErrCouldNotVerifyEmail, err)
+
+	err = repo.storeVerification("hash.token", 1, 1000)
+	assert.Equal(t, resp.ErrCouldNotStoreVerification, err)
+
+	_, _, err = repo.redeemVerification("hash.token")
+	assert.Equal(t, resp.ErrCouldNotVerifyEmail, err)
+
+	err = repo.CleanupExpiredVerifications(2000)
+	assert.Equal(t, resp.ErrCouldNotCleanupVerifications, err)
+}
@@ -2,23 +2,32 @@ package user
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	cfg "github.com/bchadwic/wordbubble/internal/config"
 	"github.com/bchadwic/wordbubble/model"
 	"github.com/bchadwic/wordbubble/model/resp"
 	"github.com/bchadwic/wordbubble/util"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type userService struct {
-	repo UserRepo
-	log  util.Logger
+	repo   UserRepo
+	log    util.Logger
+	timer  util.Timer
+	mailer util.Mailer
+	hasher util.PasswordHasher
 }
 
 func NewUserService(cfg cfg.Config, repo UserRepo) *userService {
 	return &userService{
-		log:  cfg.NewLogger("users"),
-		repo: repo,
+		log:    cfg.NewLogger("users"),
+		timer:  cfg.Timer(),
+		mailer: cfg.Mailer(),
+		hasher: cfg.PasswordHasher(),
+		repo:   repo,
 	}
 }
 
@@ -26,11 +35,11 @@ func (svc *userService) AddUser(user *model.User) error {
 	if err := svc.verifyUserUniqueness(user); err != nil {
 		return err
 	}
-	hashedPasswordBytes, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPassword, err := svc.hasher.Hash(user.Password)
 	if err != nil {
 		return resp.ErrCouldNotBeHashPassword
 	}
-	user.Password = string(hashedPasswordBytes)
+	user.Password = hashedPassword
 	id, err := svc.repo.addUser(user)
 	if err != nil {
 		return err
@@ -39,6 +48,29 @@ func (svc *userService) AddUser(user *model.User) error {
 	return nil
 }
 
+func (svc *userService) AddFederatedUser(email, usernameHint string) (*model.User, error) {
+	username, err := svc.chooseUsername(federatedUsername(email, usernameHint))
+	if err != nil {
+		return nil, err
+	}
+	user := &model.User{
+		Username: username,
+		Email:    email,
+		Password: util.RandomString(32), // never sent to anyone, the user logs in with their identity provider
+	}
+	if err = svc.AddUser(user); err != nil {
+		return nil, err
+	}
+	// the identity provider verified the email, it doesn't need to be verified again
+	if err = svc.repo.verifyUser(user.Id); err != nil {
+		return nil, err
+	}
+	svc.log.Info("federated user added: %d", user.Id)
+	user.Password = "" // sanitize
+	user.Verified = true
+	return user, nil
+}
+
 func (svc *userService) RetrieveUnauthenticatedUser(userStr string) (*model.User, error) {
 	user, err := svc.retrieveUserByString(userStr)
 	if err != nil {
@@ -53,13 +85,127 @@ func (svc *userService) RetrieveAuthenticatedUser(userStr, password string) (*mo
 	if err != nil {
 		return nil, err
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+	needsRehash, err := svc.hasher.Verify(user.Password, password)
+	if err != nil {
+		if err != resp.ErrInvalidCredentials {
+			svc.log.Error("password hash of user: %d could not be read, error: %s", user.Id, err)
+		}
 		return nil, resp.ErrInvalidCredentials
 	}
+	if needsRehash {
+		svc.rehashPassword(user, password)
+	}
 	user.Password = "" // no reason to pass the password around
 	return user, nil   // successfully authenticated
 }
 
+// rehashPassword replaces an outdated password hash while the password is known,
+// a failure is only logged since the old hash still works
+func (svc *userService) rehashPassword(user *model.User, password string) {
+	hashedPassword, err := svc.hasher.Hash(password)
+	if err != nil {
+		svc.log.Error("could not rehash password for user: %d, error: %s", user.Id, err)
+		return
+	}
+	if err = svc.repo.rehashPassword(user.Id, user.Password, hashedPassword); err != nil {
+		return
+	}
+	svc.log.Info("password rehashed for user: %d", user.Id)
+}
+
+func (svc *userService) RetrieveUserById(userId int64) (*model.User, error) {
+	user, err := svc.repo.retrieveUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = "" // sanitize
+	return user, nil
+}
+
+func (svc *userService) ForgotPassword(userStr string) error {
+	user, err := svc.retrieveUserByString(userStr)
+	if errors.Is(err, resp.ErrUnknownUser) {
+		svc.log.Info("password reset requested for an unknown user")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	token := util.RandomString(32)
+	expiresAt := svc.timer.Now().Unix() + resetTokenTimeLimit
+	if err = svc.repo.storePasswordReset(util.HashToken(token), user.Id, expiresAt); err != nil {
+		return err
+	}
+	body := fmt.Sprintf("A password reset was requested for %s. Send this token to /v1/password/reset within %d minutes "+
+		"to choose a new password, it can only be used once:\n\n%s\n\nIf you didn't request a reset, you can ignore this email.",
+		user.Username, resetTokenTimeLimit/60, token)
+	if err = svc.mailer.Send(user.Email, "Reset your wordbubble password", body); err != nil {
+		svc.log.Error("could not send password reset email for user: %d, error: %s", user.Id, err)
+		return resp.ErrCouldNotSendEmail
+	}
+	svc.log.Info("password reset sent for user: %d", user.Id)
+	return nil
+}
+
+func (svc *userService) ResetPassword(token, password string) (int64, error) {
+	if err := util.ValidPassword(password); err != nil {
+		return 0, err
+	}
+	userId, expiresAt, err := svc.repo.redeemPasswordReset(util.HashToken(token))
+	if err != nil {
+		return 0, err
+	}
+	if expiresAt <= svc.timer.Now().Unix() {
+		return 0, resp.ErrInvalidResetToken
+	}
+	hashedPassword, err := svc.hasher.Hash(password)
+	if err != nil {
+		return 0, resp.ErrCouldNotBeHashPassword
+	}
+	if err = svc.repo.updatePassword(userId, hashedPassword); err != nil {
+		return 0, err
+	}
+	svc.log.Info("password reset for user: %d", userId)
+	return userId, nil
+}
+
+func (svc *userService) SendVerification(userId int64) error {
+	user, err := svc.repo.retrieveUserById(userId)
+	if err != nil {
+		return err
+	}
+	if user.Verified {
+		return resp.ErrEmailAlreadyVerified
+	}
+	token := util.RandomString(32)
+	expiresAt := svc.timer.Now().Unix() + verificationTokenTimeLimit
+	if err = svc.repo.storeVerification(util.HashToken(token), user.Id, expiresAt); err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Welcome to wordbubble, %s! Verify your email by opening /v1/verify?token=%s within %d hours.",
+		user.Username, token, verificationTokenTimeLimit/(60*60))
+	if err = svc.mailer.Send(user.Email, "Verify your wordbubble email", body); err != nil {
+		svc.log.Error("could not send verification email for user: %d, error: %s", user.Id, err)
+		return resp.ErrCouldNotSendEmail
+	}
+	svc.log.Info("verification sent for user: %d", user.Id)
+	return nil
+}
+
+func (svc *userService) VerifyEmail(token string) error {
+	userId, expiresAt, err := svc.repo.redeemVerification(util.HashToken(token))
+	if err != nil {
+		return err
+	}
+	if expiresAt <= svc.timer.Now().Unix() {
+		return resp.ErrInvalidVerificationToken
+	}
+	if err = svc.repo.verifyUser(userId); err != nil {
+		return err
+	}
+	svc.log.Info("email verified for user: %d", userId)
+	return nil
+}
+
 // verify the uniqueness of a user against the database
 // soon to be deprecated. A stored procedure should be made to
 // give a code on which uniqueness constraint has been violated
@@ -82,6 +228,24 @@ func (svc *userService) verifyUserUniqueness(uniqueUser *model.User) error {
 	return nil
 }
 
+// chooseUsername finds a username that isn't taken, first the base itself, then the base with a random suffix
+func (svc *userService) chooseUsername(base string) (string, error) {
+	username := base
+	for attempt := 0; attempt < federatedUsernameAttempts; attempt++ {
+		if attempt > 0 {
+			username = base + "_" + util.RandomString(2)
+		}
+		_, err := svc.repo.retrieveUserByUsername(username)
+		if errors.Is(err, resp.ErrUnknownUser) {
+			return username, nil
+		} else if err != nil {
+			return "", resp.ErrCouldNotDetermineUserExistence
+		}
+	}
+	svc.log.Warn("could not choose a username for a federated user from: %s", base)
+	return "", resp.ErrCouldNotChooseUsername
+}
+
 func (svc *userService) retrieveUserByString(userStr string) (*model.User, error) {
 	switch {
 	case util.ValidEmail(userStr) == nil:
@@ -92,3 +256,33 @@ func (svc *userService) retrieveUserByString(userStr string) (*model.User, error
 		return nil, resp.ErrCouldNotDetermineUserType
 	}
 }
+
+// federatedUsername turns a username hint, or the local part of the email when there's no hint,
+// into a username that passes util.ValidUsername, short enough to be given a suffix
+func federatedUsername(email, usernameHint string) string {
+	base := usernameHint
+	if base == "" {
+		base = email
+	}
+	if at := strings.Index(base, "@"); at >= 0 {
+		base = base[:at]
+	}
+	var username strings.Builder
+	for _, c := range base {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsNumber(c) || c == '_':
+		case c == '.' || c == '-' || c == '+' || c == ' ':
+			c = '_'
+		default:
+			continue
+		}
+		if username.Len()+utf8.RuneLen(c) > federatedUsernameLength {
+			break
+		}
+		username.WriteRune(c)
+	}
+	if username.Len() == 0 {
+		return "user"
+	}
+	return username.String()
+}
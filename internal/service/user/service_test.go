@@ -1,13 +1,15 @@
 package user
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	cfg "github.com/bchadwic/wordbubble/internal/config"
 	"github.com/bchadwic/wordbubble/model"
 	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func Test_AddUser(t *testing.T) {
@@ -77,13 +79,119 @@ func Test_AddUser(t *testing.T) {
 			} else {
 				assert.Nil(t, err)
 				assert.Equal(t, tcase.repo.lastInsertId, tcase.user.Id)
-				err := bcrypt.CompareHashAndPassword([]byte(tcase.user.Password), []byte(beforeEncryptedPassword))
+				needsRehash, err := util.TestPasswordHasher().Verify(tcase.user.Password, beforeEncryptedPassword)
 				assert.Nil(t, err)
+				assert.False(t, needsRehash)
 			}
 		})
 	}
 }
 
+func Test_AddFederatedUser(t *testing.T) {
+	tests := map[string]struct {
+		email            string
+		usernameHint     string
+		repo             *testUserRepo
+		expectedUsername string
+		expectedErr      error
+	}{
+		"valid, username hint": {
+			email:            "ben@wordbubble.com",
+			usernameHint:     "ben",
+			repo:             &testUserRepo{takenUsernames: map[string]bool{}, errRetrieveEmail: resp.ErrUnknownUser},
+			expectedUsername: "ben",
+		},
+		"valid, no username hint": {
+			email:            "ben.chadwick+wb@wordbubble.com",
+			repo:             &testUserRepo{takenUsernames: map[string]bool{}, errRetrieveEmail: resp.ErrUnknownUser},
+			expectedUsername: "ben_chadwick_wb",
+		},
+		"valid, username hint is an email": {
+			email:            "ben@wordbubble.com",
+			usernameHint:     "bchadwic@example.com",
+			repo:             &testUserRepo{takenUsernames: map[string]bool{}, errRetrieveEmail: resp.ErrUnknownUser},
+			expectedUsername: "bchadwic",
+		},
+		"valid, invalid characters are dropped": {
+			email:            "ben@wordbubble.com",
+			usernameHint:     "Bén Chadwick-Smith!",
+			repo:             &testUserRepo{takenUsernames: map[string]bool{}, errRetrieveEmail: resp.ErrUnknownUser},
+			expectedUsername: "Bén_Chadwick_Smith",
+		},
+		"valid, nothing usable in the username hint": {
+			email:            "ben@wordbubble.com",
+			usernameHint:     "!!!",
+			repo:             &testUserRepo{takenUsernames: map[string]bool{}, errRetrieveEmail: resp.ErrUnknownUser},
+			expectedUsername: "user",
+		},
+		"valid, long username hint is cut short": {
+			email:            "ben@wordbubble.com",
+			usernameHint:     strings.Repeat("é", 30),
+			repo:             &testUserRepo{takenUsernames: map[string]bool{}, errRetrieveEmail: resp.ErrUnknownUser},
+			expectedUsername: strings.Repeat("é", 17),
+		},
+		"valid, username is taken": {
+			email:        "ben@wordbubble.com",
+			usernameHint: "ben",
+			repo:         &testUserRepo{takenUsernames: map[string]bool{"ben": true}, errRetrieveEmail: resp.ErrUnknownUser},
+		},
+		"invalid, could not determine if the username is taken": {
+			email:        "ben@wordbubble.com",
+			usernameHint: "ben",
+			repo:         &testUserRepo{errRetrieveUser: resp.ErrSQLMappingError},
+			expectedErr:  resp.ErrCouldNotDetermineUserExistence,
+		},
+		"invalid, every username tried is taken": {
+			email:        "ben@wordbubble.com",
+			usernameHint: "ben",
+			repo:         &testUserRepo{userRetrieveUserByUsername: &model.User{}},
+			expectedErr:  resp.ErrCouldNotChooseUsername,
+		},
+		"invalid, email is taken": {
+			email:        "ben@wordbubble.com",
+			usernameHint: "ben",
+			repo:         &testUserRepo{takenUsernames: map[string]bool{}, userRetrieveUserByEmail: &model.User{}},
+			expectedErr:  resp.ErrUserWithEmailAlreadyExists,
+		},
+		"invalid, could not verify the user": {
+			email:        "ben@wordbubble.com",
+			usernameHint: "ben",
+			repo: &testUserRepo{
+				takenUsernames:   map[string]bool{},
+				errRetrieveEmail: resp.ErrUnknownUser,
+				errVerifyUser:    resp.ErrCouldNotVerifyEmail,
+			},
+			expectedErr: resp.ErrCouldNotVerifyEmail,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			tcase.repo.lastInsertId = 7
+			svc := NewUserService(cfg.TestConfig(), tcase.repo)
+			user, err := svc.AddFederatedUser(tcase.email, tcase.usernameHint)
+			if tcase.expectedErr != nil {
+				assert.Equal(t, tcase.expectedErr, err)
+				assert.Nil(t, user)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, util.ValidUsername(user.Username))
+			if tcase.expectedUsername != "" {
+				assert.Equal(t, tcase.expectedUsername, user.Username)
+			} else {
+				assert.True(t, strings.HasPrefix(user.Username, tcase.usernameHint+"_"))
+				assert.False(t, tcase.repo.takenUsernames[user.Username])
+			}
+			assert.Equal(t, int64(7), user.Id)
+			assert.Equal(t, tcase.email, user.Email)
+			assert.True(t, user.Verified)
+			assert.Empty(t, user.Password)
+			assert.Equal(t, int64(7), tcase.repo.verifiedUserId)
+			assert.NotEmpty(t, tcase.repo.addedPassword, "a random password is hashed and stored")
+		})
+	}
+}
+
 func Test_RetrieveUnauthenticatedUser(t *testing.T) {
 	tests := map[string]struct {
 		userStr     string
@@ -139,10 +247,11 @@ func Test_RetrieveUnauthenticatedUser(t *testing.T) {
 
 func Test_RetrieveAuthenticatedUser(t *testing.T) {
 	tests := map[string]struct {
-		userStr     string
-		password    string
-		repo        *testUserRepo
-		expectedErr error
+		userStr        string
+		password       string
+		repo           *testUserRepo
+		expectedRehash bool
+		expectedErr    error
 	}{
 		"valid email": {
 			userStr:  "benchadwick87@gmail.com",
@@ -155,6 +264,7 @@ func Test_RetrieveAuthenticatedUser(t *testing.T) {
 					Id:       5,
 				},
 			},
+			expectedRehash: true,
 		},
 		"valid username": {
 			userStr:  "ben",
@@ -167,6 +277,33 @@ func Test_RetrieveAuthenticatedUser(t *testing.T) {
 					Id:       5,
 				},
 			},
+			expectedRehash: true,
+		},
+		"valid, bcrypt hash couldn't be rehashed": {
+			userStr:  "ben",
+			password: "Hello123!",
+			repo: &testUserRepo{
+				userRetrieveUserByUsername: &model.User{
+					Username: "ben",
+					Email:    "benchadwick87@gmail.com",
+					Password: "$2a$10$QLgG8tbDrlpDUooY41Vz4elR173ckJexNqy/0eozaRwkURt6MEm3W",
+					Id:       5,
+				},
+				errRehashPassword: resp.ErrCouldNotRehashPassword,
+			},
+			expectedRehash: true,
+		},
+		"valid, argon2id hash is current": {
+			userStr:  "ben",
+			password: "Hello123!",
+			repo: &testUserRepo{
+				userRetrieveUserByUsername: &model.User{
+					Username: "ben",
+					Email:    "benchadwick87@gmail.com",
+					Password: "$argon2id$v=19$m=8,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$CYqzWtv7UZVEd7kIdbVEvCh5wXU2qN7BoCwK+RoT0ME",
+					Id:       5,
+				},
+			},
 		},
 		"invalid, password was not right": {
 			userStr:  "ben",
@@ -181,6 +318,19 @@ func Test_RetrieveAuthenticatedUser(t *testing.T) {
 			},
 			expectedErr: resp.ErrInvalidCredentials,
 		},
+		"invalid, password hash is unreadable": {
+			userStr:  "ben",
+			password: "Hello123!",
+			repo: &testUserRepo{
+				userRetrieveUserByUsername: &model.User{
+					Username: "ben",
+					Email:    "benchadwick87@gmail.com",
+					Password: "Hello123!",
+					Id:       5,
+				},
+			},
+			expectedErr: resp.ErrInvalidCredentials,
+		},
 		"invalid, could not determine user type": {
 			userStr:     "ben!", // not an email, and it contains illegal character for username
 			repo:        &testUserRepo{},
@@ -202,6 +352,306 @@ func Test_RetrieveAuthenticatedUser(t *testing.T) {
 				assert.Empty(t, user.Password)
 				assert.NotZero(t, user.Id)
 			}
+			if !tcase.expectedRehash {
+				assert.Empty(t, tcase.repo.rehashedPassword)
+				return
+			}
+			assert.Equal(t, int64(5), tcase.repo.rehashedUserId)
+			needsRehash, err := util.TestPasswordHasher().Verify(tcase.repo.rehashedPassword, tcase.password)
+			assert.NoError(t, err)
+			assert.False(t, needsRehash)
+		})
+	}
+}
+
+func Test_RetrieveUserById(t *testing.T) {
+	tests := map[string]struct {
+		repo        *testUserRepo
+		expectedErr error
+	}{
+		"valid": {
+			repo: &testUserRepo{
+				userRetrieveUserById: &model.User{
+					Username: "ben",
+					Email:    "benchadwick87@gmail.com",
+					Password: "test-password",
+					Id:       5,
+				},
+			},
+		},
+		"invalid, unknown user": {
+			repo: &testUserRepo{
+				errRetrieveId: resp.ErrUnknownUser,
+			},
+			expectedErr: resp.ErrUnknownUser,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			svc := NewUserService(cfg.TestConfig(), tcase.repo)
+			user, err := svc.RetrieveUserById(5)
+			if tcase.expectedErr != nil {
+				assert.Equal(t, tcase.expectedErr, err)
+				assert.Nil(t, user)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "ben", user.Username)
+			assert.Empty(t, user.Password)
+		})
+	}
+}
+
+func Test_ForgotPassword(t *testing.T) {
+	tests := map[string]struct {
+		userStr      string
+		repo         *testUserRepo
+		mailErr      error
+		expectedSent bool
+		expectedErr  error
+	}{
+		"valid, username": {
+			userStr: "ben",
+			repo: &testUserRepo{
+				userRetrieveUserByUsername: &model.User{Id: 5, Username: "ben", Email: "ben@example.com"},
+			},
+			expectedSent: true,
+		},
+		"valid, email": {
+			userStr: "ben@example.com",
+			repo: &testUserRepo{
+				userRetrieveUserByEmail: &model.User{Id: 5, Username: "ben", Email: "ben@example.com"},
+			},
+			expectedSent: true,
+		},
+		"valid, unknown user is not revealed": {
+			userStr: "ben",
+			repo: &testUserRepo{
+				errRetrieveUser: resp.ErrUnknownUser,
+			},
+		},
+		"invalid, user string": {
+			userStr:     "*234olj2kx.s",
+			repo:        &testUserRepo{},
+			expectedErr: resp.ErrCouldNotDetermineUserType,
+		},
+		"invalid, database error": {
+			userStr: "ben",
+			repo: &testUserRepo{
+				userRetrieveUserByUsername: &model.User{Id: 5, Username: "ben", Email: "ben@example.com"},
+				errStoreReset:              resp.ErrCouldNotStorePasswordReset,
+			},
+			expectedErr: resp.ErrCouldNotStorePasswordReset,
+		},
+		"invalid, email could not be sent": {
+			userStr: "ben",
+			repo: &testUserRepo{
+				userRetrieveUserByUsername: &model.User{Id: 5, Username: "ben", Email: "ben@example.com"},
+			},
+			mailErr:     errors.New("connection refused"),
+			expectedErr: resp.ErrCouldNotSendEmail,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(1000))
+			mailer := util.TestMailer()
+			mailer.SetErr(tcase.mailErr)
+			cfg.SetMailer(mailer)
+			svc := NewUserService(cfg, tcase.repo)
+			err := svc.ForgotPassword(tcase.userStr)
+			assert.Equal(t, tcase.expectedErr, err)
+			sent := mailer.Sent()
+			if !tcase.expectedSent {
+				assert.Empty(t, sent)
+				return
+			}
+			assert.Len(t, sent, 1)
+			assert.Equal(t, "ben@example.com", sent[0].To)
+			assert.Equal(t, int64(1000+resetTokenTimeLimit), tcase.repo.storedResetExpiresAt)
+			// the token emailed is the one whose hash was stored
+			token := strings.Split(sent[0].Body, "\n\n")[1]
+			assert.Len(t, token, 64)
+			assert.Equal(t, util.HashToken(token), tcase.repo.storedResetHash)
+		})
+	}
+}
+
+func Test_ResetPassword(t *testing.T) {
+	tests := map[string]struct {
+		password       string
+		repo           *testUserRepo
+		expectedUserId int64
+		expectedErr    error
+	}{
+		"valid": {
+			password: "BlueKettle_Jumps7",
+			repo: &testUserRepo{
+				resetUserId:    5,
+				resetExpiresAt: 1001,
+			},
+			expectedUserId: 5,
+		},
+		"invalid, password": {
+			password:    "password",
+			repo:        &testUserRepo{},
+			expectedErr: util.ValidPassword("password"),
+		},
+		"invalid, unknown or used token": {
+			password: "BlueKettle_Jumps7",
+			repo: &testUserRepo{
+				errRedeemReset: resp.ErrInvalidResetToken,
+			},
+			expectedErr: resp.ErrInvalidResetToken,
+		},
+		"invalid, expired token": {
+			password: "BlueKettle_Jumps7",
+			repo: &testUserRepo{
+				resetUserId:    5,
+				resetExpiresAt: 1000,
+			},
+			expectedErr: resp.ErrInvalidResetToken,
+		},
+		"invalid, database error": {
+			password: "BlueKettle_Jumps7",
+			repo: &testUserRepo{
+				resetUserId:       5,
+				resetExpiresAt:    1001,
+				errUpdatePassword: resp.ErrCouldNotResetPassword,
+			},
+			expectedErr: resp.ErrCouldNotResetPassword,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(1000))
+			svc := NewUserService(cfg, tcase.repo)
+			userId, err := svc.ResetPassword("token", tcase.password)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedUserId, userId)
+			if tcase.expectedErr != nil {
+				return
+			}
+			assert.Equal(t, util.HashToken("token"), tcase.repo.redeemedResetHash)
+			_, err = util.TestPasswordHasher().Verify(tcase.repo.updatedPassword, tcase.password)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_SendVerification(t *testing.T) {
+	tests := map[string]struct {
+		repo         *testUserRepo
+		mailErr      error
+		expectedSent bool
+		expectedErr  error
+	}{
+		"valid": {
+			repo: &testUserRepo{
+				userRetrieveUserById: &model.User{Id: 5, Username: "ben", Email: "ben@example.com"},
+			},
+			expectedSent: true,
+		},
+		"invalid, already verified": {
+			repo: &testUserRepo{
+				userRetrieveUserById: &model.User{Id: 5, Username: "ben", Email: "ben@example.com", Verified: true},
+			},
+			expectedErr: resp.ErrEmailAlreadyVerified,
+		},
+		"invalid, unknown user": {
+			repo: &testUserRepo{
+				errRetrieveId: resp.ErrUnknownUser,
+			},
+			expectedErr: resp.ErrUnknownUser,
+		},
+		"invalid, database error": {
+			repo: &testUserRepo{
+				userRetrieveUserById: &model.User{Id: 5, Username: "ben", Email: "ben@example.com"},
+				errStoreVerification: resp.ErrCouldNotStoreVerification,
+			},
+			expectedErr: resp.ErrCouldNotStoreVerification,
+		},
+		"invalid, email could not be sent": {
+			repo: &testUserRepo{
+				userRetrieveUserById: &model.User{Id: 5, Username: "ben", Email: "ben@example.com"},
+			},
+			mailErr:     errors.New("connection refused"),
+			expectedErr: resp.ErrCouldNotSendEmail,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(1000))
+			mailer := util.TestMailer()
+			mailer.SetErr(tcase.mailErr)
+			cfg.SetMailer(mailer)
+			svc := NewUserService(cfg, tcase.repo)
+			err := svc.SendVerification(5)
+			assert.Equal(t, tcase.expectedErr, err)
+			sent := mailer.Sent()
+			if !tcase.expectedSent {
+				assert.Empty(t, sent)
+				return
+			}
+			assert.Len(t, sent, 1)
+			assert.Equal(t, "ben@example.com", sent[0].To)
+			assert.Equal(t, int64(1000+verificationTokenTimeLimit), tcase.repo.storedVerificationExpiry)
+			// the token emailed is the one whose hash was stored
+			token := strings.Fields(strings.SplitN(sent[0].Body, "?token=", 2)[1])[0]
+			assert.Len(t, token, 64)
+			assert.Equal(t, util.HashToken(token), tcase.repo.storedVerificationHash)
+		})
+	}
+}
+
+func Test_VerifyEmail(t *testing.T) {
+	tests := map[string]struct {
+		repo                 *testUserRepo
+		expectedVerifiedUser int64
+		expectedErr          error
+	}{
+		"valid": {
+			repo: &testUserRepo{
+				verificationUserId:    5,
+				verificationExpiresAt: 1001,
+			},
+			expectedVerifiedUser: 5,
+		},
+		"invalid, unknown or used token": {
+			repo: &testUserRepo{
+				errRedeemVerification: resp.ErrInvalidVerificationToken,
+			},
+			expectedErr: resp.ErrInvalidVerificationToken,
+		},
+		"invalid, expired token": {
+			repo: &testUserRepo{
+				verificationUserId:    5,
+				verificationExpiresAt: 1000,
+			},
+			expectedErr: resp.ErrInvalidVerificationToken,
+		},
+		"invalid, database error": {
+			repo: &testUserRepo{
+				verificationUserId:    5,
+				verificationExpiresAt: 1001,
+				errVerifyUser:         resp.ErrCouldNotVerifyEmail,
+			},
+			expectedVerifiedUser: 5,
+			expectedErr:          resp.ErrCouldNotVerifyEmail,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cfg := cfg.TestConfig()
+			cfg.SetTimer(util.TestTimerFromUnix(1000))
+			svc := NewUserService(cfg, tcase.repo)
+			err := svc.VerifyEmail("token")
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, util.HashToken("token"), tcase.repo.redeemedVerificationHash)
+			assert.Equal(t, tcase.expectedVerifiedUser, tcase.repo.verifiedUserId)
 		})
 	}
 }
@@ -213,9 +663,35 @@ type testUserRepo struct {
 	lastInsertId               int64
 	userRetrieveUserByEmail    *model.User
 	userRetrieveUserByUsername *model.User
+	userRetrieveUserById       *model.User
+	errRetrieveId              error
+	errUpdatePassword          error
+	updatedPassword            string
+	errRehashPassword          error
+	rehashedUserId             int64
+	rehashedPassword           string
+	errStoreReset              error
+	storedResetHash            string
+	storedResetExpiresAt       int64
+	resetUserId                int64
+	resetExpiresAt             int64
+	errRedeemReset             error
+	redeemedResetHash          string
+	errVerifyUser              error
+	verifiedUserId             int64
+	errStoreVerification       error
+	storedVerificationHash     string
+	storedVerificationExpiry   int64
+	verificationUserId         int64
+	verificationExpiresAt      int64
+	errRedeemVerification      error
+	redeemedVerificationHash   string
+	takenUsernames             map[string]bool // when set, usernames are looked up here instead
+	addedPassword              string
 }
 
 func (trepo *testUserRepo) addUser(user *model.User) (int64, error) {
+	trepo.addedPassword = user.Password
 	return trepo.lastInsertId, trepo.errAddUser
 }
 
@@ -224,5 +700,59 @@ func (trepo *testUserRepo) retrieveUserByEmail(userStr string) (*model.User, err
 }
 
 func (trepo *testUserRepo) retrieveUserByUsername(userStr string) (*model.User, error) {
+	if trepo.takenUsernames != nil {
+		if trepo.takenUsernames[userStr] {
+			return &model.User{Username: userStr}, nil
+		}
+		return nil, resp.ErrUnknownUser
+	}
 	return trepo.userRetrieveUserByUsername, trepo.errRetrieveUser
 }
+
+func (trepo *testUserRepo) retrieveUserById(userId int64) (*model.User, error) {
+	return trepo.userRetrieveUserById, trepo.errRetrieveId
+}
+
+func (trepo *testUserRepo) updatePassword(userId int64, password string) error {
+	trepo.updatedPassword = password
+	return trepo.errUpdatePassword
+}
+
+func (trepo *testUserRepo) rehashPassword(userId int64, oldHash, newHash string) error {
+	trepo.rehashedUserId = userId
+	trepo.rehashedPassword = newHash
+	return trepo.errRehashPassword
+}
+
+func (trepo *testUserRepo) storePasswordReset(tokenHash string, userId, expiresAt int64) error {
+	trepo.storedResetHash = tokenHash
+	trepo.storedResetExpiresAt = expiresAt
+	return trepo.errStoreReset
+}
+
+func (trepo *testUserRepo) redeemPasswordReset(tokenHash string) (int64, int64, error) {
+	trepo.redeemedResetHash = tokenHash
+	if trepo.errRedeemReset != nil {
+		return 0, 0, trepo.errRedeemReset
+	}
+	return trepo.resetUserId, trepo.resetExpiresAt, nil
+}
+
+func (trepo *testUserRepo) verifyUser(userId int64) error {
+	trepo.verifiedUserId = userId
+	return trepo.errVerifyUser
+}
+
+func (trepo *testUserRepo) storeVerification(tokenHash string, userId, expiresAt int64) error {
+	trepo.storedVerificationHash = tokenHash
+	trepo.storedVerificationExpiry = expiresAt
+	return trepo.errStoreVerification
+}
+
+func (trepo *testUserRepo) redeemVerification(tokenHash string) (int64, int64, error) {
+	trepo.redeemedVerificationHash = tokenHash
+	if trepo.errRedeemVerification != nil {
+		return 0, 0, trepo.errRedeemVerification
+	}
+	return trepo.verificationUserId, trepo.verificationExpiresAt, nil
+}
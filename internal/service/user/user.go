@@ -1,11 +1,40 @@
 package user
 
-import "github.com/bchadwic/wordbubble/model"
+import (
+	"time"
+
+	"github.com/bchadwic/wordbubble/model"
+)
 
 const (
+	// resetTokenTimeLimit is how long a password reset token can be used after it's sent, in seconds
+	resetTokenTimeLimit      = 15 * 60
+	PasswordResetCleanerRate = 10 * time.Minute
+	// verificationTokenTimeLimit is how long an email verification token can be used after it's sent, in seconds
+	verificationTokenTimeLimit = 24 * 60 * 60
+	VerificationCleanerRate    = time.Hour
+	// federatedUsernameLength is how long a username chosen for a federated user can be before a suffix is added to make it unique
+	federatedUsernameLength = 35
+	// federatedUsernameAttempts is how many usernames are tried for a federated user before giving up
+	federatedUsernameAttempts = 5
+
 	AddUser                = `INSERT INTO users(username, email, password) VALUES ($1, $2, $3) RETURNING user_id;`
-	RetrieveUserByEmail    = `SELECT user_id, username, email, password FROM users WHERE email = $1`
-	RetrieveUserByUsername = `SELECT user_id, username, email, password FROM users WHERE username = $1`
+	RetrieveUserByEmail    = `SELECT user_id, username, email, password, verified FROM users WHERE email = $1`
+	RetrieveUserByUsername = `SELECT user_id, username, email, password, verified FROM users WHERE username = $1`
+	RetrieveUserById       = `SELECT user_id, username, email, password, verified FROM users WHERE user_id = $1`
+	UpdatePassword         = `UPDATE users SET password = $1, updated_timestamp = CURRENT_TIMESTAMP WHERE user_id = $2`
+	RehashPassword         = `UPDATE users SET password = $1, updated_timestamp = CURRENT_TIMESTAMP WHERE user_id = $2 AND password = $3`
+	StorePasswordReset     = `INSERT INTO password_resets (token_hash, user_id, expires_at) VALUES ($1, $2, $3)`
+	GetPasswordReset       = `SELECT user_id, expires_at FROM password_resets WHERE token_hash = $1`
+	RedeemPasswordReset    = `DELETE FROM password_resets WHERE token_hash = $1`
+	DeletePasswordResets   = `DELETE FROM password_resets WHERE user_id = $1`
+	CleanupPasswordResets  = `DELETE FROM password_resets WHERE expires_at < $1`
+	VerifyUser             = `UPDATE users SET verified = TRUE, updated_timestamp = CURRENT_TIMESTAMP WHERE user_id = $1`
+	StoreVerification      = `INSERT INTO email_verifications (token_hash, user_id, expires_at) VALUES ($1, $2, $3)`
+	GetVerification        = `SELECT user_id, expires_at FROM email_verifications WHERE token_hash = $1`
+	RedeemVerification     = `DELETE FROM email_verifications WHERE token_hash = $1`
+	DeleteVerifications    = `DELETE FROM email_verifications WHERE user_id = $1`
+	CleanupVerifications   = `DELETE FROM email_verifications WHERE expires_at < $1`
 )
 
 // UserService is the interface that the application
@@ -16,6 +45,12 @@ type UserService interface {
 	// (400) resp.ErrUserWithUsernameAlreadyExists, (400) resp.ErrCouldNotDetermineUserExistence,
 	// (400) resp.ErrUserWithEmailAlreadyExists, (400) resp.ErrCouldNotDetermineUserExistence or nil.
 	AddUser(user *model.User) error
+	// AddFederatedUser adds a verified user that logs in with an external identity provider, with a random password.
+	// The username is chosen from the username hint or the email, and made unique with a random suffix when it's taken.
+	// *model.User is the user added, without a password, can be nil.
+	// error can be (500) resp.ErrCouldNotChooseUsername, (500) resp.ErrCouldNotDetermineUserExistence,
+	// (400) resp.ErrUserWithEmailAlreadyExists, (500) resp.ErrCouldNotAddUser, (500) resp.ErrCouldNotVerifyEmail or nil.
+	AddFederatedUser(email, usernameHint string) (*model.User, error)
 	// RetrieveUnauthenticatedUser retrieve everything about a user by a user string (email or username), without a password.
 	// *model.User is the unauthenticated user found, can be nil.
 	// error can be (500) resp.ErrSQLMappingError, (400) resp.ErrUnknownUser, (400) resp.ErrCouldNotDetermineUserType or nil.
@@ -26,6 +61,27 @@ type UserService interface {
 	// error can be (500) resp.ErrSQLMappingError, (400) resp.ErrUnknownUser,
 	// (400) resp.ErrCouldNotDetermineUserType, (401) resp.ErrInvalidCredentials or nil.
 	RetrieveAuthenticatedUser(userStr, password string) (*model.User, error)
+	// RetrieveUserById retrieve everything about a user by their id, without a password.
+	// *model.User is the user found, can be nil.
+	// error can be (500) resp.ErrSQLMappingError, (400) resp.ErrUnknownUser or nil.
+	RetrieveUserById(userId int64) (*model.User, error)
+	// ForgotPassword emails a single use password reset token to a user found by user string (email or username).
+	// Nothing is sent for an unknown user, and no error is returned, so the response can't be used to find accounts
+	// error can be (400) resp.ErrCouldNotDetermineUserType, (500) resp.ErrSQLMappingError,
+	// (500) resp.ErrCouldNotStorePasswordReset, (500) resp.ErrCouldNotSendEmail or nil.
+	ForgotPassword(userStr string) error
+	// ResetPassword redeems a password reset token and replaces the user's password, any other reset tokens are removed.
+	// int64 is the user id of the token, or zero.
+	// error can be (400) resp.ErrInvalidResetToken, InvalidPassword, (400) resp.ErrPasswordIsCommon, (400) resp.ErrPasswordIsTooGuessable,
+	// (500) resp.ErrCouldNotBeHashPassword, (500) resp.ErrCouldNotResetPassword or nil.
+	ResetPassword(token, password string) (int64, error)
+	// SendVerification emails a token to a user that proves they own their email, tokens sent before stay valid until they expire.
+	// error can be (400) resp.ErrUnknownUser, (409) resp.ErrEmailAlreadyVerified, (500) resp.ErrSQLMappingError,
+	// (500) resp.ErrCouldNotStoreVerification, (500) resp.ErrCouldNotSendEmail or nil.
+	SendVerification(userId int64) error
+	// VerifyEmail redeems an email verification token and marks its user as verified, any other verification tokens are removed.
+	// error can be (400) resp.ErrInvalidVerificationToken, (500) resp.ErrCouldNotVerifyEmail or nil.
+	VerifyEmail(token string) error
 }
 
 // UserRepo is the interface that the service layer
@@ -43,4 +99,45 @@ type UserRepo interface {
 	// *model.User is the user retrieved from the username, could be nil.
 	// error can be (400) resp.ErrUnknownUser, (500) resp.ErrSQLMappingError or nil.
 	retrieveUserByUsername(username string) (*model.User, error)
+	// retrieveUserById retrieves user details by user id.
+	// *model.User is the user retrieved from the user id, could be nil.
+	// error can be (400) resp.ErrUnknownUser, (500) resp.ErrSQLMappingError or nil.
+	retrieveUserById(userId int64) (*model.User, error)
+	// updatePassword replaces the password hash of a user, and removes every password reset token of the user.
+	// error can be (500) resp.ErrCouldNotResetPassword or nil.
+	updatePassword(userId int64, password string) error
+	// rehashPassword replaces the password hash of a user with a new hash of the same password,
+	// unless the hash was changed since it was read, so a password reset isn't undone.
+	// error can be (500) resp.ErrCouldNotRehashPassword or nil.
+	rehashPassword(userId int64, oldHash, newHash string) error
+	// storePasswordReset stores the hash of a password reset token.
+	// error can be (500) resp.ErrCouldNotStorePasswordReset or nil.
+	storePasswordReset(tokenHash string, userId, expiresAt int64) error
+	// redeemPasswordReset finds a password reset token by its hash and removes it, so it can only be used once.
+	// int64 is the user id of the token, or zero.
+	// int64 is the unix time the token expires, or zero.
+	// error can be (400) resp.ErrInvalidResetToken, (500) resp.ErrCouldNotResetPassword or nil.
+	redeemPasswordReset(tokenHash string) (int64, int64, error)
+	// verifyUser marks a user's email as verified, and removes every email verification token of the user.
+	// error can be (500) resp.ErrCouldNotVerifyEmail or nil.
+	verifyUser(userId int64) error
+	// storeVerification stores the hash of an email verification token.
+	// error can be (500) resp.ErrCouldNotStoreVerification or nil.
+	storeVerification(tokenHash string, userId, expiresAt int64) error
+	// redeemVerification finds an email verification token by its hash and removes it, so it can only be used once.
+	// int64 is the user id of the token, or zero.
+	// int64 is the unix time the token expires, or zero.
+	// error can be (400) resp.ErrInvalidVerificationToken, (500) resp.ErrCouldNotVerifyEmail or nil.
+	redeemVerification(tokenHash string) (int64, int64, error)
+}
+
+// UserCleaner is the interface that the application
+// uses to clean up expired password reset and email verification tokens
+type UserCleaner interface {
+	// CleanupExpiredPasswordResets remove any password reset tokens from the database that were never used and are expired.
+	// error can be (500) resp.ErrCouldNotCleanupPasswordResets or nil
+	CleanupExpiredPasswordResets(now int64) error
+	// CleanupExpiredVerifications remove any email verification tokens from the database that were never used and are expired.
+	// error can be (500) resp.ErrCouldNotCleanupVerifications or nil
+	CleanupExpiredVerifications(now int64) error
 }
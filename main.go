@@ -1,15 +1,30 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"github.com/bchadwic/wordbubble/app"
 	cfg "github.com/bchadwic/wordbubble/internal/config"
+	"github.com/bchadwic/wordbubble/internal/job"
+	"github.com/bchadwic/wordbubble/internal/service/apikey"
 	"github.com/bchadwic/wordbubble/internal/service/auth"
+	"github.com/bchadwic/wordbubble/internal/service/lockout"
+	"github.com/bchadwic/wordbubble/internal/service/magiclink"
+	"github.com/bchadwic/wordbubble/internal/service/mfa"
+	"github.com/bchadwic/wordbubble/internal/service/oauth"
+	"github.com/bchadwic/wordbubble/internal/service/oidc"
+	"github.com/bchadwic/wordbubble/internal/service/passkey"
+	"github.com/bchadwic/wordbubble/internal/service/role"
 	"github.com/bchadwic/wordbubble/internal/service/user"
 	"github.com/bchadwic/wordbubble/internal/service/wb"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/bchadwic/wordbubble/util"
 )
 
 func main() {
@@ -34,33 +49,118 @@ func main() {
 // @in                         header
 // @name                       Authorization
 // @description                JWT access token retrieved from using a refresh token, gathered from /signup, /login, or /token
+// @securityDefinitions.apikey PersonalApiKey
+// @in                         header
+// @name                       Authorization
+// @description                Personal api key created from /apikeys, sent as "ApiKey wb_..."
 func run(cfg cfg.Config) error {
 	logger := cfg.NewLogger("run")
 
 	logger.Info("initializing repos and services")
 	authRepo := auth.NewAuthRepo(cfg)
+	apiKeyRepo := apikey.NewApiKeyRepo(cfg)
+	lockoutRepo := lockout.NewLockoutRepo(cfg)
+	magicLinkRepo := magiclink.NewMagicLinkRepo(cfg)
+	mfaRepo := mfa.NewMfaRepo(cfg)
+	oauthRepo := oauth.NewOAuthRepo(cfg)
+	oidcRepo := oidc.NewOidcRepo(cfg)
+	passkeyRepo := passkey.NewPasskeyRepo(cfg)
+	roleRepo := role.NewRoleRepo(cfg)
 	usersRepo := user.NewUserRepo(cfg)
 	wbRepo := wb.NewWordbubbleRepo(cfg)
 
+	logger.Info("hashing refresh tokens stored in plaintext")
+	if _, err := authRepo.HashPlaintextRefreshTokens(); err != nil {
+		return err
+	}
+
 	authService := auth.NewAuthService(cfg, authRepo)
+	apiKeyService := apikey.NewApiKeyService(cfg, apiKeyRepo)
+	lockoutService := lockout.NewLockoutService(cfg, lockoutRepo)
+	magicLinkService := magiclink.NewMagicLinkService(cfg, magicLinkRepo)
+	mfaService := mfa.NewMfaService(cfg, mfaRepo)
+	oauthService := oauth.NewOAuthService(cfg, oauthRepo)
+	oidcService := oidc.NewOidcService(cfg, oidcRepo)
+	passkeyService := passkey.NewPasskeyService(cfg, passkeyRepo)
+	roleService := role.NewRoleService(cfg, roleRepo)
 	userService := user.NewUserService(cfg, usersRepo)
 	wbService := wb.NewWordbubblesService(cfg, wbRepo)
 
+	if err := bootstrapAdmin(cfg, userService, roleService); err != nil {
+		return err
+	}
+
 	logger.Info("creating app")
-	app := app.NewApp(cfg, authService, userService, wbService)
+	app := app.NewApp(cfg, authService, apiKeyService, lockoutService, magicLinkService, mfaService, oauthService, oidcService, passkeyService, roleService, userService, wbService)
 
 	logger.Info("attaching routes to app")
 	http.HandleFunc("/v1/signup", app.Signup)
 	http.HandleFunc("/v1/login", app.Login)
+	http.HandleFunc("/v1/login/mfa", app.LoginMfa)
+	http.HandleFunc("/v1/login/link", app.LoginLink)
+	http.HandleFunc("/v1/login/link/callback", app.LoginLinkCallback)
+	http.HandleFunc("/v1/login/oidc", app.OidcLogin)
+	http.HandleFunc("/v1/login/oidc/callback", app.OidcCallback)
+	http.HandleFunc("/v1/login/passkey", app.FinishPasskeyLogin)
+	http.HandleFunc("/v1/login/passkey/options", app.BeginPasskeyLogin)
 	http.HandleFunc("/v1/token", app.Token)
-	http.HandleFunc("/v1/push", app.Push)
-	http.HandleFunc("/v1/pop", app.Pop)
+	http.HandleFunc("/v1/logout", app.Logout)
+	http.HandleFunc("/v1/logout/all", app.RequireToken(util.ScopeAccountManage, app.LogoutAll))
+	http.HandleFunc("/v1/password/forgot", app.ForgotPassword)
+	http.HandleFunc("/v1/password/reset", app.ResetPassword)
+	http.HandleFunc("/v1/verify", app.Verify)
+	http.HandleFunc("/v1/verify/resend", app.RequireToken(util.ScopeAccountManage, app.ResendVerification))
+	http.HandleFunc("/v1/push", app.RequireTokenOrApiKey(util.ScopeWordbubblePush, app.Push))
+	http.HandleFunc("/v1/pop", app.RequireCsrf(app.Pop))
+	http.HandleFunc("/v1/sessions", app.RequireToken(util.ScopeAccountManage, app.Sessions))
+	http.HandleFunc("/v1/sessions/", app.RequireToken(util.ScopeAccountManage, app.RevokeSession))
+	http.HandleFunc("/v1/apikeys", app.RequireToken(util.ScopeAccountManage, app.ApiKeys))
+	http.HandleFunc("/v1/apikeys/", app.RequireToken(util.ScopeAccountManage, app.RevokeApiKey))
+	http.HandleFunc("/v1/mfa/totp", app.RequireToken(util.ScopeAccountManage, app.Totp))
+	http.HandleFunc("/v1/mfa/totp/confirm", app.RequireToken(util.ScopeAccountManage, app.ConfirmTotp))
+	http.HandleFunc("/v1/oauth/clients", app.RequireToken(util.ScopeAccountManage, app.RegisterClient))
+	http.HandleFunc("/v1/oauth/authorize", app.Authorize) // only approving on POST requires a token
+	http.HandleFunc("/v1/oauth/token", app.OAuthToken)
+	http.HandleFunc("/v1/oidc/link", app.RequireToken(util.ScopeAccountManage, app.LinkOidcIdentity))
+	http.HandleFunc("/v1/passkeys", app.RequireToken(util.ScopeAccountManage, app.Passkeys))
+	http.HandleFunc("/v1/passkeys/", app.RequireToken(util.ScopeAccountManage, app.RevokePasskey))
+	http.HandleFunc("/v1/passkeys/options", app.RequireToken(util.ScopeAccountManage, app.BeginPasskeyRegistration))
+	http.HandleFunc("/v1/admin/roles", app.RequirePermission(util.ScopeAccountManage, util.PermissionManageRoles, app.AssignRole))
+	http.HandleFunc("/.well-known/jwks.json", app.JWKS)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	logger.Info("starting refresh token cleaner with an interval of: %gs", auth.RefreshTokenCleanerRate.Seconds())
-	app.BackgroundCleaner(authRepo)
+	logger.Info("starting background jobs")
+	scheduler := job.NewScheduler(cfg, job.NewLockRepo(cfg))
+	scheduler.Register(auth.NewRefreshTokenCleanupJob(cfg.Timer(), authRepo))
+	scheduler.Register(auth.NewDenylistCleanupJob(cfg.Timer(), authRepo))
+	scheduler.Register(oauth.NewCodeCleanupJob(cfg.Timer(), oauthRepo))
+	scheduler.Register(mfa.NewChallengeCleanupJob(cfg.Timer(), mfaRepo))
+	scheduler.Register(lockout.NewLoginAttemptCleanupJob(cfg.Timer(), lockoutRepo))
+	scheduler.Register(magiclink.NewLoginLinkCleanupJob(cfg.Timer(), magicLinkRepo))
+	scheduler.Register(oidc.NewStateCleanupJob(cfg.Timer(), oidcRepo))
+	scheduler.Register(passkey.NewChallengeCleanupJob(cfg.Timer(), passkeyRepo))
+	scheduler.Register(user.NewPasswordResetCleanupJob(cfg.Timer(), usersRepo))
+	scheduler.Register(user.NewVerificationCleanupJob(cfg.Timer(), usersRepo))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scheduler.Run(ctx)
+	}()
+
+	server := &http.Server{Addr: cfg.Port()}
+	go func() {
+		<-ctx.Done()
+		logger.Info("shutting down server")
+		server.Shutdown(context.Background())
+	}()
 
 	logger.Info("starting server on port %s", cfg.Port())
-	err := http.ListenAndServe(cfg.Port(), nil)
+	err := server.ListenAndServe()
+	stop()
+	wg.Wait()
 	if errors.Is(err, http.ErrServerClosed) {
 		logger.Info("server closed")
 		return nil
@@ -69,3 +169,26 @@ func run(cfg cfg.Config) error {
 	}
 	return err
 }
+
+// bootstrapAdmin makes the user set with WB_BOOTSTRAP_ADMIN an admin, so there's an admin to assign roles on a new deployment.
+// The user has to sign up and verify their email first, an unknown or unverified user is skipped until the api is started again
+func bootstrapAdmin(cfg cfg.Config, users user.UserService, roles role.RoleService) error {
+	logger := cfg.NewLogger("bootstrap")
+	userStr := cfg.BootstrapAdmin()
+	if userStr == "" {
+		return nil
+	}
+	admin, err := users.RetrieveUnauthenticatedUser(userStr)
+	if err == resp.ErrUnknownUser {
+		logger.Warn("bootstrap admin has not signed up yet")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !admin.Verified { // anyone can sign up with a username or email before its owner does
+		logger.Warn("bootstrap admin: %d has not verified their email yet", admin.Id)
+		return nil
+	}
+	logger.Info("making user: %d an admin", admin.Id)
+	return roles.BootstrapAdmin(admin.Id)
+}
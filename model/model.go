@@ -5,7 +5,11 @@ import "github.com/golang-jwt/jwt"
 
 type TokenClaims struct {
 	jwt.StandardClaims
-	UserId int64 `json:"user_id"`
+	UserId   int64  `json:"user_id"`
+	Scope    string `json:"scope,omitempty"`     // space delimited, empty on tokens issued before scopes existed
+	Type     string `json:"typ,omitempty"`       // access or refresh, empty on tokens issued before token types existed
+	Role     string `json:"role,omitempty"`      // role of the user when an access token was issued, empty on refresh tokens and tokens issued before roles existed
+	ClientId string `json:"client_id,omitempty"` // oauth client a refresh token was issued to, empty on tokens issued to the user directly
 }
 
 type User struct {
@@ -13,4 +17,57 @@ type User struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Verified bool   `json:"verified"`
+}
+
+// Device is the metadata of the client a session was started or last used on
+type Device struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Principal is who sent an authenticated request, found from either an access token or a personal api key
+type Principal struct {
+	UserId    int64
+	Scope     string // space delimited, empty when every scope was granted
+	TokenId   string // id (jti) of the access token, empty for api keys and tokens issued without one
+	ExpiresAt int64  // unix time the access token expires, zero for api keys
+	ApiKey    bool   // true when the request was sent with a personal api key
+	Role      string // role the access token was issued with, empty for api keys and tokens issued before roles existed
+}
+
+// FederatedIdentity is who a user is at an OpenID Connect provider, taken from an id token the provider signed
+type FederatedIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string // empty when the provider didn't share one
+	EmailVerified bool
+	Username      string // username the provider suggests, used for a new user
+}
+
+// FederatedLogin is a finished login at an OpenID Connect provider, along with what was asked for when it started
+type FederatedLogin struct {
+	Identity   FederatedIdentity
+	LinkUserId int64 // user linking the identity to their account, zero when the user is logging in
+	Scope      string
+	RememberMe bool
+}
+
+// Passkey is a WebAuthn credential a user registered, the authenticator holding its private key can login as the user
+type Passkey struct {
+	Id         string // credential id chosen by the authenticator, base64url encoded
+	UserId     int64
+	Name       string
+	PublicKey  []byte // COSE encoded public key of the credential
+	SignCount  uint32 // signature counter of the authenticator, zero when the authenticator doesn't keep one
+	CreatedAt  int64
+	LastUsedAt int64 // zero when the passkey has never been used to login
+}
+
+// PasskeyLogin is a finished login with a passkey, along with what was asked for when it started
+type PasskeyLogin struct {
+	UserId       int64
+	Scope        string
+	RememberMe   bool
+	UserVerified bool // true when the authenticator verified the user with a pin or biometric, making the passkey two factors
 }
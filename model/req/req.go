@@ -9,6 +9,7 @@ type WordbubbleRequest struct {
 // @Description RefreshTokenRequest contains the token string of a refresh token
 type RefreshTokenRequest struct {
 	Token string `json:"refresh_token" example:"xxx.yyy.zzz"`
+	Scope string `json:"scope,omitempty" example:"wordbubble:push"` // optional, narrows the scope of the access token
 }
 
 // @Description PopUserRequest contains the data to remove and return a wordbubble
@@ -25,6 +26,109 @@ type SignupUserRequest struct {
 
 // @Description LoginUserRequest is the body sent to the /login operation
 type LoginUserRequest struct {
-	User     string `json:"user" example:"ben"`
+	User       string `json:"user" example:"ben"`
+	Password   string `json:"password" example:"SomePassword_123"`
+	Scope      string `json:"scope,omitempty" example:"wordbubble:push wordbubble:read"` // optional, every scope when empty
+	RememberMe bool   `json:"remember_me,omitempty" example:"true"`                      // optional, the session lasts longer when true
+}
+
+// @Description CreateApiKeyRequest contains the name of a new api key
+type CreateApiKeyRequest struct {
+	Name  string `json:"name" example:"nightly cron"`
+	Scope string `json:"scope,omitempty" example:"wordbubble:push"` // optional, the scope of the access token when empty
+}
+
+// @Description RegisterClientRequest contains the name and redirect uri of a third party app
+type RegisterClientRequest struct {
+	Name        string `json:"name" example:"wordbubble for desktop"`
+	RedirectUri string `json:"redirect_uri" example:"https://app.example.com/callback"`
+}
+
+// @Description AuthorizeRequest contains the query parameters a third party app sends a user to /oauth/authorize with
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type" example:"code"`
+	ClientId            string `json:"client_id" example:"5d41402abc4b2a76b9719d911017c592"`
+	RedirectUri         string `json:"redirect_uri" example:"https://app.example.com/callback"`
+	Scope               string `json:"scope,omitempty" example:"wordbubble:push"` // optional, wordbubble:push and wordbubble:read when empty
+	State               string `json:"state,omitempty" example:"af0ifjsldkj"`
+	CodeChallenge       string `json:"code_challenge" example:"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"`
+	CodeChallengeMethod string `json:"code_challenge_method" example:"S256"`
+}
+
+// @Description ConsentRequest contains whether a user approved a third party app's access to their account
+type ConsentRequest struct {
+	Approve bool `json:"approve" example:"true"`
+}
+
+// @Description MfaCodeRequest contains a code from an authenticator app, or a recovery code
+type MfaCodeRequest struct {
+	Code string `json:"code" example:"287082"`
+}
+
+// @Description LoginMfaRequest contains the mfa token returned from /login, and a code from an authenticator app or a recovery code
+type LoginMfaRequest struct {
+	MfaToken   string `json:"mfa_token" example:"7d793037a0760186574b0282f2f435e7"`
+	Code       string `json:"code" example:"287082"`
+	RememberMe bool   `json:"remember_me,omitempty" example:"true"` // optional, the session lasts longer when true
+}
+
+// @Description LoginLinkRequest contains the email a login link is sent to, and optionally the scope the tokens are granted
+type LoginLinkRequest struct {
+	Email      string `json:"email" example:"ben@wordbubble.com"`
+	Scope      string `json:"scope,omitempty" example:"wordbubble:push wordbubble:read"` // optional, every scope when empty
+	RememberMe bool   `json:"remember_me,omitempty" example:"true"`                      // optional, the session lasts longer when true
+}
+
+// @Description OidcLoginRequest optionally contains the scope the tokens are granted after logging in with the identity provider
+type OidcLoginRequest struct {
+	Scope      string `json:"scope,omitempty" example:"wordbubble:push wordbubble:read"` // optional, every scope when empty
+	RememberMe bool   `json:"remember_me,omitempty" example:"true"`                      // optional, the session lasts longer when true
+}
+
+// @Description AssignRoleRequest contains the username or email of a user, and the role they're assigned
+type AssignRoleRequest struct {
+	User string `json:"user" example:"ben"`
+	Role string `json:"role" example:"moderator"` // user, moderator or admin
+}
+
+// @Description BeginPasskeyLoginRequest optionally contains the scope the tokens are granted after logging in with a passkey
+type BeginPasskeyLoginRequest struct {
+	Scope      string `json:"scope,omitempty" example:"wordbubble:push wordbubble:read"` // optional, every scope when empty
+	RememberMe bool   `json:"remember_me,omitempty" example:"true"`                      // optional, the session lasts longer when true
+}
+
+// @Description PasskeyRegistrationRequest contains a name for a passkey, and the credential the browser returned from navigator.credentials.create
+type PasskeyRegistrationRequest struct {
+	Name       string            `json:"name" example:"work laptop"`
+	Credential PasskeyCredential `json:"credential"`
+}
+
+// @Description PasskeyCredential is the credential a browser returns from navigator.credentials.create or navigator.credentials.get,
+// @Description serialized with toJSON as defined by WebAuthn, every binary field is base64url encoded
+type PasskeyCredential struct {
+	Id       string                       `json:"id" example:"Z2Nvb2dsZS1wYXNza2V5"`
+	RawId    string                       `json:"rawId" example:"Z2Nvb2dsZS1wYXNza2V5"`
+	Type     string                       `json:"type" example:"public-key"`
+	Response PasskeyAuthenticatorResponse `json:"response"`
+}
+
+// @Description PasskeyAuthenticatorResponse is what the authenticator responded with, the attestation object is sent on registration,
+// @Description and the authenticator data, signature and user handle are sent on login
+type PasskeyAuthenticatorResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" example:"eyJ0eXBlIjoid2ViYXV0aG4uZ2V0In0"`
+	AttestationObject string `json:"attestationObject,omitempty" example:"o2NmbXRkbm9uZWdhdHRTdG10oGhhdXRoRGF0YQ"`      // registration only
+	AuthenticatorData string `json:"authenticatorData,omitempty" example:"SZYN5YgOjGh0NBcPZHZgW4_krrmihjLHmVzzuoMdl2M"` // login only
+	Signature         string `json:"signature,omitempty" example:"MEUCIQDw"`                                            // login only
+	UserHandle        string `json:"userHandle,omitempty" example:"AAAAAAAAAAI"`                                        // login only, optional
+}
+
+// @Description ForgotPasswordRequest contains the username or email of a user who forgot their password
+type ForgotPasswordRequest struct {
+	User string `json:"user" example:"ben"`
+}
+
+// @Description ResetPasswordRequest contains the token emailed from /password/forgot, and the new password
+type ResetPasswordRequest struct {
+	Token    string `json:"token" example:"9c1185a5c5e9fc54612808977ee8f548b2258d31"`
 	Password string `json:"password" example:"SomePassword_123"`
 }
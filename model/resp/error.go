@@ -18,22 +18,149 @@ var (
 	ErrUserWithEmailAlreadyExists     = BadRequest("a user already exists with this email")
 	ErrEmailIsNotValid                = BadRequest("email in request is not a valid email")
 	ErrEmailIsTooLong                 = BadRequest("no one should have an email this long")
+	ErrPasswordIsCommon               = BadRequest("password is too common, it appears in lists of breached passwords")
+	ErrPasswordIsTooGuessable         = BadRequest("password is too easy to guess, try a longer password or a few unrelated words")
 	ErrUsernameIsTooLong              = BadRequest("no one should have a username this long")
 	ErrUsernameIsMissing              = BadRequest("a username is required")
 	ErrUsernameInvalidChars           = BadRequest("username must only consist of letters, numbers, or '_'")
+	ErrNoSessionId                    = BadRequest("no session id was specified")
+	ErrParseApiKey                    = BadRequest("could not parse api key from request body")
+	ErrApiKeyNameIsMissing            = BadRequest("a name is required for an api key")
+	ErrApiKeyNameIsTooLong            = BadRequest("no one should have an api key name this long")
+	ErrNoApiKeyId                     = BadRequest("no api key id was specified")
+	ErrInvalidScope                   = BadRequest("scope requested is invalid or exceeds the scope granted")
+	ErrParseClient                    = BadRequest("could not parse oauth client from request body")
+	ErrClientNameIsMissing            = BadRequest("a name is required for an oauth client")
+	ErrClientNameIsTooLong            = BadRequest("no one should have an oauth client name this long")
+	ErrInvalidRedirectUri             = BadRequest("redirect uri must be an https url, or an http url on localhost")
+	ErrUnknownClient                  = BadRequest("could not find an oauth client with this id")
+	ErrRedirectUriMismatch            = BadRequest("redirect uri does not match the one registered for the oauth client")
+	ErrUnsupportedResponseType        = BadRequest("response type must be code")
+	ErrInvalidCodeChallenge           = BadRequest("an S256 code challenge is required")
+	ErrParseConsent                   = BadRequest("could not parse consent from request body")
+	ErrUnsupportedGrantType           = BadRequest("grant type must be authorization_code or refresh_token")
+	ErrInvalidAuthorizationCode       = BadRequest("authorization code is invalid, expired or has already been used")
+	ErrInvalidCodeVerifier            = BadRequest("code verifier does not match the code challenge")
+	ErrClientRefreshToken             = BadRequest("refresh token was issued to an oauth client, refresh it at /oauth/token")
+	ErrParseMfaCode                   = BadRequest("could not parse mfa code from request body")
+	ErrParseMfaToken                  = BadRequest("could not parse mfa token and code from request body")
+	ErrMfaNotEnrolled                 = BadRequest("two-factor authentication has not been enrolled for this user")
+	ErrParsePasswordReset             = BadRequest("could not parse reset token and password from request body")
+	ErrInvalidResetToken              = BadRequest("password reset token is invalid, expired or has already been used")
+	ErrNoVerificationToken            = BadRequest("no verification token was specified")
+	ErrInvalidVerificationToken       = BadRequest("verification token is invalid, expired or has already been used")
+	ErrParseLoginLink                 = BadRequest("could not parse email from request body")
+	ErrNoLoginLinkToken               = BadRequest("no login link token was specified")
+	ErrParseOidcLogin                 = BadRequest("could not parse federated login from request body")
+	ErrNoOidcCode                     = BadRequest("no code and state were returned from the identity provider")
+	ErrParseRole                      = BadRequest("could not parse user and role from request body")
+	ErrInvalidRole                    = BadRequest("role must be user, moderator or admin")
+	ErrParsePasskey                   = BadRequest("could not parse passkey credential from request body")
+	ErrPasskeyNameIsMissing           = BadRequest("a name is required for a passkey")
+	ErrPasskeyNameIsTooLong           = BadRequest("no one should have a passkey name this long")
+	ErrNoPasskeyId                    = BadRequest("no passkey id was specified")
+	ErrInvalidPasskeyRegistration     = BadRequest("passkey could not be registered, the response from the authenticator is invalid")
+	ErrUnsupportedPasskeyAlgorithm    = BadRequest("passkey uses an algorithm that is not supported, use ES256, EdDSA or RS256")
 	ErrUnauthorized                   = Unauthorized("bearer token authorization is required for this operation")
 	ErrInvalidCredentials             = Unauthorized("could not authenticate using credentials passed")
 	ErrCouldNotValidateRefreshToken   = Unauthorized("could not validate the refresh token, please login again")
 	ErrTokenIsExpired                 = Unauthorized("token is expired, please login again")
 	ErrInvalidTokenSignature          = Unauthorized("token signature was found to be invalid")
+	ErrMalformedToken                 = Unauthorized("token is malformed")
+	ErrTokenIsNotYetValid             = Unauthorized("token is not valid yet")
+	ErrInvalidTokenType               = Unauthorized("token is not the type of token required for this operation")
+	ErrInvalidTokenClaims             = Unauthorized("token was not issued by or for this api")
+	ErrRefreshTokenReused             = Unauthorized("refresh token has already been used, please login again")
+	ErrTokenIsRevoked                 = Unauthorized("token has been revoked, please login again")
+	ErrSessionIsExpired               = Unauthorized("session has reached its maximum lifetime, please login again")
+	ErrInvalidApiKey                  = Unauthorized("api key is invalid or has been revoked")
+	ErrInvalidMfaCode                 = Unauthorized("mfa code is invalid or has already been used")
+	ErrInvalidMfaToken                = Unauthorized("mfa token is invalid or expired, please login again")
+	ErrInvalidLoginLink               = Unauthorized("login link is invalid, expired or has already been used, please request another")
+	ErrInvalidOidcState               = Unauthorized("federated login state is invalid or expired, please login again")
+	ErrOidcLoginDenied                = Unauthorized("federated login was denied by the identity provider")
+	ErrOidcCodeRejected               = Unauthorized("the identity provider rejected the authorization code, please login again")
+	ErrInvalidIdToken                 = Unauthorized("id token from the identity provider is invalid")
+	ErrInvalidPasskey                 = Unauthorized("passkey could not be verified")
+	ErrInvalidPasskeyChallenge        = Unauthorized("passkey challenge is invalid or expired, please try again")
+	ErrInvalidClient                  = Unauthorized("refresh token was not issued to this oauth client")
+	ErrInsufficientScope              = Forbidden("token does not have the scope required for this operation")
+	ErrEmailNotVerified               = Forbidden("email must be verified before this operation, check your inbox or resend the verification")
+	ErrInvalidCsrfToken               = Forbidden("csrf token is missing or does not match, send the wb_csrf cookie in the X-CSRF-Token header")
+	ErrOidcEmailNotVerified           = Forbidden("the identity provider did not share a verified email, link the identity from your account instead")
+	ErrOidcUserNotVerified            = Forbidden("the user with this email hasn't verified it, login and link the identity from your account instead")
+	ErrInsufficientRole               = Forbidden("your role does not have the permission required for this operation")
+	ErrCannotChangeOwnRole            = Forbidden("you cannot change your own role, ask another admin")
+	ErrUnknownSession                 = NotFound("could not find an active session with this id")
+	ErrUnknownApiKey                  = NotFound("could not find an api key with this id")
+	ErrOidcNotConfigured              = NotFound("federated login is not enabled")
+	ErrUnknownPasskey                 = NotFound("could not find a passkey with this id")
+	ErrPasskeysNotConfigured          = NotFound("passkey login is not enabled")
 	ErrInvalidHttpMethod              = MethodNotAllowed("invalid http method")
 	ErrMaxAmountOfWordbubblesReached  = Conflict("the max amount of wordbubbles has been created for this user")
+	ErrMfaAlreadyEnabled              = Conflict("two-factor authentication is already enabled for this user")
+	ErrEmailAlreadyVerified           = Conflict("email has already been verified for this user")
+	ErrIdentityAlreadyLinked          = Conflict("this identity is already linked to a user")
+	ErrPasskeyAlreadyRegistered       = Conflict("this passkey is already registered")
+	ErrAccountLocked                  = Locked("account is temporarily locked after too many failed login attempts")
+	ErrTooManyLoginAttempts           = TooManyRequests("too many failed login attempts, please wait before trying again")
+	ErrTooManyLoginLinks              = TooManyRequests("too many login links have been requested for this email, please wait before trying again")
 	ErrCouldNotStoreRefreshToken      = InternalServerError("could not successfully store refresh token")
+	ErrCouldNotRevokeRefreshToken     = InternalServerError("could not successfully revoke refresh token")
+	ErrCouldNotRevokeAccessToken      = InternalServerError("could not successfully revoke access token")
+	ErrCouldNotCheckDenylist          = InternalServerError("an error occurred checking revoked access tokens")
+	ErrCouldNotListSessions           = InternalServerError("an error occurred retrieving sessions from the database")
+	ErrCouldNotStoreApiKey            = InternalServerError("could not successfully store api key")
+	ErrCouldNotListApiKeys            = InternalServerError("an error occurred retrieving api keys from the database")
+	ErrCouldNotRevokeApiKey           = InternalServerError("could not successfully revoke api key")
+	ErrCouldNotStoreClient            = InternalServerError("could not successfully store oauth client")
+	ErrCouldNotStoreAuthorizationCode = InternalServerError("could not successfully store authorization code")
+	ErrCouldNotUseAuthorizationCode   = InternalServerError("an error occurred redeeming authorization code")
+	ErrCouldNotStoreTotpSecret        = InternalServerError("could not successfully store totp secret")
+	ErrCouldNotStoreRecoveryCodes     = InternalServerError("could not successfully store recovery codes")
+	ErrCouldNotCheckMfa               = InternalServerError("an error occurred checking two-factor authentication")
+	ErrCouldNotDisableMfa             = InternalServerError("could not successfully disable two-factor authentication")
+	ErrCouldNotStoreMfaChallenge      = InternalServerError("could not successfully store mfa challenge")
+	ErrCouldNotStorePasswordReset     = InternalServerError("could not successfully store password reset token")
+	ErrCouldNotResetPassword          = InternalServerError("could not successfully reset password")
+	ErrCouldNotStoreLoginLink         = InternalServerError("could not successfully store login link")
+	ErrCouldNotCheckLoginLink         = InternalServerError("an error occurred checking login link")
+	ErrCouldNotReachOidcProvider      = InternalServerError("an error occurred communicating with the identity provider")
+	ErrCouldNotStoreOidcState         = InternalServerError("could not successfully store federated login state")
+	ErrCouldNotUseOidcState           = InternalServerError("an error occurred redeeming federated login state")
+	ErrCouldNotLinkIdentity           = InternalServerError("could not successfully link identity")
+	ErrCouldNotCheckIdentity          = InternalServerError("an error occurred checking linked identities")
+	ErrCouldNotRetrieveRole           = InternalServerError("an error occurred retrieving role from the database")
+	ErrCouldNotAssignRole             = InternalServerError("could not successfully assign role")
+	ErrCouldNotStorePasskeyChallenge  = InternalServerError("could not successfully store passkey challenge")
+	ErrCouldNotUsePasskeyChallenge    = InternalServerError("an error occurred redeeming passkey challenge")
+	ErrCouldNotStorePasskey           = InternalServerError("could not successfully store passkey")
+	ErrCouldNotCheckPasskey           = InternalServerError("an error occurred checking passkey")
+	ErrCouldNotListPasskeys           = InternalServerError("an error occurred retrieving passkeys from the database")
+	ErrCouldNotRevokePasskey          = InternalServerError("could not successfully revoke passkey")
+	ErrCouldNotSendEmail              = InternalServerError("an error occurred sending an email")
+	ErrCouldNotStoreVerification      = InternalServerError("could not successfully store verification token")
+	ErrCouldNotVerifyEmail            = InternalServerError("could not successfully verify email")
+	ErrCouldNotCheckLoginAttempts     = InternalServerError("an error occurred checking failed login attempts")
+	ErrCouldNotRecordLoginAttempt     = InternalServerError("could not successfully record login attempt")
+	ErrCouldNotCleanupCodes           = InternalServerError("an error occurred cleaning up expired authorization codes")
+	ErrCouldNotCleanupMfaChallenges   = InternalServerError("an error occurred cleaning up expired mfa challenges")
+	ErrCouldNotCleanupPasswordResets  = InternalServerError("an error occurred cleaning up expired password reset tokens")
+	ErrCouldNotCleanupVerifications   = InternalServerError("an error occurred cleaning up expired verification tokens")
+	ErrCouldNotCleanupLoginAttempts   = InternalServerError("an error occurred cleaning up failed login attempts")
+	ErrCouldNotCleanupLoginLinks      = InternalServerError("an error occurred cleaning up expired login links")
+	ErrCouldNotCleanupOidcStates      = InternalServerError("an error occurred cleaning up expired federated login states")
+	ErrCouldNotCleanupChallenges      = InternalServerError("an error occurred cleaning up expired passkey challenges")
 	ErrCouldNotDetermineUserExistence = InternalServerError("could not determine if user exists")
 	ErrCouldNotBeHashPassword         = InternalServerError("an error occurred storing password")
+	ErrCouldNotRehashPassword         = InternalServerError("could not successfully rehash password")
 	ErrCouldNotCleanupTokens          = InternalServerError("an error occurred cleaning up old refresh tokens")
+	ErrCouldNotMigrateRefreshTokens   = InternalServerError("an error occurred hashing plaintext refresh tokens")
 	ErrCouldNotAddUser                = InternalServerError("an error occurred adding user to database")
+	ErrCouldNotChooseUsername         = InternalServerError("could not choose an available username for the new user")
 	ErrSQLMappingError                = InternalServerError("an error occurred mapping data from the database")
+	ErrCouldNotAcquireJobLock         = InternalServerError("an error occurred acquiring a job lock")
+	ErrCouldNotReleaseJobLocks        = InternalServerError("an error occurred releasing job locks")
 )
 
 // @Description StatusNoContent - 201
@@ -54,6 +181,18 @@ type StatusUnauthorized struct {
 	Message string `json:"message" example:"could not validate the refresh token, please login again"`
 }
 
+// @Description StatusForbidden - 403
+type StatusForbidden struct {
+	Code    int `json:"code" example:"403"`
+	Message string `json:"message" example:"token does not have the scope required for this operation"`
+}
+
+// @Description StatusNotFound - 404
+type StatusNotFound struct {
+	Code    int `json:"code" example:"404"`
+	Message string `json:"message" example:"could not find an active session with this id"`
+}
+
 // @Description StatusMethodNotAllowed - 405
 type StatusMethodNotAllowed struct {
 	Code    int `json:"code" example:"405"`
@@ -66,6 +205,18 @@ type StatusConflict struct {
 	Message string `json:"message" example:"the max amount of wordbubbles has been created for this user"`
 }
 
+// @Description StatusLocked - 423
+type StatusLocked struct {
+	Code    int `json:"code" example:"423"`
+	Message string `json:"message" example:"account is temporarily locked after too many failed login attempts"`
+}
+
+// @Description StatusTooManyRequests - 429
+type StatusTooManyRequests struct {
+	Code    int `json:"code" example:"429"`
+	Message string `json:"message" example:"too many failed login attempts, please wait before trying again"`
+}
+
 // @Description StatusInternalServerError - 500
 type StatusInternalServerError struct {
 	Code    int `json:"code" example:"500"`
@@ -84,6 +235,14 @@ func Unauthorized(message string) *StatusUnauthorized {
 	return &StatusUnauthorized{http.StatusUnauthorized, message}
 }
 
+func Forbidden(message string) *StatusForbidden {
+	return &StatusForbidden{http.StatusForbidden, message}
+}
+
+func NotFound(message string) *StatusNotFound {
+	return &StatusNotFound{http.StatusNotFound, message}
+}
+
 func MethodNotAllowed(message string) *StatusMethodNotAllowed {
 	return &StatusMethodNotAllowed{http.StatusMethodNotAllowed, message}
 }
@@ -92,6 +251,14 @@ func Conflict(message string) *StatusConflict {
 	return &StatusConflict{http.StatusConflict, message}
 }
 
+func Locked(message string) *StatusLocked {
+	return &StatusLocked{http.StatusLocked, message}
+}
+
+func TooManyRequests(message string) *StatusTooManyRequests {
+	return &StatusTooManyRequests{http.StatusTooManyRequests, message}
+}
+
 func InternalServerError(message string) *StatusInternalServerError {
 	return &StatusInternalServerError{http.StatusInternalServerError, message}
 }
@@ -108,6 +275,14 @@ func (err *StatusUnauthorized) Error() string {
 	return err.Message
 }
 
+func (err *StatusForbidden) Error() string {
+	return err.Message
+}
+
+func (err *StatusNotFound) Error() string {
+	return err.Message
+}
+
 func (err *StatusMethodNotAllowed) Error() string {
 	return err.Message
 }
@@ -116,6 +291,14 @@ func (err *StatusConflict) Error() string {
 	return err.Message
 }
 
+func (err *StatusLocked) Error() string {
+	return err.Message
+}
+
+func (err *StatusTooManyRequests) Error() string {
+	return err.Message
+}
+
 func (err *StatusInternalServerError) Error() string {
 	return err.Message
 }
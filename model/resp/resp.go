@@ -12,7 +12,248 @@ type TokenResponse struct {
 	RefreshToken string `json:"refresh_token,omitempty" example:"xxx.yyy.zzz"`
 }
 
+// @Description CookieSessionResponse is returned instead of the tokens when they're set as cookies,
+// @Description the csrf token is read from the wb_csrf cookie and sent back in the X-CSRF-Token header
+type CookieSessionResponse struct {
+	ExpiresIn int64 `json:"expires_in" example:"30"` // seconds until the access token cookie expires and /token should be called
+}
+
 // @Description PushResponse contains the success text response from pushing a new wordbubble
 type PushResponse struct {
 	Message string `json:"message" example:"thank you!"`
 }
+
+// @Description LogoutResponse contains the success text response from revoking refresh tokens
+type LogoutResponse struct {
+	Message string `json:"message" example:"you have been logged out"`
+}
+
+// @Description JWKSResponse contains the public keys used to verify tokens
+type JWKSResponse struct {
+	Keys []JWK `json:"keys"`
+}
+
+// @Description JWK is a public key used to verify tokens, as defined by RFC 7517
+type JWK struct {
+	Kty string `json:"kty" example:"OKP"`
+	Use string `json:"use" example:"sig"`
+	Alg string `json:"alg" example:"EdDSA"`
+	Kid string `json:"kid" example:"2022-10"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty" example:"Ed25519"`
+	X   string `json:"x,omitempty" example:"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"`
+}
+
+// @Description SessionsResponse contains every active session of a user
+type SessionsResponse struct {
+	Sessions []Session `json:"sessions"`
+}
+
+// @Description Session is a login on a device, it stays active as long as its refresh token is rotated
+type Session struct {
+	Id         string `json:"id" example:"1b4e28ba2fa1f1d2883f0b6c4e7a9d05"`
+	UserAgent  string `json:"user_agent" example:"Mozilla/5.0 (X11; Linux x86_64; rv:105.0) Gecko/20100101 Firefox/105.0"`
+	IPAddress  string `json:"ip_address" example:"203.0.113.7"`
+	CreatedAt  int64  `json:"created_at" example:"1665964800"`
+	LastUsedAt int64  `json:"last_used_at" example:"1665968400"`
+}
+
+// @Description RevokeSessionResponse contains the success text response from revoking a session
+type RevokeSessionResponse struct {
+	Message string `json:"message" example:"session has been revoked"`
+}
+
+// @Description CreateApiKeyResponse contains a new api key, the key is only ever returned once
+type CreateApiKeyResponse struct {
+	Id        string `json:"id" example:"9f86d081"`
+	Name      string `json:"name" example:"nightly cron"`
+	Key       string `json:"key" example:"wb_2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"`
+	Scope     string `json:"scope" example:"wordbubble:push"`
+	CreatedAt int64  `json:"created_at" example:"1665964800"`
+}
+
+// @Description ApiKeysResponse contains every api key of a user
+type ApiKeysResponse struct {
+	ApiKeys []ApiKey `json:"api_keys"`
+}
+
+// @Description ApiKey is a long lived key a user created for scripts, the key itself is never returned again
+type ApiKey struct {
+	Id         string `json:"id" example:"9f86d081"`
+	Name       string `json:"name" example:"nightly cron"`
+	Scope      string `json:"scope" example:"wordbubble:push"`
+	CreatedAt  int64  `json:"created_at" example:"1665964800"`
+	LastUsedAt int64  `json:"last_used_at" example:"1665968400"`
+}
+
+// @Description RevokeApiKeyResponse contains the success text response from revoking an api key
+type RevokeApiKeyResponse struct {
+	Message string `json:"message" example:"api key has been revoked"`
+}
+
+// @Description RegisterClientResponse contains a new oauth client, apps use the id to send users to /oauth/authorize
+type RegisterClientResponse struct {
+	ClientId    string `json:"client_id" example:"5d41402abc4b2a76b9719d911017c592"`
+	Name        string `json:"name" example:"wordbubble for desktop"`
+	RedirectUri string `json:"redirect_uri" example:"https://app.example.com/callback"`
+	CreatedAt   int64  `json:"created_at" example:"1665964800"`
+}
+
+// @Description ConsentResponse contains what a third party app is asking for, shown to the user before they approve it
+type ConsentResponse struct {
+	ClientId    string `json:"client_id" example:"5d41402abc4b2a76b9719d911017c592"`
+	ClientName  string `json:"client_name" example:"wordbubble for desktop"`
+	RedirectUri string `json:"redirect_uri" example:"https://app.example.com/callback"`
+	Scope       string `json:"scope" example:"wordbubble:push wordbubble:read"`
+}
+
+// @Description AuthorizeResponse contains the url the user is sent back to the third party app with, holding either a code or an error
+type AuthorizeResponse struct {
+	RedirectUri string `json:"redirect_uri" example:"https://app.example.com/callback?code=9a0364b9e99bb480dd25e1f0284c8555&state=af0ifjsldkj"`
+}
+
+// @Description OAuthTokenResponse contains the tokens issued to a third party app, as defined by RFC 6749
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token" example:"xxx.yyy.zzz"`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int64  `json:"expires_in" example:"30"`
+	RefreshToken string `json:"refresh_token" example:"xxx.yyy.zzz"`
+	Scope        string `json:"scope" example:"wordbubble:push wordbubble:read"`
+}
+
+// @Description EnrollTotpResponse contains a new totp secret, it isn't required at login until it's confirmed with a code
+type EnrollTotpResponse struct {
+	Secret          string `json:"secret" example:"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"`
+	ProvisioningUri string `json:"provisioning_uri" example:"otpauth://totp/wordbubble:ben?algorithm=SHA1&digits=6&issuer=wordbubble&period=30&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"`
+}
+
+// @Description RecoveryCodesResponse contains one time codes that can be used in place of a totp code, they're only ever returned once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"4f1a9-c2e07-8b3d5-17f6a,09e2c-d41b7-a6f30-5c8e1"`
+}
+
+// @Description MfaChallengeResponse is returned from /login when two-factor authentication is enabled, the token is exchanged at /login/mfa
+type MfaChallengeResponse struct {
+	MfaToken  string `json:"mfa_token" example:"7d793037a0760186574b0282f2f435e7"`
+	ExpiresIn int64  `json:"expires_in" example:"300"`
+}
+
+// @Description DisableMfaResponse contains the success text response from disabling two-factor authentication
+type DisableMfaResponse struct {
+	Message string `json:"message" example:"two-factor authentication has been disabled"`
+}
+
+// @Description LoginLinkResponse contains the success text response from requesting a login link
+type LoginLinkResponse struct {
+	Message string `json:"message" example:"if an account uses this email, a login link has been sent"`
+}
+
+// @Description OidcAuthorizationResponse contains the url of the identity provider the user is sent to, to login
+type OidcAuthorizationResponse struct {
+	AuthorizationUrl string `json:"authorization_url" example:"https://accounts.example.com/authorize?client_id=wordbubble&response_type=code&state=9c1185a5c5e9fc54"`
+}
+
+// @Description IdentityLinkedResponse contains the success text response from linking an identity
+type IdentityLinkedResponse struct {
+	Message string `json:"message" example:"identity has been linked, it can now be used to login"`
+}
+
+// @Description RoleResponse contains the user a role was assigned to, and the role they have now
+type RoleResponse struct {
+	Username string `json:"username" example:"ben"`
+	Role     string `json:"role" example:"moderator"`
+}
+
+// @Description PasskeyCreationOptions are passed to navigator.credentials.create to register a passkey, after parsing them with
+// @Description PublicKeyCredential.parseCreationOptionsFromJSON, as defined by WebAuthn. The challenge can only be used once
+type PasskeyCreationOptions struct {
+	Challenge              string                        `json:"challenge" example:"q83vEjRWeJq83vEjRWeJq83vEjRWeJq83vEjRWeJq80"`
+	Rp                     PasskeyRelyingParty           `json:"rp"`
+	User                   PasskeyUser                   `json:"user"`
+	PubKeyCredParams       []PasskeyCredentialParameters `json:"pubKeyCredParams"`
+	Timeout                int64                         `json:"timeout" example:"300000"` // milliseconds
+	ExcludeCredentials     []PasskeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection PasskeyAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                        `json:"attestation" example:"none"`
+}
+
+// @Description PasskeyRequestOptions are passed to navigator.credentials.get to login with a passkey, after parsing them with
+// @Description PublicKeyCredential.parseRequestOptionsFromJSON, as defined by WebAuthn. The challenge can only be used once
+type PasskeyRequestOptions struct {
+	Challenge        string                        `json:"challenge" example:"q83vEjRWeJq83vEjRWeJq83vEjRWeJq83vEjRWeJq80"`
+	Timeout          int64                         `json:"timeout" example:"300000"` // milliseconds
+	RpId             string                        `json:"rpId" example:"wordbubble.com"`
+	AllowCredentials []PasskeyCredentialDescriptor `json:"allowCredentials"` // empty, any passkey registered for wordbubble can be used
+	UserVerification string                        `json:"userVerification" example:"preferred"`
+}
+
+// @Description PasskeyRelyingParty is the site a passkey is registered for
+type PasskeyRelyingParty struct {
+	Id   string `json:"id" example:"wordbubble.com"`
+	Name string `json:"name" example:"wordbubble"`
+}
+
+// @Description PasskeyUser is the user a passkey is registered to, the id is the user handle returned on login
+type PasskeyUser struct {
+	Id          string `json:"id" example:"AAAAAAAAAAI"`
+	Name        string `json:"name" example:"ben"`
+	DisplayName string `json:"displayName" example:"ben"`
+}
+
+// @Description PasskeyCredentialParameters is an algorithm a passkey can sign with, as a COSE algorithm identifier
+type PasskeyCredentialParameters struct {
+	Type string `json:"type" example:"public-key"`
+	Alg  int64  `json:"alg" example:"-7"`
+}
+
+// @Description PasskeyCredentialDescriptor identifies a passkey that's already registered
+type PasskeyCredentialDescriptor struct {
+	Type string `json:"type" example:"public-key"`
+	Id   string `json:"id" example:"Z2Nvb2dsZS1wYXNza2V5"`
+}
+
+// @Description PasskeyAuthenticatorSelection asks for a passkey the authenticator can find without being told its id
+type PasskeyAuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey" example:"required"`
+	RequireResidentKey bool   `json:"requireResidentKey" example:"true"`
+	UserVerification   string `json:"userVerification" example:"preferred"`
+}
+
+// @Description PasskeysResponse contains every passkey of a user
+type PasskeysResponse struct {
+	Passkeys []Passkey `json:"passkeys"`
+}
+
+// @Description Passkey is a passkey a user registered, its public key is never returned
+type Passkey struct {
+	Id         string `json:"id" example:"Z2Nvb2dsZS1wYXNza2V5"`
+	Name       string `json:"name" example:"work laptop"`
+	CreatedAt  int64  `json:"created_at" example:"1665964800"`
+	LastUsedAt int64  `json:"last_used_at" example:"1665968400"`
+}
+
+// @Description RevokePasskeyResponse contains the success text response from revoking a passkey
+type RevokePasskeyResponse struct {
+	Message string `json:"message" example:"passkey has been revoked"`
+}
+
+// @Description ForgotPasswordResponse contains the success text response from requesting a password reset
+type ForgotPasswordResponse struct {
+	Message string `json:"message" example:"if the user exists, a password reset email has been sent"`
+}
+
+// @Description ResetPasswordResponse contains the success text response from resetting a password
+type ResetPasswordResponse struct {
+	Message string `json:"message" example:"your password has been reset, please login again"`
+}
+
+// @Description VerifyEmailResponse contains the success text response from verifying an email
+type VerifyEmailResponse struct {
+	Message string `json:"message" example:"your email has been verified"`
+}
+
+// @Description VerificationSentResponse contains the success text response from resending a verification email
+type VerificationSentResponse struct {
+	Message string `json:"message" example:"a verification email has been sent"`
+}
@@ -0,0 +1,204 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// cborMaxDepth is how deeply arrays and maps can be nested, authenticator data never nests more than a few levels
+const cborMaxDepth = 16
+
+var errCbor = errors.New("cbor is malformed or uses a feature that isn't supported")
+
+// decodeCbor decodes the first CBOR item (RFC 8949) of the bytes passed, enough of CBOR to read what authenticators send.
+// Integers are int64, byte strings []byte, text strings string, arrays []interface{}, maps map[interface{}]interface{}
+// keyed by int64 or string, and simple values bool or nil. Indefinite lengths, tags and floats are not supported.
+// []byte is what's left after the item, authenticator data is followed by extensions.
+func decodeCbor(b []byte) (interface{}, []byte, error) {
+	return decodeCborItem(b, 0)
+}
+
+func decodeCborItem(b []byte, depth int) (interface{}, []byte, error) {
+	if depth > cborMaxDepth || len(b) == 0 {
+		return nil, nil, errCbor
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, b, nil
+		case 21:
+			return true, b, nil
+		case 22:
+			return nil, b, nil
+		}
+		return nil, nil, errCbor
+	}
+	arg, b, err := cborArgument(info, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCbor
+		}
+		return int64(arg), b, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCbor
+		}
+		return -1 - int64(arg), b, nil
+	case 2, 3:
+		if arg > uint64(len(b)) {
+			return nil, nil, errCbor
+		}
+		s := b[:arg]
+		if major == 3 {
+			return string(s), b[arg:], nil
+		}
+		return append([]byte{}, s...), b[arg:], nil
+	case 4:
+		if arg > uint64(len(b)) { // every item is at least a byte
+			return nil, nil, errCbor
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item interface{}
+			if item, b, err = decodeCborItem(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, b, nil
+	case 5:
+		if arg > uint64(len(b))/2 {
+			return nil, nil, errCbor
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value interface{}
+			if key, b, err = decodeCborItem(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errCbor
+			}
+			if _, ok := m[key]; ok {
+				return nil, nil, errCbor
+			}
+			if value, b, err = decodeCborItem(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			m[key] = value
+		}
+		return m, b, nil
+	}
+	return nil, nil, errCbor // tags
+}
+
+// cborArgument reads the argument of an item, held in the additional information or the bytes following it
+func cborArgument(info byte, b []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), b, nil
+	case info == 24 && len(b) >= 1:
+		return uint64(b[0]), b[1:], nil
+	case info == 25 && len(b) >= 2:
+		return uint64(binary.BigEndian.Uint16(b)), b[2:], nil
+	case info == 26 && len(b) >= 4:
+		return uint64(binary.BigEndian.Uint32(b)), b[4:], nil
+	case info == 27 && len(b) >= 8:
+		return binary.BigEndian.Uint64(b), b[8:], nil
+	}
+	return 0, nil, errCbor
+}
+
+// encodeCbor encodes the values decodeCbor returns, along with int and maps keyed by int64 or string,
+// whose keys are sorted so the same value always encodes the same way. It panics on any other type
+func encodeCbor(v interface{}) []byte {
+	var buf bytes.Buffer
+	encodeCborItem(&buf, v)
+	return buf.Bytes()
+}
+
+func encodeCborItem(buf *bytes.Buffer, v interface{}) {
+	switch t := v.(type) {
+	case nil:
+		buf.WriteByte(0xf6)
+	case bool:
+		if t {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case int:
+		encodeCborItem(buf, int64(t))
+	case int64:
+		if t >= 0 {
+			writeCborHead(buf, 0, uint64(t))
+		} else {
+			writeCborHead(buf, 1, uint64(-1-t))
+		}
+	case []byte:
+		writeCborHead(buf, 2, uint64(len(t)))
+		buf.Write(t)
+	case string:
+		writeCborHead(buf, 3, uint64(len(t)))
+		buf.WriteString(t)
+	case []interface{}:
+		writeCborHead(buf, 4, uint64(len(t)))
+		for _, item := range t {
+			encodeCborItem(buf, item)
+		}
+	case map[int64]interface{}:
+		keys := make([]int64, 0, len(t))
+		for key := range t {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		writeCborHead(buf, 5, uint64(len(t)))
+		for _, key := range keys {
+			encodeCborItem(buf, key)
+			encodeCborItem(buf, t[key])
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for key := range t {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		writeCborHead(buf, 5, uint64(len(t)))
+		for _, key := range keys {
+			encodeCborItem(buf, key)
+			encodeCborItem(buf, t[key])
+		}
+	default:
+		panic("cbor can't encode the type passed")
+	}
+}
+
+func writeCborHead(buf *bytes.Buffer, major byte, arg uint64) {
+	major <<= 5
+	switch {
+	case arg < 24:
+		buf.WriteByte(major | byte(arg))
+	case arg <= math.MaxUint8:
+		buf.Write([]byte{major | 24, byte(arg)})
+	case arg <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		binary.Write(buf, binary.BigEndian, uint16(arg))
+	case arg <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		binary.Write(buf, binary.BigEndian, uint32(arg))
+	default:
+		buf.WriteByte(major | 27)
+		binary.Write(buf, binary.BigEndian, arg)
+	}
+}
@@ -0,0 +1,94 @@
+package util
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cbor(t *testing.T) {
+	tests := map[string]struct {
+		value    interface{}
+		expected interface{}
+	}{
+		"small uint": {
+			value:    int64(10),
+			expected: int64(10),
+		},
+		"large uint": {
+			value:    int64(math.MaxInt64),
+			expected: int64(math.MaxInt64),
+		},
+		"negative int": {
+			value:    int64(-257),
+			expected: int64(-257),
+		},
+		"int": {
+			value:    1000,
+			expected: int64(1000),
+		},
+		"byte string": {
+			value:    []byte{0xde, 0xad, 0xbe, 0xef},
+			expected: []byte{0xde, 0xad, 0xbe, 0xef},
+		},
+		"text string": {
+			value:    "none",
+			expected: "none",
+		},
+		"simple values": {
+			value:    []interface{}{true, false, nil},
+			expected: []interface{}{true, false, nil},
+		},
+		"nested maps": {
+			value: map[string]interface{}{
+				"fmt":     "none",
+				"attStmt": map[string]interface{}{},
+				"key":     map[int64]interface{}{1: 2, -1: []byte{1}},
+			},
+			expected: map[interface{}]interface{}{
+				"fmt":     "none",
+				"attStmt": map[interface{}]interface{}{},
+				"key":     map[interface{}]interface{}{int64(1): int64(2), int64(-1): []byte{1}},
+			},
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			b := encodeCbor(tcase.value)
+			value, rest, err := decodeCbor(append(b, 0xff))
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.expected, value)
+			assert.Equal(t, []byte{0xff}, rest)
+		})
+	}
+}
+
+func Test_DecodeCborMalformed(t *testing.T) {
+	deeplyNested := []byte{}
+	for i := 0; i <= cborMaxDepth+1; i++ {
+		deeplyNested = append(deeplyNested, 0x81) // array of one item
+	}
+	deeplyNested = append(deeplyNested, 0x00)
+	tests := map[string][]byte{
+		"empty":                  {},
+		"truncated argument":     {0x19, 0x01},
+		"truncated byte string":  {0x44, 0x01, 0x02},
+		"truncated array":        {0x82, 0x01},
+		"truncated map":          {0xa1, 0x01},
+		"indefinite length":      {0x5f, 0x41, 0x01, 0xff},
+		"tag":                    {0xc0, 0x00},
+		"float":                  {0xfa, 0x00, 0x00, 0x00, 0x00},
+		"uint too large":         {0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"map with an array key":  {0xa1, 0x80, 0x00},
+		"map with duplicate key": {0xa2, 0x01, 0x00, 0x01, 0x00},
+		"nested too deeply":      deeplyNested,
+		"huge array length":      {0x9a, 0xff, 0xff, 0xff, 0xff},
+	}
+	for tname, b := range tests {
+		t.Run(tname, func(t *testing.T) {
+			_, _, err := decodeCbor(b)
+			assert.Equal(t, errCbor, err)
+		})
+	}
+}
@@ -1,43 +1,150 @@
 package util
 
 import (
-	"log"
+	"errors"
+	"strconv"
+	"time"
 
 	"github.com/bchadwic/wordbubble/model"
 	"github.com/bchadwic/wordbubble/model/resp"
 	"github.com/golang-jwt/jwt"
 )
 
-var SigningKey func() []byte = func() []byte {
-	log.Fatal("token signing key was not set")
+// token types, set as the typ claim so an access token can't be used as a refresh token, or a refresh token as an access token
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+const (
+	DefaultTokenIssuer   = "https://api.wordbubble.com"
+	DefaultTokenAudience = "wordbubble"
+	// DefaultTokenLeeway is how far the clocks of the instances signing and verifying a token can drift apart
+	DefaultTokenLeeway = 5 * time.Second
+)
+
+// ClaimsPolicy is the issuer and audience every token is issued with and must have when it's parsed,
+// along with the leeway allowed when the times of a token are checked
+type ClaimsPolicy struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+// DefaultClaimsPolicy returns the claims policy used when the environment doesn't set one
+func DefaultClaimsPolicy() ClaimsPolicy {
+	return ClaimsPolicy{
+		Issuer:   DefaultTokenIssuer,
+		Audience: DefaultTokenAudience,
+		Leeway:   DefaultTokenLeeway,
+	}
+}
+
+// Validate makes sure the issuer and audience are set, and that the leeway isn't negative
+func (policy ClaimsPolicy) Validate() error {
+	if policy.Issuer == "" {
+		return errors.New("token issuer must be set")
+	}
+	if policy.Audience == "" {
+		return errors.New("token audience must be set")
+	}
+	if policy.Leeway < 0 {
+		return errors.New("token leeway must not be negative")
+	}
 	return nil
 }
 
-func GenerateSignedToken(iat, exp, userId int64) string {
-	signedToken, _ := jwt.NewWithClaims(
-		jwt.SigningMethodHS256, &model.TokenClaims{StandardClaims: jwt.StandardClaims{IssuedAt: iat, ExpiresAt: exp}, UserId: userId},
-	).SignedString(SigningKey())
+// GenerateSignedToken generates a token of the type passed, signed with the active key, the key's id is set as the kid header.
+// Every token carries a unique id (jti) so that two tokens issued for the same user in the same second are never identical.
+// The role is only carried by access tokens, it's looked up again whenever a refresh token is used
+func GenerateSignedToken(keys KeyProvider, tokenType string, iat, exp, userId int64, scope, role string) string {
+	return GenerateSignedClientToken(keys, tokenType, iat, exp, userId, scope, role, "")
+}
+
+// GenerateSignedClientToken generates a token like GenerateSignedToken, for the oauth client passed to act for the user
+func GenerateSignedClientToken(keys KeyProvider, tokenType string, iat, exp, userId int64, scope, role, clientId string) string {
+	key := keys.SigningKey()
+	policy := keys.Policy()
+	token := jwt.NewWithClaims(
+		key.method(), &model.TokenClaims{
+			StandardClaims: jwt.StandardClaims{
+				Id:        RandomString(16),
+				Issuer:    policy.Issuer,
+				Audience:  policy.Audience,
+				Subject:   strconv.FormatInt(userId, 10),
+				IssuedAt:  iat,
+				NotBefore: iat,
+				ExpiresAt: exp,
+			},
+			UserId:   userId,
+			Scope:    scope,
+			Type:     tokenType,
+			Role:     role,
+			ClientId: clientId,
+		},
+	)
+	token.Header["kid"] = key.Id
+	signedToken, _ := token.SignedString(key.signingKey())
 	return signedToken
 }
 
-func GetUserIdFromTokenString(tokenStr string) (int64, error) {
-	if tokenClaims, err := ParseWithClaims(tokenStr); err != nil {
+func GetUserIdFromTokenString(keys KeyProvider, tokenStr string) (int64, error) {
+	if tokenClaims, err := ParseWithClaims(keys, TokenTypeAccess, tokenStr); err != nil {
 		return 0, err
 	} else {
 		return tokenClaims.UserId, nil
 	}
 }
 
-func ParseWithClaims(tokenStr string) (*model.TokenClaims, error) {
+// ParseWithClaims parses the token string, verifying the signature with the key matching the kid header.
+// The token's alg header must match the algorithm of the key, so a public key can never be used as an HS256 secret.
+// The claims must match the policy of the key provider, and the token must be of the type passed
+// *model.TokenClaims is the claims of the token, can be nil.
+// error could be (401) resp.ErrMalformedToken, (401) resp.ErrInvalidTokenSignature, (401) resp.ErrTokenIsExpired,
+// (401) resp.ErrTokenIsNotYetValid, (401) resp.ErrInvalidTokenType, (401) resp.ErrInvalidTokenClaims or nil.
+func ParseWithClaims(keys KeyProvider, tokenType, tokenStr string) (*model.TokenClaims, error) {
 	var tokenClaims model.TokenClaims
-	token, _ := jwt.ParseWithClaims(tokenStr, &tokenClaims, func(t *jwt.Token) (interface{}, error) {
-		return SigningKey(), nil
+	parser := jwt.Parser{SkipClaimsValidation: true} // the claims are checked below, with the leeway of the policy
+	_, err := parser.ParseWithClaims(tokenStr, &tokenClaims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key := keys.VerificationKey(kid)
+		if key == nil || t.Method.Alg() != key.method().Alg() {
+			return nil, resp.ErrInvalidTokenSignature
+		}
+		return key.verificationKey(), nil
 	})
-	if token != nil {
-		if !token.Valid {
-			return nil, resp.ErrTokenIsExpired
+	if err != nil {
+		if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors&jwt.ValidationErrorMalformed != 0 {
+			return nil, resp.ErrMalformedToken
 		}
-		return &tokenClaims, nil
+		return nil, resp.ErrInvalidTokenSignature
 	}
-	return nil, resp.ErrInvalidTokenSignature
+	if err = keys.Policy().validClaims(&tokenClaims, tokenType, jwt.TimeFunc().Unix()); err != nil {
+		return nil, err
+	}
+	return &tokenClaims, nil
+}
+
+// validClaims checks the times of a token, allowing for the leeway, then that it's of the type expected and
+// was issued by and for this api, for the user it carries
+func (policy ClaimsPolicy) validClaims(claims *model.TokenClaims, tokenType string, now int64) error {
+	leeway := int64(policy.Leeway / time.Second)
+	switch {
+	case claims.ExpiresAt == 0:
+		return resp.ErrMalformedToken
+	case now-leeway > claims.ExpiresAt:
+		return resp.ErrTokenIsExpired
+	case now+leeway < claims.NotBefore, now+leeway < claims.IssuedAt:
+		return resp.ErrTokenIsNotYetValid
+	}
+	if claims.Type == "" && tokenType == TokenTypeRefresh {
+		return nil // refresh tokens issued before token types existed have none of the claims below, they're checked against the store instead
+	}
+	if claims.Type != tokenType {
+		return resp.ErrInvalidTokenType
+	}
+	if claims.Issuer != policy.Issuer || !claims.VerifyAudience(policy.Audience, true) || claims.Subject != strconv.FormatInt(claims.UserId, 10) {
+		return resp.ErrInvalidTokenClaims
+	}
+	return nil
 }
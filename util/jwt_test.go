@@ -0,0 +1,179 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+// signClaims signs the claims passed as they are, so tokens that this api would never issue can be parsed
+func signClaims(keys KeyProvider, claims *model.TokenClaims) string {
+	key := keys.SigningKey()
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.Id
+	signedToken, _ := token.SignedString(key.signingKey())
+	return signedToken
+}
+
+func Test_ParseWithClaims(t *testing.T) {
+	jwt.TimeFunc = TestTimerFromUnix(1000).Now
+	defer func() { jwt.TimeFunc = time.Now }()
+	keys := NewKeyProvider(TestTimerFromUnix(1000), 0, DefaultClaimsPolicy(), &Key{Id: "a", Secret: []byte("secret")})
+	other := NewKeyProvider(TestTimerFromUnix(1000), 0, DefaultClaimsPolicy(), &Key{Id: "a", Secret: []byte("another secret")})
+	claims := func(change func(c *model.TokenClaims)) string {
+		c := &model.TokenClaims{
+			StandardClaims: jwt.StandardClaims{
+				Issuer:    DefaultTokenIssuer,
+				Audience:  DefaultTokenAudience,
+				Subject:   "7",
+				IssuedAt:  1000,
+				NotBefore: 1000,
+				ExpiresAt: 2000,
+			},
+			UserId: 7,
+			Type:   TokenTypeAccess,
+		}
+		change(c)
+		return signClaims(keys, c)
+	}
+	tests := map[string]struct {
+		tokenType   string
+		tokenStr    string
+		expectedErr error
+	}{
+		"valid, access token": {
+			tokenType: TokenTypeAccess,
+			tokenStr:  GenerateSignedToken(keys, TokenTypeAccess, 1000, 2000, 7, "", ""),
+		},
+		"valid, refresh token": {
+			tokenType: TokenTypeRefresh,
+			tokenStr:  GenerateSignedToken(keys, TokenTypeRefresh, 1000, 2000, 7, "", ""),
+		},
+		"valid, expired within the leeway": {
+			tokenType: TokenTypeAccess,
+			tokenStr:  GenerateSignedToken(keys, TokenTypeAccess, 900, 1000-int64(DefaultTokenLeeway/time.Second), 7, "", ""),
+		},
+		"valid, issued ahead within the leeway": {
+			tokenType: TokenTypeAccess,
+			tokenStr:  GenerateSignedToken(keys, TokenTypeAccess, 1000+int64(DefaultTokenLeeway/time.Second), 2000, 7, "", ""),
+		},
+		"valid, refresh token issued before token types existed": {
+			tokenType: TokenTypeRefresh,
+			tokenStr:  signClaims(keys, &model.TokenClaims{StandardClaims: jwt.StandardClaims{ExpiresAt: 2000}, UserId: 7}),
+		},
+		"invalid, malformed": {
+			tokenType:   TokenTypeAccess,
+			tokenStr:    "kladsjfkasjd;fkljasd",
+			expectedErr: resp.ErrMalformedToken,
+		},
+		"invalid, no expiry": {
+			tokenType:   TokenTypeAccess,
+			tokenStr:    claims(func(c *model.TokenClaims) { c.ExpiresAt = 0 }),
+			expectedErr: resp.ErrMalformedToken,
+		},
+		"invalid, signed with another secret": {
+			tokenType:   TokenTypeAccess,
+			tokenStr:    GenerateSignedToken(other, TokenTypeAccess, 1000, 2000, 7, "", ""),
+			expectedErr: resp.ErrInvalidTokenSignature,
+		},
+		"invalid, expired": {
+			tokenType:   TokenTypeAccess,
+			tokenStr:    GenerateSignedToken(keys, TokenTypeAccess, 900, 999-int64(DefaultTokenLeeway/time.Second), 7, "", ""),
+			expectedErr: resp.ErrTokenIsExpired,
+		},
+		"invalid, not valid yet": {
+			tokenType:   TokenTypeAccess,
+			tokenStr:    claims(func(c *model.TokenClaims) { c.NotBefore = 1500 }),
+			expectedErr: resp.ErrTokenIsNotYetValid,
+		},
+		"invalid, issued in the future": {
+			tokenType:   TokenTypeAccess,
+			tokenStr:    claims(func(c *model.TokenClaims) { c.IssuedAt = 1500 }),
+			expectedErr: resp.ErrTokenIsNotYetValid,
+		},
+		"invalid, refresh token used as an access token": {
+			tokenType:   TokenTypeAccess,
+			tokenStr:    GenerateSignedToken(keys, TokenTypeRefresh, 1000, 2000, 7, "", ""),
+			expectedErr: resp.ErrInvalidTokenType,
+		},
+		"invalid, access token used as a refresh token": {
+			tokenType:   TokenTypeRefresh,
+			tokenStr:    GenerateSignedToken(keys, TokenTypeAccess, 1000, 2000, 7, "", ""),
+			expectedErr: resp.ErrInvalidTokenType,
+		},
+		"invalid, access token issued before token types existed": {
+			tokenType:   TokenTypeAccess,
+			tokenStr:    signClaims(keys, &model.TokenClaims{StandardClaims: jwt.StandardClaims{ExpiresAt: 2000}, UserId: 7}),
+			expectedErr: resp.ErrInvalidTokenType,
+		},
+		"invalid, another issuer": {
+			tokenType:   TokenTypeAccess,
+			tokenStr:    claims(func(c *model.TokenClaims) { c.Issuer = "https://example.com" }),
+			expectedErr: resp.ErrInvalidTokenClaims,
+		},
+		"invalid, another audience": {
+			tokenType:   TokenTypeAccess,
+			tokenStr:    claims(func(c *model.TokenClaims) { c.Audience = "another api" }),
+			expectedErr: resp.ErrInvalidTokenClaims,
+		},
+		"invalid, subject isn't the user": {
+			tokenType:   TokenTypeAccess,
+			tokenStr:    claims(func(c *model.TokenClaims) { c.Subject = "8" }),
+			expectedErr: resp.ErrInvalidTokenClaims,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			claims, err := ParseWithClaims(keys, tcase.tokenType, tcase.tokenStr)
+			if tcase.expectedErr != nil {
+				assert.Nil(t, claims)
+				assert.Equal(t, tcase.expectedErr, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, int64(7), claims.UserId)
+		})
+	}
+}
+
+func Test_ClaimsPolicyValidate(t *testing.T) {
+	tests := map[string]struct {
+		change   func(p *ClaimsPolicy)
+		wantsErr bool
+	}{
+		"valid, defaults": {
+			change: func(p *ClaimsPolicy) {},
+		},
+		"valid, no leeway": {
+			change: func(p *ClaimsPolicy) { p.Leeway = 0 },
+		},
+		"invalid, no issuer": {
+			change:   func(p *ClaimsPolicy) { p.Issuer = "" },
+			wantsErr: true,
+		},
+		"invalid, no audience": {
+			change:   func(p *ClaimsPolicy) { p.Audience = "" },
+			wantsErr: true,
+		},
+		"invalid, negative leeway": {
+			change:   func(p *ClaimsPolicy) { p.Leeway = -time.Second },
+			wantsErr: true,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			policy := DefaultClaimsPolicy()
+			tcase.change(&policy)
+			err := policy.Validate()
+			if tcase.wantsErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
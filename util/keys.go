@@ -0,0 +1,246 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/golang-jwt/jwt"
+)
+
+// DefaultKeyId is the id of the key used to verify tokens that were signed before tokens carried a kid header
+const DefaultKeyId = "default"
+
+// signing algorithms supported for tokens
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// Key is a secret used to sign and verify tokens
+type Key struct {
+	Id        string
+	Algorithm string        // HS256 when empty
+	Secret    []byte        // shared secret of an HS256 key
+	Private   crypto.Signer // private key of an RS256 or EdDSA key
+	RetiredAt int64         // unix time the key stopped signing tokens, zero while the key is active
+}
+
+// NewKey creates a key for the algorithm passed, RS256 and EdDSA keys are parsed from a PEM encoded private key.
+// When the algorithm is empty, it's determined by the secret; a PEM encoded private key or an HS256 secret
+func NewKey(id, algorithm string, secret []byte) (*Key, error) {
+	if algorithm == "" {
+		algorithm = HS256
+		if private, err := jwt.ParseRSAPrivateKeyFromPEM(secret); err == nil {
+			return &Key{Id: id, Algorithm: RS256, Private: private}, nil
+		}
+		if private, err := jwt.ParseEdPrivateKeyFromPEM(secret); err == nil {
+			return &Key{Id: id, Algorithm: EdDSA, Private: private.(crypto.Signer)}, nil
+		}
+	}
+	switch algorithm {
+	case HS256:
+		return &Key{Id: id, Algorithm: HS256, Secret: secret}, nil
+	case RS256:
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(secret)
+		if err != nil {
+			return nil, fmt.Errorf("key %s is not a PEM encoded RSA private key", id)
+		}
+		return &Key{Id: id, Algorithm: RS256, Private: private}, nil
+	case EdDSA:
+		private, err := jwt.ParseEdPrivateKeyFromPEM(secret)
+		if err != nil {
+			return nil, fmt.Errorf("key %s is not a PEM encoded Ed25519 private key", id)
+		}
+		return &Key{Id: id, Algorithm: EdDSA, Private: private.(crypto.Signer)}, nil
+	default:
+		return nil, errors.New("unsupported signing algorithm: " + algorithm)
+	}
+}
+
+func (key *Key) method() jwt.SigningMethod {
+	switch key.Algorithm {
+	case RS256:
+		return jwt.SigningMethodRS256
+	case EdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+func (key *Key) signingKey() interface{} {
+	if key.Private != nil {
+		return key.Private
+	}
+	return key.Secret
+}
+
+func (key *Key) verificationKey() interface{} {
+	if key.Private != nil {
+		return key.Private.Public()
+	}
+	return key.Secret
+}
+
+// PublicJWK returns the public half of an asymmetric key as a JWK, HS256 keys are never published.
+// *resp.JWK can be nil if the key is an HS256 key
+func (key *Key) PublicJWK() *resp.JWK {
+	switch public := key.verificationKey().(type) {
+	case *rsa.PublicKey:
+		return &resp.JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: RS256,
+			Kid: key.Id,
+			N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return &resp.JWK{
+			Kty: "OKP",
+			Use: "sig",
+			Alg: EdDSA,
+			Kid: key.Id,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(public),
+		}
+	default:
+		return nil
+	}
+}
+
+// PublicKeyFromJWK parses the public key of a JWK published by another service, the reverse of PublicJWK.
+// crypto.PublicKey is an *rsa.PublicKey or an ed25519.PublicKey, can be nil.
+// string is the algorithm of the tokens the key verifies, or empty string.
+func PublicKeyFromJWK(jwk resp.JWK) (crypto.PublicKey, string, error) {
+	switch {
+	case jwk.Kty == "RSA" && (jwk.Alg == "" || jwk.Alg == RS256):
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil || len(n) == 0 {
+			return nil, "", fmt.Errorf("key %s has an invalid modulus", jwk.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, "", fmt.Errorf("key %s has an invalid exponent", jwk.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, RS256, nil
+	case jwk.Kty == "OKP" && jwk.Crv == "Ed25519" && (jwk.Alg == "" || jwk.Alg == EdDSA):
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, "", fmt.Errorf("key %s has an invalid public key", jwk.Kid)
+		}
+		return ed25519.PublicKey(x), EdDSA, nil
+	default:
+		return nil, "", fmt.Errorf("key %s is not an RS256 or EdDSA key", jwk.Kid)
+	}
+}
+
+// KeyProvider holds the keys used to sign and verify tokens, and the policy for the claims of those tokens
+type KeyProvider interface {
+	// SigningKey returns the active key, every new token is signed with this key.
+	SigningKey() *Key
+	// VerificationKey returns the key with the id passed, a retired key is only returned during the grace period.
+	// *Key is the key found, can be nil if there is no key with the id or the key's grace period is over.
+	VerificationKey(kid string) *Key
+	// VerificationKeys returns every key that can currently verify a token, sorted by id.
+	VerificationKeys() []*Key
+	// Rotate retires the active key and starts signing tokens with the key passed,
+	// the key must have an id that isn't used by the active key or a retired key.
+	Rotate(key *Key) error
+	// Policy returns the claims every token is issued with and checked against.
+	Policy() ClaimsPolicy
+}
+
+type keyProvider struct {
+	mu          sync.RWMutex
+	timer       Timer
+	gracePeriod time.Duration
+	policy      ClaimsPolicy
+	active      *Key
+	keys        map[string]*Key
+}
+
+// NewKeyProvider creates a key provider that signs with the active key, retired keys can
+// still verify tokens until the grace period has passed since the time they were retired
+func NewKeyProvider(timer Timer, gracePeriod time.Duration, policy ClaimsPolicy, active *Key, retired ...*Key) *keyProvider {
+	keys := make(map[string]*Key, len(retired)+1)
+	for _, key := range retired {
+		keys[key.Id] = key
+	}
+	keys[active.Id] = active
+	return &keyProvider{
+		timer:       timer,
+		gracePeriod: gracePeriod,
+		policy:      policy,
+		active:      active,
+		keys:        keys,
+	}
+}
+
+func TestKeyProvider() *keyProvider {
+	return NewKeyProvider(NewTimer(), 0, DefaultClaimsPolicy(), &Key{Id: DefaultKeyId, Secret: []byte("test signing key")})
+}
+
+func (kp *keyProvider) SigningKey() *Key {
+	kp.mu.RLock()
+	defer kp.mu.RUnlock()
+	return kp.active
+}
+
+func (kp *keyProvider) VerificationKey(kid string) *Key {
+	if kid == "" {
+		kid = DefaultKeyId
+	}
+	kp.mu.RLock()
+	key, ok := kp.keys[kid]
+	kp.mu.RUnlock()
+	if !ok || kp.pastGracePeriod(key) {
+		return nil
+	}
+	return key
+}
+
+func (kp *keyProvider) VerificationKeys() []*Key {
+	kp.mu.RLock()
+	keys := make([]*Key, 0, len(kp.keys))
+	for _, key := range kp.keys {
+		if !kp.pastGracePeriod(key) {
+			keys = append(keys, key)
+		}
+	}
+	kp.mu.RUnlock()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Id < keys[j].Id })
+	return keys
+}
+
+func (kp *keyProvider) Rotate(key *Key) error {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	if _, ok := kp.keys[key.Id]; ok { // reusing an id would replace a key tokens are still signed with
+		return fmt.Errorf("key %s is already in use, a new key needs a new id", key.Id)
+	}
+	retired := *kp.active // keys are shared with readers, so a copy is retired
+	retired.RetiredAt = kp.timer.Now().Unix()
+	kp.keys[retired.Id] = &retired
+	kp.active = key
+	kp.keys[key.Id] = key
+	return nil
+}
+
+func (kp *keyProvider) Policy() ClaimsPolicy {
+	return kp.policy
+}
+
+func (kp *keyProvider) pastGracePeriod(key *Key) bool {
+	return key.RetiredAt != 0 && kp.timer.Now().After(time.Unix(key.RetiredAt, 0).Add(kp.gracePeriod))
+}
@@ -0,0 +1,217 @@
+package util
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_VerificationKey(t *testing.T) {
+	active := &Key{Id: "b", Secret: []byte("active")}
+	retired := &Key{Id: "a", Secret: []byte("retired"), RetiredAt: 1000}
+	legacy := &Key{Id: DefaultKeyId, Secret: []byte("legacy"), RetiredAt: 1000}
+	tests := map[string]struct {
+		timer       Timer
+		kid         string
+		expectedKey *Key
+	}{
+		"active key": {
+			timer:       TestTimerFromUnix(5000),
+			kid:         "b",
+			expectedKey: active,
+		},
+		"retired key during grace period": {
+			timer:       TestTimerFromUnix(1000 + 60),
+			kid:         "a",
+			expectedKey: retired,
+		},
+		"retired key after grace period": {
+			timer: TestTimerFromUnix(1000 + 61),
+			kid:   "a",
+		},
+		"no kid uses the default key": {
+			timer:       TestTimerFromUnix(1000),
+			expectedKey: legacy,
+		},
+		"unknown kid": {
+			timer: TestTimerFromUnix(1000),
+			kid:   "c",
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			keys := NewKeyProvider(tcase.timer, time.Minute, DefaultClaimsPolicy(), active, retired, legacy)
+			assert.Equal(t, active, keys.SigningKey())
+			assert.Equal(t, tcase.expectedKey, keys.VerificationKey(tcase.kid))
+		})
+	}
+}
+
+func Test_KeyRotation(t *testing.T) {
+	jwt.TimeFunc = TestTimerFromUnix(1000).Now
+	defer func() { jwt.TimeFunc = time.Now }()
+	timer := TestTimerFromUnix(1000)
+	keys := NewKeyProvider(timer, time.Minute, DefaultClaimsPolicy(), &Key{Id: "a", Secret: []byte("first")})
+
+	// tokens are signed with the active key, and carry its id
+	before := GenerateSignedToken(keys, TokenTypeAccess, 1000, 2000, 7, "", "")
+	token, _ := jwt.Parse(before, nil)
+	assert.Equal(t, "a", token.Header["kid"])
+
+	// after a rotation new tokens are signed with the new key, old tokens are still valid
+	assert.NoError(t, keys.Rotate(&Key{Id: "b", Secret: []byte("second")}))
+	after := GenerateSignedToken(keys, TokenTypeAccess, 1000, 2000, 7, "", "")
+	token, _ = jwt.Parse(after, nil)
+	assert.Equal(t, "b", token.Header["kid"])
+	for _, tokenStr := range []string{before, after} {
+		claims, err := ParseWithClaims(keys, TokenTypeAccess, tokenStr)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), claims.UserId)
+	}
+
+	// a rotation that reuses the id of a key is rejected, tokens signed with the key stay valid
+	assert.NotNil(t, keys.Rotate(&Key{Id: "b", Secret: []byte("third")}))
+	assert.NotNil(t, keys.Rotate(&Key{Id: "a", Secret: []byte("third")}))
+	for _, tokenStr := range []string{before, after} {
+		_, err := ParseWithClaims(keys, TokenTypeAccess, tokenStr)
+		assert.NoError(t, err)
+	}
+
+	// once the grace period is over, tokens signed with the retired key are rejected
+	timer.SetNow(timer.Now().Add(time.Minute + time.Second))
+	_, err := ParseWithClaims(keys, TokenTypeAccess, before)
+	assert.NotNil(t, err)
+	claims, err := ParseWithClaims(keys, TokenTypeAccess, after)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), claims.UserId)
+
+	// a token signed with a different secret under the same id is rejected
+	other := NewKeyProvider(timer, time.Minute, DefaultClaimsPolicy(), &Key{Id: "b", Secret: []byte("not the same secret")})
+	_, err = ParseWithClaims(keys, TokenTypeAccess, GenerateSignedToken(other, TokenTypeAccess, 1000, 2000, 7, "", ""))
+	assert.NotNil(t, err)
+}
+
+func Test_AsymmetricSigning(t *testing.T) {
+	rsaPrivate, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaPrivate)})
+	_, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	edDER, _ := x509.MarshalPKCS8PrivateKey(edPrivate)
+	edPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER})
+	tests := map[string]struct {
+		algorithm   string
+		secret      []byte
+		expectedAlg string
+		expectedKty string
+		expectedErr bool
+	}{
+		"RS256": {
+			algorithm:   RS256,
+			secret:      rsaPEM,
+			expectedAlg: RS256,
+			expectedKty: "RSA",
+		},
+		"EdDSA": {
+			algorithm:   EdDSA,
+			secret:      edPEM,
+			expectedAlg: EdDSA,
+			expectedKty: "OKP",
+		},
+		"RS256 determined by secret": {
+			secret:      rsaPEM,
+			expectedAlg: RS256,
+			expectedKty: "RSA",
+		},
+		"EdDSA determined by secret": {
+			secret:      edPEM,
+			expectedAlg: EdDSA,
+			expectedKty: "OKP",
+		},
+		"HS256 determined by secret": {
+			secret:      []byte("shared secret"),
+			expectedAlg: HS256,
+		},
+		"invalid, RS256 with an Ed25519 key": {
+			algorithm:   RS256,
+			secret:      edPEM,
+			expectedErr: true,
+		},
+		"invalid, EdDSA with a shared secret": {
+			algorithm:   EdDSA,
+			secret:      []byte("shared secret"),
+			expectedErr: true,
+		},
+		"invalid, unsupported algorithm": {
+			algorithm:   "none",
+			secret:      []byte("shared secret"),
+			expectedErr: true,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			jwt.TimeFunc = TestTimerFromUnix(1000).Now
+			defer func() { jwt.TimeFunc = time.Now }()
+			key, err := NewKey("a", tcase.algorithm, tcase.secret)
+			if tcase.expectedErr {
+				assert.NotNil(t, err)
+				assert.Nil(t, key)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.expectedAlg, key.Algorithm)
+			keys := NewKeyProvider(TestTimerFromUnix(1000), 0, DefaultClaimsPolicy(), key)
+
+			tokenStr := GenerateSignedToken(keys, TokenTypeAccess, 1000, 2000, 7, "", "")
+			token, _ := jwt.Parse(tokenStr, nil)
+			assert.Equal(t, tcase.expectedAlg, token.Header["alg"])
+			claims, err := ParseWithClaims(keys, TokenTypeAccess, tokenStr)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(7), claims.UserId)
+
+			jwk := key.PublicJWK()
+			if tcase.expectedKty == "" {
+				assert.Nil(t, jwk)
+				return
+			}
+			assert.Equal(t, tcase.expectedKty, jwk.Kty)
+			assert.Equal(t, tcase.expectedAlg, jwk.Alg)
+			assert.Equal(t, "a", jwk.Kid)
+			parsed, alg, err := PublicKeyFromJWK(*jwk)
+			assert.NoError(t, err)
+			assert.Equal(t, key.Private.Public(), parsed)
+			assert.Equal(t, tcase.expectedAlg, alg)
+
+			// a token signed with HS256 using the public key as the secret is rejected
+			public, _ := x509.MarshalPKIXPublicKey(key.Private.Public())
+			forged := NewKeyProvider(TestTimerFromUnix(1000), 0, DefaultClaimsPolicy(), &Key{Id: "a", Algorithm: HS256, Secret: public})
+			_, err = ParseWithClaims(keys, TokenTypeAccess, GenerateSignedToken(forged, TokenTypeAccess, 1000, 2000, 7, "", ""))
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func Test_PublicKeyFromJWK(t *testing.T) {
+	tests := map[string]resp.JWK{
+		"invalid, HS256 key":         {Kty: "oct", Alg: HS256, Kid: "a"},
+		"invalid, RSA key for ES256": {Kty: "RSA", Alg: "ES256", Kid: "a", N: "AQAB", E: "AQAB"},
+		"invalid, RSA modulus":       {Kty: "RSA", Kid: "a", N: "not base64!", E: "AQAB"},
+		"invalid, RSA exponent":      {Kty: "RSA", Kid: "a", N: "AQAB", E: ""},
+		"invalid, Ed25519 key size":  {Kty: "OKP", Crv: "Ed25519", Kid: "a", X: "AQAB"},
+		"invalid, unsupported curve": {Kty: "OKP", Crv: "X25519", Kid: "a", X: "AQAB"},
+	}
+	for tname, jwk := range tests {
+		t.Run(tname, func(t *testing.T) {
+			public, alg, err := PublicKeyFromJWK(jwk)
+			assert.NotNil(t, err)
+			assert.Nil(t, public)
+			assert.Empty(t, alg)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package util
+
+import (
+	"errors"
+	"time"
+)
+
+// TokenLifetimes are how long tokens and sessions can be used for
+type TokenLifetimes struct {
+	// AccessToken is how long an access token can be used
+	AccessToken time.Duration
+	// RefreshToken is how long a refresh token can be used, each token from /token gets a full lifetime
+	RefreshToken time.Duration
+	// RememberMe is how long a refresh token can be used when the user asked to be remembered at login
+	RememberMe time.Duration
+	// RenewalWindow is how long before it expires that a refresh token is near the end of its life
+	RenewalWindow time.Duration
+	// MaxSession is the longest a session can last after its login, no matter how often its refresh token is renewed
+	MaxSession time.Duration
+	// LoginLink is how long an emailed login link can be used
+	LoginLink time.Duration
+}
+
+// DefaultTokenLifetimes are the lifetimes used when none are configured
+func DefaultTokenLifetimes() TokenLifetimes {
+	return TokenLifetimes{
+		AccessToken:   30 * time.Second,
+		RefreshToken:  10 * time.Hour,
+		RememberMe:    30 * 24 * time.Hour,
+		RenewalWindow: 2 * time.Hour,
+		MaxSession:    90 * 24 * time.Hour,
+		LoginLink:     15 * time.Minute,
+	}
+}
+
+// Validate checks that every lifetime is positive, and that each fits inside the lifetime that contains it
+func (l TokenLifetimes) Validate() error {
+	switch {
+	case l.AccessToken <= 0 || l.RefreshToken <= 0 || l.RememberMe <= 0 || l.RenewalWindow <= 0 || l.MaxSession <= 0 || l.LoginLink <= 0:
+		return errors.New("every token lifetime must be positive")
+	case l.AccessToken > l.RefreshToken:
+		return errors.New("access token lifetime must not be longer than the refresh token lifetime")
+	case l.RenewalWindow >= l.RefreshToken:
+		return errors.New("renewal window must be shorter than the refresh token lifetime")
+	case l.RememberMe < l.RefreshToken:
+		return errors.New("remember me lifetime must not be shorter than the refresh token lifetime")
+	case l.MaxSession < l.RememberMe:
+		return errors.New("max session lifetime must not be shorter than the remember me lifetime")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TokenLifetimesValidate(t *testing.T) {
+	tests := map[string]struct {
+		change   func(l *TokenLifetimes)
+		wantsErr bool
+	}{
+		"valid, defaults": {
+			change: func(l *TokenLifetimes) {},
+		},
+		"valid, remember me is the same as a refresh token": {
+			change: func(l *TokenLifetimes) { l.RememberMe = l.RefreshToken },
+		},
+		"invalid, zero": {
+			change:   func(l *TokenLifetimes) { l.AccessToken = 0 },
+			wantsErr: true,
+		},
+		"invalid, login link is zero": {
+			change:   func(l *TokenLifetimes) { l.LoginLink = 0 },
+			wantsErr: true,
+		},
+		"invalid, negative": {
+			change:   func(l *TokenLifetimes) { l.MaxSession = -time.Hour },
+			wantsErr: true,
+		},
+		"invalid, access token outlives refresh token": {
+			change:   func(l *TokenLifetimes) { l.AccessToken = l.RefreshToken + time.Second },
+			wantsErr: true,
+		},
+		"invalid, renewal window is the whole refresh token": {
+			change:   func(l *TokenLifetimes) { l.RenewalWindow = l.RefreshToken },
+			wantsErr: true,
+		},
+		"invalid, remember me is shorter than a refresh token": {
+			change:   func(l *TokenLifetimes) { l.RememberMe = l.RefreshToken - time.Second },
+			wantsErr: true,
+		},
+		"invalid, max session is shorter than remember me": {
+			change:   func(l *TokenLifetimes) { l.MaxSession = l.RememberMe - time.Second },
+			wantsErr: true,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			lifetimes := DefaultTokenLifetimes()
+			tcase.change(&lifetimes)
+			err := lifetimes.Validate()
+			if tcase.wantsErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Mailer sends emails to users, the implementation used depends on the environment
+type Mailer interface {
+	// Send delivers an email with a plain text body
+	Send(to, subject, body string) error
+}
+
+// Mail is a single email that was sent
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type writerMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterMailer creates a mailer that writes each email to w instead of delivering it,
+// used for local development with a log file or stdout
+func NewWriterMailer(w io.Writer) *writerMailer {
+	return &writerMailer{w: w}
+}
+
+func (m *writerMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := fmt.Fprintf(m.w, "To: %s\nSubject: %s\n\n%s\n\n", to, subject, body)
+	return err
+}
+
+type testMailer struct {
+	mu   sync.Mutex
+	sent []Mail
+	err  error
+}
+
+func TestMailer() *testMailer {
+	return &testMailer{}
+}
+
+func (m *testMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, Mail{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+// Sent returns every email sent so far, in order
+func (m *testMailer) Sent() []Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Mail(nil), m.sent...)
+}
+
+// SetErr makes every following Send fail with err
+func (m *testMailer) SetErr(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+}
@@ -0,0 +1,16 @@
+package util
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WriterMailer(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := NewWriterMailer(&buf)
+	assert.NoError(t, mailer.Send("ben@example.com", "first", "hello"))
+	assert.NoError(t, mailer.Send("ben@example.com", "second", "world"))
+	assert.Equal(t, "To: ben@example.com\nSubject: first\n\nhello\n\nTo: ben@example.com\nSubject: second\n\nworld\n\n", buf.String())
+}
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"errors"
+	"net/url"
+)
+
+// OidcProvider is the OpenID Connect provider users can login with instead of a wordbubble password,
+// federated login is turned off when no issuer is set
+type OidcProvider struct {
+	// Issuer is the url of the provider, its discovery document is served from /.well-known/openid-configuration below it
+	Issuer string
+	// ClientId and ClientSecret are the credentials wordbubble was registered with at the provider
+	ClientId     string
+	ClientSecret string
+	// RedirectUri is where the provider sends users back to, it must lead to /login/oidc/callback
+	RedirectUri string
+}
+
+// Enabled is true when an issuer is set
+func (provider OidcProvider) Enabled() bool {
+	return provider.Issuer != ""
+}
+
+// Validate makes sure the client is set, and that the issuer and redirect uri are urls, when the provider is enabled
+func (provider OidcProvider) Validate() error {
+	if !provider.Enabled() {
+		return nil
+	}
+	if u, err := url.Parse(provider.Issuer); err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("oidc issuer must be a url")
+	}
+	if provider.ClientId == "" {
+		return errors.New("oidc client id must be set")
+	}
+	if u, err := url.Parse(provider.RedirectUri); err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("oidc redirect uri must be a url")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OidcProviderValidate(t *testing.T) {
+	valid := OidcProvider{
+		Issuer:      "https://accounts.example.com",
+		ClientId:    "wordbubble",
+		RedirectUri: "https://wordbubble.com/login/callback",
+	}
+	tests := map[string]struct {
+		change   func(p *OidcProvider)
+		enabled  bool
+		wantsErr bool
+	}{
+		"valid": {
+			change:  func(p *OidcProvider) {},
+			enabled: true,
+		},
+		"valid, disabled": {
+			change: func(p *OidcProvider) { *p = OidcProvider{} },
+		},
+		"invalid, issuer is not a url": {
+			change:   func(p *OidcProvider) { p.Issuer = "accounts" },
+			enabled:  true,
+			wantsErr: true,
+		},
+		"invalid, no client id": {
+			change:   func(p *OidcProvider) { p.ClientId = "" },
+			enabled:  true,
+			wantsErr: true,
+		},
+		"invalid, no redirect uri": {
+			change:   func(p *OidcProvider) { p.RedirectUri = "" },
+			enabled:  true,
+			wantsErr: true,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			provider := valid
+			tcase.change(&provider)
+			assert.Equal(t, tcase.enabled, provider.Enabled())
+			if tcase.wantsErr {
+				assert.NotNil(t, provider.Validate())
+			} else {
+				assert.NoError(t, provider.Validate())
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// password hashing algorithms supported
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// PasswordHasher hashes passwords with the configured algorithm, and verifies them against hashes made by any
+// supported algorithm. Hashes are stored in the PHC string format, which carries the algorithm and its parameters
+type PasswordHasher interface {
+	// Hash hashes a password with the configured algorithm and parameters
+	Hash(password string) (string, error)
+	// Verify checks that a password matches a hash.
+	// bool is true when the hash was made with another algorithm or weaker parameters than configured,
+	// so it should be replaced with a new hash while the password is known.
+	// error could be resp.ErrInvalidCredentials, an error for a hash that can't be read, or nil.
+	Verify(hash, password string) (bool, error)
+}
+
+// PasswordParams are the algorithm, and its parameters, that new passwords are hashed with
+type PasswordParams struct {
+	Algorithm   string
+	BcryptCost  int
+	Memory      uint32 // argon2id memory in KiB
+	Iterations  uint32 // argon2id passes over the memory
+	Parallelism uint8  // argon2id threads
+}
+
+// DefaultPasswordParams are the second recommended argon2id parameters from RFC 9106
+func DefaultPasswordParams() PasswordParams {
+	return PasswordParams{
+		Algorithm:   Argon2id,
+		BcryptCost:  bcrypt.DefaultCost,
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 4,
+	}
+}
+
+type passwordHasher struct {
+	params PasswordParams
+}
+
+// NewPasswordHasher creates a hasher that hashes new passwords using the params passed
+func NewPasswordHasher(params PasswordParams) (*passwordHasher, error) {
+	switch params.Algorithm {
+	case Argon2id:
+		if params.Iterations < 1 || params.Parallelism < 1 || params.Memory < 8*uint32(params.Parallelism) {
+			return nil, errors.New("argon2id needs at least 1 iteration, 1 thread and 8 KiB of memory per thread")
+		}
+	case Bcrypt:
+		if params.BcryptCost < bcrypt.MinCost || params.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("password hashing algorithm is not supported: %s", params.Algorithm)
+	}
+	return &passwordHasher{params: params}, nil
+}
+
+// TestPasswordHasher hashes with argon2id using the cheapest parameters, use for testing only
+func TestPasswordHasher() *passwordHasher {
+	return &passwordHasher{params: PasswordParams{Algorithm: Argon2id, BcryptCost: bcrypt.MinCost, Memory: 8, Iterations: 1, Parallelism: 1}}
+}
+
+func (h *passwordHasher) Hash(password string) (string, error) {
+	if h.params.Algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.params.BcryptCost)
+		return string(hash), err
+	}
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, argon2KeyLength)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", Argon2id, argon2.Version,
+		h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *passwordHasher) Verify(hash, password string) (bool, error) {
+	if strings.HasPrefix(hash, "$"+Argon2id+"$") {
+		return h.verifyArgon2id(hash, password)
+	}
+	// bcrypt hashes use the modular crypt format, $2a$, $2b$ or $2y$, which PHC strings are based on
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false, err
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, resp.ErrInvalidCredentials
+		}
+		return false, err
+	}
+	return h.params.Algorithm != Bcrypt || cost < h.params.BcryptCost, nil
+}
+
+func (h *passwordHasher) verifyArgon2id(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, errors.New("argon2id hash is not in the PHC string format")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, fmt.Errorf("argon2id hash version is not supported: %s", parts[2])
+	}
+	var params PasswordParams
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return false, fmt.Errorf("argon2id hash parameters could not be read: %s", parts[3])
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, errors.New("argon2id hash salt is not valid base64")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, errors.New("argon2id hash is not valid base64")
+	}
+	found := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(found, key) != 1 {
+		return false, resp.ErrInvalidCredentials
+	}
+	return h.params.Algorithm != Argon2id ||
+		params.Memory < h.params.Memory ||
+		params.Iterations < h.params.Iterations ||
+		params.Parallelism < h.params.Parallelism, nil
+}
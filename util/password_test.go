@@ -0,0 +1,117 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PasswordHasher(t *testing.T) {
+	cheapArgon2id := TestPasswordHasher()
+	strongerArgon2id, err := NewPasswordHasher(PasswordParams{Algorithm: Argon2id, Memory: 16, Iterations: 2, Parallelism: 1})
+	assert.NoError(t, err)
+	cheapBcrypt, err := NewPasswordHasher(PasswordParams{Algorithm: Bcrypt, BcryptCost: 4})
+	assert.NoError(t, err)
+	strongerBcrypt, err := NewPasswordHasher(PasswordParams{Algorithm: Bcrypt, BcryptCost: 5})
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		hashedBy            *passwordHasher
+		verifiedBy          *passwordHasher
+		password            string
+		expectedNeedsRehash bool
+		expectedErr         error
+	}{
+		"argon2id, same params": {
+			hashedBy:   cheapArgon2id,
+			verifiedBy: cheapArgon2id,
+			password:   "Hello123!",
+		},
+		"argon2id, stronger params are configured": {
+			hashedBy:            cheapArgon2id,
+			verifiedBy:          strongerArgon2id,
+			password:            "Hello123!",
+			expectedNeedsRehash: true,
+		},
+		"argon2id, weaker params are configured": {
+			hashedBy:   strongerArgon2id,
+			verifiedBy: cheapArgon2id,
+			password:   "Hello123!",
+		},
+		"argon2id, bcrypt is configured": {
+			hashedBy:            cheapArgon2id,
+			verifiedBy:          cheapBcrypt,
+			password:            "Hello123!",
+			expectedNeedsRehash: true,
+		},
+		"argon2id, wrong password": {
+			hashedBy:    cheapArgon2id,
+			verifiedBy:  cheapArgon2id,
+			password:    "Hello1234!",
+			expectedErr: resp.ErrInvalidCredentials,
+		},
+		"bcrypt, same cost": {
+			hashedBy:   cheapBcrypt,
+			verifiedBy: cheapBcrypt,
+			password:   "Hello123!",
+		},
+		"bcrypt, higher cost is configured": {
+			hashedBy:            cheapBcrypt,
+			verifiedBy:          strongerBcrypt,
+			password:            "Hello123!",
+			expectedNeedsRehash: true,
+		},
+		"bcrypt, argon2id is configured": {
+			hashedBy:            cheapBcrypt,
+			verifiedBy:          cheapArgon2id,
+			password:            "Hello123!",
+			expectedNeedsRehash: true,
+		},
+		"bcrypt, wrong password": {
+			hashedBy:    cheapBcrypt,
+			verifiedBy:  cheapArgon2id,
+			password:    "Hello1234!",
+			expectedErr: resp.ErrInvalidCredentials,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			hash, err := tcase.hashedBy.Hash("Hello123!")
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(hash, map[string]string{Argon2id: "$argon2id$v=19$", Bcrypt: "$2a$"}[tcase.hashedBy.params.Algorithm]))
+
+			needsRehash, err := tcase.verifiedBy.Verify(hash, tcase.password)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedNeedsRehash, needsRehash)
+		})
+	}
+}
+
+func Test_PasswordHasherUnreadableHash(t *testing.T) {
+	hasher := TestPasswordHasher()
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$argon2id$v=19$m=8,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=8,t=1,p=1$c2FsdHNhbHRzYWx0$a2V5",
+		"$argon2id$v=19$m=8,t=1$c2FsdHNhbHRzYWx0$a2V5",
+		"$argon2id$v=19$m=8,t=1,p=1$not base64$a2V5",
+	} {
+		_, err := hasher.Verify(hash, "Hello123!")
+		assert.NotNil(t, err, hash)
+		assert.NotEqual(t, resp.ErrInvalidCredentials, err, hash)
+	}
+}
+
+func Test_NewPasswordHasher(t *testing.T) {
+	_, err := NewPasswordHasher(DefaultPasswordParams())
+	assert.NoError(t, err)
+	_, err = NewPasswordHasher(PasswordParams{Algorithm: "scrypt"})
+	assert.NotNil(t, err)
+	_, err = NewPasswordHasher(PasswordParams{Algorithm: Bcrypt, BcryptCost: 2})
+	assert.NotNil(t, err)
+	_, err = NewPasswordHasher(PasswordParams{Algorithm: Argon2id, Memory: 4, Iterations: 1, Parallelism: 1})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,35 @@
+package util
+
+import (
+	"context"
+
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+)
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of the context carrying the principal of an authenticated request
+func WithPrincipal(ctx context.Context, principal *model.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal put into the context by the auth middleware
+// *model.Principal is who sent the request, can be nil.
+// error could be (401) resp.ErrUnauthorized or nil.
+func PrincipalFromContext(ctx context.Context) (*model.Principal, error) {
+	principal, ok := ctx.Value(principalKey{}).(*model.Principal)
+	if !ok || principal == nil {
+		return nil, resp.ErrUnauthorized
+	}
+	return principal, nil
+}
+
+// UserIdFromContext returns the user id of the principal put into the context by the auth middleware, or zero
+func UserIdFromContext(ctx context.Context) int64 {
+	principal, err := PrincipalFromContext(ctx)
+	if err != nil {
+		return 0
+	}
+	return principal.UserId
+}
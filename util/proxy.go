@@ -0,0 +1,79 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// TrustedProxies are the proxies in front of the api. The X-Forwarded-For header is only read from requests
+// a trusted proxy sent, anyone else can put any address they like in it
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses a comma separated list of addresses and CIDR ranges, an empty string trusts no proxy
+func ParseTrustedProxies(s string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy is not an address or CIDR range: %s", entry)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy is not an address or CIDR range: %s", entry)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// Trusts returns true when the address is one of the trusted proxies
+func (tp TrustedProxies) Trusts(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range tp {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIp returns the address of the client that sent a request. When the request came from a trusted proxy,
+// X-Forwarded-For is read from the right, since each proxy appends the address it received the request from,
+// and the first address that isn't a trusted proxy is the client. Anything further left was sent by the client
+func (tp TrustedProxies) ClientIp(remoteAddr, forwardedFor string) string {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+	if !tp.Trusts(ip) {
+		return ip
+	}
+	entries := strings.Split(forwardedFor, ",")
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := strings.TrimSpace(entries[i])
+		if entry == "" {
+			break
+		}
+		ip = entry
+		if !tp.Trusts(entry) {
+			break
+		}
+	}
+	return ip
+}
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseTrustedProxies(t *testing.T) {
+	tests := map[string]struct {
+		proxies     string
+		expectedLen int
+		expectedErr bool
+	}{
+		"valid, none": {},
+		"valid, addresses and ranges": {
+			proxies:     "10.0.0.1, 172.16.0.0/12,::1",
+			expectedLen: 3,
+		},
+		"invalid, not an address": {
+			proxies:     "10.0.0.1,proxy.internal",
+			expectedErr: true,
+		},
+		"invalid, bad range": {
+			proxies:     "10.0.0.0/33",
+			expectedErr: true,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			proxies, err := ParseTrustedProxies(tcase.proxies)
+			if tcase.expectedErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, proxies, tcase.expectedLen)
+		})
+	}
+}
+
+func Test_ClientIp(t *testing.T) {
+	proxies, _ := ParseTrustedProxies("10.0.0.0/8")
+	tests := map[string]struct {
+		proxies      TrustedProxies
+		remoteAddr   string
+		forwardedFor string
+		expectedIp   string
+	}{
+		"direct connection": {
+			proxies:    proxies,
+			remoteAddr: "203.0.113.7:52814",
+			expectedIp: "203.0.113.7",
+		},
+		"forwarded header from a client is ignored": {
+			proxies:      proxies,
+			remoteAddr:   "203.0.113.7:52814",
+			forwardedFor: "198.51.100.1",
+			expectedIp:   "203.0.113.7",
+		},
+		"forwarded header is ignored when no proxy is trusted": {
+			remoteAddr:   "10.0.0.2:52814",
+			forwardedFor: "203.0.113.7",
+			expectedIp:   "10.0.0.2",
+		},
+		"behind a proxy": {
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.2:52814",
+			forwardedFor: "203.0.113.7",
+			expectedIp:   "203.0.113.7",
+		},
+		"behind a proxy, address sent by the client is skipped": {
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.2:52814",
+			forwardedFor: "198.51.100.1, 203.0.113.7, 10.0.0.1",
+			expectedIp:   "203.0.113.7",
+		},
+		"behind a proxy, no forwarded header": {
+			proxies:    proxies,
+			remoteAddr: "10.0.0.2:52814",
+			expectedIp: "10.0.0.2",
+		},
+		"no port": {
+			remoteAddr: "203.0.113.7",
+			expectedIp: "203.0.113.7",
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			assert.Equal(t, tcase.expectedIp, tcase.proxies.ClientIp(tcase.remoteAddr, tcase.forwardedFor))
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+)
+
+// RandomBytes returns n cryptographically random bytes
+func RandomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal("could not read random bytes: ", err)
+	}
+	return b
+}
+
+// RandomString returns a hex string made from n cryptographically random bytes
+func RandomString(n int) string {
+	return hex.EncodeToString(RandomBytes(n))
+}
+
+// HashToken returns the hex sha256 of a token, so the database never holds a token that can be used.
+// A fast hash is only safe for tokens with enough randomness that they can't be brute forced,
+// at least 80 random bits, never for passwords or anything a user picks
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+)
+
+// roles a user can be assigned, every user is a user until they're assigned another role
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// permissions a role can have, checked with Authorize before an operation that not every user can do
+const (
+	PermissionModerateWordbubbles = "wordbubbles:moderate"
+	PermissionManageRoles         = "roles:manage"
+)
+
+var permissions = map[string]map[string]bool{
+	RoleUser: {},
+	RoleModerator: {
+		PermissionModerateWordbubbles: true,
+	},
+	RoleAdmin: {
+		PermissionModerateWordbubbles: true,
+		PermissionManageRoles:         true,
+	},
+}
+
+// ValidRole validates the name of a role
+func ValidRole(role string) error {
+	if _, ok := permissions[role]; !ok {
+		return resp.ErrInvalidRole
+	}
+	return nil
+}
+
+// HasPermission returns true if the role has the permission required.
+// Tokens issued before roles existed have an empty role, and are treated as a user
+func HasPermission(role, required string) bool {
+	if role == "" {
+		role = RoleUser
+	}
+	return permissions[role][required]
+}
+
+// Authorize checks that the principal's role has the permission required, for handlers and services to call before
+// an operation that not every user can do. The role is the one the principal's access token was issued with
+// error could be (401) resp.ErrUnauthorized, (403) resp.ErrInsufficientRole or nil.
+func Authorize(principal *model.Principal, required string) error {
+	if principal == nil {
+		return resp.ErrUnauthorized
+	}
+	if !HasPermission(principal.Role, required) {
+		return resp.ErrInsufficientRole
+	}
+	return nil
+}
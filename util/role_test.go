@@ -0,0 +1,67 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model"
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ValidRole(t *testing.T) {
+	for _, role := range []string{RoleUser, RoleModerator, RoleAdmin} {
+		assert.NoError(t, ValidRole(role))
+	}
+	assert.Equal(t, resp.ErrInvalidRole, ValidRole(""))
+	assert.Equal(t, resp.ErrInvalidRole, ValidRole("superuser"))
+}
+
+func Test_Authorize(t *testing.T) {
+	tests := map[string]struct {
+		principal   *model.Principal
+		required    string
+		expectedErr error
+	}{
+		"valid, admin manages roles": {
+			principal: &model.Principal{UserId: 2, Role: RoleAdmin},
+			required:  PermissionManageRoles,
+		},
+		"valid, admin moderates": {
+			principal: &model.Principal{UserId: 2, Role: RoleAdmin},
+			required:  PermissionModerateWordbubbles,
+		},
+		"valid, moderator moderates": {
+			principal: &model.Principal{UserId: 2, Role: RoleModerator},
+			required:  PermissionModerateWordbubbles,
+		},
+		"invalid, moderator manages roles": {
+			principal:   &model.Principal{UserId: 2, Role: RoleModerator},
+			required:    PermissionManageRoles,
+			expectedErr: resp.ErrInsufficientRole,
+		},
+		"invalid, user moderates": {
+			principal:   &model.Principal{UserId: 2, Role: RoleUser},
+			required:    PermissionModerateWordbubbles,
+			expectedErr: resp.ErrInsufficientRole,
+		},
+		"invalid, legacy token is a user": {
+			principal:   &model.Principal{UserId: 2},
+			required:    PermissionModerateWordbubbles,
+			expectedErr: resp.ErrInsufficientRole,
+		},
+		"invalid, unknown role": {
+			principal:   &model.Principal{UserId: 2, Role: "superuser"},
+			required:    PermissionModerateWordbubbles,
+			expectedErr: resp.ErrInsufficientRole,
+		},
+		"invalid, no principal": {
+			required:    PermissionModerateWordbubbles,
+			expectedErr: resp.ErrUnauthorized,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			assert.Equal(t, tcase.expectedErr, Authorize(tcase.principal, tcase.required))
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+)
+
+// scopes that can be granted to tokens and api keys, a scope is a space delimited list of these
+const (
+	ScopeWordbubblePush = "wordbubble:push"
+	ScopeWordbubbleRead = "wordbubble:read"
+	ScopeAccountManage  = "account:manage"
+)
+
+var scopes = map[string]bool{
+	ScopeWordbubblePush: true,
+	ScopeWordbubbleRead: true,
+	ScopeAccountManage:  true,
+}
+
+// ValidScope validates a space delimited list of scopes, an empty scope is every scope.
+// string is the scope sorted with duplicates removed, or empty string
+func ValidScope(scope string) (string, error) {
+	if strings.TrimSpace(scope) == "" {
+		return AllScopes(), nil
+	}
+	requested := make(map[string]bool)
+	for _, s := range strings.Fields(scope) {
+		if !scopes[s] {
+			return "", resp.ErrInvalidScope
+		}
+		requested[s] = true
+	}
+	return joinScopes(requested), nil
+}
+
+// NarrowScope validates the scope requested, making sure it doesn't exceed the scope already granted.
+// An empty request keeps the scope granted
+// string is the scope sorted with duplicates removed, or empty string
+func NarrowScope(granted, requested string) (string, error) {
+	granted, _ = ValidScope(granted)
+	if strings.TrimSpace(requested) == "" {
+		return granted, nil
+	}
+	narrowed, err := ValidScope(requested)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range strings.Fields(narrowed) {
+		if !HasScope(granted, s) {
+			return "", resp.ErrInvalidScope
+		}
+	}
+	return narrowed, nil
+}
+
+// HasScope returns true if the scope required is in the scope granted.
+// Tokens issued before scopes existed have an empty scope, and are granted every scope
+func HasScope(granted, required string) bool {
+	if granted == "" {
+		return true
+	}
+	for _, s := range strings.Fields(granted) {
+		if s == required {
+			return true
+		}
+	}
+	return false
+}
+
+// AllScopes returns every scope, the scope granted to a user that signs up or logs in without requesting one
+func AllScopes() string {
+	return joinScopes(scopes)
+}
+
+func joinScopes(set map[string]bool) string {
+	list := make([]string, 0, len(set))
+	for s := range set {
+		list = append(list, s)
+	}
+	sort.Strings(list)
+	return strings.Join(list, " ")
+}
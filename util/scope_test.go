@@ -0,0 +1,86 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ValidScope(t *testing.T) {
+	tests := map[string]struct {
+		scope         string
+		expectedScope string
+		expectedErr   error
+	}{
+		"valid": {
+			scope:         "wordbubble:push",
+			expectedScope: "wordbubble:push",
+		},
+		"valid, sorted without duplicates": {
+			scope:         " wordbubble:read  account:manage wordbubble:read",
+			expectedScope: "account:manage wordbubble:read",
+		},
+		"valid, empty is every scope": {
+			expectedScope: "account:manage wordbubble:push wordbubble:read",
+		},
+		"invalid, unknown scope": {
+			scope:       "wordbubble:push admin",
+			expectedErr: resp.ErrInvalidScope,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			scope, err := ValidScope(tcase.scope)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedScope, scope)
+		})
+	}
+}
+
+func Test_NarrowScope(t *testing.T) {
+	tests := map[string]struct {
+		granted       string
+		requested     string
+		expectedScope string
+		expectedErr   error
+	}{
+		"valid": {
+			granted:       "wordbubble:push wordbubble:read",
+			requested:     "wordbubble:read",
+			expectedScope: "wordbubble:read",
+		},
+		"valid, empty request keeps the scope granted": {
+			granted:       "wordbubble:push wordbubble:read",
+			expectedScope: "wordbubble:push wordbubble:read",
+		},
+		"valid, legacy token is granted every scope": {
+			requested:     "account:manage",
+			expectedScope: "account:manage",
+		},
+		"invalid, request exceeds the scope granted": {
+			granted:     "wordbubble:push",
+			requested:   "wordbubble:push account:manage",
+			expectedErr: resp.ErrInvalidScope,
+		},
+		"invalid, unknown scope": {
+			granted:     "wordbubble:push",
+			requested:   "admin",
+			expectedErr: resp.ErrInvalidScope,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			scope, err := NarrowScope(tcase.granted, tcase.requested)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedScope, scope)
+		})
+	}
+}
+
+func Test_HasScope(t *testing.T) {
+	assert.True(t, HasScope("wordbubble:push wordbubble:read", ScopeWordbubbleRead))
+	assert.False(t, HasScope("wordbubble:push", ScopeAccountManage))
+	assert.False(t, HasScope("wordbubble:pushy", ScopeWordbubblePush))
+	assert.True(t, HasScope("", ScopeAccountManage))
+}
@@ -0,0 +1,217 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// commonPasswordsGz is a list of common and breached passwords that ships with the binary, so screening works offline.
+// The format is one lowercase password per line, gzip compressed. It only holds about a thousand of the most common passwords,
+// enough to catch the worst choices but not most breached passwords, deployments should load a larger list with LoadCommonPasswords
+//
+//go:embed common_passwords.txt.gz
+var commonPasswordsGz []byte
+
+// minDictionaryWordLength is the shortest common password that's looked for inside a longer password
+const minDictionaryWordLength = 4
+
+var (
+	commonPasswordsOnce sync.Once
+	commonPasswords     []string
+	maxCommonPassword   int
+)
+
+// loadCommonPasswords decompresses the embedded list the first time it's needed, unless a list was loaded from a file
+func loadCommonPasswords() {
+	commonPasswordsOnce.Do(func() {
+		r, err := gzip.NewReader(bytes.NewReader(commonPasswordsGz))
+		if err != nil {
+			panic("common password list is not gzip compressed: " + err.Error())
+		}
+		if commonPasswords, maxCommonPassword, err = readCommonPasswords(r); err != nil {
+			panic("common password list could not be read: " + err.Error())
+		}
+	})
+}
+
+// LoadCommonPasswords replaces the embedded list with a larger one, like the top 100k passwords of a breach corpus, from the file at path.
+// The file has one password per line, and is gzip compressed when the path ends in .gz. It has to be called before any password is checked
+func LoadCommonPasswords(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		r = gz
+	}
+	words, longest, err := readCommonPasswords(r)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return errors.New("common password list is empty")
+	}
+	commonPasswordsOnce.Do(func() {}) // the embedded list is never needed
+	commonPasswords, maxCommonPassword = words, longest
+	return nil
+}
+
+// readCommonPasswords reads a list of passwords, one per line, returning them lowercased and sorted along with the length of the longest
+func readCommonPasswords(r io.Reader) ([]string, int, error) {
+	var words []string
+	longest := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if word := strings.ToLower(strings.TrimSpace(scanner.Text())); word != "" {
+			words = append(words, word)
+			if n := len([]rune(word)); n > longest {
+				longest = n
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	sort.Strings(words)
+	return words, longest, nil
+}
+
+func isCommonPassword(lower string) bool {
+	loadCommonPasswords()
+	i := sort.SearchStrings(commonPasswords, lower)
+	return i < len(commonPasswords) && commonPasswords[i] == lower
+}
+
+// leetReplacer undoes the substitutions people usually make to a word, p@ssw0rd is still password
+var leetReplacer = strings.NewReplacer("@", "a", "4", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t")
+
+// IsCommonPassword reports whether a password, ignoring case and common substitutions,
+// is in the list of common and breached passwords. Digits and symbols added around a common password,
+// like Password1 or Summer2022!, don't make it any less common
+func IsCommonPassword(password string) bool {
+	lower := strings.ToLower(password)
+	if isCommonPassword(lower) || isCommonPassword(leetReplacer.Replace(lower)) {
+		return true
+	}
+	base := strings.TrimFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) })
+	return base != "" && isCommonPassword(leetReplacer.Replace(base))
+}
+
+// PasswordStrength is an estimate of how hard a password is to guess
+type PasswordStrength struct {
+	// Entropy is the estimated number of guesses needed, in bits
+	Entropy float64
+	// Score is 0 when the password is too guessable, 1 very guessable, 2 somewhat guessable,
+	// 3 safely unguessable and 4 very unguessable
+	Score int
+}
+
+// EstimatePasswordStrength estimates the entropy of a password. Each character is worth the size of the
+// character classes used, except characters that repeat or continue a sequence, like aaa or 1234, are worth 1 bit,
+// and a common password found anywhere inside is worth about as much as picking one from the list
+func EstimatePasswordStrength(password string) *PasswordStrength {
+	loadCommonPasswords()
+	runes := []rune(password)
+	lower := []rune(strings.ToLower(password))
+	leet := []rune(leetReplacer.Replace(string(lower)))
+	if len(leet) != len(lower) {
+		leet = lower // every replacement is a single ascii character, this only happens for unusual unicode
+	}
+	charBits := math.Log2(float64(characterPool(runes)))
+	wordBits := math.Log2(float64(len(commonPasswords)))
+
+	var entropy float64
+	for i := 0; i < len(runes); {
+		if n := longestCommonWord(lower, leet, i); n > 0 {
+			entropy += wordBits
+			i += n
+			continue
+		}
+		if i > 0 && (lower[i] == lower[i-1] || lower[i]-lower[i-1] == 1 || lower[i-1]-lower[i] == 1) {
+			entropy++
+		} else {
+			entropy += charBits
+		}
+		i++
+	}
+
+	strength := &PasswordStrength{Entropy: entropy}
+	switch {
+	case entropy < 28:
+		strength.Score = 0
+	case entropy < 36:
+		strength.Score = 1
+	case entropy < 60:
+		strength.Score = 2
+	case entropy < 128:
+		strength.Score = 3
+	default:
+		strength.Score = 4
+	}
+	return strength
+}
+
+// longestCommonWord is the length of the longest common password that starts at i, as is or with substitutions undone
+func longestCommonWord(lower, leet []rune, i int) int {
+	for n := min(maxCommonPassword, len(lower)-i); n >= minDictionaryWordLength; n-- {
+		if isCommonPassword(string(lower[i:i+n])) || isCommonPassword(string(leet[i:i+n])) {
+			return n
+		}
+	}
+	return 0
+}
+
+// characterPool is how many characters could have been picked from, based on the classes of character used
+func characterPool(runes []rune) int {
+	var hasLower, hasUpper, hasNumber, hasSymbol, hasOther bool
+	for _, c := range runes {
+		switch {
+		case c > unicode.MaxASCII:
+			hasOther = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsNumber(c):
+			hasNumber = true
+		default:
+			hasSymbol = true
+		}
+	}
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{hasLower, 26}, {hasUpper, 26}, {hasNumber, 10}, {hasSymbol, 33}, {hasOther, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 1
+	}
+	return pool
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
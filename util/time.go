@@ -1,6 +1,7 @@
 package util
 
 import (
+	"sync"
 	t "time"
 )
 
@@ -8,7 +9,8 @@ type timer struct{}
 
 type Timer interface {
 	Now() t.Time
-	Tick(d t.Duration) <-chan t.Time
+	// Tick returns a channel that receives the time every d, stop must be called once the channel is no longer read
+	Tick(d t.Duration) (tick <-chan t.Time, stop func())
 }
 
 func NewTimer() *timer {
@@ -19,11 +21,13 @@ func (ti *timer) Now() t.Time {
 	return t.Now()
 }
 
-func (ti *timer) Tick(d t.Duration) <-chan t.Time {
-	return t.NewTicker(d).C
+func (ti *timer) Tick(d t.Duration) (<-chan t.Time, func()) {
+	ticker := t.NewTicker(d)
+	return ticker.C, ticker.Stop
 }
 
 type testTimer struct {
+	mu   sync.RWMutex
 	now  t.Time
 	tick <-chan t.Time
 }
@@ -35,9 +39,27 @@ func TestTimerFromUnix(ti int64) *testTimer {
 }
 
 func (tti *testTimer) Now() t.Time {
+	tti.mu.RLock()
+	defer tti.mu.RUnlock()
 	return tti.now
 }
 
-func (tti *testTimer) Tick(t.Duration) <-chan t.Time {
-	return tti.tick
+func (tti *testTimer) Tick(t.Duration) (<-chan t.Time, func()) {
+	tti.mu.RLock()
+	defer tti.mu.RUnlock()
+	return tti.tick, func() {}
+}
+
+// SetNow changes the time returned by Now
+func (tti *testTimer) SetNow(now t.Time) {
+	tti.mu.Lock()
+	defer tti.mu.Unlock()
+	tti.now = now
+}
+
+// SetTick sets the channel returned by Tick, so a test decides when each tick happens
+func (tti *testTimer) SetTick(tick <-chan t.Time) {
+	tti.mu.Lock()
+	defer tti.mu.Unlock()
+	tti.tick = tick
 }
@@ -0,0 +1,83 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpDigits = 6
+	totpPeriod = 30 // seconds
+	totpSkew   = 1  // time steps either side of now a code is accepted in, to allow for clock drift
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTotpSecret returns a base32 encoded secret made from 160 cryptographically random bits, as recommended by RFC 4226
+func NewTotpSecret() string {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal("could not read random bytes: ", err)
+	}
+	return totpEncoding.EncodeToString(b)
+}
+
+// TotpStep returns the RFC 6238 time step that the time passed falls in
+func TotpStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+// TotpCode returns the code of a base32 encoded secret for the time step passed, as defined by RFC 6238
+func TotpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// MatchTotpCode finds the time step within the skew of now that the code was generated for
+// int64 is the time step the code matched, or zero if the code doesn't match any step.
+func MatchTotpCode(secret, code string, now time.Time) int64 {
+	if len(code) != totpDigits {
+		return 0
+	}
+	current := TotpStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := TotpCode(secret, step)
+		if err != nil {
+			return 0
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step
+		}
+	}
+	return 0
+}
+
+// TotpProvisioningUri returns the otpauth uri of a secret, authenticator apps enroll the secret by scanning it as a QR code
+func TotpProvisioningUri(issuer, account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + params.Encode()
+}
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfc6238Secret is the SHA1 seed used by the test vectors in RFC 6238 appendix B, "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func Test_TotpCode(t *testing.T) {
+	// the RFC's vectors are 8 digits, the last 6 digits are the 6 digit code
+	tests := map[string]struct {
+		unix         int64
+		expectedCode string
+	}{
+		"59":         {unix: 59, expectedCode: "287082"},
+		"1111111109": {unix: 1111111109, expectedCode: "081804"},
+		"1111111111": {unix: 1111111111, expectedCode: "050471"},
+		"1234567890": {unix: 1234567890, expectedCode: "005924"},
+		"2000000000": {unix: 2000000000, expectedCode: "279037"},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			code, err := TotpCode(rfc6238Secret, TotpStep(time.Unix(tcase.unix, 0)))
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.expectedCode, code)
+		})
+	}
+
+	_, err := TotpCode("not base32!", 1)
+	assert.NotNil(t, err)
+}
+
+func Test_MatchTotpCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TotpStep(now)
+	previous, _ := TotpCode(rfc6238Secret, step-1)
+	next, _ := TotpCode(rfc6238Secret, step+1)
+	stale, _ := TotpCode(rfc6238Secret, step-2)
+
+	assert.Equal(t, step, MatchTotpCode(rfc6238Secret, "050471", now))
+	assert.Equal(t, step-1, MatchTotpCode(rfc6238Secret, previous, now))
+	assert.Equal(t, step+1, MatchTotpCode(rfc6238Secret, next, now))
+	assert.Zero(t, MatchTotpCode(rfc6238Secret, stale, now))
+	assert.Zero(t, MatchTotpCode(rfc6238Secret, "000000", now))
+	assert.Zero(t, MatchTotpCode(rfc6238Secret, "50471", now))
+	assert.Zero(t, MatchTotpCode("not base32!", "050471", now))
+}
+
+func Test_NewTotpSecret(t *testing.T) {
+	secret := NewTotpSecret()
+	assert.Len(t, secret, 32)
+	assert.NotEqual(t, secret, NewTotpSecret())
+	_, err := TotpCode(secret, 1)
+	assert.NoError(t, err)
+}
+
+func Test_TotpProvisioningUri(t *testing.T) {
+	assert.Equal(t,
+		"otpauth://totp/wordbubble:ben?algorithm=SHA1&digits=6&issuer=wordbubble&period=30&secret="+rfc6238Secret,
+		TotpProvisioningUri("wordbubble", "ben", rfc6238Secret),
+	)
+}
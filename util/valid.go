@@ -3,6 +3,7 @@ package util
 import (
 	"fmt"
 	"net/mail"
+	"net/url"
 	"unicode"
 
 	"github.com/bchadwic/wordbubble/model"
@@ -11,11 +12,16 @@ import (
 )
 
 const (
-	minPasswordLength   = 6
-	maxUsernameLength   = 40
-	maxEmailLength      = 100
-	MinWordbubbleLength = 1
-	MaxWordbubbleLength = 255
+	minPasswordLength    = 8
+	minPasswordScore     = 2 // somewhat guessable
+	maxUsernameLength    = 40
+	maxEmailLength       = 100
+	MinWordbubbleLength  = 1
+	MaxWordbubbleLength  = 255
+	maxApiKeyNameLength  = 50
+	maxClientNameLength  = 50
+	maxPasskeyNameLength = 50
+	maxRedirectUriLength = 255
 )
 
 // validate all the fields of a user
@@ -59,10 +65,11 @@ func ValidUsername(username string) error {
 	return nil
 }
 
-// ValidPassword validate password based on the 6 characters, 1 upper, 1 lower, 1 number, 1 special character
+// ValidPassword validate password based on the 8 characters, 1 upper, 1 lower, 1 number,
+// then that it's not a common or breached password, and that it's not too easy to guess
 func ValidPassword(password string) error {
 	var hasMinLen, hasUpper, hasLower, hasNumber bool
-	if len(password) > minPasswordLength {
+	if len([]rune(password)) >= minPasswordLength {
 		hasMinLen = true
 	}
 	for _, c := range password {
@@ -75,7 +82,13 @@ func ValidPassword(password string) error {
 			hasNumber = true
 		}
 	}
-	if hasUpper && hasLower && hasNumber {
+	if hasMinLen && hasUpper && hasLower && hasNumber {
+		if IsCommonPassword(password) {
+			return resp.ErrPasswordIsCommon
+		}
+		if EstimatePasswordStrength(password).Score < minPasswordScore {
+			return resp.ErrPasswordIsTooGuessable
+		}
 		return nil
 	}
 	errStr := "password must contain "
@@ -119,3 +132,52 @@ func ValidWordbubble(wb *req.WordbubbleRequest) error {
 	}
 	return nil
 }
+
+// ValidApiKeyName validates the name of an api key, shorter than maxApiKeyNameLength and longer than ""
+func ValidApiKeyName(name string) error {
+	if len(name) > maxApiKeyNameLength {
+		return resp.ErrApiKeyNameIsTooLong
+	} else if len(name) == 0 {
+		return resp.ErrApiKeyNameIsMissing
+	}
+	return nil
+}
+
+// ValidPasskeyName validates the name of a passkey, shorter than maxPasskeyNameLength and longer than ""
+func ValidPasskeyName(name string) error {
+	if len(name) > maxPasskeyNameLength {
+		return resp.ErrPasskeyNameIsTooLong
+	} else if len(name) == 0 {
+		return resp.ErrPasskeyNameIsMissing
+	}
+	return nil
+}
+
+// ValidClientName validates the name of an oauth client, shorter than maxClientNameLength and longer than ""
+func ValidClientName(name string) error {
+	if len(name) > maxClientNameLength {
+		return resp.ErrClientNameIsTooLong
+	} else if len(name) == 0 {
+		return resp.ErrClientNameIsMissing
+	}
+	return nil
+}
+
+// ValidRedirectUri validates the redirect uri of an oauth client, it must be an absolute https url without a fragment.
+// Native apps can't serve https, so an http url is allowed when it's on the loopback interface
+func ValidRedirectUri(redirectUri string) error {
+	if len(redirectUri) > maxRedirectUriLength {
+		return resp.ErrInvalidRedirectUri
+	}
+	u, err := url.Parse(redirectUri)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return resp.ErrInvalidRedirectUri
+	}
+	switch host := u.Hostname(); {
+	case u.Scheme == "https":
+		return nil
+	case u.Scheme == "http" && (host == "localhost" || host == "127.0.0.1" || host == "::1"):
+		return nil
+	}
+	return resp.ErrInvalidRedirectUri
+}
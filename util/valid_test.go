@@ -1,8 +1,12 @@
 package util
 
 import (
+	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -93,14 +97,14 @@ func Test_ValidPassword(t *testing.T) {
 		expectedErr error
 	}{
 		"valid": {
-			password: "Password123" + strings.Repeat("a", minPasswordLength),
+			password: "BlueKettle_Jumps7",
 		},
 		"invalid, empty": {
 			password:    "",
-			expectedErr: errors.New("password must contain at least 6 characters, one uppercase character, one lowercase character, and one number"),
+			expectedErr: fmt.Errorf("password must contain at least %d characters, one uppercase character, one lowercase character, and one number", minPasswordLength),
 		},
 		"invalid, has lowercase but not long enough": {
-			password:    strings.Repeat("a", minPasswordLength),
+			password:    strings.Repeat("a", minPasswordLength-1),
 			expectedErr: fmt.Errorf("password must contain at least %d characters, one uppercase character, and one number", minPasswordLength),
 		},
 		"invalid, has lowercase is long enough, but no uppercase or number": {
@@ -112,7 +116,7 @@ func Test_ValidPassword(t *testing.T) {
 			expectedErr: errors.New("password must contain one lowercase character, and one number"),
 		},
 		"invalid, has uppercase but not long enough, but no lowercase or number": {
-			password:    strings.Repeat("A", minPasswordLength),
+			password:    strings.Repeat("A", minPasswordLength-1),
 			expectedErr: fmt.Errorf("password must contain at least %d characters, one lowercase character, and one number", minPasswordLength),
 		},
 		"invalid, numbers only": {
@@ -123,6 +127,26 @@ func Test_ValidPassword(t *testing.T) {
 			password:    "Password" + strings.Repeat("a", minPasswordLength),
 			expectedErr: errors.New("password must contain one number"),
 		},
+		"invalid, every character class but not long enough": {
+			password:    "Xk9#mQ2",
+			expectedErr: fmt.Errorf("password must contain at least %d characters", minPasswordLength),
+		},
+		"invalid, common": {
+			password:    "Password1",
+			expectedErr: resp.ErrPasswordIsCommon,
+		},
+		"invalid, common with substitutions": {
+			password:    "P@ssw0rd2024",
+			expectedErr: resp.ErrPasswordIsCommon,
+		},
+		"invalid, common word with a repeated suffix": {
+			password:    "Password123" + strings.Repeat("a", minPasswordLength),
+			expectedErr: resp.ErrPasswordIsTooGuessable,
+		},
+		"invalid, keyboard walk": {
+			password:    "qwertyuiop1A",
+			expectedErr: resp.ErrPasswordIsTooGuessable,
+		},
 	}
 	for tname, tcase := range tests {
 		t.Run(tname, func(t *testing.T) {
@@ -177,6 +201,82 @@ func Test_ValidWordbubble(t *testing.T) {
 	}
 }
 
+func Test_IsCommonPassword(t *testing.T) {
+	tests := map[string]struct {
+		password       string
+		expectedCommon bool
+	}{
+		"common":                         {password: "password", expectedCommon: true},
+		"common, different case":         {password: "PassWord", expectedCommon: true},
+		"common, numbers only":           {password: "123456789", expectedCommon: true},
+		"common, with substitutions":     {password: "p@55w0rd", expectedCommon: true},
+		"common, with digits and symbol": {password: "Summer2022!", expectedCommon: true},
+		"common, with leading digits":    {password: "123monkey", expectedCommon: true},
+		"not common":                     {password: "BlueKettle_Jumps7"},
+		"not common, common word inside": {password: "mypasswordisgreat"},
+		"not common, empty":              {password: ""},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			assert.Equal(t, tcase.expectedCommon, IsCommonPassword(tcase.password))
+		})
+	}
+}
+
+func Test_LoadCommonPasswords(t *testing.T) {
+	loadCommonPasswords()
+	embedded, longest := commonPasswords, maxCommonPassword
+	t.Cleanup(func() { commonPasswords, maxCommonPassword = embedded, longest })
+
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "passwords.txt")
+	assert.NoError(t, os.WriteFile(plain, []byte("CorrectHorseBatteryStaple\n\nkettle\n"), 0600))
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("bluekettlejumps\n"))
+	gz.Close()
+	compressed := filepath.Join(dir, "passwords.txt.gz")
+	assert.NoError(t, os.WriteFile(compressed, buf.Bytes(), 0600))
+	empty := filepath.Join(dir, "empty.txt")
+	assert.NoError(t, os.WriteFile(empty, []byte("\n"), 0600))
+
+	assert.NoError(t, LoadCommonPasswords(plain))
+	assert.True(t, IsCommonPassword("correcthorsebatterystaple"))
+	assert.False(t, IsCommonPassword("password"), "the embedded list is replaced")
+	assert.Equal(t, len("correcthorsebatterystaple"), maxCommonPassword)
+
+	assert.NoError(t, LoadCommonPasswords(compressed))
+	assert.True(t, IsCommonPassword("BlueKettleJumps"))
+
+	assert.NotNil(t, LoadCommonPasswords(empty))
+	assert.NotNil(t, LoadCommonPasswords(filepath.Join(dir, "missing.txt")))
+	assert.True(t, IsCommonPassword("BlueKettleJumps"), "a list that fails to load doesn't replace the one loaded")
+}
+
+func Test_EstimatePasswordStrength(t *testing.T) {
+	tests := map[string]struct {
+		password      string
+		expectedScore int
+	}{
+		"empty":                 {password: "", expectedScore: 0},
+		"repeated":              {password: "aaaaaaaaaaaaaaaa", expectedScore: 0},
+		"sequence":              {password: "abcdefghijklmnop", expectedScore: 0},
+		"common word":           {password: "sunshine", expectedScore: 0},
+		"common words":          {password: "sunshinedragon", expectedScore: 0},
+		"common word, and more": {password: "SomePassword123", expectedScore: 1},
+		"short and random":      {password: "Xk9#mQ2v", expectedScore: 2},
+		"substitutions":         {password: "Tr0ub4dor&3", expectedScore: 3},
+		"passphrase":            {password: "correct horse battery staple", expectedScore: 4},
+		"long and random":       {password: "j8$Kq2!vLw9#Zr4@Tx6%Nb1^Hm3&Pc7*", expectedScore: 4},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			strength := EstimatePasswordStrength(tcase.password)
+			assert.Equal(t, tcase.expectedScore, strength.Score, strength.Entropy)
+		})
+	}
+}
+
 func Test_ValidUser(t *testing.T) {
 	tests := map[string]struct {
 		user        *model.User
@@ -186,14 +286,14 @@ func Test_ValidUser(t *testing.T) {
 			user: &model.User{
 				Username: "ben",
 				Email:    "benchadwick87@gmail.com",
-				Password: "Password123" + strings.Repeat("a", minPasswordLength),
+				Password: "BlueKettle_Jumps7",
 			},
 		},
 		"invalid, invalid username": {
 			user: &model.User{
 				Username: "ben*",
 				Email:    "benchadwick87@gmail.com",
-				Password: "Password123" + strings.Repeat("a", minPasswordLength),
+				Password: "BlueKettle_Jumps7",
 			},
 			expectedErr: resp.ErrUsernameInvalidChars,
 		},
@@ -201,7 +301,7 @@ func Test_ValidUser(t *testing.T) {
 			user: &model.User{
 				Username: "ben",
 				Email:    "benchadwick87@gmail.com" + strings.Repeat("a", maxEmailLength),
-				Password: "Password123" + strings.Repeat("a", minPasswordLength),
+				Password: "BlueKettle_Jumps7",
 			},
 			expectedErr: resp.ErrEmailIsTooLong,
 		},
@@ -226,3 +326,137 @@ func Test_ValidUser(t *testing.T) {
 		})
 	}
 }
+
+func Test_ValidApiKeyName(t *testing.T) {
+	tests := map[string]struct {
+		name        string
+		expectedErr error
+	}{
+		"valid": {
+			name: "nightly cron",
+		},
+		"valid, max length": {
+			name: strings.Repeat("a", maxApiKeyNameLength),
+		},
+		"invalid, empty": {
+			name:        "",
+			expectedErr: resp.ErrApiKeyNameIsMissing,
+		},
+		"invalid, too long": {
+			name:        strings.Repeat("a", maxApiKeyNameLength+1),
+			expectedErr: resp.ErrApiKeyNameIsTooLong,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			err := ValidApiKeyName(tcase.name)
+			assert.Equal(t, tcase.expectedErr, err)
+		})
+	}
+}
+
+func Test_ValidPasskeyName(t *testing.T) {
+	tests := map[string]struct {
+		name        string
+		expectedErr error
+	}{
+		"valid": {
+			name: "work laptop",
+		},
+		"valid, max length": {
+			name: strings.Repeat("a", maxPasskeyNameLength),
+		},
+		"invalid, empty": {
+			name:        "",
+			expectedErr: resp.ErrPasskeyNameIsMissing,
+		},
+		"invalid, too long": {
+			name:        strings.Repeat("a", maxPasskeyNameLength+1),
+			expectedErr: resp.ErrPasskeyNameIsTooLong,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			err := ValidPasskeyName(tcase.name)
+			assert.Equal(t, tcase.expectedErr, err)
+		})
+	}
+}
+
+func Test_ValidClientName(t *testing.T) {
+	tests := map[string]struct {
+		name        string
+		expectedErr error
+	}{
+		"valid": {
+			name: "wordbubble for desktop",
+		},
+		"valid, max length": {
+			name: strings.Repeat("a", maxClientNameLength),
+		},
+		"invalid, empty": {
+			name:        "",
+			expectedErr: resp.ErrClientNameIsMissing,
+		},
+		"invalid, too long": {
+			name:        strings.Repeat("a", maxClientNameLength+1),
+			expectedErr: resp.ErrClientNameIsTooLong,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			err := ValidClientName(tcase.name)
+			assert.Equal(t, tcase.expectedErr, err)
+		})
+	}
+}
+
+func Test_ValidRedirectUri(t *testing.T) {
+	tests := map[string]struct {
+		redirectUri string
+		expectedErr error
+	}{
+		"valid, https": {
+			redirectUri: "https://app.example.com/callback",
+		},
+		"valid, https with a query": {
+			redirectUri: "https://app.example.com/callback?from=wordbubble",
+		},
+		"valid, http on localhost": {
+			redirectUri: "http://localhost:8000/callback",
+		},
+		"valid, http on the loopback address": {
+			redirectUri: "http://127.0.0.1:8000/callback",
+		},
+		"invalid, http": {
+			redirectUri: "http://app.example.com/callback",
+			expectedErr: resp.ErrInvalidRedirectUri,
+		},
+		"invalid, relative": {
+			redirectUri: "/callback",
+			expectedErr: resp.ErrInvalidRedirectUri,
+		},
+		"invalid, fragment": {
+			redirectUri: "https://app.example.com/callback#token",
+			expectedErr: resp.ErrInvalidRedirectUri,
+		},
+		"invalid, javascript": {
+			redirectUri: "javascript:alert(1)",
+			expectedErr: resp.ErrInvalidRedirectUri,
+		},
+		"invalid, empty": {
+			redirectUri: "",
+			expectedErr: resp.ErrInvalidRedirectUri,
+		},
+		"invalid, too long": {
+			redirectUri: "https://app.example.com/" + strings.Repeat("a", maxRedirectUriLength),
+			expectedErr: resp.ErrInvalidRedirectUri,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			err := ValidRedirectUri(tcase.redirectUri)
+			assert.Equal(t, tcase.expectedErr, err)
+		})
+	}
+}
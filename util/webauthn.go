@@ -0,0 +1,413 @@
+package util
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+
+	"github.com/bchadwic/wordbubble/model/req"
+	"github.com/bchadwic/wordbubble/model/resp"
+)
+
+// ceremonies a browser collects client data for, as the type of the client data
+const (
+	WebauthnCreate = "webauthn.create"
+	WebauthnGet    = "webauthn.get"
+)
+
+// COSE algorithms a passkey can sign with, as registered with IANA
+const (
+	CoseES256 int64 = -7
+	CoseEdDSA int64 = -8
+	CoseRS256 int64 = -257
+)
+
+// PasskeyAlgorithms are the algorithms passkeys are registered with, in order of preference
+var PasskeyAlgorithms = []int64{CoseES256, CoseEdDSA, CoseRS256}
+
+// flags of authenticator data
+const (
+	authDataUserPresent  = 0x01
+	authDataUserVerified = 0x04
+	authDataAttested     = 0x40
+	authDataExtensions   = 0x80
+)
+
+const (
+	maxCredentialIdLength = 1023
+	minRsaKeyBits         = 2048
+)
+
+var errCose = errors.New("cose key is malformed")
+
+// WebauthnRelyingParty is the site passkeys are registered for, passkey login is turned off when no id is set
+type WebauthnRelyingParty struct {
+	// Id is the domain passkeys are scoped to, a passkey can be used on the domain and any domain below it
+	Id string
+	// Name is shown to users by their authenticator when a passkey is registered
+	Name string
+	// Origin is where the frontend running the ceremonies is served from, browsers report it in the client data
+	Origin string
+}
+
+// Enabled is true when an id is set
+func (rp WebauthnRelyingParty) Enabled() bool {
+	return rp.Id != ""
+}
+
+// Validate makes sure the origin is a secure url on the id or a domain below it, when the relying party is enabled.
+// Browsers only allow plain http on localhost
+func (rp WebauthnRelyingParty) Validate() error {
+	if !rp.Enabled() {
+		return nil
+	}
+	u, err := url.Parse(rp.Origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+		return errors.New("webauthn origin must be a url without a path")
+	}
+	if host := u.Hostname(); host != rp.Id && !strings.HasSuffix(host, "."+rp.Id) {
+		return errors.New("webauthn origin must be on the relying party id or a domain below it")
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && u.Hostname() == "localhost") {
+		return errors.New("webauthn origin must use https")
+	}
+	return nil
+}
+
+// clientData is the part of the client data a browser collects during a ceremony that's checked
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin,omitempty"`
+}
+
+// ParseClientData checks that the client data was collected by a browser on the origin of the relying party, for the ceremony passed.
+// string is the base64url encoded challenge the authenticator responded to, the caller makes sure it was issued, or empty string.
+// error could be (400) resp.ErrInvalidPasskeyRegistration for WebauthnCreate, (401) resp.ErrInvalidPasskey for WebauthnGet or nil.
+func (rp WebauthnRelyingParty) ParseClientData(clientDataJSON []byte, ceremony string) (string, error) {
+	var invalid error = resp.ErrInvalidPasskey
+	if ceremony == WebauthnCreate {
+		invalid = resp.ErrInvalidPasskeyRegistration
+	}
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return "", invalid
+	}
+	if data.Type != ceremony || data.Challenge == "" || data.CrossOrigin || data.Origin != strings.TrimSuffix(rp.Origin, "/") {
+		return "", invalid
+	}
+	return data.Challenge, nil
+}
+
+// PasskeyAttestation is a passkey an authenticator created during registration
+type PasskeyAttestation struct {
+	CredentialId []byte
+	PublicKey    []byte // COSE encoded
+	SignCount    uint32
+	UserVerified bool
+}
+
+// VerifyAttestation reads the passkey an authenticator created from its attestation object, after checking that it was created for the relying party.
+// Only "none" attestation is accepted since none is asked for, the authenticator's make and model aren't checked.
+// *PasskeyAttestation is the passkey created, can be nil.
+// error could be (400) resp.ErrInvalidPasskeyRegistration, (400) resp.ErrUnsupportedPasskeyAlgorithm or nil.
+func (rp WebauthnRelyingParty) VerifyAttestation(attestationObject []byte) (*PasskeyAttestation, error) {
+	v, rest, err := decodeCbor(attestationObject)
+	obj, ok := v.(map[interface{}]interface{})
+	if err != nil || !ok || len(rest) != 0 {
+		return nil, resp.ErrInvalidPasskeyRegistration
+	}
+	format, _ := obj["fmt"].(string)
+	statement, _ := obj["attStmt"].(map[interface{}]interface{})
+	raw, _ := obj["authData"].([]byte)
+	if format != "none" || statement == nil || len(statement) != 0 {
+		return nil, resp.ErrInvalidPasskeyRegistration
+	}
+	authData, err := rp.parseAuthenticatorData(raw)
+	if err != nil || authData.flags&authDataAttested == 0 {
+		return nil, resp.ErrInvalidPasskeyRegistration
+	}
+	if _, _, err = parseCoseKey(authData.publicKey); err != nil {
+		if err == resp.ErrUnsupportedPasskeyAlgorithm {
+			return nil, err
+		}
+		return nil, resp.ErrInvalidPasskeyRegistration
+	}
+	return &PasskeyAttestation{
+		CredentialId: authData.credentialId,
+		PublicKey:    authData.publicKey,
+		SignCount:    authData.signCount,
+		UserVerified: authData.flags&authDataUserVerified != 0,
+	}, nil
+}
+
+// VerifyAssertion checks the signature an authenticator made with a passkey during login,
+// over the authenticator data and the hash of the client data, and that the authenticator data is for the relying party.
+// uint32 is the signature counter of the authenticator, or zero.
+// bool is true when the authenticator verified the user.
+// error could be (401) resp.ErrInvalidPasskey or nil.
+func (rp WebauthnRelyingParty) VerifyAssertion(publicKey, clientDataJSON, authenticatorData, signature []byte) (uint32, bool, error) {
+	authData, err := rp.parseAuthenticatorData(authenticatorData)
+	if err != nil || authData.flags&authDataAttested != 0 {
+		return 0, false, resp.ErrInvalidPasskey
+	}
+	key, alg, err := parseCoseKey(publicKey)
+	if err != nil {
+		return 0, false, resp.ErrInvalidPasskey
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authenticatorData...), clientDataHash[:]...)
+	if !verifyCoseSignature(key, alg, signed, signature) {
+		return 0, false, resp.ErrInvalidPasskey
+	}
+	return authData.signCount, authData.flags&authDataUserVerified != 0, nil
+}
+
+type authenticatorData struct {
+	flags        byte
+	signCount    uint32
+	credentialId []byte // empty when no passkey was attested
+	publicKey    []byte // empty when no passkey was attested
+}
+
+// parseAuthenticatorData reads authenticator data, as defined by WebAuthn, making sure it's for the relying party and the user was present
+func (rp WebauthnRelyingParty) parseAuthenticatorData(b []byte) (*authenticatorData, error) {
+	if len(b) < 37 {
+		return nil, errCbor
+	}
+	rpIdHash := sha256.Sum256([]byte(rp.Id))
+	if !bytes.Equal(b[:32], rpIdHash[:]) || b[32]&authDataUserPresent == 0 {
+		return nil, errCbor
+	}
+	authData := &authenticatorData{
+		flags:     b[32],
+		signCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	rest := b[37:]
+	if authData.flags&authDataAttested != 0 {
+		if len(rest) < 18 { // aaguid and the length of the credential id
+			return nil, errCbor
+		}
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > maxCredentialIdLength || idLength > len(rest) {
+			return nil, errCbor
+		}
+		authData.credentialId, rest = rest[:idLength], rest[idLength:]
+		keyRest := rest
+		var err error
+		if _, rest, err = decodeCbor(rest); err != nil {
+			return nil, err
+		}
+		authData.publicKey = keyRest[:len(keyRest)-len(rest)]
+	}
+	if authData.flags&authDataExtensions != 0 {
+		extensions, extRest, err := decodeCbor(rest)
+		if _, ok := extensions.(map[interface{}]interface{}); err != nil || !ok {
+			return nil, errCbor
+		}
+		rest = extRest
+	}
+	if len(rest) != 0 {
+		return nil, errCbor
+	}
+	return authData, nil
+}
+
+// parseCoseKey reads a COSE encoded public key (RFC 9052), of an algorithm in PasskeyAlgorithms.
+// crypto.PublicKey is the key, *ecdsa.PublicKey, ed25519.PublicKey or *rsa.PublicKey, can be nil.
+// int64 is the COSE algorithm of the key, or zero.
+// error could be (400) resp.ErrUnsupportedPasskeyAlgorithm, errCose or nil.
+func parseCoseKey(b []byte) (crypto.PublicKey, int64, error) {
+	v, rest, err := decodeCbor(b)
+	key, ok := v.(map[interface{}]interface{})
+	if err != nil || !ok || len(rest) != 0 {
+		return nil, 0, errCose
+	}
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+	switch {
+	case kty == 2 && alg == CoseES256:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 { // P-256
+			return nil, 0, errCose
+		}
+		public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !public.Curve.IsOnCurve(public.X, public.Y) {
+			return nil, 0, errCose
+		}
+		return public, alg, nil
+	case kty == 1 && alg == CoseEdDSA:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize { // Ed25519
+			return nil, 0, errCose
+		}
+		return ed25519.PublicKey(x), alg, nil
+	case kty == 3 && alg == CoseRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		modulus, exponent := new(big.Int).SetBytes(n), new(big.Int).SetBytes(e)
+		if modulus.BitLen() < minRsaKeyBits || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, 0, errCose
+		}
+		return &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}, alg, nil
+	}
+	return nil, 0, resp.ErrUnsupportedPasskeyAlgorithm
+}
+
+// verifyCoseSignature checks a signature made by a passkey with the key and algorithm read by parseCoseKey
+func verifyCoseSignature(key crypto.PublicKey, alg int64, signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+	switch alg {
+	case CoseES256:
+		public, ok := key.(*ecdsa.PublicKey)
+		return ok && ecdsa.VerifyASN1(public, digest[:], signature)
+	case CoseEdDSA:
+		public, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(public, signed, signature)
+	case CoseRS256:
+		public, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) == nil
+	}
+	return false
+}
+
+// TestRelyingParty is the relying party passkeys are registered for in unit tests
+func TestRelyingParty() WebauthnRelyingParty {
+	return WebauthnRelyingParty{
+		Id:     "wordbubble.com",
+		Name:   "wordbubble",
+		Origin: "https://wordbubble.com",
+	}
+}
+
+// TestAuthenticator is a software authenticator holding a single ES256 passkey, used for unit testing only.
+// It responds to ceremonies the way a browser and a security key would, without leaving the process
+type TestAuthenticator struct {
+	rp           WebauthnRelyingParty
+	key          *ecdsa.PrivateKey
+	credentialId []byte
+	userHandle   string
+	// Origin is the origin the browser reports, the origin of the relying party unless a test changes it
+	Origin string
+	// UserVerified is true when the authenticator verifies the user with a pin or biometric
+	UserVerified bool
+	// Counter is true when the authenticator increments SignCount before every login, authenticators without one always send zero
+	Counter   bool
+	SignCount uint32
+}
+
+// NewTestAuthenticator creates an authenticator for the relying party passed, with a new passkey that verifies the user and keeps a counter
+func NewTestAuthenticator(rp WebauthnRelyingParty) *TestAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return &TestAuthenticator{
+		rp:           rp,
+		key:          key,
+		credentialId: RandomBytes(16),
+		Origin:       rp.Origin,
+		UserVerified: true,
+		Counter:      true,
+	}
+}
+
+// Id is the base64url encoded credential id of the passkey
+func (ta *TestAuthenticator) Id() string {
+	return base64.RawURLEncoding.EncodeToString(ta.credentialId)
+}
+
+// PublicKey is the COSE encoded public key of the passkey
+func (ta *TestAuthenticator) PublicKey() []byte {
+	x, y := make([]byte, 32), make([]byte, 32)
+	ta.key.X.FillBytes(x)
+	ta.key.Y.FillBytes(y)
+	return encodeCbor(map[int64]interface{}{1: 2, 3: CoseES256, -1: 1, -2: x, -3: y})
+}
+
+// Register responds to a registration challenge with the passkey, for the user handle passed, which is returned on every login after
+func (ta *TestAuthenticator) Register(challenge, userHandle string) *req.PasskeyCredential {
+	ta.userHandle = userHandle
+	clientDataJSON := ta.clientData(WebauthnCreate, challenge)
+	authData := ta.authenticatorData(authDataAttested)
+	authData = append(authData, make([]byte, 16)...) // aaguid, zero for none attestation
+	authData = append(authData, byte(len(ta.credentialId)>>8), byte(len(ta.credentialId)))
+	authData = append(authData, ta.credentialId...)
+	authData = append(authData, ta.PublicKey()...)
+	attestationObject := encodeCbor(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	return &req.PasskeyCredential{
+		Id:    ta.Id(),
+		RawId: ta.Id(),
+		Type:  "public-key",
+		Response: req.PasskeyAuthenticatorResponse{
+			ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientDataJSON),
+			AttestationObject: base64.RawURLEncoding.EncodeToString(attestationObject),
+		},
+	}
+}
+
+// Login responds to a login challenge by signing it with the passkey
+func (ta *TestAuthenticator) Login(challenge string) *req.PasskeyCredential {
+	if ta.Counter {
+		ta.SignCount++
+	}
+	clientDataJSON := ta.clientData(WebauthnGet, challenge)
+	authData := ta.authenticatorData(0)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, ta.key, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return &req.PasskeyCredential{
+		Id:    ta.Id(),
+		RawId: ta.Id(),
+		Type:  "public-key",
+		Response: req.PasskeyAuthenticatorResponse{
+			ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientDataJSON),
+			AuthenticatorData: base64.RawURLEncoding.EncodeToString(authData),
+			Signature:         base64.RawURLEncoding.EncodeToString(signature),
+			UserHandle:        ta.userHandle,
+		},
+	}
+}
+
+func (ta *TestAuthenticator) clientData(ceremony, challenge string) []byte {
+	b, err := json.Marshal(&clientData{Type: ceremony, Challenge: challenge, Origin: ta.Origin})
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (ta *TestAuthenticator) authenticatorData(flags byte) []byte {
+	flags |= authDataUserPresent
+	if ta.UserVerified {
+		flags |= authDataUserVerified
+	}
+	rpIdHash := sha256.Sum256([]byte(ta.rp.Id))
+	authData := append(rpIdHash[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(authData[33:], ta.SignCount)
+	return authData
+}
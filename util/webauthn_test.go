@@ -0,0 +1,342 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/bchadwic/wordbubble/model/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WebauthnRelyingPartyValidate(t *testing.T) {
+	tests := map[string]struct {
+		change   func(rp *WebauthnRelyingParty)
+		enabled  bool
+		wantsErr bool
+	}{
+		"valid": {
+			change:  func(rp *WebauthnRelyingParty) {},
+			enabled: true,
+		},
+		"valid, disabled": {
+			change: func(rp *WebauthnRelyingParty) { *rp = WebauthnRelyingParty{} },
+		},
+		"valid, origin below the id": {
+			change:  func(rp *WebauthnRelyingParty) { rp.Origin = "https://app.wordbubble.com" },
+			enabled: true,
+		},
+		"valid, localhost over http": {
+			change:  func(rp *WebauthnRelyingParty) { rp.Id, rp.Origin = "localhost", "http://localhost:3000" },
+			enabled: true,
+		},
+		"invalid, origin is not a url": {
+			change:   func(rp *WebauthnRelyingParty) { rp.Origin = "wordbubble.com" },
+			enabled:  true,
+			wantsErr: true,
+		},
+		"invalid, origin has a path": {
+			change:   func(rp *WebauthnRelyingParty) { rp.Origin = "https://wordbubble.com/login" },
+			enabled:  true,
+			wantsErr: true,
+		},
+		"invalid, origin on another domain": {
+			change:   func(rp *WebauthnRelyingParty) { rp.Origin = "https://notwordbubble.com" },
+			enabled:  true,
+			wantsErr: true,
+		},
+		"invalid, origin over http": {
+			change:   func(rp *WebauthnRelyingParty) { rp.Origin = "http://wordbubble.com" },
+			enabled:  true,
+			wantsErr: true,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			rp := TestRelyingParty()
+			tcase.change(&rp)
+			assert.Equal(t, tcase.enabled, rp.Enabled())
+			assert.Equal(t, tcase.wantsErr, rp.Validate() != nil)
+		})
+	}
+}
+
+func Test_ParseClientData(t *testing.T) {
+	tests := map[string]struct {
+		clientDataJSON    string
+		ceremony          string
+		expectedChallenge string
+		expectedErr       error
+	}{
+		"valid, create": {
+			clientDataJSON:    `{"type":"webauthn.create","challenge":"q83vEjRW","origin":"https://wordbubble.com"}`,
+			ceremony:          WebauthnCreate,
+			expectedChallenge: "q83vEjRW",
+		},
+		"valid, get": {
+			clientDataJSON:    `{"type":"webauthn.get","challenge":"q83vEjRW","origin":"https://wordbubble.com","crossOrigin":false,"other_keys_can_be_added_here":"do not compare clientDataJSON against a template"}`,
+			ceremony:          WebauthnGet,
+			expectedChallenge: "q83vEjRW",
+		},
+		"invalid, collected for another ceremony": {
+			clientDataJSON: `{"type":"webauthn.create","challenge":"q83vEjRW","origin":"https://wordbubble.com"}`,
+			ceremony:       WebauthnGet,
+			expectedErr:    resp.ErrInvalidPasskey,
+		},
+		"invalid, phishing origin": {
+			clientDataJSON: `{"type":"webauthn.get","challenge":"q83vEjRW","origin":"https://wordbubble.co"}`,
+			ceremony:       WebauthnGet,
+			expectedErr:    resp.ErrInvalidPasskey,
+		},
+		"invalid, cross origin iframe": {
+			clientDataJSON: `{"type":"webauthn.get","challenge":"q83vEjRW","origin":"https://wordbubble.com","crossOrigin":true}`,
+			ceremony:       WebauthnGet,
+			expectedErr:    resp.ErrInvalidPasskey,
+		},
+		"invalid, no challenge": {
+			clientDataJSON: `{"type":"webauthn.create","origin":"https://wordbubble.com"}`,
+			ceremony:       WebauthnCreate,
+			expectedErr:    resp.ErrInvalidPasskeyRegistration,
+		},
+		"invalid, not json": {
+			clientDataJSON: `webauthn.create`,
+			ceremony:       WebauthnCreate,
+			expectedErr:    resp.ErrInvalidPasskeyRegistration,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			challenge, err := TestRelyingParty().ParseClientData([]byte(tcase.clientDataJSON), tcase.ceremony)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.expectedChallenge, challenge)
+		})
+	}
+}
+
+func Test_VerifyAttestation(t *testing.T) {
+	ta := NewTestAuthenticator(TestRelyingParty())
+	attested := func(authData []byte, key []byte) []byte {
+		authData = append(authData, make([]byte, 16)...)
+		authData = append(authData, 0, byte(len(ta.credentialId)))
+		authData = append(authData, ta.credentialId...)
+		return append(authData, key...)
+	}
+	attestationObject := func(format string, authData []byte) []byte {
+		return encodeCbor(map[string]interface{}{"fmt": format, "attStmt": map[string]interface{}{}, "authData": authData})
+	}
+	otherRp := NewTestAuthenticator(WebauthnRelyingParty{Id: "evil.com", Origin: "https://evil.com"})
+	tests := map[string]struct {
+		attestationObject []byte
+		userVerified      bool
+		expectedErr       error
+	}{
+		"valid": {
+			attestationObject: decodeTestField(ta.Register("q83vEjRW", "AAAAAAAAAAI").Response.AttestationObject),
+			userVerified:      true,
+		},
+		"valid, user not verified": {
+			attestationObject: func() []byte {
+				unverified := *ta
+				unverified.UserVerified = false
+				return decodeTestField(unverified.Register("q83vEjRW", "AAAAAAAAAAI").Response.AttestationObject)
+			}(),
+		},
+		"valid, with extensions": {
+			attestationObject: attestationObject("none", append(attested(ta.authenticatorData(authDataAttested|authDataExtensions), ta.PublicKey()), encodeCbor(map[string]interface{}{"credProtect": 1})...)),
+			userVerified:      true,
+		},
+		"invalid, created for another relying party": {
+			attestationObject: decodeTestField(otherRp.Register("q83vEjRW", "AAAAAAAAAAI").Response.AttestationObject),
+			expectedErr:       resp.ErrInvalidPasskeyRegistration,
+		},
+		"invalid, packed attestation": {
+			attestationObject: attestationObject("packed", attested(ta.authenticatorData(authDataAttested), ta.PublicKey())),
+			expectedErr:       resp.ErrInvalidPasskeyRegistration,
+		},
+		"invalid, no passkey attested": {
+			attestationObject: attestationObject("none", ta.authenticatorData(0)),
+			expectedErr:       resp.ErrInvalidPasskeyRegistration,
+		},
+		"invalid, trailing bytes": {
+			attestationObject: attestationObject("none", append(attested(ta.authenticatorData(authDataAttested), ta.PublicKey()), 0x00)),
+			expectedErr:       resp.ErrInvalidPasskeyRegistration,
+		},
+		"invalid, key is not on the curve": {
+			attestationObject: attestationObject("none", attested(ta.authenticatorData(authDataAttested), encodeCbor(map[int64]interface{}{1: 2, 3: CoseES256, -1: 1, -2: make([]byte, 32), -3: make([]byte, 32)}))),
+			expectedErr:       resp.ErrInvalidPasskeyRegistration,
+		},
+		"invalid, unsupported algorithm": {
+			attestationObject: attestationObject("none", attested(ta.authenticatorData(authDataAttested), encodeCbor(map[int64]interface{}{1: 2, 3: -35, -1: 2, -2: make([]byte, 48), -3: make([]byte, 48)}))),
+			expectedErr:       resp.ErrUnsupportedPasskeyAlgorithm,
+		},
+		"invalid, not cbor": {
+			attestationObject: []byte("none"),
+			expectedErr:       resp.ErrInvalidPasskeyRegistration,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			attestation, err := TestRelyingParty().VerifyAttestation(tcase.attestationObject)
+			assert.Equal(t, tcase.expectedErr, err)
+			if tcase.expectedErr == nil {
+				assert.Equal(t, ta.credentialId, attestation.CredentialId)
+				assert.Equal(t, ta.PublicKey(), attestation.PublicKey)
+				assert.Equal(t, tcase.userVerified, attestation.UserVerified)
+			}
+		})
+	}
+}
+
+func Test_VerifyAssertion(t *testing.T) {
+	ta := NewTestAuthenticator(TestRelyingParty())
+	edPublic, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	rsaPrivate, _ := rsa.GenerateKey(rand.Reader, 2048)
+	smallRsaPrivate, _ := rsa.GenerateKey(rand.Reader, 1024)
+	rsaKey := func(key *rsa.PrivateKey) []byte {
+		return encodeCbor(map[int64]interface{}{1: 3, 3: CoseRS256, -1: key.N.Bytes(), -2: big.NewInt(int64(key.E)).Bytes()})
+	}
+	clientDataJSON := ta.clientData(WebauthnGet, "q83vEjRW")
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	authData := ta.authenticatorData(0)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+	digest := sha256.Sum256(signed)
+	rsaSignature, _ := rsa.SignPKCS1v15(rand.Reader, rsaPrivate, crypto.SHA256, digest[:])
+	smallRsaSignature, _ := rsa.SignPKCS1v15(rand.Reader, smallRsaPrivate, crypto.SHA256, digest[:])
+
+	type assertion struct {
+		publicKey, clientDataJSON, authenticatorData, signature []byte
+	}
+	fromAuthenticator := func(ta *TestAuthenticator) assertion {
+		credential := ta.Login("q83vEjRW")
+		return assertion{
+			publicKey:         ta.PublicKey(),
+			clientDataJSON:    decodeTestField(credential.Response.ClientDataJSON),
+			authenticatorData: decodeTestField(credential.Response.AuthenticatorData),
+			signature:         decodeTestField(credential.Response.Signature),
+		}
+	}
+	tests := map[string]struct {
+		assertion         func() assertion
+		expectedSignCount uint32
+		userVerified      bool
+		expectedErr       error
+	}{
+		"valid, ES256": {
+			assertion: func() assertion {
+				counter := NewTestAuthenticator(TestRelyingParty())
+				counter.SignCount = 41
+				return fromAuthenticator(counter)
+			},
+			expectedSignCount: 42,
+			userVerified:      true,
+		},
+		"valid, EdDSA": {
+			assertion: func() assertion {
+				return assertion{
+					publicKey:         encodeCbor(map[int64]interface{}{1: 1, 3: CoseEdDSA, -1: 6, -2: []byte(edPublic)}),
+					clientDataJSON:    clientDataJSON,
+					authenticatorData: authData,
+					signature:         ed25519.Sign(edPrivate, signed),
+				}
+			},
+			userVerified: true,
+		},
+		"valid, RS256": {
+			assertion: func() assertion {
+				return assertion{rsaKey(rsaPrivate), clientDataJSON, authData, rsaSignature}
+			},
+			userVerified: true,
+		},
+		"valid, user not verified and no counter": {
+			assertion: func() assertion {
+				unverified := NewTestAuthenticator(TestRelyingParty())
+				unverified.UserVerified, unverified.Counter = false, false
+				return fromAuthenticator(unverified)
+			},
+		},
+		"invalid, RSA key is too small": {
+			assertion: func() assertion {
+				return assertion{rsaKey(smallRsaPrivate), clientDataJSON, authData, smallRsaSignature}
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+		"invalid, signed by another passkey": {
+			assertion: func() assertion {
+				a := fromAuthenticator(ta)
+				a.publicKey = NewTestAuthenticator(TestRelyingParty()).PublicKey()
+				return a
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+		"invalid, client data changed": {
+			assertion: func() assertion {
+				a := fromAuthenticator(ta)
+				a.clientDataJSON = ta.clientData(WebauthnGet, "AAAAAAAA")
+				return a
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+		"invalid, counter changed": {
+			assertion: func() assertion {
+				a := fromAuthenticator(ta)
+				a.authenticatorData[36]++
+				return a
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+		"invalid, signed for another relying party": {
+			assertion: func() assertion {
+				return fromAuthenticator(NewTestAuthenticator(WebauthnRelyingParty{Id: "evil.com", Origin: "https://evil.com"}))
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+		"invalid, user not present": {
+			assertion: func() assertion {
+				a := assertion{ta.PublicKey(), clientDataJSON, append([]byte{}, authData...), nil}
+				a.authenticatorData[32] &^= authDataUserPresent
+				return a
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+		"invalid, malformed public key": {
+			assertion: func() assertion {
+				a := fromAuthenticator(ta)
+				a.publicKey = []byte{0xa0}
+				return a
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+		"invalid, truncated authenticator data": {
+			assertion: func() assertion {
+				a := fromAuthenticator(ta)
+				a.authenticatorData = a.authenticatorData[:36]
+				return a
+			},
+			expectedErr: resp.ErrInvalidPasskey,
+		},
+	}
+	for tname, tcase := range tests {
+		t.Run(tname, func(t *testing.T) {
+			a := tcase.assertion()
+			signCount, userVerified, err := TestRelyingParty().VerifyAssertion(a.publicKey, a.clientDataJSON, a.authenticatorData, a.signature)
+			assert.Equal(t, tcase.expectedErr, err)
+			assert.Equal(t, tcase.userVerified, userVerified)
+			if tcase.expectedErr == nil && tcase.expectedSignCount != 0 {
+				assert.Equal(t, tcase.expectedSignCount, signCount)
+			}
+		})
+	}
+}
+
+func decodeTestField(s string) []byte {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}